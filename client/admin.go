@@ -0,0 +1,68 @@
+package client
+
+import "net/url"
+
+// Project describes a project in the admin API.
+type Project struct {
+	Name     string `json:"name"`
+	DataFile string `json:"data_file"`
+	Backend  string `json:"backend"`
+	Archived bool   `json:"archived"`
+	Users    int    `json:"users"` // number of named users
+}
+
+// ProjectToken is a project with its new project token. The server keeps
+// only the token's hash, so this is the one time it can be read.
+type ProjectToken struct {
+	Project
+	Token string `json:"token"`
+}
+
+// CreateProjectRequest describes a new project. Only Name is required; by
+// default the server keeps the data in a JSON file next to the projects
+// file.
+type CreateProjectRequest struct {
+	Name     string `json:"name"`
+	DataFile string `json:"data_file,omitempty"`
+	Backend  string `json:"backend,omitempty"`
+}
+
+// ListProjects returns every project, archived ones included.
+func (c *Client) ListProjects() ([]Project, error) {
+	var projects []Project
+	err := c.call("GET", "/api/v1/admin/projects", nil, nil, &projects)
+	return projects, err
+}
+
+// CreateProject adds a project and starts serving it.
+func (c *Client) CreateProject(req CreateProjectRequest) (ProjectToken, error) {
+	var p ProjectToken
+	err := c.call("POST", "/api/v1/admin/projects", req, nil, &p)
+	return p, err
+}
+
+// RotateProjectToken gives a project a new project token. The old one stops
+// working at once.
+func (c *Client) RotateProjectToken(name string) (ProjectToken, error) {
+	var p ProjectToken
+	err := c.call("POST", projectPath(name)+"/rotate-token", nil, nil, &p)
+	return p, err
+}
+
+// ArchiveProject stops serving a project but keeps its data.
+func (c *Client) ArchiveProject(name string) (Project, error) {
+	var p Project
+	err := c.call("POST", projectPath(name)+"/archive", nil, nil, &p)
+	return p, err
+}
+
+// DeleteProject removes an archived project and its data.
+func (c *Client) DeleteProject(name string) (Project, error) {
+	var p Project
+	err := c.call("DELETE", projectPath(name), nil, nil, &p)
+	return p, err
+}
+
+func projectPath(name string) string {
+	return "/api/v1/admin/projects/" + url.PathEscape(name)
+}
@@ -0,0 +1,426 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// CreateRequest describes a new bead. Only Title is required; the server
+// fills in defaults for the rest. ParentID creates the bead as a child of
+// that epic.
+type CreateRequest struct {
+	Title       string         `json:"title"`
+	Description string         `json:"description,omitempty"`
+	Status      model.Status   `json:"status,omitempty"`
+	Priority    model.Priority `json:"priority,omitempty"`
+	Type        model.BeadType `json:"type,omitempty"`
+	Tags        []string       `json:"tags,omitempty"`
+	BlockedBy   []string       `json:"blocked_by,omitempty"`
+	Assignee    string         `json:"assignee,omitempty"`
+	ParentID    string         `json:"parent_id,omitempty"`
+	ExternalKey string         `json:"external_key,omitempty"`
+}
+
+// UpdateRequest describes changes to a bead. Nil fields are left alone.
+// Setting ParentID to "" detaches the bead from its epic.
+type UpdateRequest struct {
+	Title       *string         `json:"title,omitempty"`
+	Description *string         `json:"description,omitempty"`
+	Status      *model.Status   `json:"status,omitempty"`
+	Priority    *model.Priority `json:"priority,omitempty"`
+	Type        *model.BeadType `json:"type,omitempty"`
+	Tags        *[]string       `json:"tags,omitempty"`
+	AddTags     []string        `json:"add_tags,omitempty"`
+	RemoveTags  []string        `json:"remove_tags,omitempty"`
+	BlockedBy   *[]string       `json:"blocked_by,omitempty"`
+	Assignee    *string         `json:"assignee,omitempty"`
+	ParentID    *string         `json:"parent_id,omitempty"`
+}
+
+// UpdateResult is a changed bead. When the change closed or deleted it,
+// Unblocked lists the beads that are now ready as a result.
+type UpdateResult struct {
+	model.Bead
+	Unblocked []model.Bead `json:"unblocked,omitempty"`
+}
+
+// BeadDetail is a bead as returned by Get. For an epic it includes its
+// children and their progress; for a child, the parent's title.
+type BeadDetail struct {
+	model.Bead
+	IsEpic      bool           `json:"is_epic,omitempty"`
+	Progress    *Progress      `json:"progress,omitempty"`
+	Children    []ChildSummary `json:"children,omitempty"`
+	ParentTitle string         `json:"parent_title,omitempty"`
+}
+
+// Progress counts an epic's children by status.
+type Progress struct {
+	Total      int `json:"total"`
+	Open       int `json:"open"`
+	InProgress int `json:"in_progress"`
+	Closed     int `json:"closed"`
+	Deleted    int `json:"deleted"`
+	NotReady   int `json:"not_ready"`
+}
+
+// ChildSummary is one child of an epic in a BeadDetail.
+type ChildSummary struct {
+	ID       string         `json:"id"`
+	Title    string         `json:"title"`
+	Status   model.Status   `json:"status"`
+	Priority model.Priority `json:"priority"`
+	Type     model.BeadType `json:"type"`
+	Assignee string         `json:"assignee"`
+}
+
+// BeadSummary contains the key fields returned by List and Search.
+type BeadSummary struct {
+	ID          string         `json:"id"`
+	Title       string         `json:"title"`
+	Status      model.Status   `json:"status"`
+	Priority    model.Priority `json:"priority"`
+	Type        model.BeadType `json:"type"`
+	Assignee    string         `json:"assignee"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	IsEpic      bool           `json:"is_epic,omitempty"`
+	Children    []BeadSummary  `json:"children,omitempty"`
+	ParentID    string         `json:"parent_id,omitempty"`
+	ParentTitle string         `json:"parent_title,omitempty"`
+	Blocked     bool           `json:"blocked,omitempty"`
+	BlockDepth  int            `json:"block_depth,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	Project     string         `json:"project,omitempty"` // set only by ListAllProjects
+	Score       float64        `json:"score,omitempty"`   // set only by Search
+	Matches     []SearchMatch  `json:"matches,omitempty"` // set only by Search
+}
+
+// SearchMatch is one place a search matched a bead: the field, the comment's
+// index for a comment match, and a snippet with matching words marked
+// **like this**.
+type SearchMatch struct {
+	Field   string `json:"field"`
+	Comment *int   `json:"comment,omitempty"`
+	Snippet string `json:"snippet"`
+}
+
+// ListFilters narrows List. Zero fields do not filter.
+type ListFilters struct {
+	Statuses []model.Status // any of these; default open, in_progress and not_ready
+	Priority model.Priority
+	Type     model.BeadType
+	Tags     []string // any of these
+	Assignee string
+	All      bool // every status, including closed and deleted
+	Ready    bool // open beads with no active blockers
+	Page     int  // 1-indexed; default 1
+	PerPage  int  // default 100
+}
+
+// SearchOptions narrows and orders Search. Zero fields use the defaults.
+type SearchOptions struct {
+	In      []string // "title", "description" and/or "comments"; default all
+	Sort    string   // "priority" (default) or "relevance"
+	Page    int      // 1-indexed; default 1
+	PerPage int      // default 100
+}
+
+// ListResult is one page of beads.
+type ListResult struct {
+	Beads      []BeadSummary `json:"beads"`
+	Page       int           `json:"page"`
+	PerPage    int           `json:"per_page"`
+	Total      int           `json:"total"`
+	TotalPages int           `json:"total_pages"`
+}
+
+// ClaimFilters narrows which ready bead ClaimNext and WaitReady pick.
+// Zero fields do not filter.
+type ClaimFilters struct {
+	Tags     []string       `json:"tags,omitempty"`
+	Assignee string         `json:"assignee,omitempty"`
+	Priority model.Priority `json:"priority,omitempty"`
+	Type     model.BeadType `json:"type,omitempty"`
+}
+
+// Deps is a bead's dependency information.
+type Deps struct {
+	ActiveBlockers   []model.Bead `json:"active_blockers"`
+	ResolvedBlockers []model.Bead `json:"resolved_blockers"`
+	Blocks           []model.Bead `json:"blocks"`
+}
+
+// History is a bead's change history, oldest first.
+type History struct {
+	ID      string               `json:"id"`
+	Entries []model.HistoryEntry `json:"history"`
+}
+
+// CleanResult reports how many beads Clean removed.
+type CleanResult struct {
+	Removed int `json:"removed"`
+}
+
+// TransferEdge is a dependency between a transferred bead and one left
+// behind: bead ID is blocked by BlockedBy.
+type TransferEdge struct {
+	ID        string `json:"id"`
+	BlockedBy string `json:"blocked_by"`
+}
+
+// TransferResult reports a transfer: the bead in its new project, the IDs
+// moved with it, and the dependencies that now cross projects, or that
+// were removed if the transfer unlinked them.
+type TransferResult struct {
+	Bead         model.Bead     `json:"bead"`
+	Project      string         `json:"project"`
+	Moved        []string       `json:"moved"`
+	CrossProject []TransferEdge `json:"cross_project,omitempty"`
+	Unlinked     []TransferEdge `json:"unlinked,omitempty"`
+}
+
+func beadPath(id string, rest ...string) string {
+	p := "/api/v1/beads/" + url.PathEscape(id)
+	for _, r := range rest {
+		p += "/" + url.PathEscape(r)
+	}
+	return p
+}
+
+// ifRevision returns an If-Match header for rev.
+func ifRevision(rev int64) http.Header {
+	h := http.Header{}
+	h.Set("If-Match", fmt.Sprintf(`"%d"`, rev))
+	return h
+}
+
+// Create creates a bead.
+func (c *Client) Create(req CreateRequest) (model.Bead, error) {
+	var b model.Bead
+	err := c.call("POST", "/api/v1/beads", req, nil, &b)
+	return b, err
+}
+
+// Get returns a bead by ID.
+func (c *Client) Get(id string) (BeadDetail, error) {
+	var d BeadDetail
+	err := c.call("GET", beadPath(id), nil, nil, &d)
+	return d, err
+}
+
+// Update applies req to a bead.
+func (c *Client) Update(id string, req UpdateRequest) (UpdateResult, error) {
+	return c.update(id, req, nil)
+}
+
+// UpdateIfRevision is Update, but fails with ErrPreconditionFailed unless
+// the bead is still at revision rev.
+func (c *Client) UpdateIfRevision(id string, rev int64, req UpdateRequest) (UpdateResult, error) {
+	return c.update(id, req, ifRevision(rev))
+}
+
+func (c *Client) update(id string, req UpdateRequest, header http.Header) (UpdateResult, error) {
+	var r UpdateResult
+	err := c.call("PATCH", beadPath(id), req, header, &r)
+	return r, err
+}
+
+// SetStatus changes a bead's status.
+func (c *Client) SetStatus(id string, status model.Status) (UpdateResult, error) {
+	return c.Update(id, UpdateRequest{Status: &status})
+}
+
+// Close closes a bead.
+func (c *Client) Close(id string) (UpdateResult, error) {
+	return c.SetStatus(id, model.StatusClosed)
+}
+
+// Delete marks a bead deleted. It is removed for good by a later Clean.
+func (c *Client) Delete(id string) (UpdateResult, error) {
+	var r UpdateResult
+	err := c.call("DELETE", beadPath(id), nil, nil, &r)
+	return r, err
+}
+
+// List returns one page of beads matching f.
+func (c *Client) List(f ListFilters) (ListResult, error) {
+	var r ListResult
+	err := c.call("GET", "/api/v1/beads"+listQuery(f), nil, nil, &r)
+	return r, err
+}
+
+// ListAllProjects is List across projects: every project on the server if
+// the client has the admin token, or else its own project and those of its
+// ProjectTokens. Each bead's Project says where it is.
+func (c *Client) ListAllProjects(f ListFilters) (ListResult, error) {
+	var r ListResult
+	err := c.call("GET", "/api/v1/all/beads"+listQuery(f), nil, nil, &r)
+	return r, err
+}
+
+// listQuery encodes f as a query string, with its leading '?'.
+func listQuery(f ListFilters) string {
+	params := url.Values{}
+	if len(f.Statuses) > 0 {
+		s := make([]string, len(f.Statuses))
+		for i, st := range f.Statuses {
+			s[i] = string(st)
+		}
+		params.Set("status", strings.Join(s, ","))
+	}
+	if f.Priority != "" {
+		params.Set("priority", string(f.Priority))
+	}
+	if f.Type != "" {
+		params.Set("type", string(f.Type))
+	}
+	if len(f.Tags) > 0 {
+		params.Set("tag", strings.Join(f.Tags, ","))
+	}
+	if f.Assignee != "" {
+		params.Set("assignee", f.Assignee)
+	}
+	if f.All {
+		params.Set("all", "true")
+	}
+	if f.Ready {
+		params.Set("ready", "true")
+	}
+	if f.Page > 0 {
+		params.Set("page", strconv.Itoa(f.Page))
+	}
+	if f.PerPage > 0 {
+		params.Set("per_page", strconv.Itoa(f.PerPage))
+	}
+
+	if len(params) == 0 {
+		return ""
+	}
+	return "?" + params.Encode()
+}
+
+// Search returns the first page of beads matching query, by priority. The
+// query's words must all appear in a bead's title, description or comments;
+// a word ending in *, and the last word, match as a prefix, and words in
+// double quotes must appear together as a phrase.
+func (c *Client) Search(query string) (ListResult, error) {
+	return c.SearchWith(query, SearchOptions{})
+}
+
+// SearchWith is Search with options.
+func (c *Client) SearchWith(query string, o SearchOptions) (ListResult, error) {
+	params := url.Values{}
+	params.Set("q", query)
+	if len(o.In) > 0 {
+		params.Set("in", strings.Join(o.In, ","))
+	}
+	if o.Sort != "" {
+		params.Set("sort", o.Sort)
+	}
+	if o.Page > 0 {
+		params.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.PerPage > 0 {
+		params.Set("per_page", strconv.Itoa(o.PerPage))
+	}
+	var r ListResult
+	err := c.call("GET", "/api/v1/search?"+params.Encode(), nil, nil, &r)
+	return r, err
+}
+
+// Claim claims a bead for c.Actor, setting it in progress. Claiming a bead
+// already held by someone else fails with ErrConflict.
+func (c *Client) Claim(id string) (model.Bead, error) {
+	var b model.Bead
+	err := c.call("POST", beadPath(id, "claim"), map[string]string{"user": c.Actor}, nil, &b)
+	return b, err
+}
+
+// ClaimNext claims the highest-priority ready bead matching f for c.Actor.
+// It fails with ErrNotFound when no bead is ready.
+func (c *Client) ClaimNext(f ClaimFilters) (model.Bead, error) {
+	body := struct {
+		User string `json:"user"`
+		ClaimFilters
+	}{c.Actor, f}
+	var b model.Bead
+	err := c.call("POST", "/api/v1/claim-next", body, nil, &b)
+	return b, err
+}
+
+// Heartbeat renews c.Actor's lease on a claimed bead.
+func (c *Client) Heartbeat(id string) (model.Bead, error) {
+	var b model.Bead
+	err := c.call("POST", beadPath(id, "heartbeat"), map[string]string{"user": c.Actor}, nil, &b)
+	return b, err
+}
+
+// Comment adds a comment by c.Actor to a bead.
+func (c *Client) Comment(id, text string) (model.Bead, error) {
+	var b model.Bead
+	err := c.call("POST", beadPath(id, "comments"), map[string]string{"author": c.Actor, "text": text}, nil, &b)
+	return b, err
+}
+
+// Link records that a bead is blocked by blockedBy.
+func (c *Client) Link(id, blockedBy string) (model.Bead, error) {
+	return c.link(id, blockedBy, nil)
+}
+
+// LinkIfRevision is Link, but fails with ErrPreconditionFailed unless the
+// bead is still at revision rev.
+func (c *Client) LinkIfRevision(id, blockedBy string, rev int64) (model.Bead, error) {
+	return c.link(id, blockedBy, ifRevision(rev))
+}
+
+func (c *Client) link(id, blockedBy string, header http.Header) (model.Bead, error) {
+	var b model.Bead
+	err := c.call("POST", beadPath(id, "link"), map[string]string{"blocked_by": blockedBy}, header, &b)
+	return b, err
+}
+
+// Unlink removes blockedBy from a bead's blockers.
+func (c *Client) Unlink(id, blockedBy string) (model.Bead, error) {
+	var b model.Bead
+	err := c.call("DELETE", beadPath(id, "link", blockedBy), nil, nil, &b)
+	return b, err
+}
+
+// Transfer moves a bead, or an epic with its children, to the project to.
+// c.ProjectTokens must hold a token for that project. If unlink is set,
+// dependencies on beads left behind are removed rather than kept across
+// projects.
+func (c *Client) Transfer(id, to string, unlink bool) (TransferResult, error) {
+	var r TransferResult
+	body := map[string]any{"to": to, "unlink": unlink}
+	err := c.call("POST", beadPath(id, "transfer"), body, nil, &r)
+	return r, err
+}
+
+// Deps returns a bead's blockers and the beads it blocks.
+func (c *Client) Deps(id string) (Deps, error) {
+	var d Deps
+	err := c.call("GET", beadPath(id, "deps"), nil, nil, &d)
+	return d, err
+}
+
+// History returns a bead's change history.
+func (c *Client) History(id string) (History, error) {
+	var h History
+	err := c.call("GET", beadPath(id, "history"), nil, nil, &h)
+	return h, err
+}
+
+// Clean permanently removes closed and deleted beads last updated more than
+// days ago. Zero removes them all.
+func (c *Client) Clean(days float64) (CleanResult, error) {
+	var r CleanResult
+	err := c.call("POST", "/api/v1/clean", map[string]float64{"days": days}, nil, &r)
+	return r, err
+}
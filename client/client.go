@@ -0,0 +1,167 @@
+// Package client is a typed Go client for the beads server HTTP API.
+//
+// A Client is bound to one project by its token, or, created with the
+// server's admin token, manages projects through the admin API (see
+// ListProjects and CreateProject). Mutations are recorded
+// under Client.Actor, and Claim and Comment act as that user. Failed
+// requests return an *Error, which matches ErrNotFound, ErrConflict and
+// ErrPreconditionFailed with errors.Is.
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// actorHeader carries Client.Actor; the server records it as the author of
+// each change.
+const actorHeader = "X-BS-User"
+
+// projectTokensHeader carries Client.ProjectTokens.
+const projectTokensHeader = "X-BS-Project-Tokens"
+
+// Client is an HTTP client for the beads API.
+type Client struct {
+	BaseURL    string
+	Token      string
+	Actor      string
+	HTTPClient *http.Client
+
+	// ProjectTokens are tokens for other projects on the same server. With
+	// them, beads may depend on those projects' beads, and Deps shows the
+	// other side of such dependencies in full.
+	ProjectTokens []string
+
+	// RetryDelays is how long Watch and StreamSSE wait before each
+	// reconnect attempt after the event stream drops. Once they are used
+	// up the stream gives up with an error. Nil means a default schedule
+	// of about 18 seconds in total.
+	RetryDelays []time.Duration
+}
+
+// New returns a Client for the server at baseURL, authenticating with token.
+func New(baseURL, token string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		Token:      token,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+// Errors that an *Error from a request matches, by status code.
+var (
+	ErrNotFound           = errors.New("not found")           // 404
+	ErrConflict           = errors.New("conflict")            // 409
+	ErrPreconditionFailed = errors.New("precondition failed") // 412
+)
+
+// Error is returned when the server responds with a non-2xx status.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is reports whether target is the sentinel error for e's status code.
+func (e *Error) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return target == ErrNotFound
+	case http.StatusConflict:
+		return target == ErrConflict
+	case http.StatusPreconditionFailed:
+		return target == ErrPreconditionFailed
+	}
+	return false
+}
+
+// Do sends an HTTP request and returns the response body as parsed JSON.
+// A json.RawMessage body is sent as is. Returns an *Error if the response
+// status is not in the 2xx range.
+func (c *Client) Do(method, path string, body any) (json.RawMessage, error) {
+	return c.DoWithHeader(method, path, body, nil)
+}
+
+// DoWithHeader is Do with extra request headers, such as If-Match.
+func (c *Client) DoWithHeader(method, path string, body any, header http.Header) (json.RawMessage, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling request: %w", err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	url := c.BaseURL + path
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	if c.Actor != "" {
+		req.Header.Set(actorHeader, c.Actor)
+	}
+	if len(c.ProjectTokens) > 0 {
+		req.Header.Set(projectTokensHeader, strings.Join(c.ProjectTokens, ","))
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		// Try to extract error message from JSON response
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(respBody, &errResp) == nil && errResp.Error != "" {
+			return nil, &Error{StatusCode: resp.StatusCode, Message: errResp.Error}
+		}
+		return nil, &Error{StatusCode: resp.StatusCode, Message: fmt.Sprintf("HTTP %d: %s", resp.StatusCode, string(respBody))}
+	}
+
+	return json.RawMessage(respBody), nil
+}
+
+// call sends a request and decodes the JSON response into out.
+func (c *Client) call(method, path string, body any, header http.Header, out any) error {
+	data, err := c.DoWithHeader(method, path, body, header)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("parsing response: %w", err)
+	}
+	return nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
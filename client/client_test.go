@@ -0,0 +1,226 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vector76/beads_server/internal/server"
+	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
+)
+
+const testToken = "test-secret"
+
+// startServer returns a client for a fresh test server, acting as alice.
+func startServer(t *testing.T) *Client {
+	t.Helper()
+	dir := t.TempDir()
+	s, err := store.Load(filepath.Join(dir, "beads.json"))
+	if err != nil {
+		t.Fatalf("store.Load: %v", err)
+	}
+	srv, err := server.New(server.Config{LogOutput: io.Discard}, server.NewSingleStoreProvider(testToken, s))
+	if err != nil {
+		t.Fatalf("server.New: %v", err)
+	}
+	ts := httptest.NewServer(srv.Router)
+	t.Cleanup(ts.Close)
+
+	c := New(ts.URL, testToken)
+	c.Actor = "alice"
+	return c
+}
+
+func TestClient_BeadLifecycle(t *testing.T) {
+	c := startServer(t)
+
+	epic, err := c.Create(CreateRequest{Title: "Epic"})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	blocker, _ := c.Create(CreateRequest{Title: "Blocker", ParentID: epic.ID, Priority: model.PriorityHigh})
+	work, _ := c.Create(CreateRequest{Title: "Work", ParentID: epic.ID, Tags: []string{"api"}})
+
+	if _, err := c.Link(work.ID, blocker.ID); err != nil {
+		t.Fatalf("Link: %v", err)
+	}
+	deps, err := c.Deps(work.ID)
+	if err != nil || len(deps.ActiveBlockers) != 1 || deps.ActiveBlockers[0].ID != blocker.ID {
+		t.Fatalf("Deps = %+v, %v", deps, err)
+	}
+
+	ready, err := c.List(ListFilters{Ready: true, Tags: []string{"api"}})
+	if err != nil || ready.Total != 0 {
+		t.Fatalf("ready api beads = %+v, %v; want none while blocked", ready, err)
+	}
+
+	claimed, err := c.Claim(blocker.ID)
+	if err != nil || claimed.Assignee != "alice" || claimed.Status != model.StatusInProgress {
+		t.Fatalf("Claim = %+v, %v", claimed, err)
+	}
+	if _, err := c.Comment(blocker.ID, "done"); err != nil {
+		t.Fatalf("Comment: %v", err)
+	}
+	closed, err := c.Close(blocker.ID)
+	if err != nil || len(closed.Unblocked) != 1 || closed.Unblocked[0].ID != work.ID {
+		t.Fatalf("Close = %+v, %v; want work unblocked", closed, err)
+	}
+
+	detail, err := c.Get(epic.ID)
+	if err != nil || !detail.IsEpic || detail.Progress.Closed != 1 || len(detail.Children) != 2 {
+		t.Fatalf("Get epic = %+v, %v", detail, err)
+	}
+	detail, _ = c.Get(blocker.ID)
+	if detail.ParentTitle != "Epic" || len(detail.Comments) != 1 || detail.Comments[0].Author != "alice" {
+		t.Errorf("Get blocker = %+v", detail)
+	}
+
+	next, err := c.ClaimNext(ClaimFilters{Tags: []string{"api"}})
+	if err != nil || next.ID != work.ID {
+		t.Fatalf("ClaimNext = %+v, %v", next, err)
+	}
+	h, err := c.History(work.ID)
+	if err != nil || h.ID != work.ID || len(h.Entries) == 0 || h.Entries[len(h.Entries)-1].Actor != "alice" {
+		t.Errorf("History = %+v, %v", h, err)
+	}
+
+	mine, _ := c.List(ListFilters{Assignee: "alice", Statuses: []model.Status{model.StatusInProgress}})
+	if mine.Total != 1 || mine.Beads[0].ID != work.ID {
+		t.Errorf("in-progress beads for alice = %+v", mine)
+	}
+}
+
+func TestClient_ExportImport(t *testing.T) {
+	src := startServer(t)
+	epic, _ := src.Create(CreateRequest{Title: "Epic"})
+	child, _ := src.Create(CreateRequest{Title: "Child", ParentID: epic.ID})
+
+	doc, err := src.Export()
+	if err != nil || doc.Version != 1 || len(doc.Beads) != 2 || len(doc.History[child.ID]) == 0 {
+		t.Fatalf("Export = %+v, %v", doc, err)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatalf("marshaling export: %v", err)
+	}
+
+	dst := startServer(t)
+	res, err := dst.Import(data, ImportOptions{Mode: "replace"})
+	if err != nil || res.Mode != "replace" || res.Imported != 2 {
+		t.Fatalf("Import = %+v, %v", res, err)
+	}
+	if got, err := dst.Get(child.ID); err != nil || got.ParentID != epic.ID {
+		t.Errorf("imported child = %+v, %v", got, err)
+	}
+
+	plan := map[string]any{"key": "auth", "title": "Auth", "children": []map[string]any{{"key": "api", "title": "API"}}}
+	dry, err := dst.ImportPlan(plan, true)
+	if err != nil || !dry.DryRun || len(dry.Created) != 2 {
+		t.Fatalf("ImportPlan dry run = %+v, %v", dry, err)
+	}
+	applied, err := dst.ImportPlan(plan, false)
+	if err != nil || applied.DryRun || applied.Keys["api"] == "" {
+		t.Fatalf("ImportPlan = %+v, %v", applied, err)
+	}
+}
+
+func TestClient_TypedErrors(t *testing.T) {
+	c := startServer(t)
+	b, _ := c.Create(CreateRequest{Title: "Contested"})
+
+	if _, err := c.Get("bd-none"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get missing bead: err = %v, want ErrNotFound", err)
+	}
+
+	if _, err := c.Claim(b.ID); err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	bob := New(c.BaseURL, testToken)
+	bob.Actor = "bob"
+	_, err := bob.Claim(b.ID)
+	var apiErr *Error
+	if !errors.Is(err, ErrConflict) || !errors.As(err, &apiErr) || apiErr.Message == "" {
+		t.Errorf("Claim held bead: err = %v, want ErrConflict", err)
+	}
+
+	title := "Stale"
+	if _, err := c.UpdateIfRevision(b.ID, 1, UpdateRequest{Title: &title}); !errors.Is(err, ErrPreconditionFailed) {
+		t.Errorf("UpdateIfRevision at old revision: err = %v, want ErrPreconditionFailed", err)
+	}
+	if _, err := c.ClaimNext(ClaimFilters{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ClaimNext with nothing ready: err = %v, want ErrNotFound", err)
+	}
+}
+
+func TestClient_Watch(t *testing.T) {
+	c := startServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, errs := c.Watch(ctx)
+	// Give the stream time to connect, so the creation is not missed.
+	time.Sleep(100 * time.Millisecond)
+	b, _ := c.Create(CreateRequest{Title: "Watched"})
+	c.Claim(b.ID)
+
+	var got []Event
+	for len(got) < 2 {
+		select {
+		case ev := <-events:
+			got = append(got, ev)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out; events so far: %+v", got)
+		}
+	}
+	if got[0].Type != EventBeadCreated || got[0].BeadID != b.ID || got[0].Actor != "alice" {
+		t.Errorf("first event = %+v", got[0])
+	}
+	if got[1].Type != EventBeadClaimed || len(got[1].Changes) == 0 {
+		t.Errorf("second event = %+v", got[1])
+	}
+
+	cancel()
+	if err := <-errs; err != nil {
+		t.Errorf("Watch error after cancel = %v, want nil", err)
+	}
+}
+
+func TestClient_WaitClaim(t *testing.T) {
+	c := startServer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	done := make(chan model.Bead, 1)
+	go func() {
+		b, err := c.WaitClaim(ctx, ClaimFilters{Type: model.TypeBug})
+		if err != nil {
+			t.Errorf("WaitClaim: %v", err)
+		}
+		done <- b
+	}()
+
+	time.Sleep(100 * time.Millisecond)
+	c.Create(CreateRequest{Title: "Not a bug"})
+	bug, _ := c.Create(CreateRequest{Title: "Bug", Type: model.TypeBug})
+
+	select {
+	case b := <-done:
+		if b.ID != bug.ID || b.Assignee != "alice" {
+			t.Errorf("WaitClaim = %+v, want the bug claimed by alice", b)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WaitClaim did not return")
+	}
+
+	short, cancelShort := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancelShort()
+	if err := c.WaitReady(short, ClaimFilters{Type: model.TypeChore}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitReady with nothing ready: err = %v, want DeadlineExceeded", err)
+	}
+}
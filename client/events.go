@@ -0,0 +1,198 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// Event types sent by Watch, one per successful mutation.
+const (
+	EventBeadCreated   = "bead.created"
+	EventBeadUpdated   = "bead.updated"
+	EventBeadClosed    = "bead.closed"
+	EventBeadDeleted   = "bead.deleted"
+	EventBeadClaimed   = "bead.claimed"
+	EventBeadReleased  = "bead.released"
+	EventBeadMoved     = "bead.moved"
+	EventCommentAdded  = "comment.added"
+	EventDepLinked     = "dep.linked"
+	EventDepUnlinked   = "dep.unlinked"
+	EventBeadsCleaned  = "beads.cleaned"
+	EventBeadsImported = "beads.imported"
+
+	// EventReset means events were missed and cannot be replayed, so the
+	// caller must re-fetch whatever state it keeps.
+	EventReset = "reset"
+)
+
+// Event describes one mutation: what happened, to which bead, who did it,
+// and which fields changed. Project-wide events such as beads.cleaned have
+// no BeadID.
+type Event struct {
+	ID      uint64              `json:"id"`
+	Type    string              `json:"type"`
+	Project string              `json:"project,omitempty"`
+	BeadID  string              `json:"bead_id,omitempty"`
+	Actor   string              `json:"actor,omitempty"`
+	Changes []model.FieldChange `json:"changes,omitempty"`
+	Comment *model.Comment      `json:"comment,omitempty"`
+	At      time.Time           `json:"at"`
+}
+
+// sseRetryDelays is the default Client.RetryDelays.
+var sseRetryDelays = []time.Duration{
+	500 * time.Millisecond, 1 * time.Second, 2 * time.Second, 5 * time.Second, 10 * time.Second,
+}
+
+// Watch streams the events of the client's project from /api/v1/events
+// until ctx is cancelled. It returns a buffered event channel and an error
+// channel that receives nil on cancellation or a non-nil error if the
+// stream fails for good. Both are closed when Watch stops.
+//
+// If the stream drops, Watch reconnects and the server replays what was
+// missed, or sends an EventReset when it cannot.
+func (c *Client) Watch(ctx context.Context) (<-chan Event, <-chan error) {
+	events := make(chan Event, 16)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(events)
+		errs <- c.stream(ctx, func(name, data string) {
+			if name == "" {
+				return
+			}
+			var ev Event
+			if json.Unmarshal([]byte(data), &ev) != nil {
+				return
+			}
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	return events, errs
+}
+
+// StreamSSE is like Watch, but only signals that something changed: its
+// channel receives a value once per batch of events rather than the events
+// themselves.
+func (c *Client) StreamSSE(ctx context.Context) (<-chan struct{}, <-chan error) {
+	signals := make(chan struct{}, 16)
+	errs := make(chan error, 1)
+
+	go func() {
+		defer close(errs)
+		defer close(signals)
+		errs <- c.stream(ctx, func(name, _ string) {
+			if name != "" {
+				return
+			}
+			select {
+			case signals <- struct{}{}:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	return signals, errs
+}
+
+// stream reads the event stream, calling handle with the name and data of
+// each message, until ctx is cancelled (returning nil) or the stream fails.
+// Named messages are individual events; each batch of them is followed by
+// an unnamed "update" message.
+//
+// If the stream drops or the server errors, stream reconnects, sending the
+// last event ID it saw as Last-Event-ID so the server replays what was
+// missed (or sends a reset). Client errors such as 401 are not retried.
+func (c *Client) stream(ctx context.Context, handle func(name, data string)) error {
+	delays := c.RetryDelays
+	if delays == nil {
+		delays = sseRetryDelays
+	}
+	lastID := ""
+	attempt := 0
+	for {
+		connected, err := c.streamOnce(ctx, &lastID, handle)
+		if ctx.Err() != nil {
+			return nil
+		}
+		var httpErr *Error
+		if errors.As(err, &httpErr) && httpErr.StatusCode < 500 {
+			return err
+		}
+		if connected {
+			attempt = 0
+		}
+		if attempt >= len(delays) {
+			return err
+		}
+		select {
+		case <-time.After(delays[attempt]):
+		case <-ctx.Done():
+			return nil
+		}
+		attempt++
+	}
+}
+
+// streamOnce makes one connection to the event stream and reads it until it
+// ends, updating lastID as events arrive. connected reports whether the
+// server accepted the connection; the returned error says why it ended.
+func (c *Client) streamOnce(ctx context.Context, lastID *string, handle func(name, data string)) (connected bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/api/v1/events", nil)
+	if err != nil {
+		return false, fmt.Errorf("creating SSE request: %w", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	if *lastID != "" {
+		req.Header.Set("Last-Event-ID", *lastID)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return false, fmt.Errorf("SSE connect: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return false, &Error{StatusCode: resp.StatusCode, Message: fmt.Sprintf("SSE HTTP %d", resp.StatusCode)}
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	name := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			name = ""
+		case strings.HasPrefix(line, "id:"):
+			*lastID = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
+		case strings.HasPrefix(line, "event:"):
+			name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			handle(name, strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		}
+	}
+
+	scanErr := scanner.Err()
+	if scanErr == nil {
+		scanErr = io.EOF
+	}
+	return true, fmt.Errorf("SSE stream ended: %w", scanErr)
+}
@@ -1,10 +1,11 @@
-package cli
+package client
 
 import (
 	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
 	"time"
 )
@@ -16,10 +17,30 @@ func newTestClient(serverURL string) *Client {
 	}
 }
 
+// withSSERetryDelays replaces the reconnect schedule for the duration of a test.
+func withSSERetryDelays(t *testing.T, delays ...time.Duration) {
+	t.Helper()
+	saved := sseRetryDelays
+	sseRetryDelays = delays
+	t.Cleanup(func() { sseRetryDelays = saved })
+}
+
 // TestStreamSSE_ServerSendsEvents verifies that 3 SSE events produce 3 signals,
-// then the closed body causes a non-nil error.
+// then the closed body, with the server failing every reconnect, causes a
+// non-nil error.
 func TestStreamSSE_ServerSendsEvents(t *testing.T) {
+	withSSERetryDelays(t, time.Millisecond, time.Millisecond)
+	var mu sync.Mutex
+	connections := 0
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		connections++
+		first := connections == 1
+		mu.Unlock()
+		if !first {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
 		w.Header().Set("Content-Type", "text/event-stream")
 		w.WriteHeader(http.StatusOK)
 		flusher, ok := w.(http.Flusher)
@@ -67,6 +88,39 @@ loop:
 	}
 }
 
+// TestStreamSSE_NamedEventsNotSignalled verifies that typed events do not
+// produce signals of their own; only the unnamed update that ends each batch does.
+func TestStreamSSE_NamedEventsNotSignalled(t *testing.T) {
+	withSSERetryDelays(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "event: bead.created\ndata: {\"type\":\"bead.created\"}\n\n")
+		fmt.Fprintf(w, "event: bead.claimed\ndata: {\"type\":\"bead.claimed\"}\n\n")
+		fmt.Fprintf(w, "data: update\n\n")
+	}))
+	defer srv.Close()
+
+	signals, _ := newTestClient(srv.URL).StreamSSE(context.Background())
+
+	count := 0
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-signals:
+			if !ok {
+				if count != 1 {
+					t.Fatalf("expected 1 signal, got %d", count)
+				}
+				return
+			}
+			count++
+		case <-timeout:
+			t.Fatal("timed out waiting for signals")
+		}
+	}
+}
+
 // TestStreamSSE_ContextCancel verifies that cancelling the context causes a nil error.
 func TestStreamSSE_ContextCancel(t *testing.T) {
 	// Server blocks indefinitely until the client disconnects.
@@ -153,3 +207,42 @@ checkErr:
 		t.Fatal("timed out waiting for error")
 	}
 }
+
+// TestStreamSSE_ReconnectsWithLastEventID verifies that a dropped stream is
+// reopened with the ID of the last event seen, and keeps signalling.
+func TestStreamSSE_ReconnectsWithLastEventID(t *testing.T) {
+	withSSERetryDelays(t, time.Millisecond)
+	lastIDs := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastIDs <- r.Header.Get("Last-Event-ID")
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		if r.Header.Get("Last-Event-ID") == "" {
+			fmt.Fprintf(w, "id: 41\nevent: bead.created\ndata: {}\n\n")
+			fmt.Fprintf(w, "id: 42\nevent: bead.updated\ndata: {}\n\ndata: update\n\n")
+			return
+		}
+		fmt.Fprintf(w, "id: 43\nevent: bead.closed\ndata: {}\n\ndata: update\n\n")
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals, _ := newTestClient(srv.URL).StreamSSE(ctx)
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-signals:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for signal %d", i+1)
+		}
+	}
+	if first := <-lastIDs; first != "" {
+		t.Errorf("first connection sent Last-Event-ID %q, want none", first)
+	}
+	if second := <-lastIDs; second != "42" {
+		t.Errorf("reconnect sent Last-Event-ID %q, want 42", second)
+	}
+}
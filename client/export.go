@@ -0,0 +1,82 @@
+package client
+
+import (
+	"encoding/json"
+	"net/url"
+	"time"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// ExportDocument is a project export: every bead, including closed and
+// deleted ones, with its history.
+type ExportDocument struct {
+	Version    int       `json:"version"`
+	Project    string    `json:"project,omitempty"`
+	ExportedAt time.Time `json:"exported_at"`
+	model.Snapshot
+}
+
+// ImportOptions controls Import. Zero fields take the server's defaults:
+// merge mode, failing on IDs already in use.
+type ImportOptions struct {
+	Mode       string // "merge" or "replace"
+	OnConflict string // "fail" or "remap"
+}
+
+// ImportResult reports an import. Remapped maps each imported ID that was
+// already in use to the ID it was given.
+type ImportResult struct {
+	Mode     string            `json:"mode"`
+	Imported int               `json:"imported"`
+	Removed  int               `json:"removed"`
+	Remapped map[string]string `json:"remapped"`
+}
+
+// PlanResult reports what ImportPlan did, or would do for a dry run. Keys
+// maps plan keys to bead IDs; a dry run only knows the IDs of beads that
+// already exist. Created, Updated and Unchanged list plan keys.
+type PlanResult struct {
+	DryRun    bool              `json:"dry_run"`
+	Keys      map[string]string `json:"keys"`
+	Created   []string          `json:"created"`
+	Updated   []string          `json:"updated"`
+	Unchanged []string          `json:"unchanged"`
+}
+
+// Export returns every bead in the project with its history.
+func (c *Client) Export() (ExportDocument, error) {
+	var doc ExportDocument
+	err := c.call("GET", "/api/v1/export", nil, nil, &doc)
+	return doc, err
+}
+
+// Import adds the beads in data, an export document or a JSON backend data
+// file, to the project, or replaces the project's beads with them. data is
+// sent as is, so the server can migrate legacy values in old data files.
+func (c *Client) Import(data json.RawMessage, o ImportOptions) (ImportResult, error) {
+	params := url.Values{}
+	if o.Mode != "" {
+		params.Set("mode", o.Mode)
+	}
+	if o.OnConflict != "" {
+		params.Set("on_conflict", o.OnConflict)
+	}
+	path := "/api/v1/import"
+	if len(params) > 0 {
+		path += "?" + params.Encode()
+	}
+	var r ImportResult
+	err := c.call("POST", path, data, nil, &r)
+	return r, err
+}
+
+// ImportPlan creates or updates an epic and its children from plan, which
+// encodes to the plan JSON the server expects. With dryRun, nothing is
+// changed.
+func (c *Client) ImportPlan(plan any, dryRun bool) (PlanResult, error) {
+	var r PlanResult
+	body := map[string]any{"plan": plan, "dry_run": dryRun}
+	err := c.call("POST", "/api/v1/import-plan", body, nil, &r)
+	return r, err
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"errors"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// WaitReady blocks until a ready bead matching f exists, re-checking each
+// time the event stream reports a change. It returns ctx.Err() if ctx is
+// done first.
+func (c *Client) WaitReady(ctx context.Context, f ClaimFilters) error {
+	return c.wait(ctx, func() (bool, error) {
+		r, err := c.List(ListFilters{
+			Ready: true, Tags: f.Tags, Assignee: f.Assignee, Priority: f.Priority, Type: f.Type, PerPage: 1,
+		})
+		return r.Total >= 1, err
+	})
+}
+
+// WaitClaim keeps trying to claim a ready bead matching f for c.Actor, as
+// ClaimNext does, until it succeeds, re-trying each time the event stream
+// reports a change. Competing callers never win the same bead. It returns
+// ctx.Err() if ctx is done first.
+func (c *Client) WaitClaim(ctx context.Context, f ClaimFilters) (model.Bead, error) {
+	var claimed model.Bead
+	err := c.wait(ctx, func() (bool, error) {
+		b, err := c.ClaimNext(f)
+		// Losing every race just means waiting for the next change.
+		if errors.Is(err, ErrNotFound) {
+			return false, nil
+		}
+		claimed = b
+		return err == nil, err
+	})
+	return claimed, err
+}
+
+// wait calls check once and then after every change on the event stream,
+// until it reports done or fails, or ctx is done.
+func (c *Client) wait(ctx context.Context, check func() (bool, error)) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	signals, errs := c.StreamSSE(ctx)
+
+	if done, err := check(); err != nil || done {
+		return err
+	}
+
+	for {
+		select {
+		case _, ok := <-signals:
+			if !ok {
+				// The stream has stopped; errs says why.
+				signals = nil
+				continue
+			}
+			if done, err := check(); err != nil || done {
+				return err
+			}
+		case err := <-errs:
+			if err != nil {
+				return err
+			}
+			return ctx.Err()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
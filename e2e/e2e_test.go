@@ -12,9 +12,9 @@ import (
 	"testing"
 
 	"github.com/vector76/beads_server/internal/cli"
-	"github.com/vector76/beads_server/internal/model"
 	"github.com/vector76/beads_server/internal/server"
 	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
 )
 
 const testToken = "e2e-test-secret"
@@ -31,7 +31,6 @@ func startServer(t *testing.T) *httptest.Server {
 	if err != nil {
 		t.Fatalf("server.New: %v", err)
 	}
-	srv.Store = s
 	ts := httptest.NewServer(srv.Router)
 	t.Cleanup(ts.Close)
 	return ts
@@ -0,0 +1,308 @@
+package beadsjsonl
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// FormatName is how the upstream format is named on the command line.
+const FormatName = "beads-jsonl"
+
+// Dependency types used by upstream beads. Only blocks and parent-child have
+// a counterpart here; the others are dropped on import.
+const (
+	DepBlocks      = "blocks"
+	DepParentChild = "parent-child"
+)
+
+// maxLineSize bounds a single issue line, descriptions included.
+const maxLineSize = 16 << 20
+
+// Issue is one line of an upstream beads issues.jsonl file, limited to the
+// fields that map onto model.Bead. Unknown fields are ignored on import.
+type Issue struct {
+	ID                 string       `json:"id"`
+	Title              string       `json:"title"`
+	Description        string       `json:"description,omitempty"`
+	Design             string       `json:"design,omitempty"`
+	AcceptanceCriteria string       `json:"acceptance_criteria,omitempty"`
+	Notes              string       `json:"notes,omitempty"`
+	Status             string       `json:"status"`
+	Priority           *int         `json:"priority"`
+	IssueType          string       `json:"issue_type"`
+	Assignee           string       `json:"assignee,omitempty"`
+	Labels             []string     `json:"labels,omitempty"`
+	CreatedAt          time.Time    `json:"created_at"`
+	UpdatedAt          time.Time    `json:"updated_at"`
+	ClosedAt           *time.Time   `json:"closed_at,omitempty"`
+	Dependencies       []Dependency `json:"dependencies,omitempty"`
+	Comments           []Comment    `json:"comments,omitempty"`
+}
+
+// Dependency is an edge between two upstream issues. For blocks, IssueID is
+// blocked by DependsOnID; for parent-child, IssueID is the child and
+// DependsOnID its parent.
+type Dependency struct {
+	IssueID     string    `json:"issue_id"`
+	DependsOnID string    `json:"depends_on_id"`
+	Type        string    `json:"type"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Comment is an upstream issue comment.
+type Comment struct {
+	IssueID   string    `json:"issue_id"`
+	Author    string    `json:"author"`
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// priorities maps upstream priorities 0-4 to ours, by index.
+var priorities = []model.Priority{
+	model.PriorityCritical,
+	model.PriorityHigh,
+	model.PriorityMedium,
+	model.PriorityLow,
+	model.PriorityNone,
+}
+
+// statusFromUpstream maps an upstream status. "blocked" becomes open, since
+// being blocked follows from dependencies here; "hooked" counts as in
+// progress, "deferred" becomes not_ready and "tombstone" deleted. Any other
+// status is open.
+func statusFromUpstream(s string) model.Status {
+	switch s {
+	case "in_progress", "hooked":
+		return model.StatusInProgress
+	case "closed":
+		return model.StatusClosed
+	case "deferred":
+		return model.StatusNotReady
+	case "tombstone":
+		return model.StatusDeleted
+	}
+	return model.StatusOpen
+}
+
+// statusToUpstream is the inverse of statusFromUpstream.
+func statusToUpstream(s model.Status) string {
+	switch s {
+	case model.StatusInProgress:
+		return "in_progress"
+	case model.StatusClosed:
+		return "closed"
+	case model.StatusNotReady:
+		return "deferred"
+	case model.StatusDeleted:
+		return "tombstone"
+	}
+	return "open"
+}
+
+// Decode reads an upstream issues.jsonl stream and returns the beads it
+// describes. Upstream epics become ordinary beads whose children point at
+// them, since an epic here is any bead with children; deeper levels of the
+// upstream hierarchy are flattened onto the top-level epic. Design, acceptance
+// criteria and notes are appended to the description under headings.
+func Decode(r io.Reader) ([]model.Bead, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	var beads []model.Bead
+	var deps []Dependency
+	index := map[string]int{}
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		var is Issue
+		if err := json.Unmarshal([]byte(text), &is); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if is.ID == "" {
+			return nil, fmt.Errorf("line %d: issue has no id", line)
+		}
+		if _, dup := index[is.ID]; dup {
+			return nil, fmt.Errorf("line %d: duplicate issue %s", line, is.ID)
+		}
+
+		b, err := fromIssue(is)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		index[b.ID] = len(beads)
+		beads = append(beads, b)
+		for _, d := range is.Dependencies {
+			if d.IssueID == "" {
+				d.IssueID = is.ID
+			}
+			deps = append(deps, d)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	// Dependencies are applied once every issue is known, since an edge may
+	// be listed on either end.
+	for _, d := range deps {
+		i, ok := index[d.IssueID]
+		if !ok || d.DependsOnID == "" {
+			continue
+		}
+		b := &beads[i]
+		switch d.Type {
+		case DepBlocks, "":
+			if !slices.Contains(b.BlockedBy, d.DependsOnID) {
+				b.BlockedBy = append(b.BlockedBy, d.DependsOnID)
+			}
+		case DepParentChild:
+			b.ParentID = d.DependsOnID
+		}
+	}
+	if err := flatten(beads, index); err != nil {
+		return nil, err
+	}
+	return beads, nil
+}
+
+// flatten re-parents every bead nested more than one level deep onto its
+// top-level ancestor: upstream allows a hierarchy of any depth, but epics
+// here cannot be nested. A parent-child cycle is an error.
+func flatten(beads []model.Bead, index map[string]int) error {
+	for i := range beads {
+		b := &beads[i]
+		root := b.ParentID
+		seen := map[string]bool{b.ID: true}
+		for root != "" {
+			j, ok := index[root]
+			if !ok || beads[j].ParentID == "" {
+				break
+			}
+			if seen[root] {
+				return fmt.Errorf("issue %s: parent-child cycle through %s", b.ID, root)
+			}
+			seen[root] = true
+			root = beads[j].ParentID
+		}
+		b.ParentID = root
+	}
+	return nil
+}
+
+func fromIssue(is Issue) (model.Bead, error) {
+	b := model.NewBead(is.Title)
+	b.ID = is.ID
+	b.Status = statusFromUpstream(is.Status)
+	if is.Priority != nil {
+		if *is.Priority < 0 || *is.Priority >= len(priorities) {
+			return model.Bead{}, fmt.Errorf("issue %s has priority %d, want 0-4", is.ID, *is.Priority)
+		}
+		b.Priority = priorities[*is.Priority]
+	}
+	if t := model.BeadType(is.IssueType); t.Valid() {
+		b.Type = t
+	}
+	b.Assignee = is.Assignee
+	if is.Labels != nil {
+		b.Tags = is.Labels
+	}
+
+	parts := []string{is.Description}
+	for _, s := range []struct{ heading, text string }{
+		{"Design", is.Design},
+		{"Acceptance Criteria", is.AcceptanceCriteria},
+		{"Notes", is.Notes},
+	} {
+		if s.text != "" {
+			parts = append(parts, "## "+s.heading+"\n\n"+s.text)
+		}
+	}
+	b.Description = strings.TrimSpace(strings.Join(parts, "\n\n"))
+
+	for _, c := range is.Comments {
+		b.Comments = append(b.Comments, model.Comment{Author: c.Author, Text: c.Text, CreatedAt: c.CreatedAt})
+	}
+	if !is.CreatedAt.IsZero() {
+		b.CreatedAt = is.CreatedAt
+		b.UpdatedAt = is.CreatedAt
+	}
+	if !is.UpdatedAt.IsZero() {
+		b.UpdatedAt = is.UpdatedAt
+	}
+	b.Revision = 1
+	return b, nil
+}
+
+// Encode writes beads as an upstream issues.jsonl stream, one issue per
+// line in ID order. Beads with children are written as epics, parent links
+// and blockers as dependencies.
+func Encode(w io.Writer, beads []model.Bead) error {
+	parents := map[string]bool{}
+	for _, b := range beads {
+		if b.ParentID != "" {
+			parents[b.ParentID] = true
+		}
+	}
+
+	sorted := slices.Clone(beads)
+	slices.SortFunc(sorted, func(a, b model.Bead) int { return strings.Compare(a.ID, b.ID) })
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+	for _, b := range sorted {
+		if err := enc.Encode(toIssue(b, parents[b.ID])); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+func toIssue(b model.Bead, isEpic bool) Issue {
+	priority := slices.Index(priorities, b.Priority)
+	if priority < 0 {
+		priority = slices.Index(priorities, model.PriorityMedium)
+	}
+	is := Issue{
+		ID:          b.ID,
+		Title:       b.Title,
+		Description: b.Description,
+		Status:      statusToUpstream(b.Status),
+		Priority:    &priority,
+		IssueType:   string(b.Type),
+		Assignee:    b.Assignee,
+		Labels:      b.Tags,
+		CreatedAt:   b.CreatedAt,
+		UpdatedAt:   b.UpdatedAt,
+	}
+	if isEpic {
+		is.IssueType = "epic"
+	}
+	if b.Status == model.StatusClosed {
+		closed := b.UpdatedAt
+		is.ClosedAt = &closed
+	}
+	if b.ParentID != "" {
+		is.Dependencies = append(is.Dependencies, Dependency{
+			IssueID: b.ID, DependsOnID: b.ParentID, Type: DepParentChild, CreatedAt: b.CreatedAt,
+		})
+	}
+	for _, dep := range b.BlockedBy {
+		is.Dependencies = append(is.Dependencies, Dependency{
+			IssueID: b.ID, DependsOnID: dep, Type: DepBlocks, CreatedAt: b.CreatedAt,
+		})
+	}
+	for _, c := range b.Comments {
+		is.Comments = append(is.Comments, Comment{IssueID: b.ID, Author: c.Author, Text: c.Text, CreatedAt: c.CreatedAt})
+	}
+	return is
+}
@@ -0,0 +1,125 @@
+package beadsjsonl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vector76/beads_server/model"
+)
+
+const upstream = `{"id":"bd-1","title":"Epic","status":"open","priority":1,"issue_type":"epic","created_at":"2025-01-01T00:00:00Z","updated_at":"2025-01-02T00:00:00Z"}
+{"id":"bd-2","title":"Schema","description":"Tables.","design":"Use SQLite.","status":"closed","priority":0,"issue_type":"feature","labels":["db"],"created_at":"2025-01-01T00:00:00Z","updated_at":"2025-01-03T00:00:00Z","closed_at":"2025-01-03T00:00:00Z","dependencies":[{"issue_id":"bd-2","depends_on_id":"bd-1","type":"parent-child","created_at":"2025-01-01T00:00:00Z"}]}
+
+{"id":"bd-3","title":"API","status":"blocked","priority":4,"issue_type":"task","assignee":"alice","created_at":"2025-01-01T00:00:00Z","updated_at":"2025-01-01T00:00:00Z","dependencies":[{"issue_id":"bd-3","depends_on_id":"bd-1","type":"parent-child"},{"issue_id":"bd-3","depends_on_id":"bd-2","type":"blocks"},{"issue_id":"bd-3","depends_on_id":"bd-9","type":"discovered-from"}],"comments":[{"id":1,"issue_id":"bd-3","author":"bob","text":"Waiting on schema","created_at":"2025-01-01T01:00:00Z"}]}
+{"id":"bd-4","title":"Later","status":"deferred","issue_type":"molecule","created_at":"2025-01-01T00:00:00Z","updated_at":"2025-01-01T00:00:00Z"}
+`
+
+func TestDecode(t *testing.T) {
+	beads, err := Decode(strings.NewReader(upstream))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(beads) != 4 {
+		t.Fatalf("got %d beads, want 4", len(beads))
+	}
+	epic, schema, api, later := beads[0], beads[1], beads[2], beads[3]
+
+	if epic.Type != model.TypeTask || epic.Priority != model.PriorityHigh || !epic.UpdatedAt.Equal(time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("epic = %+v", epic)
+	}
+	if schema.Status != model.StatusClosed || schema.Priority != model.PriorityCritical || schema.Type != model.TypeFeature ||
+		schema.ParentID != "bd-1" || len(schema.Tags) != 1 {
+		t.Errorf("schema = %+v", schema)
+	}
+	if schema.Description != "Tables.\n\n## Design\n\nUse SQLite." {
+		t.Errorf("schema description = %q", schema.Description)
+	}
+	if api.Status != model.StatusOpen || api.Priority != model.PriorityNone || api.Assignee != "alice" || api.ParentID != "bd-1" {
+		t.Errorf("api = %+v", api)
+	}
+	if len(api.BlockedBy) != 1 || api.BlockedBy[0] != "bd-2" {
+		t.Errorf("api blocked_by = %v, want only the blocks dependency", api.BlockedBy)
+	}
+	if len(api.Comments) != 1 || api.Comments[0].Author != "bob" {
+		t.Errorf("api comments = %+v", api.Comments)
+	}
+	if later.Status != model.StatusNotReady || later.Priority != model.PriorityMedium || later.Type != model.TypeTask {
+		t.Errorf("later = %+v", later)
+	}
+}
+
+// threeLevels is an upstream hierarchy three levels deep: an epic, a
+// sub-epic and its task, with a second task under the top epic.
+const threeLevels = `{"id":"bd-1","title":"Epic","status":"open","issue_type":"epic"}
+{"id":"bd-1.1","title":"Sub-epic","status":"open","issue_type":"epic","dependencies":[{"issue_id":"bd-1.1","depends_on_id":"bd-1","type":"parent-child"}]}
+{"id":"bd-1.1.1","title":"Task","status":"open","issue_type":"task","dependencies":[{"issue_id":"bd-1.1.1","depends_on_id":"bd-1.1","type":"parent-child"}]}
+{"id":"bd-1.2","title":"Other task","status":"open","issue_type":"task","dependencies":[{"issue_id":"bd-1.2","depends_on_id":"bd-1","type":"parent-child"}]}
+`
+
+func TestDecode_FlattensDeepHierarchy(t *testing.T) {
+	beads, err := Decode(strings.NewReader(threeLevels))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	want := map[string]string{"bd-1": "", "bd-1.1": "bd-1", "bd-1.1.1": "bd-1", "bd-1.2": "bd-1"}
+	for _, b := range beads {
+		if b.ParentID != want[b.ID] {
+			t.Errorf("%s: parent = %q, want %q", b.ID, b.ParentID, want[b.ID])
+		}
+	}
+}
+
+func TestDecode_Errors(t *testing.T) {
+	tests := map[string]string{
+		"bad JSON":       `{"id":`,
+		"missing id":     `{"title":"x"}`,
+		"duplicate id":   "{\"id\":\"bd-1\",\"title\":\"a\"}\n{\"id\":\"bd-1\",\"title\":\"b\"}",
+		"priority range": `{"id":"bd-1","title":"a","priority":7}`,
+		"parent cycle": "{\"id\":\"bd-1\",\"title\":\"a\",\"dependencies\":[{\"depends_on_id\":\"bd-2\",\"type\":\"parent-child\"}]}\n" +
+			"{\"id\":\"bd-2\",\"title\":\"b\",\"dependencies\":[{\"depends_on_id\":\"bd-1\",\"type\":\"parent-child\"}]}",
+	}
+	for name, input := range tests {
+		if _, err := Decode(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestEncode_RoundTrip(t *testing.T) {
+	beads, err := Decode(strings.NewReader(upstream))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, beads); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want 4:\n%s", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], `"issue_type":"epic"`) {
+		t.Errorf("bead with children not written as an epic: %s", lines[0])
+	}
+	if !strings.Contains(lines[1], `"closed_at"`) || !strings.Contains(lines[1], `"priority":0`) {
+		t.Errorf("closed critical bead: %s", lines[1])
+	}
+	if !strings.Contains(lines[3], `"status":"deferred"`) {
+		t.Errorf("not_ready bead: %s", lines[3])
+	}
+
+	again, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Decode after Encode: %v", err)
+	}
+	for i := range beads {
+		a, b := beads[i], again[i]
+		if a.ID != b.ID || a.Status != b.Status || a.Priority != b.Priority || a.ParentID != b.ParentID ||
+			len(a.BlockedBy) != len(b.BlockedBy) || len(a.Comments) != len(b.Comments) || a.Description != b.Description {
+			t.Errorf("round trip changed %s:\n%+v\n%+v", a.ID, a, b)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/vector76/beads_server/client"
+)
+
+func newAdminCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Manage a multi-project server (needs BS_ADMIN_TOKEN)",
+	}
+	cmd.AddCommand(newAdminProjectCmd())
+	return cmd
+}
+
+func newAdminProjectCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "project",
+		Short: "Create, list, rotate tokens of, archive and delete projects",
+	}
+
+	var dataFile, backend string
+	create := &cobra.Command{
+		Use:   "create <name>",
+		Short: "Create a project and print its token",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := newAdminClientFromEnv()
+			if err != nil {
+				return err
+			}
+			p, err := c.CreateProject(client.CreateProjectRequest{Name: args[0], DataFile: dataFile, Backend: backend})
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd.OutOrStdout(), p)
+		},
+	}
+	create.Flags().StringVar(&dataFile, "data-file", "", "path to the data file (default: <name>.json next to the projects file)")
+	create.Flags().StringVar(&backend, "backend", "", "storage backend: json (default) or sqlite")
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List all projects, archived ones included",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := newAdminClientFromEnv()
+			if err != nil {
+				return err
+			}
+			projects, err := c.ListProjects()
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd.OutOrStdout(), projects)
+		},
+	}
+
+	cmd.AddCommand(
+		create,
+		list,
+		newAdminProjectActionCmd("rotate-token", "Give a project a new token and print it", (*client.Client).RotateProjectToken),
+		newAdminProjectActionCmd("archive", "Stop serving a project, keeping its data", (*client.Client).ArchiveProject),
+		newAdminProjectActionCmd("delete", "Delete an archived project and its data", (*client.Client).DeleteProject),
+	)
+	return cmd
+}
+
+// newAdminProjectActionCmd returns a command that calls action on the named
+// project and prints the result.
+func newAdminProjectActionCmd[T any](name, short string, action func(*client.Client, string) (T, error)) *cobra.Command {
+	return &cobra.Command{
+		Use:   name + " <name>",
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := newAdminClientFromEnv()
+			if err != nil {
+				return err
+			}
+			result, err := action(c, args[0])
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd.OutOrStdout(), result)
+		},
+	}
+}
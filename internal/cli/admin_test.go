@@ -0,0 +1,160 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/vector76/beads_server/client"
+	"github.com/vector76/beads_server/internal/server"
+)
+
+// startAdminServer starts a multi-project server with the admin API on,
+// the way serve does, and points BS_URL and BS_ADMIN_TOKEN at it.
+func startAdminServer(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "projects.json")
+	writeProjects(t, path, "alpha")
+
+	loader := newProjectsLoader(path)
+	var srv *server.Server
+	err := loader.load(func(entries []server.ProviderEntry, hooks []server.Webhook) error {
+		var err error
+		srv, err = server.New(server.Config{
+			LogOutput:          io.Discard,
+			AdminToken:         "admin-secret",
+			ProjectsFile:       path,
+			ReloadProjectsFile: func() error { return loader.apply(srv) },
+		}, server.NewMultiStoreProvider(entries))
+		return err
+	}, nil)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	ts := httptest.NewServer(srv.Router)
+	t.Cleanup(func() {
+		ts.Close()
+		srv.Close()
+	})
+
+	os.Setenv("BS_URL", ts.URL)
+	os.Setenv("BS_ADMIN_TOKEN", "admin-secret")
+	t.Cleanup(func() {
+		os.Unsetenv("BS_URL")
+		os.Unsetenv("BS_ADMIN_TOKEN")
+	})
+	return path
+}
+
+func TestAdminProject_Commands(t *testing.T) {
+	startAdminServer(t)
+
+	var created client.ProjectToken
+	json.Unmarshal([]byte(runCmd(t, "admin", "project", "create", "beta")), &created)
+	if created.Name != "beta" || created.Token == "" {
+		t.Fatalf("create = %+v", created)
+	}
+	c := client.New(os.Getenv("BS_URL"), created.Token)
+	if _, err := c.List(client.ListFilters{}); err != nil {
+		t.Errorf("new project's token: %v", err)
+	}
+
+	var projects []client.Project
+	json.Unmarshal([]byte(runCmd(t, "admin", "project", "list")), &projects)
+	if len(projects) != 2 || projects[0].Name != "alpha" || projects[1].Name != "beta" {
+		t.Errorf("list = %+v", projects)
+	}
+
+	var rotated client.ProjectToken
+	json.Unmarshal([]byte(runCmd(t, "admin", "project", "rotate-token", "beta")), &rotated)
+	if rotated.Token == "" || rotated.Token == created.Token {
+		t.Errorf("rotate-token = %+v", rotated)
+	}
+
+	if err := runCmdErr(t, "admin", "project", "delete", "beta"); err == nil {
+		t.Error("expected delete of an unarchived project to fail")
+	}
+	runCmd(t, "admin", "project", "archive", "beta")
+	runCmd(t, "admin", "project", "delete", "beta")
+	json.Unmarshal([]byte(runCmd(t, "admin", "project", "list")), &projects)
+	if len(projects) != 1 {
+		t.Errorf("list after delete = %+v", projects)
+	}
+}
+
+func TestAdminProject_RequiresAdminToken(t *testing.T) {
+	startAdminServer(t)
+	os.Setenv("BS_ADMIN_TOKEN", "tok-alpha")
+
+	err := runCmdErr(t, "admin", "project", "list")
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("err = %v, want 401", err)
+	}
+}
+
+func TestList_AllProjects(t *testing.T) {
+	startAdminServer(t)
+	var beta client.ProjectToken
+	json.Unmarshal([]byte(runCmd(t, "admin", "project", "create", "beta")), &beta)
+	client.New(os.Getenv("BS_URL"), "tok-alpha").Create(client.CreateRequest{Title: "Alpha work"})
+	client.New(os.Getenv("BS_URL"), beta.Token).Create(client.CreateRequest{Title: "Beta work"})
+
+	projects := func(out string) []string {
+		var r client.ListResult
+		json.Unmarshal([]byte(out), &r)
+		var names []string
+		for _, b := range r.Beads {
+			names = append(names, b.Project)
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	if got := projects(runCmd(t, "list", "--all-projects")); !slices.Equal(got, []string{"alpha", "beta"}) {
+		t.Errorf("with the admin token: projects = %v, want alpha and beta", got)
+	}
+
+	os.Unsetenv("BS_ADMIN_TOKEN")
+	os.Setenv("BS_TOKEN", "tok-alpha")
+	t.Cleanup(func() { os.Unsetenv("BS_TOKEN") })
+	if got := projects(runCmd(t, "list", "--all-projects")); !slices.Equal(got, []string{"alpha"}) {
+		t.Errorf("with alpha's token: projects = %v, want alpha", got)
+	}
+	os.Setenv("BS_PROJECT_TOKENS", beta.Token)
+	t.Cleanup(func() { os.Unsetenv("BS_PROJECT_TOKENS") })
+	if got := projects(runCmd(t, "list", "--all-projects")); !slices.Equal(got, []string{"alpha", "beta"}) {
+		t.Errorf("with alpha's and beta's tokens: projects = %v, want alpha and beta", got)
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	startAdminServer(t)
+	var beta client.ProjectToken
+	json.Unmarshal([]byte(runCmd(t, "admin", "project", "create", "beta")), &beta)
+	b, _ := client.New(os.Getenv("BS_URL"), "tok-alpha").Create(client.CreateRequest{Title: "Filed in the wrong place"})
+
+	os.Unsetenv("BS_ADMIN_TOKEN")
+	os.Setenv("BS_TOKEN", "tok-alpha")
+	t.Cleanup(func() { os.Unsetenv("BS_TOKEN") })
+	if err := runCmdErr(t, "transfer", b.ID, "--to", "beta"); err == nil {
+		t.Fatal("transfer without a token for beta: expected an error")
+	}
+
+	os.Setenv("BS_PROJECT_TOKENS", beta.Token)
+	t.Cleanup(func() { os.Unsetenv("BS_PROJECT_TOKENS") })
+	var r client.TransferResult
+	json.Unmarshal([]byte(runCmd(t, "transfer", b.ID, "--to", "beta")), &r)
+	if r.Project != "beta" || !slices.Equal(r.Moved, []string{b.ID}) {
+		t.Errorf("transfer = %+v, want %s moved to beta", r, b.ID)
+	}
+	if _, err := client.New(os.Getenv("BS_URL"), beta.Token).Get(b.ID); err != nil {
+		t.Errorf("Get in beta: %v", err)
+	}
+}
@@ -2,9 +2,12 @@ package cli
 
 import (
 	"bytes"
+	"encoding/json"
 	"os"
 	"strings"
 	"testing"
+
+	"github.com/vector76/beads_server/internal/project"
 )
 
 func TestWhoami_DefaultAnonymous(t *testing.T) {
@@ -100,6 +103,13 @@ func TestHelp_ServeShowsFlags(t *testing.T) {
 	}
 }
 
+func TestServe_LeasesOffByDefault(t *testing.T) {
+	f := newServeCmd().Flags().Lookup("lease-ttl")
+	if f == nil || f.DefValue != "0s" {
+		t.Fatalf("lease-ttl flag = %+v, want a default of 0s", f)
+	}
+}
+
 func TestServe_RefusesWithoutToken(t *testing.T) {
 	os.Unsetenv("BS_TOKEN")
 
@@ -125,3 +135,34 @@ func TestServe_RefusesWithoutToken_EnvAlsoClear(t *testing.T) {
 		t.Fatal("expected error when token is empty, got nil")
 	}
 }
+
+func TestToken_GeneratesTokenAndHash(t *testing.T) {
+	var first, second map[string]string
+	json.Unmarshal([]byte(runCmd(t, "token")), &first)
+	json.Unmarshal([]byte(runCmd(t, "token")), &second)
+
+	if !strings.HasPrefix(first["token"], "bs_") || first["token"] == second["token"] {
+		t.Errorf("tokens %q and %q should be distinct and start with bs_", first["token"], second["token"])
+	}
+	if first["token_hash"] != project.HashToken(first["token"]) {
+		t.Errorf("token_hash = %q, want the hash of %q", first["token_hash"], first["token"])
+	}
+
+	var given map[string]string
+	json.Unmarshal([]byte(runCmd(t, "token", "tok-abc")), &given)
+	if given["token"] != "tok-abc" || given["token_hash"] != project.HashToken("tok-abc") {
+		t.Errorf("token tok-abc = %v", given)
+	}
+}
+
+func TestServe_AdminTokenRequiresProjects(t *testing.T) {
+	cmd := NewRootCmd()
+	cmd.SetOut(new(bytes.Buffer))
+	cmd.SetErr(new(bytes.Buffer))
+	cmd.SetArgs([]string{"serve", "--token", "tok", "--admin-token", "admin"})
+
+	err := cmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "--admin-token requires --projects") {
+		t.Fatalf("err = %v, want --admin-token to require --projects", err)
+	}
+}
@@ -1,29 +1,26 @@
 package cli
 
 import (
-	"bufio"
-	"bytes"
-	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
 	"strings"
+	"time"
+
+	"github.com/vector76/beads_server/client"
 )
 
 const defaultURL = "http://localhost:9999"
 
-// Client is an HTTP client for the beads API.
-type Client struct {
-	BaseURL    string
-	Token      string
-	HTTPClient *http.Client
-}
+// sseRetryDelays overrides the client's event stream reconnect schedule
+// when set; tests shorten it.
+var sseRetryDelays []time.Duration
 
-// NewClientFromEnv creates a Client from BS_URL and BS_TOKEN.
-// Values are read from environment variables first, then from a .env
-// file in the current directory. Returns an error if BS_TOKEN is not set.
-func NewClientFromEnv() (*Client, error) {
+// NewClientFromEnv creates a client from BS_URL, BS_TOKEN, BS_USER and
+// BS_PROJECT_TOKENS. Values are read from environment variables first, then
+// from a .env file in the current directory. Returns an error if BS_TOKEN
+// is not set.
+func NewClientFromEnv() (*client.Client, error) {
 	token := getenv("BS_TOKEN")
 	if token == "" {
 		return nil, fmt.Errorf("BS_TOKEN is required")
@@ -34,126 +31,39 @@ func NewClientFromEnv() (*Client, error) {
 		baseURL = defaultURL
 	}
 
-	return &Client{
-		BaseURL:    baseURL,
-		Token:      token,
-		HTTPClient: http.DefaultClient,
-	}, nil
-}
-
-// Do sends an HTTP request and returns the response body as parsed JSON.
-// Returns an error if the response status is not in the 2xx range.
-func (c *Client) Do(method, path string, body any) (json.RawMessage, error) {
-	var reqBody io.Reader
-	if body != nil {
-		data, err := json.Marshal(body)
-		if err != nil {
-			return nil, fmt.Errorf("marshaling request: %w", err)
+	c := client.New(baseURL, token)
+	c.Actor = getUser()
+	c.RetryDelays = sseRetryDelays
+	for _, t := range strings.Split(getenv("BS_PROJECT_TOKENS"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			c.ProjectTokens = append(c.ProjectTokens, t)
 		}
-		reqBody = bytes.NewReader(data)
-	}
-
-	url := c.BaseURL + path
-	req, err := http.NewRequest(method, url, reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
 	}
+	return c, nil
+}
 
-	req.Header.Set("Authorization", "Bearer "+c.Token)
-	if body != nil {
-		req.Header.Set("Content-Type", "application/json")
-	}
-
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("sending request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("reading response: %w", err)
+// newAdminClientFromEnv creates a client for the admin API from BS_URL and
+// BS_ADMIN_TOKEN, read like NewClientFromEnv's settings.
+func newAdminClientFromEnv() (*client.Client, error) {
+	token := getenv("BS_ADMIN_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("BS_ADMIN_TOKEN is required")
 	}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		// Try to extract error message from JSON response
-		var errResp struct {
-			Error string `json:"error"`
-		}
-		if json.Unmarshal(respBody, &errResp) == nil && errResp.Error != "" {
-			return nil, fmt.Errorf("%s", errResp.Error)
-		}
-		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(respBody))
+	baseURL := getenv("BS_URL")
+	if baseURL == "" {
+		baseURL = defaultURL
 	}
-
-	return json.RawMessage(respBody), nil
-}
-
-// StreamSSE opens a connection to the /events SSE endpoint and returns two
-// buffered channels: a signal channel that receives a value for each SSE event,
-// and an error channel that receives nil on clean context cancellation or a
-// non-nil error on unexpected connection failure.
-func (c *Client) StreamSSE(ctx context.Context) (<-chan struct{}, <-chan error) {
-	signals := make(chan struct{}, 16)
-	errs := make(chan error, 1)
-
-	go func() {
-		defer close(errs)
-		defer close(signals)
-
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/events", nil)
-		if err != nil {
-			errs <- fmt.Errorf("creating SSE request: %w", err)
-			return
-		}
-		req.Header.Set("Accept", "text/event-stream")
-		if c.Token != "" {
-			req.Header.Set("Authorization", "Bearer "+c.Token)
-		}
-
-		resp, err := c.HTTPClient.Do(req)
-		if err != nil {
-			if ctx.Err() != nil {
-				errs <- nil
-			} else {
-				errs <- fmt.Errorf("SSE connect: %w", err)
-			}
-			return
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-			errs <- fmt.Errorf("SSE HTTP %d", resp.StatusCode)
-			return
-		}
-
-		scanner := bufio.NewScanner(resp.Body)
-		for scanner.Scan() {
-			line := scanner.Text()
-			if strings.HasPrefix(line, "data:") {
-				signals <- struct{}{}
-			}
-		}
-
-		if ctx.Err() != nil {
-			errs <- nil
-		} else {
-			scanErr := scanner.Err()
-			if scanErr == nil {
-				scanErr = io.EOF
-			}
-			errs <- fmt.Errorf("SSE stream ended: %w", scanErr)
-		}
-	}()
-
-	return signals, errs
+	return client.New(baseURL, token), nil
 }
 
-// prettyJSON formats a json.RawMessage with 2-space indentation.
-func prettyJSON(data json.RawMessage) (string, error) {
-	var buf bytes.Buffer
-	if err := json.Indent(&buf, data, "", "  "); err != nil {
-		return "", err
+// printJSON writes v to w as 2-space indented JSON. A typed result from
+// the client package comes out exactly as the server sent it.
+func printJSON(w io.Writer, v any) error {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
 	}
-	return buf.String(), nil
+	fmt.Fprintln(w, string(out))
+	return nil
 }
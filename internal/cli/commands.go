@@ -4,6 +4,8 @@ import (
 	"fmt"
 
 	"github.com/spf13/cobra"
+	"github.com/vector76/beads_server/client"
+	"github.com/vector76/beads_server/model"
 )
 
 // newRedirectCmd returns a hidden command that always exits 1 and prints a
@@ -62,42 +64,26 @@ func newAddCmd() *cobra.Command {
 				return err
 			}
 
-			body := map[string]any{
-				"title": title,
-			}
-			if description != "" {
-				body["description"] = description
-			}
-			if beadType != "" {
-				body["type"] = beadType
-			}
-			if priority != "" {
-				body["priority"] = priority
-			}
-			if len(tags) > 0 {
-				body["tags"] = tags
-			}
-			if parentID != "" {
-				body["parent_id"] = parentID
+			req := client.CreateRequest{
+				Title:       title,
+				Description: description,
+				Type:        model.BeadType(beadType),
+				Priority:    model.Priority(priority),
+				Tags:        tags,
+				ParentID:    parentID,
 			}
 			if status != "" {
 				if status != "open" && status != "not_ready" {
 					return fmt.Errorf("--status must be 'open' or 'not_ready'")
 				}
-				body["status"] = status
-			}
-
-			data, err := c.Do("POST", "/api/v1/beads", body)
-			if err != nil {
-				return err
+				req.Status = model.Status(status)
 			}
 
-			out, err := prettyJSON(data)
+			b, err := c.Create(req)
 			if err != nil {
 				return err
 			}
-			fmt.Fprintln(cmd.OutOrStdout(), out)
-			return nil
+			return printJSON(cmd.OutOrStdout(), b)
 		},
 	}
 
@@ -123,17 +109,11 @@ func newShowCmd() *cobra.Command {
 				return err
 			}
 
-			data, err := c.Do("GET", "/api/v1/beads/"+args[0], nil)
+			b, err := c.Get(args[0])
 			if err != nil {
 				return err
 			}
-
-			out, err := prettyJSON(data)
-			if err != nil {
-				return err
-			}
-			fmt.Fprintln(cmd.OutOrStdout(), out)
-			return nil
+			return printJSON(cmd.OutOrStdout(), b)
 		},
 	}
 }
@@ -148,6 +128,7 @@ func newEditCmd() *cobra.Command {
 	var addTags []string
 	var removeTags []string
 	var blockedBy []string
+	var ifRev int64
 
 	cmd := &cobra.Command{
 		Use:   "edit <id>",
@@ -159,60 +140,82 @@ func newEditCmd() *cobra.Command {
 				return err
 			}
 
-			body := map[string]any{}
+			var req client.UpdateRequest
+			changed := false
 			if cmd.Flags().Changed("title") {
-				body["title"] = title
+				req.Title = &title
+				changed = true
 			}
 			if cmd.Flags().Changed("status") {
-				body["status"] = status
+				s := model.Status(status)
+				req.Status = &s
+				changed = true
 			}
 			if cmd.Flags().Changed("priority") {
-				body["priority"] = priority
+				p := model.Priority(priority)
+				req.Priority = &p
+				changed = true
 			}
 			if cmd.Flags().Changed("type") {
-				body["type"] = beadType
+				t := model.BeadType(beadType)
+				req.Type = &t
+				changed = true
 			}
 			if cmd.Flags().Changed("description") {
-				body["description"] = description
+				req.Description = &description
+				changed = true
 			}
 			if cmd.Flags().Changed("assignee") {
-				body["assignee"] = assignee
+				req.Assignee = &assignee
+				changed = true
 			}
 			if len(addTags) > 0 {
-				body["add_tags"] = addTags
+				req.AddTags = addTags
+				changed = true
 			}
 			if len(removeTags) > 0 {
-				body["remove_tags"] = removeTags
+				req.RemoveTags = removeTags
+				changed = true
 			}
 
-			if len(body) == 0 && len(blockedBy) == 0 {
+			if !changed && len(blockedBy) == 0 {
 				return fmt.Errorf("no fields to update")
 			}
 
-			var data []byte
-			if len(body) > 0 {
-				data, err = c.Do("PATCH", "/api/v1/beads/"+args[0], body)
+			// With --if-rev, each request is conditional on the revision
+			// returned by the previous one, so the edit fails with 412 if
+			// anyone else changes the bead part way through.
+			conditional := cmd.Flags().Changed("if-rev")
+			rev := ifRev
+
+			var out any
+			if changed {
+				var r client.UpdateResult
+				if conditional {
+					r, err = c.UpdateIfRevision(args[0], rev, req)
+				} else {
+					r, err = c.Update(args[0], req)
+				}
 				if err != nil {
 					return err
 				}
+				out, rev = r, r.Revision
 			}
 
 			for _, dep := range blockedBy {
-				linkBody := map[string]any{
-					"blocked_by": dep,
+				var b model.Bead
+				if conditional {
+					b, err = c.LinkIfRevision(args[0], dep, rev)
+				} else {
+					b, err = c.Link(args[0], dep)
 				}
-				data, err = c.Do("POST", "/api/v1/beads/"+args[0]+"/link", linkBody)
 				if err != nil {
 					return err
 				}
+				out, rev = b, b.Revision
 			}
 
-			out, err := prettyJSON(data)
-			if err != nil {
-				return err
-			}
-			fmt.Fprintln(cmd.OutOrStdout(), out)
-			return nil
+			return printJSON(cmd.OutOrStdout(), out)
 		},
 	}
 
@@ -225,6 +228,7 @@ func newEditCmd() *cobra.Command {
 	cmd.Flags().StringSliceVar(&addTags, "add-tag", nil, "add a tag")
 	cmd.Flags().StringSliceVar(&removeTags, "remove-tag", nil, "remove a tag")
 	cmd.Flags().StringSliceVar(&blockedBy, "blocked-by", nil, "add dependency (ID of blocking bead, repeatable)")
+	cmd.Flags().Int64Var(&ifRev, "if-rev", 0, "only edit if the bead is still at this revision")
 
 	return cmd
 }
@@ -240,21 +244,11 @@ func newStatusCmd(name string, targetStatus string) *cobra.Command {
 				return err
 			}
 
-			body := map[string]any{
-				"status": targetStatus,
-			}
-
-			data, err := c.Do("PATCH", "/api/v1/beads/"+args[0], body)
+			r, err := c.SetStatus(args[0], model.Status(targetStatus))
 			if err != nil {
 				return err
 			}
-
-			out, err := prettyJSON(data)
-			if err != nil {
-				return err
-			}
-			fmt.Fprintln(cmd.OutOrStdout(), out)
-			return nil
+			return printJSON(cmd.OutOrStdout(), r)
 		},
 	}
 }
@@ -284,21 +278,11 @@ func newCleanCmd() *cobra.Command {
 				return err
 			}
 
-			body := map[string]any{
-				"days": value,
-			}
-
-			data, err := c.Do("POST", "/api/v1/clean", body)
-			if err != nil {
-				return err
-			}
-
-			out, err := prettyJSON(data)
+			r, err := c.Clean(value)
 			if err != nil {
 				return err
 			}
-			fmt.Fprintln(cmd.OutOrStdout(), out)
-			return nil
+			return printJSON(cmd.OutOrStdout(), r)
 		},
 	}
 
@@ -332,29 +316,60 @@ func newMoveCmd() *cobra.Command {
 				return err
 			}
 
-			body := map[string]any{}
+			parentID := ""
 			if intoChanged {
-				body["parent_id"] = into
-			} else {
-				body["parent_id"] = ""
+				parentID = into
 			}
 
-			data, err := c.Do("PATCH", "/api/v1/beads/"+args[0], body)
+			r, err := c.Update(args[0], client.UpdateRequest{ParentID: &parentID})
 			if err != nil {
 				return err
 			}
+			return printJSON(cmd.OutOrStdout(), r)
+		},
+	}
+
+	cmd.Flags().StringVar(&into, "into", "", "target epic ID to move the bead into")
+	cmd.Flags().BoolVar(&out, "out", false, "detach the bead from its parent epic")
+
+	return cmd
+}
 
-			pretty, err := prettyJSON(data)
+func newTransferCmd() *cobra.Command {
+	var to string
+	var unlink bool
+
+	cmd := &cobra.Command{
+		Use:   "transfer <id>",
+		Short: "Move a bead, or an epic with its children, to another project",
+		Long: `Move a bead, or an epic with its children, to another project on the same
+server. IDs, comments and history are kept. BS_PROJECT_TOKENS must hold a
+token for the destination project.
+
+Dependencies between the moved beads and beads left behind become
+cross-project dependencies and are listed in the output; with --unlink
+they are removed instead.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if to == "" {
+				return fmt.Errorf("--to is required")
+			}
+
+			c, err := NewClientFromEnv()
+			if err != nil {
+				return err
+			}
+
+			r, err := c.Transfer(args[0], to, unlink)
 			if err != nil {
 				return err
 			}
-			fmt.Fprintln(cmd.OutOrStdout(), pretty)
-			return nil
+			return printJSON(cmd.OutOrStdout(), r)
 		},
 	}
 
-	cmd.Flags().StringVar(&into, "into", "", "target epic ID to move the bead into")
-	cmd.Flags().BoolVar(&out, "out", false, "detach the bead from its parent epic")
+	cmd.Flags().StringVar(&to, "to", "", "project to move the bead to")
+	cmd.Flags().BoolVar(&unlink, "unlink", false, "remove dependencies on beads left behind instead of keeping them across projects")
 
 	return cmd
 }
@@ -370,17 +385,11 @@ func newDeleteCmd() *cobra.Command {
 				return err
 			}
 
-			data, err := c.Do("DELETE", "/api/v1/beads/"+args[0], nil)
-			if err != nil {
-				return err
-			}
-
-			out, err := prettyJSON(data)
+			r, err := c.Delete(args[0])
 			if err != nil {
 				return err
 			}
-			fmt.Fprintln(cmd.OutOrStdout(), out)
-			return nil
+			return printJSON(cmd.OutOrStdout(), r)
 		},
 	}
 }
@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/vector76/beads_server/client"
+	"github.com/vector76/beads_server/internal/beadsjsonl"
+	"github.com/vector76/beads_server/model"
+)
+
+// formatJSON is the server's own export format.
+const formatJSON = "json"
+
+// checkFormat rejects formats other than json and beads-jsonl.
+func checkFormat(format string) error {
+	if format != formatJSON && format != beadsjsonl.FormatName {
+		return fmt.Errorf("--format must be %q or %q", formatJSON, beadsjsonl.FormatName)
+	}
+	return nil
+}
+
+func newExportCmd() *cobra.Command {
+	var output string
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export every bead in the project, with comments, dependencies and history",
+		Long: `Export every bead in the project, with comments, dependencies and history.
+
+--format beads-jsonl writes an issues.jsonl file for the upstream beads
+tool instead. It carries comments, dependencies and parent links, but not
+history.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat(format); err != nil {
+				return err
+			}
+
+			c, err := NewClientFromEnv()
+			if err != nil {
+				return err
+			}
+
+			doc, err := c.Export()
+			if err != nil {
+				return err
+			}
+
+			var buf bytes.Buffer
+			if format == beadsjsonl.FormatName {
+				if err := beadsjsonl.Encode(&buf, doc.Beads); err != nil {
+					return err
+				}
+			} else if err := printJSON(&buf, doc); err != nil {
+				return err
+			}
+			if output == "" {
+				_, err := buf.WriteTo(cmd.OutOrStdout())
+				return err
+			}
+			return os.WriteFile(output, buf.Bytes(), 0644)
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "write the export to this file instead of stdout")
+	cmd.Flags().StringVar(&format, "format", formatJSON, "json or beads-jsonl (upstream beads issues.jsonl)")
+
+	return cmd
+}
+
+func newImportCmd() *cobra.Command {
+	var mode string
+	var onConflict string
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "import <file>",
+		Short: "Import beads from an export or a beads.json data file",
+		Long: `Import beads from an export or a beads.json data file.
+
+Beads keep their IDs, comments, dependencies, parent links and history.
+--mode merge (the default) adds them to the project; --mode replace removes
+everything in the project first. If an imported ID is already in use, the
+import fails and lists the conflicts, unless --on-conflict remap is given,
+in which case those beads get new IDs and references to them are updated.
+
+--format beads-jsonl reads an issues.jsonl file from the upstream beads
+tool. Priorities 0-4 map to critical through none, "blocked" issues become
+open (blocking follows from their dependencies), "deferred" becomes
+not_ready, and blocks and parent-child dependencies become blocked_by and
+parent links. Other dependency types are dropped.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkFormat(format); err != nil {
+				return err
+			}
+			raw, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			if format == beadsjsonl.FormatName {
+				beads, err := beadsjsonl.Decode(bytes.NewReader(raw))
+				if err != nil {
+					return fmt.Errorf("parsing %s: %w", args[0], err)
+				}
+				if raw, err = json.Marshal(model.Snapshot{Beads: beads}); err != nil {
+					return err
+				}
+			} else if !json.Valid(raw) {
+				return fmt.Errorf("%s is not valid JSON", args[0])
+			}
+
+			c, err := NewClientFromEnv()
+			if err != nil {
+				return err
+			}
+
+			res, err := c.Import(raw, client.ImportOptions{Mode: mode, OnConflict: onConflict})
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd.OutOrStdout(), res)
+		},
+	}
+
+	cmd.Flags().StringVar(&mode, "mode", "merge", "merge (add to the project) or replace (remove existing beads first)")
+	cmd.Flags().StringVar(&onConflict, "on-conflict", "fail", "fail or remap when an imported ID is already in use")
+	cmd.Flags().StringVar(&format, "format", formatJSON, "json or beads-jsonl (upstream beads issues.jsonl)")
+
+	return cmd
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportImport(t *testing.T) {
+	src := startTestServer(t)
+	setClientEnv(t, src.URL)
+	epic := parseBeadFromOutput(t, runCmd(t, "add", "Epic"))
+	child := parseBeadFromOutput(t, runCmd(t, "add", "Child", "--parent", epic.ID))
+	runCmd(t, "comment", child.ID, "halfway there")
+
+	path := filepath.Join(t.TempDir(), "export.json")
+	runCmd(t, "export", "-o", path)
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("export file: %v", err)
+	}
+
+	// Importing into the same project clashes on every ID.
+	err := runCmdErr(t, "import", path)
+	if err == nil || !strings.Contains(err.Error(), child.ID) {
+		t.Errorf("expected a conflict naming %s, got %v", child.ID, err)
+	}
+
+	dst := startTestServer(t)
+	setClientEnv(t, dst.URL)
+	out := runCmd(t, "import", path)
+	var result struct {
+		Imported int `json:"imported"`
+	}
+	if err := json.Unmarshal([]byte(out), &result); err != nil || result.Imported != 2 {
+		t.Fatalf("import output = %s", out)
+	}
+
+	got := parseBeadFromOutput(t, runCmd(t, "show", child.ID))
+	if got.ParentID != epic.ID || len(got.Comments) != 1 {
+		t.Errorf("imported child = %+v", got)
+	}
+}
+
+func TestImport_InvalidFile(t *testing.T) {
+	ts := startTestServer(t)
+	setClientEnv(t, ts.URL)
+
+	path := filepath.Join(t.TempDir(), "broken.json")
+	os.WriteFile(path, []byte("{not json"), 0644)
+	if err := runCmdErr(t, "import", path); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+	if err := runCmdErr(t, "import", "--mode", "overwrite", path); err == nil {
+		t.Error("expected error for an unknown mode")
+	}
+}
+
+func TestImportExport_BeadsJSONL(t *testing.T) {
+	ts := startTestServer(t)
+	setClientEnv(t, ts.URL)
+
+	path := filepath.Join(t.TempDir(), "issues.jsonl")
+	os.WriteFile(path, []byte(`{"id":"bd-1","title":"Epic","status":"open","priority":1,"issue_type":"epic"}
+{"id":"bd-2","title":"Child","status":"blocked","priority":3,"issue_type":"bug","dependencies":[{"issue_id":"bd-2","depends_on_id":"bd-1","type":"parent-child"},{"issue_id":"bd-2","depends_on_id":"bd-3","type":"blocks"}]}
+{"id":"bd-3","title":"Blocker","status":"in_progress","priority":0,"issue_type":"task"}
+`), 0644)
+	runCmd(t, "import", "--format", "beads-jsonl", path)
+
+	child := parseBeadFromOutput(t, runCmd(t, "show", "bd-2"))
+	if child.ParentID != "bd-1" || len(child.BlockedBy) != 1 || child.BlockedBy[0] != "bd-3" || child.Status != "open" || child.Priority != "low" {
+		t.Errorf("imported child = %+v", child)
+	}
+
+	out := runCmd(t, "export", "--format", "beads-jsonl")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 || !strings.Contains(lines[0], `"issue_type":"epic"`) || !strings.Contains(lines[1], `"type":"parent-child"`) {
+		t.Errorf("exported jsonl = %s", out)
+	}
+
+	if err := runCmdErr(t, "export", "--format", "csv"); err == nil {
+		t.Error("expected error for an unknown format")
+	}
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+func newImportPlanCmd() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "import-plan <file>",
+		Short: "Create or update an epic from a YAML or JSON plan",
+		Long: `Create or update an epic from a YAML or JSON plan.
+
+The plan is the epic itself, with its children listed under "children".
+Every item needs a key and a title; blocked_by names the keys of other
+items in the plan, or the IDs of existing beads. Importing the same plan
+again updates the beads it created instead of creating new ones.
+
+  key: auth
+  title: Auth rewrite
+  children:
+    - key: schema
+      title: Design schema
+      priority: high
+    - key: api
+      title: Build API
+      blocked_by: [schema]
+    - key: rollout
+      title: Roll out
+      status: not_ready
+      blocked_by: [api]`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			raw, err := os.ReadFile(args[0])
+			if err != nil {
+				return err
+			}
+			// JSON is valid YAML, so one decoder handles both.
+			var plan any
+			if err := yaml.Unmarshal(raw, &plan); err != nil {
+				return fmt.Errorf("parsing %s: %w", args[0], err)
+			}
+			if plan == nil {
+				return fmt.Errorf("%s is empty", args[0])
+			}
+
+			c, err := NewClientFromEnv()
+			if err != nil {
+				return err
+			}
+
+			res, err := c.ImportPlan(plan, dryRun)
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd.OutOrStdout(), res)
+		},
+	}
+
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "validate the plan and report what would change without applying it")
+
+	return cmd
+}
@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testPlanYAML = `key: auth
+title: Auth rewrite
+children:
+  - key: schema
+    title: Design schema
+    priority: high
+  - key: api
+    title: Build API
+    blocked_by: [schema]
+`
+
+func writePlan(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "plan.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestImportPlan(t *testing.T) {
+	ts := startTestServer(t)
+	setClientEnv(t, ts.URL)
+	path := writePlan(t, testPlanYAML)
+
+	var result struct {
+		DryRun    bool              `json:"dry_run"`
+		Keys      map[string]string `json:"keys"`
+		Created   []string          `json:"created"`
+		Unchanged []string          `json:"unchanged"`
+	}
+
+	out := runCmd(t, "import-plan", "--dry-run", path)
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("failed to parse output: %v\n%s", err, out)
+	}
+	if !result.DryRun || len(result.Created) != 3 {
+		t.Errorf("dry run = %+v, want 3 created", result)
+	}
+
+	out = runCmd(t, "import-plan", path)
+	result.Created = nil
+	json.Unmarshal([]byte(out), &result)
+	if len(result.Created) != 3 || len(result.Keys) != 3 {
+		t.Fatalf("import = %+v, want 3 created", result)
+	}
+
+	api := parseBeadFromOutput(t, runCmd(t, "show", result.Keys["api"]))
+	if api.ParentID != result.Keys["auth"] || len(api.BlockedBy) != 1 || api.BlockedBy[0] != result.Keys["schema"] {
+		t.Errorf("api = %+v", api)
+	}
+
+	out = runCmd(t, "import-plan", path)
+	result.Created = nil
+	json.Unmarshal([]byte(out), &result)
+	if len(result.Created) != 0 || len(result.Unchanged) != 3 {
+		t.Errorf("re-import = %+v, want everything unchanged", result)
+	}
+}
+
+func TestImportPlan_Errors(t *testing.T) {
+	ts := startTestServer(t)
+	setClientEnv(t, ts.URL)
+
+	if err := runCmdErr(t, "import-plan", filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected error for a missing file")
+	}
+	if err := runCmdErr(t, "import-plan", writePlan(t, "key: [unclosed")); err == nil {
+		t.Error("expected error for invalid YAML")
+	}
+	if err := runCmdErr(t, "import-plan", writePlan(t, "title: No key\n")); err == nil {
+		t.Error("expected error for a plan without a key")
+	}
+}
@@ -2,10 +2,11 @@ package cli
 
 import (
 	"fmt"
-	"net/url"
-	"strconv"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/vector76/beads_server/client"
+	"github.com/vector76/beads_server/model"
 )
 
 func newListCmd() *cobra.Command {
@@ -18,65 +19,57 @@ func newListCmd() *cobra.Command {
 	var assignee string
 	var page int
 	var perPage int
+	var allProjects bool
 
 	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List beads",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			c, err := NewClientFromEnv()
+			newClient := NewClientFromEnv
+			if allProjects && getenv("BS_ADMIN_TOKEN") != "" {
+				newClient = newAdminClientFromEnv
+			}
+			c, err := newClient()
 			if err != nil {
 				return err
 			}
 
-			params := url.Values{}
-			if all {
-				params.Set("all", "true")
-			}
-			if ready {
-				params.Set("ready", "true")
+			f := client.ListFilters{
+				All:      all,
+				Ready:    ready,
+				Priority: model.Priority(priority),
+				Type:     model.BeadType(beadType),
+				Assignee: assignee,
 			}
 			if status != "" {
-				params.Set("status", status)
-			}
-			if priority != "" {
-				params.Set("priority", priority)
-			}
-			if beadType != "" {
-				params.Set("type", beadType)
+				for _, st := range strings.Split(status, ",") {
+					f.Statuses = append(f.Statuses, model.Status(strings.TrimSpace(st)))
+				}
 			}
 			if tag != "" {
-				params.Set("tag", tag)
-			}
-			if assignee != "" {
-				params.Set("assignee", assignee)
+				f.Tags = strings.Split(tag, ",")
 			}
 			if cmd.Flags().Changed("page") {
-				params.Set("page", strconv.Itoa(page))
+				f.Page = page
 			}
 			if cmd.Flags().Changed("per-page") {
-				params.Set("per_page", strconv.Itoa(perPage))
-			}
-
-			path := "/api/v1/beads"
-			if len(params) > 0 {
-				path += "?" + params.Encode()
+				f.PerPage = perPage
 			}
 
-			data, err := c.Do("GET", path, nil)
-			if err != nil {
-				return err
+			list := c.List
+			if allProjects {
+				list = c.ListAllProjects
 			}
-
-			out, err := prettyJSON(data)
+			r, err := list(f)
 			if err != nil {
 				return err
 			}
-			fmt.Fprintln(cmd.OutOrStdout(), out)
-			return nil
+			return printJSON(cmd.OutOrStdout(), r)
 		},
 	}
 
 	cmd.Flags().BoolVar(&all, "all", false, "include all statuses")
+	cmd.Flags().BoolVar(&allProjects, "all-projects", false, "list every project: all with BS_ADMIN_TOKEN, else BS_TOKEN's and BS_PROJECT_TOKENS'")
 	cmd.Flags().BoolVar(&ready, "ready", false, "only show unblocked beads")
 	cmd.Flags().StringVar(&status, "status", "", "filter by status (comma-separated)")
 	cmd.Flags().StringVar(&priority, "priority", "", "filter by priority")
@@ -90,32 +83,42 @@ func newListCmd() *cobra.Command {
 }
 
 func newSearchCmd() *cobra.Command {
-	return &cobra.Command{
+	var in string
+	var sortBy string
+	var page int
+	var perPage int
+
+	cmd := &cobra.Command{
 		Use:   "search <query>",
 		Short: "Search beads",
-		Args:  cobra.ExactArgs(1),
+		Long: `Search titles, descriptions and comments. Every word must match;
+a word ending in *, and the last word of the query, match as a prefix, and
+"quoted words" match as a phrase.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			c, err := NewClientFromEnv()
 			if err != nil {
 				return err
 			}
 
-			params := url.Values{}
-			params.Set("q", args[0])
-
-			data, err := c.Do("GET", "/api/v1/search?"+params.Encode(), nil)
-			if err != nil {
-				return err
+			o := client.SearchOptions{Sort: sortBy, Page: page, PerPage: perPage}
+			if in != "" {
+				o.In = strings.Split(in, ",")
 			}
-
-			out, err := prettyJSON(data)
+			r, err := c.SearchWith(args[0], o)
 			if err != nil {
 				return err
 			}
-			fmt.Fprintln(cmd.OutOrStdout(), out)
-			return nil
+			return printJSON(cmd.OutOrStdout(), r)
 		},
 	}
+
+	cmd.Flags().StringVar(&in, "in", "", "only search these fields: title, description, comments (comma-separated)")
+	cmd.Flags().StringVar(&sortBy, "sort", "priority", "order results by priority or relevance")
+	cmd.Flags().IntVar(&page, "page", 1, "page number")
+	cmd.Flags().IntVar(&perPage, "per-page", 100, "results per page")
+
+	return cmd
 }
 
 func newClaimCmd() *cobra.Command {
@@ -129,54 +132,95 @@ func newClaimCmd() *cobra.Command {
 				return err
 			}
 
-			user := getUser()
-
-			body := map[string]any{
-				"user": user,
+			r, err := c.Claim(args[0])
+			if err != nil {
+				return err
 			}
+			return printJSON(cmd.OutOrStdout(), r)
+		},
+	}
+}
 
-			data, err := c.Do("POST", "/api/v1/beads/"+args[0]+"/claim", body)
+// claimFilters builds the claim-next filters from command-line flags.
+func claimFilters(tags []string, assignee, priority, beadType string) client.ClaimFilters {
+	return client.ClaimFilters{
+		Tags:     tags,
+		Assignee: assignee,
+		Priority: model.Priority(priority),
+		Type:     model.BeadType(beadType),
+	}
+}
+
+func newNextCmd() *cobra.Command {
+	var tags []string
+	var assignee string
+	var priority string
+	var beadType string
+
+	cmd := &cobra.Command{
+		Use:   "next",
+		Short: "Claim the highest-priority ready bead",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := NewClientFromEnv()
 			if err != nil {
 				return err
 			}
 
-			out, err := prettyJSON(data)
+			r, err := c.ClaimNext(claimFilters(tags, assignee, priority, beadType))
 			if err != nil {
 				return err
 			}
-			fmt.Fprintln(cmd.OutOrStdout(), out)
-			return nil
+			return printJSON(cmd.OutOrStdout(), r)
 		},
 	}
+
+	cmd.Flags().StringArrayVar(&tags, "tag", nil, "filter by tag (repeatable)")
+	cmd.Flags().StringVar(&assignee, "assignee", "", "filter by assignee")
+	cmd.Flags().StringVar(&priority, "priority", "", "filter by priority")
+	cmd.Flags().StringVar(&beadType, "type", "", "filter by bead type")
+
+	return cmd
 }
 
-func newMineCmd() *cobra.Command {
+func newHeartbeatCmd() *cobra.Command {
 	return &cobra.Command{
-		Use:   "mine",
-		Short: "List beads assigned to me that are in progress",
+		Use:   "heartbeat <id>",
+		Short: "Renew the lease on a bead you have claimed",
+		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			c, err := NewClientFromEnv()
 			if err != nil {
 				return err
 			}
 
-			user := getUser()
-
-			params := url.Values{}
-			params.Set("assignee", user)
-			params.Set("status", "in_progress")
+			r, err := c.Heartbeat(args[0])
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd.OutOrStdout(), r)
+		},
+	}
+}
 
-			data, err := c.Do("GET", "/api/v1/beads?"+params.Encode(), nil)
+func newMineCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "mine",
+		Short: "List beads assigned to me that are in progress",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := NewClientFromEnv()
 			if err != nil {
 				return err
 			}
 
-			out, err := prettyJSON(data)
+			r, err := c.List(client.ListFilters{
+				Assignee: c.Actor,
+				Statuses: []model.Status{model.StatusInProgress},
+			})
 			if err != nil {
 				return err
 			}
-			fmt.Fprintln(cmd.OutOrStdout(), out)
-			return nil
+			return printJSON(cmd.OutOrStdout(), r)
 		},
 	}
 }
@@ -192,24 +236,11 @@ func newCommentCmd() *cobra.Command {
 				return err
 			}
 
-			user := getUser()
-
-			body := map[string]any{
-				"author": user,
-				"text":   args[1],
-			}
-
-			data, err := c.Do("POST", "/api/v1/beads/"+args[0]+"/comments", body)
-			if err != nil {
-				return err
-			}
-
-			out, err := prettyJSON(data)
+			r, err := c.Comment(args[0], args[1])
 			if err != nil {
 				return err
 			}
-			fmt.Fprintln(cmd.OutOrStdout(), out)
-			return nil
+			return printJSON(cmd.OutOrStdout(), r)
 		},
 	}
 }
@@ -231,23 +262,14 @@ func newLinkCmd() *cobra.Command {
 				return err
 			}
 
-			var data []byte
+			var r model.Bead
 			for _, dep := range blockedBy {
-				body := map[string]any{
-					"blocked_by": dep,
-				}
-				data, err = c.Do("POST", "/api/v1/beads/"+args[0]+"/link", body)
+				r, err = c.Link(args[0], dep)
 				if err != nil {
 					return err
 				}
 			}
-
-			out, err := prettyJSON(data)
-			if err != nil {
-				return err
-			}
-			fmt.Fprintln(cmd.OutOrStdout(), out)
-			return nil
+			return printJSON(cmd.OutOrStdout(), r)
 		},
 	}
 
@@ -273,17 +295,11 @@ func newUnlinkCmd() *cobra.Command {
 				return err
 			}
 
-			data, err := c.Do("DELETE", "/api/v1/beads/"+args[0]+"/link/"+blockedBy, nil)
-			if err != nil {
-				return err
-			}
-
-			out, err := prettyJSON(data)
+			r, err := c.Unlink(args[0], blockedBy)
 			if err != nil {
 				return err
 			}
-			fmt.Fprintln(cmd.OutOrStdout(), out)
-			return nil
+			return printJSON(cmd.OutOrStdout(), r)
 		},
 	}
 
@@ -303,17 +319,31 @@ func newDepsCmd() *cobra.Command {
 				return err
 			}
 
-			data, err := c.Do("GET", "/api/v1/beads/"+args[0]+"/deps", nil)
+			r, err := c.Deps(args[0])
+			if err != nil {
+				return err
+			}
+			return printJSON(cmd.OutOrStdout(), r)
+		},
+	}
+}
+
+func newHistoryCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "history <id>",
+		Short: "Show the change history of a bead",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := NewClientFromEnv()
 			if err != nil {
 				return err
 			}
 
-			out, err := prettyJSON(data)
+			r, err := c.History(args[0])
 			if err != nil {
 				return err
 			}
-			fmt.Fprintln(cmd.OutOrStdout(), out)
-			return nil
+			return printJSON(cmd.OutOrStdout(), r)
 		},
 	}
 }
@@ -5,8 +5,8 @@ import (
 	"os"
 	"testing"
 
-	"github.com/vector76/beads_server/internal/model"
 	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
 )
 
 func TestList_Default(t *testing.T) {
@@ -101,6 +101,32 @@ func TestSearch(t *testing.T) {
 	}
 }
 
+func TestSearch_InCommentsByRelevance(t *testing.T) {
+	ts := startTestServer(t)
+	setClientEnv(t, ts.URL)
+
+	runCmd(t, "add", "Login times out")
+	out := runCmd(t, "add", "Session cleanup")
+	commented := parseBeadFromOutput(t, out)
+	runCmd(t, "comment", commented.ID, "Probably the same login timeout")
+
+	out = runCmd(t, "search", "login", "--in", "comments", "--sort", "relevance")
+	var result store.ListResult
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("failed to parse search output: %v", err)
+	}
+	if result.Total != 1 || result.Beads[0].ID != commented.ID {
+		t.Fatalf("search --in comments = %+v, want only %s", result.Beads, commented.ID)
+	}
+	if m := result.Beads[0].Matches; len(m) != 1 || m[0].Snippet != "Probably the same **login** timeout" {
+		t.Errorf("matches = %+v", m)
+	}
+
+	if err := runCmdErr(t, "search", "login", "--sort", "newest"); err == nil {
+		t.Error("expected an error for an unknown sort")
+	}
+}
+
 func TestClaim(t *testing.T) {
 	ts := startTestServer(t)
 	setClientEnv(t, ts.URL)
@@ -408,3 +434,77 @@ func TestUnlink_MissingBlockedBy(t *testing.T) {
 		t.Error("expected error when --blocked-by is missing")
 	}
 }
+
+func TestHistory(t *testing.T) {
+	ts := startTestServer(t)
+	setClientEnv(t, ts.URL)
+	os.Setenv("BS_USER", "historian")
+	t.Cleanup(func() { os.Unsetenv("BS_USER") })
+
+	out := runCmd(t, "add", "Tracked bead")
+	b := parseBeadFromOutput(t, out)
+	runCmd(t, "edit", b.ID, "--title", "Renamed bead")
+
+	out = runCmd(t, "history", b.ID)
+	var resp struct {
+		ID      string               `json:"id"`
+		History []model.HistoryEntry `json:"history"`
+	}
+	if err := json.Unmarshal([]byte(out), &resp); err != nil {
+		t.Fatalf("failed to parse history output: %v", err)
+	}
+	if len(resp.History) != 2 {
+		t.Fatalf("history length = %d, want 2", len(resp.History))
+	}
+	for _, e := range resp.History {
+		if e.Actor != "historian" {
+			t.Errorf("actor = %q, want historian", e.Actor)
+		}
+	}
+	if resp.History[1].Action != model.ActionUpdated {
+		t.Errorf("second action = %q, want %q", resp.History[1].Action, model.ActionUpdated)
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	ts := startTestServer(t)
+	setClientEnv(t, ts.URL)
+	os.Setenv("BS_USER", "agent-42")
+	t.Cleanup(func() { os.Unsetenv("BS_USER") })
+
+	out := runCmd(t, "add", "Long task")
+	b := parseBeadFromOutput(t, out)
+	runCmd(t, "claim", b.ID)
+
+	out = runCmd(t, "heartbeat", b.ID)
+	hb := parseBeadFromOutput(t, out)
+	if hb.ID != b.ID || hb.Assignee != "agent-42" {
+		t.Errorf("unexpected heartbeat result: id=%q assignee=%q", hb.ID, hb.Assignee)
+	}
+
+	os.Setenv("BS_USER", "someone-else")
+	if err := runCmdErr(t, "heartbeat", b.ID); err == nil {
+		t.Error("expected heartbeat by a different user to fail")
+	}
+}
+
+func TestNext(t *testing.T) {
+	ts := startTestServer(t)
+	setClientEnv(t, ts.URL)
+	os.Setenv("BS_USER", "agent-7")
+	t.Cleanup(func() { os.Unsetenv("BS_USER") })
+
+	runCmd(t, "add", "Routine", "--priority", "low")
+	out := runCmd(t, "add", "Urgent", "--priority", "critical", "--tags", "ops")
+	urgent := parseBeadFromOutput(t, out)
+
+	out = runCmd(t, "next", "--tag", "ops")
+	got := parseBeadFromOutput(t, out)
+	if got.ID != urgent.ID || got.Status != model.StatusInProgress || got.Assignee != "agent-7" {
+		t.Errorf("expected %s claimed by agent-7, got id=%q status=%q assignee=%q", urgent.ID, got.ID, got.Status, got.Assignee)
+	}
+
+	if err := runCmdErr(t, "next", "--type", "bug"); err == nil {
+		t.Error("expected error when no ready bead matches")
+	}
+}
@@ -3,6 +3,7 @@ package cli
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"io"
 	"net/http/httptest"
 	"os"
@@ -10,9 +11,10 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/vector76/beads_server/internal/model"
+	"github.com/vector76/beads_server/client"
 	"github.com/vector76/beads_server/internal/server"
 	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
 )
 
 const testToken = "test-secret"
@@ -30,7 +32,6 @@ func startTestServer(t *testing.T) *httptest.Server {
 	if err != nil {
 		t.Fatalf("server.New: %v", err)
 	}
-	srv.Store = s
 	ts := httptest.NewServer(srv.Router)
 	t.Cleanup(ts.Close)
 	return ts
@@ -334,6 +335,34 @@ func TestEdit_BlockedByRepeatedFlag(t *testing.T) {
 	}
 }
 
+func TestEdit_IfRev(t *testing.T) {
+	ts := startTestServer(t)
+	setClientEnv(t, ts.URL)
+
+	out := runCmd(t, "add", "Blocker")
+	blocker := parseBeadFromOutput(t, out)
+
+	out = runCmd(t, "add", "Target")
+	target := parseBeadFromOutput(t, out)
+
+	// The PATCH and the link are each conditional on the previous revision.
+	out = runCmd(t, "edit", target.ID, "--if-rev", "1", "--title", "Mine", "--blocked-by", blocker.ID)
+	edited := parseBeadFromOutput(t, out)
+	if edited.Title != "Mine" || len(edited.BlockedBy) != 1 || edited.Revision != 3 {
+		t.Fatalf("edited = %+v, want title Mine, one blocker, revision 3", edited)
+	}
+
+	err := runCmdErr(t, "edit", target.ID, "--if-rev", "1", "--title", "Stale")
+	if !errors.Is(err, client.ErrPreconditionFailed) {
+		t.Fatalf("stale --if-rev: err = %v, want 412", err)
+	}
+
+	out = runCmd(t, "show", target.ID)
+	if got := parseBeadFromOutput(t, out); got.Title != "Mine" {
+		t.Errorf("title after stale edit = %q, want %q", got.Title, "Mine")
+	}
+}
+
 func TestClean_RemovesClosedBeads(t *testing.T) {
 	ts := startTestServer(t)
 	setClientEnv(t, ts.URL)
@@ -2,14 +2,13 @@ package cli
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"net/url"
-	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/vector76/beads_server/client"
+	"github.com/vector76/beads_server/model"
 )
 
 var errTimeout = errors.New("timed out")
@@ -20,10 +19,15 @@ func newWaitReadyCmd() *cobra.Command {
 	var assignee string
 	var priority string
 	var beadType string
+	var claim bool
 
 	cmd := &cobra.Command{
-		Use:           "wait-ready",
-		Short:         "Wait until a ready bead exists",
+		Use:   "wait-ready",
+		Short: "Wait until a ready bead exists",
+		Long: `Wait until a ready bead exists.
+
+With --claim, keep waiting until a ready bead has been claimed for the
+current user, then print it. Competing agents never win the same bead.`,
 		SilenceErrors: true,
 		SilenceUsage:  true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -42,76 +46,17 @@ func newWaitReadyCmd() *cobra.Command {
 			}
 			defer cancel()
 
-			signals, errChan := c.StreamSSE(ctx)
-
-			// Build the query path once; flags are immutable after parsing.
-			params := url.Values{}
-			params.Set("ready", "true")
-			params.Set("per_page", "1")
-			if len(tags) > 0 {
-				params.Set("tag", strings.Join(tags, ","))
-			}
-			if assignee != "" {
-				params.Set("assignee", assignee)
-			}
-			if priority != "" {
-				params.Set("priority", priority)
-			}
-			if beadType != "" {
-				params.Set("type", beadType)
-			}
-			path := "/api/v1/beads?" + params.Encode()
-
-			checkReady := func() (bool, error) {
-				data, err := c.Do("GET", path, nil)
-				if err != nil {
-					return false, err
-				}
-				var result struct {
-					Total int `json:"total"`
-				}
-				if err := json.Unmarshal(data, &result); err != nil {
-					return false, fmt.Errorf("parsing response: %w", err)
-				}
-				return result.Total >= 1, nil
-			}
-
-			ready, err := checkReady()
+			b, err := waitReady(ctx, c, claimFilters(tags, assignee, priority, beadType), claim)
 			if err != nil {
-				fmt.Fprintln(cmd.ErrOrStderr(), "error:", err)
+				if !errors.Is(err, errTimeout) {
+					fmt.Fprintln(cmd.ErrOrStderr(), "error:", err)
+				}
 				return err
 			}
-			if ready {
+			if b == nil {
 				return nil
 			}
-
-			for {
-				select {
-				case _, ok := <-signals:
-					if !ok {
-						// signals closed; errChan will deliver the outcome — stop selecting on signals.
-						signals = nil
-						continue
-					}
-					ready, err := checkReady()
-					if err != nil {
-						fmt.Fprintln(cmd.ErrOrStderr(), "error:", err)
-						return err
-					}
-					if ready {
-						return nil
-					}
-				case err, ok := <-errChan:
-					if ok && err != nil {
-						fmt.Fprintln(cmd.ErrOrStderr(), "error:", err)
-						return err
-					}
-					// nil error or channel closed: context was cancelled (deadline or explicit).
-					return errTimeout
-				case <-ctx.Done():
-					return errTimeout
-				}
-			}
+			return printJSON(cmd.OutOrStdout(), b)
 		},
 	}
 
@@ -121,6 +66,28 @@ func newWaitReadyCmd() *cobra.Command {
 	cmd.Flags().StringVar(&assignee, "assignee", "", "filter by assignee")
 	cmd.Flags().StringVar(&priority, "priority", "", "filter by priority")
 	cmd.Flags().StringVar(&beadType, "type", "", "filter by bead type")
+	cmd.Flags().BoolVar(&claim, "claim", false, "claim the ready bead and print it")
 
 	return cmd
 }
+
+// waitReady blocks until a ready bead matching f exists. With claim, it
+// instead keeps trying to claim one for the current user and returns the
+// claimed bead; otherwise it returns nil. It returns errTimeout once ctx is
+// done.
+func waitReady(ctx context.Context, c *client.Client, f client.ClaimFilters, claim bool) (*model.Bead, error) {
+	var claimed *model.Bead
+	var err error
+	if claim {
+		var b model.Bead
+		if b, err = c.WaitClaim(ctx, f); err == nil {
+			claimed = &b
+		}
+	} else {
+		err = c.WaitReady(ctx, f)
+	}
+	if ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		return nil, errTimeout
+	}
+	return claimed, err
+}
@@ -7,6 +7,8 @@ import (
 	"os"
 	"testing"
 	"time"
+
+	"github.com/vector76/beads_server/model"
 )
 
 type waitReadyResult struct {
@@ -27,6 +29,13 @@ func runWaitReadyCmd(t *testing.T, args ...string) (string, string, error) {
 	return outBuf.String(), errBuf.String(), err
 }
 
+// withSSERetryDelays replaces the reconnect schedule for the duration of a test.
+func withSSERetryDelays(t *testing.T, delays ...time.Duration) {
+	t.Helper()
+	sseRetryDelays = delays
+	t.Cleanup(func() { sseRetryDelays = nil })
+}
+
 // TestWaitReady_ImmediatelyReady verifies that the command exits 0 when a ready bead
 // already exists before the SSE connection is established.
 func TestWaitReady_ImmediatelyReady(t *testing.T) {
@@ -275,8 +284,10 @@ func TestWaitReady_ConnectivityError(t *testing.T) {
 }
 
 // TestWaitReady_SSEDropMidWait verifies that the command exits non-zero with non-empty
-// stderr when the SSE connection is dropped while the command is waiting.
+// stderr when the SSE connection is dropped while the command is waiting and
+// the server does not come back.
 func TestWaitReady_SSEDropMidWait(t *testing.T) {
+	withSSERetryDelays(t, 10*time.Millisecond, 10*time.Millisecond)
 	ts := startTestServer(t)
 	// startTestServer registers t.Cleanup(ts.Close); closing early is safe — idempotent.
 	setClientEnv(t, ts.URL)
@@ -292,10 +303,11 @@ func TestWaitReady_SSEDropMidWait(t *testing.T) {
 		ch <- waitReadyResult{err, errBuf.String()}
 	}()
 
-	// Wait for the SSE connection to be established, then immediately drop all
-	// active connections. CloseClientConnections does not block like ts.Close().
+	// Wait for the SSE connection to be established, then drop all active
+	// connections and shut the server down so reconnects fail.
 	time.Sleep(100 * time.Millisecond)
 	ts.CloseClientConnections()
+	ts.Close()
 
 	select {
 	case r := <-ch:
@@ -309,3 +321,72 @@ func TestWaitReady_SSEDropMidWait(t *testing.T) {
 		t.Fatal("timed out waiting for wait-ready to detect server close")
 	}
 }
+
+// TestWaitReady_ClaimPrintsClaimedBead verifies that --claim claims the ready
+// bead for the current user and prints it.
+func TestWaitReady_ClaimPrintsClaimedBead(t *testing.T) {
+	ts := startTestServer(t)
+	setClientEnv(t, ts.URL)
+	os.Setenv("BS_USER", "agent-9")
+	t.Cleanup(func() { os.Unsetenv("BS_USER") })
+
+	b := parseBeadFromOutput(t, runCmd(t, "add", "Ready bead"))
+
+	stdout, _, err := runWaitReadyCmd(t, "--timeout", "5", "--claim")
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	got := parseBeadFromOutput(t, stdout)
+	if got.ID != b.ID || got.Assignee != "agent-9" {
+		t.Errorf("expected %s claimed by agent-9, got id=%q assignee=%q", b.ID, got.ID, got.Assignee)
+	}
+
+	// The only bead is now claimed, so a second waiter times out.
+	_, _, err = runWaitReadyCmd(t, "--timeout", "1", "--claim")
+	if !errors.Is(err, errTimeout) {
+		t.Fatalf("expected errTimeout, got %v", err)
+	}
+}
+
+// TestWaitReady_ClaimWaitsForReadyBead verifies that --claim blocks until a bead
+// becomes ready and then wins it.
+func TestWaitReady_ClaimWaitsForReadyBead(t *testing.T) {
+	ts := startTestServer(t)
+	setClientEnv(t, ts.URL)
+
+	blocker := parseBeadFromOutput(t, runCmd(t, "add", "Blocker"))
+	blocked := parseBeadFromOutput(t, runCmd(t, "add", "Blocked bead"))
+	runCmd(t, "link", blocked.ID, "--blocked-by", blocker.ID)
+	runCmd(t, "claim", blocker.ID)
+
+	type claimResult struct {
+		waitReadyResult
+		stdout string
+	}
+	ch := make(chan claimResult, 1)
+	go func() {
+		cmd := NewRootCmd()
+		outBuf := new(bytes.Buffer)
+		errBuf := new(bytes.Buffer)
+		cmd.SetOut(outBuf)
+		cmd.SetErr(errBuf)
+		cmd.SetArgs([]string{"wait-ready", "--timeout", "10", "--claim"})
+		err := cmd.Execute()
+		ch <- claimResult{waitReadyResult{err, errBuf.String()}, outBuf.String()}
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	runCmd(t, "close", blocker.ID)
+
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			t.Fatalf("expected nil error after blocker closed, got %v (stderr: %q)", r.err, r.stderr)
+		}
+		if got := parseBeadFromOutput(t, r.stdout); got.ID != blocked.ID || got.Status != model.StatusInProgress {
+			t.Errorf("expected %s to be claimed, got id=%q status=%q", blocked.ID, got.ID, got.Status)
+		}
+	case <-time.After(15 * time.Second):
+		t.Fatal("timed out waiting for wait-ready --claim to complete")
+	}
+}
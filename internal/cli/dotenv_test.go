@@ -3,6 +3,7 @@ package cli
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -220,6 +221,21 @@ func TestNewClientFromEnv_EnvOverridesDotenv(t *testing.T) {
 	}
 }
 
+func TestNewClientFromEnv_ProjectTokens(t *testing.T) {
+	dir := t.TempDir()
+	writeDotenv(t, dir, "BS_TOKEN=secret\nBS_PROJECT_TOKENS=tok-a, tok-b,\n")
+	os.Unsetenv("BS_TOKEN")
+	os.Unsetenv("BS_PROJECT_TOKENS")
+
+	c, err := NewClientFromEnv()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(c.ProjectTokens, []string{"tok-a", "tok-b"}) {
+		t.Errorf("ProjectTokens = %q, want [tok-a tok-b]", c.ProjectTokens)
+	}
+}
+
 func TestNewClientFromEnv_MissingTokenEverywhere(t *testing.T) {
 	dir := t.TempDir()
 	writeDotenv(t, dir, "OTHER=val\n")
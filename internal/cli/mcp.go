@@ -0,0 +1,502 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vector76/beads_server/client"
+	"github.com/vector76/beads_server/model"
+)
+
+// mcpProtocolVersions lists the MCP revisions the server speaks, newest
+// first. A client asking for any other revision is offered the newest.
+var mcpProtocolVersions = []string{"2025-06-18", "2025-03-26", "2024-11-05"}
+
+// JSON-RPC error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+)
+
+// mcpMaxMessage bounds a single JSON-RPC message read from stdin.
+const mcpMaxMessage = 16 << 20
+
+// defaultWaitReadyTimeout applies when the wait_ready tool is called
+// without timeout_seconds; maxWaitReadyTimeout caps it.
+const (
+	defaultWaitReadyTimeout = 60
+	maxWaitReadyTimeout     = 3600
+)
+
+func newMCPCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "mcp",
+		Short: "Run a Model Context Protocol server on stdin/stdout",
+		Long: `Run a Model Context Protocol server on stdin/stdout.
+
+Agent frameworks that speak MCP can start "bs mcp" as a stdio server and
+use beads through typed tools instead of parsing CLI output. The tools call
+the server at BS_URL with BS_TOKEN and act as BS_USER, read from the
+environment or a .env file like every other client command.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			c, err := NewClientFromEnv()
+			if err != nil {
+				return err
+			}
+			return newMCPServer(c).serve(cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+}
+
+// rpcRequest is an incoming JSON-RPC message. Notifications have no ID.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// rpcResponse answers a request with either a result or an error.
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// mcpTool is a tool advertised by tools/list. call receives the tool's
+// arguments and returns the JSON to show the agent.
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+	call        func(ctx context.Context, args json.RawMessage) (any, error)
+}
+
+// mcpContent is one item of a tool result.
+type mcpContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// mcpToolResult is the result of tools/call. Failures reported by the beads
+// server are tool results with IsError set, so the agent can read them.
+type mcpToolResult struct {
+	Content []mcpContent `json:"content"`
+	IsError bool         `json:"isError"`
+}
+
+// mcpServer serves MCP requests over a pair of streams. Tool calls run
+// concurrently, so a long wait_ready does not hold up other requests.
+type mcpServer struct {
+	client *client.Client
+	tools  []mcpTool
+
+	writeMu sync.Mutex
+	enc     *json.Encoder
+
+	mu       sync.Mutex
+	inflight map[string]context.CancelFunc // by request ID
+	wg       sync.WaitGroup
+}
+
+func newMCPServer(c *client.Client) *mcpServer {
+	s := &mcpServer{client: c, inflight: map[string]context.CancelFunc{}}
+	s.tools = s.buildTools()
+	return s
+}
+
+// serve reads newline-delimited JSON-RPC messages from r until it is
+// exhausted, writing responses to w. Tool calls still running at the end
+// are allowed to finish.
+func (s *mcpServer) serve(r io.Reader, w io.Writer) error {
+	s.enc = json.NewEncoder(w)
+	defer s.wg.Wait()
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), mcpMaxMessage)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var req rpcRequest
+		if err := json.Unmarshal(line, &req); err != nil {
+			s.reply(json.RawMessage("null"), nil, &rpcError{Code: rpcParseError, Message: err.Error()})
+			continue
+		}
+		s.handle(req)
+	}
+	return sc.Err()
+}
+
+// reply writes a response. Responses to notifications are dropped.
+func (s *mcpServer) reply(id json.RawMessage, result any, rerr *rpcError) {
+	if len(id) == 0 {
+		return
+	}
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	s.enc.Encode(rpcResponse{JSONRPC: "2.0", ID: id, Result: result, Error: rerr})
+}
+
+func (s *mcpServer) handle(req rpcRequest) {
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		s.reply(req.ID, nil, &rpcError{Code: rpcInvalidRequest, Message: "not a JSON-RPC 2.0 request"})
+		return
+	}
+
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(req.Params, &params)
+		negotiated := mcpProtocolVersions[0]
+		if slices.Contains(mcpProtocolVersions, params.ProtocolVersion) {
+			negotiated = params.ProtocolVersion
+		}
+		s.reply(req.ID, map[string]any{
+			"protocolVersion": negotiated,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": "bs", "version": version},
+		}, nil)
+	case "ping":
+		s.reply(req.ID, struct{}{}, nil)
+	case "tools/list":
+		s.reply(req.ID, map[string]any{"tools": s.tools}, nil)
+	case "tools/call":
+		s.startCall(req)
+	case "notifications/cancelled":
+		var params struct {
+			RequestID json.RawMessage `json:"requestId"`
+		}
+		json.Unmarshal(req.Params, &params)
+		s.mu.Lock()
+		if cancel, ok := s.inflight[string(params.RequestID)]; ok {
+			cancel()
+		}
+		s.mu.Unlock()
+	default:
+		// Other notifications, such as notifications/initialized, need
+		// no action.
+		s.reply(req.ID, nil, &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + req.Method})
+	}
+}
+
+// startCall runs a tools/call request in the background.
+func (s *mcpServer) startCall(req rpcRequest) {
+	var params struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		s.reply(req.ID, nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()})
+		return
+	}
+	i := slices.IndexFunc(s.tools, func(t mcpTool) bool { return t.Name == params.Name })
+	if i < 0 {
+		s.reply(req.ID, nil, &rpcError{Code: rpcInvalidParams, Message: "unknown tool: " + params.Name})
+		return
+	}
+	tool := s.tools[i]
+	if len(params.Arguments) == 0 || string(params.Arguments) == "null" {
+		params.Arguments = json.RawMessage("{}")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	key := string(req.ID)
+	s.mu.Lock()
+	s.inflight[key] = cancel
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.inflight, key)
+			s.mu.Unlock()
+			cancel()
+		}()
+
+		result, err := tool.call(ctx, params.Arguments)
+		if ctx.Err() == context.Canceled {
+			// The client gave up on this request and expects no reply.
+			return
+		}
+		if err != nil {
+			s.reply(req.ID, mcpToolResult{Content: []mcpContent{{Type: "text", Text: err.Error()}}, IsError: true}, nil)
+			return
+		}
+		text, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			s.reply(req.ID, mcpToolResult{Content: []mcpContent{{Type: "text", Text: err.Error()}}, IsError: true}, nil)
+			return
+		}
+		s.reply(req.ID, mcpToolResult{Content: []mcpContent{{Type: "text", Text: string(text)}}}, nil)
+	}()
+}
+
+// Schema helpers for tool inputs.
+
+func objectSchema(required []string, props map[string]any) map[string]any {
+	schema := map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func stringSchema(desc string, enum ...string) map[string]any {
+	schema := map[string]any{"type": "string", "description": desc}
+	if len(enum) > 0 {
+		schema["enum"] = enum
+	}
+	return schema
+}
+
+func stringArraySchema(desc string) map[string]any {
+	return map[string]any{"type": "array", "items": map[string]any{"type": "string"}, "description": desc}
+}
+
+func boolSchema(desc string) map[string]any {
+	return map[string]any{"type": "boolean", "description": desc}
+}
+
+func intSchema(desc string, min, max int) map[string]any {
+	return map[string]any{"type": "integer", "description": desc, "minimum": min, "maximum": max}
+}
+
+var (
+	mcpStatuses   = []string{string(model.StatusOpen), string(model.StatusInProgress), string(model.StatusClosed), string(model.StatusDeleted), string(model.StatusNotReady)}
+	mcpPriorities = []string{string(model.PriorityCritical), string(model.PriorityHigh), string(model.PriorityMedium), string(model.PriorityLow), string(model.PriorityNone)}
+	mcpTypes      = []string{string(model.TypeBug), string(model.TypeFeature), string(model.TypeTask), string(model.TypeChore)}
+)
+
+// beadArgs is the argument of the tools that act on a single bead.
+type beadArgs struct {
+	ID string `json:"id"`
+}
+
+// decodeArgs unmarshals tool arguments and checks that required string
+// fields are set.
+func decodeArgs(raw json.RawMessage, v any, required ...string) error {
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	var fields map[string]any
+	json.Unmarshal(raw, &fields)
+	for _, name := range required {
+		if s, _ := fields[name].(string); s == "" {
+			return fmt.Errorf("%s is required", name)
+		}
+	}
+	return nil
+}
+
+func (s *mcpServer) buildTools() []mcpTool {
+	c := s.client
+	idSchema := stringSchema("bead ID, e.g. bd-a1b2")
+	oneBead := func(call func(id string) (any, error)) func(context.Context, json.RawMessage) (any, error) {
+		return func(_ context.Context, raw json.RawMessage) (any, error) {
+			var args beadArgs
+			if err := decodeArgs(raw, &args, "id"); err != nil {
+				return nil, err
+			}
+			return call(args.ID)
+		}
+	}
+
+	return []mcpTool{
+		{
+			Name:        "list_beads",
+			Description: "List beads, with a total count for paging. Closed and deleted beads are left out unless all or status asks for them.",
+			InputSchema: objectSchema(nil, map[string]any{
+				"status":   stringSchema("only beads with this status", mcpStatuses...),
+				"priority": stringSchema("only beads with this priority", mcpPriorities...),
+				"type":     stringSchema("only beads of this type", mcpTypes...),
+				"tag":      stringSchema("only beads with this tag"),
+				"assignee": stringSchema("only beads assigned to this user"),
+				"ready":    boolSchema("only open beads with no active blockers"),
+				"all":      boolSchema("include closed and deleted beads"),
+				"page":     intSchema("page number, starting at 1", 1, 1<<20),
+				"per_page": intSchema("beads per page", 1, 1000),
+			}),
+			call: func(_ context.Context, raw json.RawMessage) (any, error) {
+				var args struct {
+					Status   model.Status
+					Priority model.Priority
+					Type     model.BeadType
+					Tag      string
+					Assignee string
+					Ready    bool
+					All      bool
+					Page     int
+					PerPage  int `json:"per_page"`
+				}
+				if err := decodeArgs(raw, &args); err != nil {
+					return nil, err
+				}
+				f := client.ListFilters{
+					Priority: args.Priority,
+					Type:     args.Type,
+					Assignee: args.Assignee,
+					Ready:    args.Ready,
+					All:      args.All,
+					Page:     args.Page,
+					PerPage:  args.PerPage,
+				}
+				if args.Status != "" {
+					f.Statuses = []model.Status{args.Status}
+				}
+				if args.Tag != "" {
+					f.Tags = []string{args.Tag}
+				}
+				return c.List(f)
+			},
+		},
+		{
+			Name:        "show_bead",
+			Description: "Show a bead with its description, comments and dependencies.",
+			InputSchema: objectSchema([]string{"id"}, map[string]any{"id": idSchema}),
+			call: oneBead(func(id string) (any, error) {
+				return c.Get(id)
+			}),
+		},
+		{
+			Name:        "add_bead",
+			Description: "Create a bead. Give parent_id to create it as a child of an epic.",
+			InputSchema: objectSchema([]string{"title"}, map[string]any{
+				"title":       stringSchema("one-line summary"),
+				"description": stringSchema("longer description, in markdown"),
+				"type":        stringSchema("bead type (default task)", mcpTypes...),
+				"priority":    stringSchema("priority (default medium)", mcpPriorities...),
+				"tags":        stringArraySchema("tags"),
+				"parent_id":   stringSchema("ID of the epic to create the bead in"),
+				"status":      stringSchema("initial status (default open); not_ready keeps it out of the ready queue", string(model.StatusOpen), string(model.StatusNotReady)),
+			}),
+			call: func(_ context.Context, raw json.RawMessage) (any, error) {
+				var args client.CreateRequest
+				if err := decodeArgs(raw, &args, "title"); err != nil {
+					return nil, err
+				}
+				return c.Create(args)
+			},
+		},
+		{
+			Name:        "claim_bead",
+			Description: "Claim a bead for the current user (BS_USER), setting it in_progress.",
+			InputSchema: objectSchema([]string{"id"}, map[string]any{"id": idSchema}),
+			call: oneBead(func(id string) (any, error) {
+				return c.Claim(id)
+			}),
+		},
+		{
+			Name:        "comment_bead",
+			Description: "Add a comment to a bead as the current user (BS_USER).",
+			InputSchema: objectSchema([]string{"id", "text"}, map[string]any{
+				"id":   idSchema,
+				"text": stringSchema("comment text, in markdown"),
+			}),
+			call: func(_ context.Context, raw json.RawMessage) (any, error) {
+				var args struct{ ID, Text string }
+				if err := decodeArgs(raw, &args, "id", "text"); err != nil {
+					return nil, err
+				}
+				return c.Comment(args.ID, args.Text)
+			},
+		},
+		{
+			Name:        "close_bead",
+			Description: "Close a bead, marking its work done.",
+			InputSchema: objectSchema([]string{"id"}, map[string]any{"id": idSchema}),
+			call: oneBead(func(id string) (any, error) {
+				return c.Close(id)
+			}),
+		},
+		{
+			Name:        "link_beads",
+			Description: "Record that a bead is blocked by another bead.",
+			InputSchema: objectSchema([]string{"id", "blocked_by"}, map[string]any{
+				"id":         idSchema,
+				"blocked_by": stringSchema("ID of the bead that must be closed first"),
+			}),
+			call: func(_ context.Context, raw json.RawMessage) (any, error) {
+				var args struct {
+					ID        string
+					BlockedBy string `json:"blocked_by"`
+				}
+				if err := decodeArgs(raw, &args, "id", "blocked_by"); err != nil {
+					return nil, err
+				}
+				return c.Link(args.ID, args.BlockedBy)
+			},
+		},
+		{
+			Name:        "get_deps",
+			Description: "Show a bead's active and resolved blockers, and the beads it blocks.",
+			InputSchema: objectSchema([]string{"id"}, map[string]any{"id": idSchema}),
+			call: oneBead(func(id string) (any, error) {
+				return c.Deps(id)
+			}),
+		},
+		{
+			Name: "wait_ready",
+			Description: "Wait until a ready bead matching the filters exists. With claim, wait until one " +
+				"has been claimed for the current user and return it. Returns {\"ready\": false} on timeout.",
+			InputSchema: objectSchema(nil, map[string]any{
+				"timeout_seconds": intSchema(fmt.Sprintf("how long to wait (default %d)", defaultWaitReadyTimeout), 1, maxWaitReadyTimeout),
+				"tags":            stringArraySchema("only beads with all of these tags"),
+				"assignee":        stringSchema("only beads assigned to this user"),
+				"priority":        stringSchema("only beads with this priority", mcpPriorities...),
+				"type":            stringSchema("only beads of this type", mcpTypes...),
+				"claim":           boolSchema("claim the bead for the current user"),
+			}),
+			call: func(ctx context.Context, raw json.RawMessage) (any, error) {
+				var args struct {
+					TimeoutSeconds int `json:"timeout_seconds"`
+					client.ClaimFilters
+					Claim bool
+				}
+				if err := decodeArgs(raw, &args); err != nil {
+					return nil, err
+				}
+				timeout := args.TimeoutSeconds
+				if timeout <= 0 {
+					timeout = defaultWaitReadyTimeout
+				}
+				timeout = min(timeout, maxWaitReadyTimeout)
+
+				ctx, cancel := context.WithTimeout(ctx, time.Duration(timeout)*time.Second)
+				defer cancel()
+				b, err := waitReady(ctx, c, args.ClaimFilters, args.Claim)
+				switch {
+				case errors.Is(err, errTimeout):
+					return map[string]bool{"ready": false}, nil
+				case err != nil:
+					return nil, err
+				case b != nil:
+					return b, nil
+				}
+				return map[string]bool{"ready": true}, nil
+			},
+		},
+	}
+}
@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// mcpSession drives an MCP server over pipes.
+type mcpSession struct {
+	t      *testing.T
+	in     *io.PipeWriter
+	out    *bufio.Scanner
+	nextID int
+}
+
+func startMCP(t *testing.T) *mcpSession {
+	t.Helper()
+	c, err := NewClientFromEnv()
+	if err != nil {
+		t.Fatalf("NewClientFromEnv: %v", err)
+	}
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	s := &mcpSession{t: t, in: inW, out: bufio.NewScanner(outR)}
+	go func() {
+		newMCPServer(c).serve(inR, outW)
+		outW.Close()
+	}()
+	t.Cleanup(func() { inW.Close() })
+	return s
+}
+
+func (s *mcpSession) send(msg string) {
+	s.t.Helper()
+	if _, err := io.WriteString(s.in, msg+"\n"); err != nil {
+		s.t.Fatalf("writing to MCP server: %v", err)
+	}
+}
+
+// call sends a request and returns the response with the same ID.
+func (s *mcpSession) call(method string, params any) rpcResponse {
+	s.t.Helper()
+	s.nextID++
+	p, _ := json.Marshal(params)
+	s.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":%d,"method":%q,"params":%s}`, s.nextID, method, p))
+	return s.read()
+}
+
+func (s *mcpSession) read() rpcResponse {
+	s.t.Helper()
+	lines := make(chan string, 1)
+	go func() {
+		if s.out.Scan() {
+			lines <- s.out.Text()
+		}
+		close(lines)
+	}()
+	select {
+	case line, ok := <-lines:
+		if !ok {
+			s.t.Fatal("MCP server closed its output")
+		}
+		var resp rpcResponse
+		if err := json.Unmarshal([]byte(line), &resp); err != nil {
+			s.t.Fatalf("decoding %s: %v", line, err)
+		}
+		return resp
+	case <-time.After(5 * time.Second):
+		s.t.Fatal("no response from MCP server")
+	}
+	return rpcResponse{}
+}
+
+// callTool calls a tool and returns its text and whether it is an error.
+func (s *mcpSession) callTool(name string, args map[string]any) (string, bool) {
+	s.t.Helper()
+	resp := s.call("tools/call", map[string]any{"name": name, "arguments": args})
+	if resp.Error != nil {
+		s.t.Fatalf("%s: rpc error %+v", name, resp.Error)
+	}
+	var result mcpToolResult
+	data, _ := json.Marshal(resp.Result)
+	json.Unmarshal(data, &result)
+	if len(result.Content) != 1 {
+		s.t.Fatalf("%s: result = %+v", name, result)
+	}
+	return result.Content[0].Text, result.IsError
+}
+
+func TestMCP_InitializeAndListTools(t *testing.T) {
+	ts := startTestServer(t)
+	setClientEnv(t, ts.URL)
+	s := startMCP(t)
+
+	resp := s.call("initialize", map[string]any{"protocolVersion": "2025-03-26", "capabilities": map[string]any{}})
+	result, _ := resp.Result.(map[string]any)
+	if resp.Error != nil || result["protocolVersion"] != "2025-03-26" {
+		t.Fatalf("initialize = %+v", resp)
+	}
+	s.send(`{"jsonrpc":"2.0","method":"notifications/initialized"}`)
+
+	resp = s.call("tools/list", map[string]any{})
+	var list struct {
+		Tools []struct {
+			Name        string         `json:"name"`
+			InputSchema map[string]any `json:"inputSchema"`
+		} `json:"tools"`
+	}
+	data, _ := json.Marshal(resp.Result)
+	json.Unmarshal(data, &list)
+	var names []string
+	for _, tool := range list.Tools {
+		names = append(names, tool.Name)
+		if tool.InputSchema["type"] != "object" {
+			t.Errorf("%s schema = %v", tool.Name, tool.InputSchema)
+		}
+	}
+	want := "list_beads show_bead add_bead claim_bead comment_bead close_bead link_beads get_deps wait_ready"
+	if strings.Join(names, " ") != want {
+		t.Errorf("tools = %v, want %s", names, want)
+	}
+
+	if resp := s.call("resources/list", nil); resp.Error == nil || resp.Error.Code != rpcMethodNotFound {
+		t.Errorf("unknown method = %+v", resp)
+	}
+	if resp := s.call("ping", nil); resp.Error != nil {
+		t.Errorf("ping = %+v", resp)
+	}
+}
+
+func TestMCP_Tools(t *testing.T) {
+	ts := startTestServer(t)
+	setClientEnv(t, ts.URL)
+	t.Setenv("BS_USER", "agent-7")
+	s := startMCP(t)
+
+	text, isErr := s.callTool("add_bead", map[string]any{"title": "Blocker", "priority": "high"})
+	if isErr {
+		t.Fatalf("add_bead: %s", text)
+	}
+	var blocker model.Bead
+	json.Unmarshal([]byte(text), &blocker)
+	text, _ = s.callTool("add_bead", map[string]any{"title": "Work", "tags": []string{"api"}})
+	var work model.Bead
+	json.Unmarshal([]byte(text), &work)
+
+	if text, isErr = s.callTool("link_beads", map[string]any{"id": work.ID, "blocked_by": blocker.ID}); isErr {
+		t.Fatalf("link_beads: %s", text)
+	}
+	text, _ = s.callTool("get_deps", map[string]any{"id": work.ID})
+	if !strings.Contains(text, blocker.ID) {
+		t.Errorf("get_deps = %s", text)
+	}
+
+	text, _ = s.callTool("list_beads", map[string]any{"ready": true})
+	var list struct {
+		Total int `json:"total"`
+	}
+	json.Unmarshal([]byte(text), &list)
+	if list.Total != 1 {
+		t.Errorf("ready beads = %s, want only the blocker", text)
+	}
+
+	text, _ = s.callTool("claim_bead", map[string]any{"id": blocker.ID})
+	var claimed model.Bead
+	json.Unmarshal([]byte(text), &claimed)
+	if claimed.Assignee != "agent-7" || claimed.Status != model.StatusInProgress {
+		t.Errorf("claim_bead = %s", text)
+	}
+	s.callTool("comment_bead", map[string]any{"id": blocker.ID, "text": "done"})
+	s.callTool("close_bead", map[string]any{"id": blocker.ID})
+
+	text, _ = s.callTool("show_bead", map[string]any{"id": blocker.ID})
+	var shown model.Bead
+	json.Unmarshal([]byte(text), &shown)
+	if shown.Status != model.StatusClosed || len(shown.Comments) != 1 || shown.Comments[0].Author != "agent-7" {
+		t.Errorf("show_bead = %s", text)
+	}
+
+	// wait_ready returns at once now that the blocker is closed.
+	text, _ = s.callTool("wait_ready", map[string]any{"timeout_seconds": 5, "tags": []string{"api"}, "claim": true})
+	json.Unmarshal([]byte(text), &claimed)
+	if claimed.ID != work.ID || claimed.Assignee != "agent-7" {
+		t.Errorf("wait_ready claim = %s", text)
+	}
+	if text, _ = s.callTool("wait_ready", map[string]any{"timeout_seconds": 1}); !strings.Contains(text, `"ready": false`) {
+		t.Errorf("wait_ready timeout = %s", text)
+	}
+
+	// Server errors come back as tool errors; bad calls as RPC errors.
+	if text, isErr = s.callTool("show_bead", map[string]any{"id": "bd-none"}); !isErr {
+		t.Errorf("show_bead of a missing bead = %s, want a tool error", text)
+	}
+	if text, isErr = s.callTool("add_bead", map[string]any{}); !isErr || !strings.Contains(text, "title") {
+		t.Errorf("add_bead without title = %s", text)
+	}
+	if resp := s.call("tools/call", map[string]any{"name": "explode"}); resp.Error == nil || resp.Error.Code != rpcInvalidParams {
+		t.Errorf("unknown tool = %+v", resp)
+	}
+}
@@ -0,0 +1,192 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/vector76/beads_server/internal/project"
+	"github.com/vector76/beads_server/internal/server"
+	"github.com/vector76/beads_server/internal/store"
+)
+
+// projectsPollInterval is how often a multi-project server checks whether
+// its projects file has changed.
+var projectsPollInterval = 2 * time.Second
+
+// storeKey identifies an open store by what it was opened from.
+type storeKey struct {
+	backend  string
+	dataFile string
+}
+
+// projectsLoader loads the projects file, keeping one open store per data
+// file across reloads so that a project whose data file is unchanged keeps
+// its store, and with it its event subscribers and waiting claims.
+type projectsLoader struct {
+	mu     sync.Mutex // serializes loads from the watcher and the admin API
+	path   string
+	stores map[storeKey]store.Backend
+
+	// modTime and size are the projects file's as of the last load, so
+	// the poller can tell when it changes.
+	modTime time.Time
+	size    int64
+}
+
+func newProjectsLoader(path string) *projectsLoader {
+	return &projectsLoader{path: path, stores: make(map[storeKey]store.Backend)}
+}
+
+// load reads and validates the projects file and passes its projects,
+// apart from archived ones, to apply. Stores for new data files are
+// opened, the rest reused. If apply succeeds, a function closing the
+// stores no longer used is passed to retire, which decides when to call
+// it; a nil retire calls it at once. If apply fails, the newly opened
+// stores are closed and the loader is left as it was.
+func (l *projectsLoader) load(apply func([]server.ProviderEntry, []server.Webhook) error, retire func(close func())) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if info, err := os.Stat(l.path); err == nil {
+		l.modTime, l.size = info.ModTime(), info.Size()
+	}
+	entries, err := project.LoadProjectsFile(l.path)
+	if err != nil {
+		return err
+	}
+
+	next := make(map[storeKey]store.Backend)
+	opened := make(map[storeKey]store.Backend)
+	closeOpened := func() {
+		for _, s := range opened {
+			s.Close()
+		}
+	}
+
+	var providerEntries []server.ProviderEntry
+	var webhooks []server.Webhook
+	for _, e := range entries {
+		if e.Archived {
+			continue
+		}
+		backend := e.Backend
+		if backend == "" {
+			backend = store.BackendJSON
+		}
+		key := storeKey{backend: backend, dataFile: e.DataFile}
+		s, ok := l.stores[key]
+		if !ok {
+			s, ok = opened[key]
+		}
+		if !ok {
+			s, err = store.Open(e.Backend, e.DataFile)
+			if err != nil {
+				closeOpened()
+				return fmt.Errorf("loading data file for project %q: %w", e.Name, err)
+			}
+			opened[key] = s
+		}
+		next[key] = s
+
+		providerEntries = append(providerEntries, server.ProviderEntry{
+			Name:      e.Name,
+			Token:     e.Token,
+			TokenHash: e.TokenHash,
+			Users:     e.Users,
+			Store:     s,
+		})
+		for _, w := range e.Webhooks {
+			webhooks = append(webhooks, server.Webhook{
+				Project: e.Name,
+				URL:     w.URL,
+				Secret:  w.Secret,
+				Events:  w.Events,
+			})
+		}
+	}
+
+	if err := apply(providerEntries, webhooks); err != nil {
+		closeOpened()
+		return err
+	}
+	var retired []store.Backend
+	for key, s := range l.stores {
+		if _, ok := next[key]; !ok {
+			retired = append(retired, s)
+		}
+	}
+	l.stores = next
+	if len(retired) > 0 {
+		closeRetired := func() {
+			for _, s := range retired {
+				s.Close()
+			}
+		}
+		if retire == nil {
+			closeRetired()
+		} else {
+			retire(closeRetired)
+		}
+	}
+	return nil
+}
+
+// apply loads the projects file into srv. Stores it no longer uses are
+// closed once the requests that may still be using them have finished.
+func (l *projectsLoader) apply(srv *server.Server) error {
+	return l.load(func(entries []server.ProviderEntry, hooks []server.Webhook) error {
+		return srv.ReloadProjects(entries, hooks)
+	}, srv.AfterRequests)
+}
+
+// reload loads the projects file into srv, logging the outcome. A file that
+// fails to load or validate leaves the running projects as they were.
+func (l *projectsLoader) reload(srv *server.Server, logger *log.Logger) {
+	if err := l.apply(srv); err != nil {
+		logger.Printf("reloading %s: %v; keeping the current projects", l.path, err)
+		return
+	}
+	logger.Printf("reloaded %s", l.path)
+}
+
+// changed reports whether the projects file has been modified since it was
+// last loaded.
+func (l *projectsLoader) changed() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return false
+	}
+	return !info.ModTime().Equal(l.modTime) || info.Size() != l.size
+}
+
+// watch reloads srv's projects when the projects file changes or the process
+// receives SIGHUP, until ctx is done.
+func (l *projectsLoader) watch(ctx context.Context, srv *server.Server, logger *log.Logger) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(projectsPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			l.reload(srv, logger)
+		case <-ticker.C:
+			if l.changed() {
+				l.reload(srv, logger)
+			}
+		}
+	}
+}
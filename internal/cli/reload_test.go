@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vector76/beads_server/internal/server"
+	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
+)
+
+// writeProjects writes a projects file with one project per name, each
+// with token "tok-" plus the name and a JSON data file in dir.
+func writeProjects(t *testing.T, path string, names ...string) {
+	t.Helper()
+	var entries []string
+	for _, n := range names {
+		entries = append(entries, fmt.Sprintf(`{"name": %q, "token": "tok-%s", "data_file": %q}`,
+			n, n, filepath.Join(filepath.Dir(path), n+".json")))
+	}
+	data := `{"projects": [` + strings.Join(entries, ", ") + `]}`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+// reloadServer starts a multi-project server from the projects file at path
+// and returns it with its loader.
+func reloadServer(t *testing.T, path string) (*server.Server, *projectsLoader) {
+	t.Helper()
+	loader := newProjectsLoader(path)
+	var srv *server.Server
+	err := loader.load(func(entries []server.ProviderEntry, hooks []server.Webhook) error {
+		var err error
+		srv, err = server.New(server.Config{LogOutput: io.Discard, Webhooks: hooks}, server.NewMultiStoreProvider(entries))
+		return err
+	}, nil)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	return srv, loader
+}
+
+func listStatus(srv *server.Server, token string) int {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/beads", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestProjectsLoader_ReloadKeepsUnchangedStores(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "projects.json")
+	writeProjects(t, path, "alpha", "beta")
+	srv, loader := reloadServer(t, path)
+	alpha := loader.stores[storeKey{"json", filepath.Join(filepath.Dir(path), "alpha.json")}]
+
+	writeProjects(t, path, "alpha", "gamma")
+	var logs bytes.Buffer
+	loader.reload(srv, log.New(&logs, "", 0))
+
+	if !strings.Contains(logs.String(), "reloaded") {
+		t.Errorf("log = %q, want a reload message", logs.String())
+	}
+	if got := listStatus(srv, "tok-gamma"); got != http.StatusOK {
+		t.Errorf("added project: got %d, want 200", got)
+	}
+	if got := listStatus(srv, "tok-beta"); got != http.StatusUnauthorized {
+		t.Errorf("removed project: got %d, want 401", got)
+	}
+	if len(loader.stores) != 2 {
+		t.Errorf("open stores = %d, want 2", len(loader.stores))
+	}
+	if loader.stores[storeKey{"json", filepath.Join(filepath.Dir(path), "alpha.json")}] != alpha {
+		t.Error("alpha's store should be reused, not reopened")
+	}
+}
+
+func TestProjectsLoader_ReloadClosesStoresAfterRequests(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "projects.json")
+	betaFile := filepath.Join(dir, "beta.db")
+	projects := `{"projects": [
+		{"name": "alpha", "token": "tok-alpha", "data_file": %q},
+		{"name": "beta", "token": "tok-beta", "data_file": %q, "backend": "sqlite"}
+	]}`
+	os.WriteFile(path, []byte(fmt.Sprintf(projects, filepath.Join(dir, "alpha.json"), betaFile)), 0644)
+	srv, loader := reloadServer(t, path)
+	beta := loader.stores[storeKey{"sqlite", betaFile}]
+
+	// A request that is still using beta's store when beta is removed.
+	started, finish := make(chan struct{}), make(chan struct{})
+	var createErr error
+	srv.Router.Get("/test/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+		_, createErr = beta.Create(model.NewBead("Late"))
+	})
+	done := make(chan struct{})
+	go func() {
+		srv.Router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test/slow", nil))
+		close(done)
+	}()
+	<-started
+
+	writeProjects(t, path, "alpha")
+	loader.reload(srv, log.New(io.Discard, "", 0))
+	if got := listStatus(srv, "tok-beta"); got != http.StatusUnauthorized {
+		t.Errorf("removed project: got %d, want 401", got)
+	}
+
+	close(finish)
+	<-done
+	if createErr != nil {
+		t.Errorf("store closed under a running request: %v", createErr)
+	}
+	var notFound *store.NotFoundError
+	if _, err := beta.Get("bd-none"); err == nil || errors.As(err, &notFound) {
+		t.Errorf("Get after the request ended: err = %v, want the store closed", err)
+	}
+}
+
+func TestProjectsLoader_InvalidFileKeepsProjects(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "projects.json")
+	writeProjects(t, path, "alpha")
+	srv, loader := reloadServer(t, path)
+
+	os.WriteFile(path, []byte(`{"projects": [{"name": "alpha", "data_file": "a.json"}]}`), 0644)
+	var logs bytes.Buffer
+	loader.reload(srv, log.New(&logs, "", 0))
+
+	if !strings.Contains(logs.String(), "keeping the current projects") {
+		t.Errorf("log = %q, want the failure reported", logs.String())
+	}
+	if got := listStatus(srv, "tok-alpha"); got != http.StatusOK {
+		t.Errorf("after a failed reload: got %d, want 200", got)
+	}
+}
+
+func TestProjectsLoader_WatchPicksUpChanges(t *testing.T) {
+	old := projectsPollInterval
+	projectsPollInterval = 20 * time.Millisecond
+	t.Cleanup(func() { projectsPollInterval = old })
+
+	path := filepath.Join(t.TempDir(), "projects.json")
+	writeProjects(t, path, "alpha")
+	srv, loader := reloadServer(t, path)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		loader.watch(ctx, srv, log.New(io.Discard, "", 0))
+		close(done)
+	}()
+	t.Cleanup(func() { cancel(); <-done })
+
+	writeProjects(t, path, "alpha", "beta")
+	deadline := time.Now().Add(5 * time.Second)
+	for listStatus(srv, "tok-beta") != http.StatusOK {
+		if time.Now().After(deadline) {
+			t.Fatal("the new project was not picked up")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
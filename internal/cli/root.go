@@ -64,6 +64,14 @@ func NewRootCmd() *cobra.Command {
 	serveCmd.GroupID = "server"
 	root.AddCommand(serveCmd)
 
+	tokenCmd := newTokenCmd()
+	tokenCmd.GroupID = "server"
+	root.AddCommand(tokenCmd)
+
+	adminCmd := newAdminCmd()
+	adminCmd.GroupID = "server"
+	root.AddCommand(adminCmd)
+
 	for _, cmd := range []*cobra.Command{
 		newWhoamiCmd(),
 		newAddCmd(),
@@ -72,17 +80,25 @@ func NewRootCmd() *cobra.Command {
 		newStatusCmd("close", "closed"),
 		newStatusCmd("reopen", "open"),
 		newMoveCmd(),
+		newTransferCmd(),
 		newDeleteCmd(),
 		newCleanCmd(),
 		newListCmd(),
 		newSearchCmd(),
 		newClaimCmd(),
+		newNextCmd(),
+		newHeartbeatCmd(),
 		newMineCmd(),
 		newCommentCmd(),
 		newLinkCmd(),
 		newUnlinkCmd(),
 		newDepsCmd(),
+		newHistoryCmd(),
 		newWaitReadyCmd(),
+		newImportPlanCmd(),
+		newExportCmd(),
+		newImportCmd(),
+		newMCPCmd(),
 	} {
 		cmd.GroupID = "client"
 		root.AddCommand(cmd)
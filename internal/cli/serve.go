@@ -1,22 +1,35 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/vector76/beads_server/internal/project"
 	"github.com/vector76/beads_server/internal/server"
 	"github.com/vector76/beads_server/internal/store"
 )
 
+// shutdownTimeout bounds how long serve waits for requests in flight when
+// it is told to stop.
+const shutdownTimeout = 10 * time.Second
+
 func newServeCmd() *cobra.Command {
 	var port int
 	var dataFile string
 	var token string
 	var projectsFile string
+	var leaseTTL time.Duration
+	var webhooksFile string
+	var adminToken string
 
 	cmd := &cobra.Command{
 		Use:   "serve",
@@ -51,29 +64,50 @@ func newServeCmd() *cobra.Command {
 				}
 			}
 
+			// Resolve lease TTL: flag > env > default
+			if !cmd.Flags().Changed("lease-ttl") {
+				if envLease := os.Getenv("BS_LEASE_TTL"); envLease != "" {
+					d, err := time.ParseDuration(envLease)
+					if err != nil {
+						return fmt.Errorf("invalid BS_LEASE_TTL: %w", err)
+					}
+					leaseTTL = d
+				}
+			}
+			if leaseTTL < 0 {
+				return fmt.Errorf("lease TTL must not be negative")
+			}
+
+			// Resolve webhooks file: flag > env > default
+			if !cmd.Flags().Changed("webhooks-file") {
+				if envFile := os.Getenv("BS_WEBHOOKS_FILE"); envFile != "" {
+					webhooksFile = envFile
+				}
+			}
+
+			// Resolve admin token: flag > env
+			if adminToken == "" {
+				adminToken = os.Getenv("BS_ADMIN_TOKEN")
+			}
+			if adminToken != "" && projectsFile == "" {
+				return fmt.Errorf("--admin-token requires --projects")
+			}
+
 			var provider server.StoreProvider
+			var webhooks []server.Webhook
 
+			var loader *projectsLoader
 			if projectsFile != "" {
 				// Multi-project mode
-				entries, err := project.LoadProjectsFile(projectsFile)
+				loader = newProjectsLoader(projectsFile)
+				err := loader.load(func(entries []server.ProviderEntry, hooks []server.Webhook) error {
+					provider = server.NewMultiStoreProvider(entries)
+					webhooks = hooks
+					return nil
+				}, nil)
 				if err != nil {
 					return err
 				}
-
-				providerEntries := make([]server.ProviderEntry, len(entries))
-				for i, e := range entries {
-					s, err := store.Load(e.DataFile)
-					if err != nil {
-						return fmt.Errorf("loading data file for project %q: %w", e.Name, err)
-					}
-					providerEntries[i] = server.ProviderEntry{
-						Name:  e.Name,
-						Token: e.Token,
-						Store: s,
-					}
-				}
-
-				provider = server.NewMultiStoreProvider(providerEntries)
 			} else {
 				// Single-project mode
 				if !cmd.Flags().Changed("data-file") {
@@ -88,11 +122,29 @@ func newServeCmd() *cobra.Command {
 				}
 
 				provider = server.NewSingleStoreProvider(token, s)
+
+				// The webhook file defaults to sitting next to the data file.
+				if webhooksFile == "" {
+					webhooksFile = filepath.Join(filepath.Dir(dataFile), "webhooks.json")
+				}
+			}
+			if webhooksFile == "" {
+				webhooksFile = "webhooks.json"
 			}
 
 			cfg := server.Config{
-				Port:    port,
-				Version: version,
+				Port:        port,
+				Version:     version,
+				LeaseTTL:    leaseTTL,
+				WebhookFile: webhooksFile,
+				Webhooks:    webhooks,
+			}
+
+			var srv *server.Server
+			if adminToken != "" {
+				cfg.AdminToken = adminToken
+				cfg.ProjectsFile = projectsFile
+				cfg.ReloadProjectsFile = func() error { return loader.apply(srv) }
 			}
 
 			srv, err := server.New(cfg, provider)
@@ -100,9 +152,41 @@ func newServeCmd() *cobra.Command {
 				return err
 			}
 
+			// SIGINT and SIGTERM shut the server down gracefully. The
+			// context also ends event streams, which would otherwise hold
+			// Shutdown open until it times out.
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			if loader != nil {
+				go loader.watch(ctx, srv, log.New(cmd.OutOrStdout(), "", log.LstdFlags))
+			}
+
 			addr := srv.ListenAddr()
+			httpSrv := &http.Server{
+				Addr:        addr,
+				Handler:     srv.Router,
+				BaseContext: func(net.Listener) context.Context { return ctx },
+			}
 			fmt.Fprintf(cmd.OutOrStdout(), "listening on %s\n", addr)
-			return http.ListenAndServe(addr, srv.Router)
+
+			served := make(chan error, 1)
+			go func() { served <- httpSrv.ListenAndServe() }()
+			select {
+			case err := <-served:
+				srv.Close()
+				return err
+			case <-ctx.Done():
+			}
+
+			// Let the requests in flight finish, then stop the background
+			// goroutines, which saves the webhook queue.
+			fmt.Fprintln(cmd.OutOrStdout(), "shutting down")
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+			defer cancel()
+			err = httpSrv.Shutdown(shutdownCtx)
+			srv.Close()
+			return err
 		},
 	}
 
@@ -110,6 +194,9 @@ func newServeCmd() *cobra.Command {
 	cmd.Flags().StringVar(&dataFile, "data-file", "beads.json", "path to data file")
 	cmd.Flags().StringVar(&token, "token", "", "bearer token for authentication")
 	cmd.Flags().StringVar(&projectsFile, "projects", "", "path to projects config file (multi-project mode)")
+	cmd.Flags().StringVar(&adminToken, "admin-token", "", "bearer token for the admin API (multi-project mode)")
+	cmd.Flags().StringVar(&webhooksFile, "webhooks-file", "", "path to webhook state file (API-created webhooks, delivery queue and log; default webhooks.json next to the data file, or in the current directory with --projects)")
+	cmd.Flags().DurationVar(&leaseTTL, "lease-ttl", 0, "how long a claim lasts without a heartbeat, e.g. 1h (default 0: claims never expire)")
 
 	return cmd
 }
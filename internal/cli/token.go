@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/vector76/beads_server/internal/project"
+)
+
+func newTokenCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "token [<token>]",
+		Short: "Generate a token and the hash to store in the projects file",
+		Long: `Generate a random token and print it with its hash.
+
+Give the token to the user and put the hash in their entry in the projects
+file as token_hash. With an argument, print the hash of that token instead
+of generating one.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var token string
+			if len(args) == 1 {
+				token = args[0]
+			} else {
+				var err error
+				if token, err = project.GenerateToken(); err != nil {
+					return err
+				}
+			}
+			return printJSON(cmd.OutOrStdout(), map[string]string{
+				"token":      token,
+				"token_hash": project.HashToken(token),
+			})
+		},
+	}
+}
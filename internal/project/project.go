@@ -3,14 +3,37 @@ package project
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
 )
 
 // ProjectEntry defines a single project's configuration.
 type ProjectEntry struct {
 	Name     string `json:"name"`
-	Token    string `json:"token"`
+	Token    string `json:"token,omitempty"`
 	DataFile string `json:"data_file"`
+	Backend  string `json:"backend,omitempty"` // "json" (default) or "sqlite"
+
+	// TokenHash is the project token stored hashed, instead of Token. The
+	// project token has the admin role and no identity of its own.
+	TokenHash string `json:"token_hash,omitempty"`
+
+	// Users are the project's named principals, each with its own token.
+	Users []UserEntry `json:"users,omitempty"`
+
+	Webhooks []WebhookEntry `json:"webhooks,omitempty"`
+
+	// Archived projects stay in the file with their data but are not
+	// served: their tokens are rejected until the flag is cleared.
+	Archived bool `json:"archived,omitempty"`
+}
+
+// WebhookEntry defines a webhook subscription for a project's events.
+type WebhookEntry struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"` // key for the payload's HMAC signature
+	Events []string `json:"events,omitempty"` // event types to send; empty means all
 }
 
 // projectsFile is the on-disk JSON format for the projects config.
@@ -38,6 +61,45 @@ func LoadProjectsFile(path string) ([]ProjectEntry, error) {
 	return pf.Projects, nil
 }
 
+// SaveProjectsFile validates projects and writes them to path atomically
+// (temp file + rename), keeping the file's permissions if it exists.
+func SaveProjectsFile(path string, projects []ProjectEntry) error {
+	if err := validate(projects); err != nil {
+		return err
+	}
+	if projects == nil {
+		projects = []ProjectEntry{}
+	}
+	data, err := json.MarshalIndent(projectsFile{Projects: projects}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling projects: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "projects-*.json.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if info, err := os.Stat(path); err == nil {
+		tmp.Chmod(info.Mode().Perm())
+	}
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("writing temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("closing temp file: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("renaming temp file: %w", err)
+	}
+	return nil
+}
+
 // validate checks that all project entries are well-formed and unique.
 func validate(projects []ProjectEntry) error {
 	names := make(map[string]bool)
@@ -47,21 +109,46 @@ func validate(projects []ProjectEntry) error {
 		if p.Name == "" {
 			return fmt.Errorf("project %d: name must not be empty", i)
 		}
-		if p.Token == "" {
-			return fmt.Errorf("project %q: token must not be empty", p.Name)
+		if err := validateUsers(p); err != nil {
+			return err
 		}
 		if p.DataFile == "" {
 			return fmt.Errorf("project %q: data_file must not be empty", p.Name)
 		}
+		if p.Backend != "" && p.Backend != "json" && p.Backend != "sqlite" {
+			return fmt.Errorf("project %q: backend must be \"json\" or \"sqlite\"", p.Name)
+		}
+		if err := validateWebhooks(p); err != nil {
+			return err
+		}
 		if names[p.Name] {
 			return fmt.Errorf("duplicate project name: %q", p.Name)
 		}
-		if tokens[p.Token] {
-			return fmt.Errorf("duplicate token in project %q", p.Name)
+		for _, h := range p.TokenHashes() {
+			if tokens[h] {
+				return fmt.Errorf("duplicate token in project %q", p.Name)
+			}
+			tokens[h] = true
 		}
 		names[p.Name] = true
-		tokens[p.Token] = true
 	}
 
 	return nil
 }
+
+// validateWebhooks checks that a project's webhooks have absolute http(s)
+// URLs and that no URL appears twice. Event types are checked by the server.
+func validateWebhooks(p ProjectEntry) error {
+	urls := make(map[string]bool)
+	for i, w := range p.Webhooks {
+		u, err := url.Parse(w.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("project %q: webhook %d: url must be an absolute http or https URL", p.Name, i)
+		}
+		if urls[w.URL] {
+			return fmt.Errorf("project %q: duplicate webhook url %q", p.Name, w.URL)
+		}
+		urls[w.URL] = true
+	}
+	return nil
+}
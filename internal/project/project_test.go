@@ -151,3 +151,195 @@ func TestLoadProjectsFile_SingleProject(t *testing.T) {
 		t.Errorf("expected data_file=solo.json, got %q", entries[0].DataFile)
 	}
 }
+
+func TestLoadProjectsFile_Backend(t *testing.T) {
+	path := writeFile(t, `{
+		"projects": [
+			{"name": "small", "token": "tok-a", "data_file": "small.json"},
+			{"name": "large", "token": "tok-b", "data_file": "large.db", "backend": "sqlite"}
+		]
+	}`)
+
+	entries, err := LoadProjectsFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if entries[0].Backend != "" {
+		t.Errorf("expected default backend, got %q", entries[0].Backend)
+	}
+	if entries[1].Backend != "sqlite" {
+		t.Errorf("expected backend=sqlite, got %q", entries[1].Backend)
+	}
+}
+
+func TestLoadProjectsFile_UnknownBackend(t *testing.T) {
+	path := writeFile(t, `{
+		"projects": [
+			{"name": "webapp", "token": "tok-a", "data_file": "webapp.json", "backend": "postgres"}
+		]
+	}`)
+
+	if _, err := LoadProjectsFile(path); err == nil {
+		t.Fatal("expected error for unknown backend")
+	}
+}
+
+func TestLoadProjectsFile_Webhooks(t *testing.T) {
+	path := writeFile(t, `{
+		"projects": [
+			{"name": "webapp", "token": "tok-a", "data_file": "webapp.json", "webhooks": [
+				{"url": "https://ci.example.com/hook", "secret": "s3cret", "events": ["bead.closed"]}
+			]}
+		]
+	}`)
+
+	entries, err := LoadProjectsFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	hooks := entries[0].Webhooks
+	if len(hooks) != 1 || hooks[0].URL != "https://ci.example.com/hook" || hooks[0].Secret != "s3cret" {
+		t.Fatalf("unexpected webhooks: %+v", hooks)
+	}
+	if len(hooks[0].Events) != 1 || hooks[0].Events[0] != "bead.closed" {
+		t.Errorf("unexpected events: %v", hooks[0].Events)
+	}
+}
+
+func TestLoadProjectsFile_InvalidWebhooks(t *testing.T) {
+	for name, hooks := range map[string]string{
+		"relative url":  `[{"url": "/hook"}]`,
+		"bad scheme":    `[{"url": "ftp://example.com/hook"}]`,
+		"duplicate url": `[{"url": "http://example.com/a"}, {"url": "http://example.com/a"}]`,
+	} {
+		path := writeFile(t, `{"projects": [
+			{"name": "webapp", "token": "tok-a", "data_file": "webapp.json", "webhooks": `+hooks+`}
+		]}`)
+		if _, err := LoadProjectsFile(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestLoadProjectsFile_Users(t *testing.T) {
+	hash := HashToken("tok-alice")
+	path := writeFile(t, `{
+		"projects": [
+			{"name": "webapp", "data_file": "a.json", "users": [
+				{"name": "alice", "role": "writer", "token_hash": "`+hash+`"},
+				{"name": "ci", "role": "reader", "token_hash": "`+HashToken("tok-ci")+`"}
+			]}
+		]
+	}`)
+
+	entries, err := LoadProjectsFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	users := entries[0].Users
+	if len(users) != 2 || users[0].Name != "alice" || users[0].Role != RoleWriter || users[0].TokenHash != hash {
+		t.Errorf("unexpected users: %+v", users)
+	}
+}
+
+func TestLoadProjectsFile_InvalidUsers(t *testing.T) {
+	hash := HashToken("tok-alice")
+	cases := map[string]string{
+		"unknown role":      `"users": [{"name": "alice", "role": "owner", "token_hash": "` + hash + `"}]`,
+		"empty user name":   `"users": [{"name": "", "role": "agent", "token_hash": "` + hash + `"}]`,
+		"bad token hash":    `"users": [{"name": "alice", "role": "agent", "token_hash": "tok-alice"}]`,
+		"duplicate user":    `"users": [{"name": "alice", "role": "agent", "token_hash": "` + hash + `"}, {"name": "alice", "role": "reader", "token_hash": "` + HashToken("x") + `"}]`,
+		"token and hash":    `"token": "tok-abc", "token_hash": "` + hash + `"`,
+		"bad project hash":  `"token_hash": "sha256:abc"`,
+		"shared user token": `"token": "tok-alice", "users": [{"name": "alice", "role": "agent", "token_hash": "` + hash + `"}]`,
+	}
+	for name, fields := range cases {
+		t.Run(name, func(t *testing.T) {
+			path := writeFile(t, `{"projects": [{"name": "webapp", "data_file": "a.json", `+fields+`}]}`)
+			if _, err := LoadProjectsFile(path); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+func TestLoadProjectsFile_DuplicateUserTokensAcrossProjects(t *testing.T) {
+	hash := HashToken("tok-alice")
+	path := writeFile(t, `{
+		"projects": [
+			{"name": "webapp", "data_file": "a.json", "users": [{"name": "alice", "role": "agent", "token_hash": "`+hash+`"}]},
+			{"name": "backend", "data_file": "b.json", "users": [{"name": "alice", "role": "agent", "token_hash": "`+hash+`"}]}
+		]
+	}`)
+
+	if _, err := LoadProjectsFile(path); err == nil {
+		t.Fatal("expected error for a user token shared by two projects")
+	}
+}
+
+func TestRoleAtLeast(t *testing.T) {
+	cases := []struct {
+		role, min string
+		want      bool
+	}{
+		{RoleAdmin, RoleReader, true},
+		{RoleWriter, RoleAgent, true},
+		{RoleAgent, RoleAgent, true},
+		{RoleAgent, RoleWriter, false},
+		{RoleReader, RoleAgent, false},
+		{"owner", RoleReader, false},
+	}
+	for _, c := range cases {
+		if got := RoleAtLeast(c.role, c.min); got != c.want {
+			t.Errorf("RoleAtLeast(%q, %q) = %v, want %v", c.role, c.min, got, c.want)
+		}
+	}
+}
+
+func TestGenerateToken(t *testing.T) {
+	a, err := GenerateToken()
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	b, _ := GenerateToken()
+	if a == b || len(a) < 40 {
+		t.Errorf("tokens %q and %q should be long and distinct", a, b)
+	}
+	if !validHash(HashToken(a)) {
+		t.Errorf("HashToken(%q) = %q is not a valid hash", a, HashToken(a))
+	}
+}
+
+func TestSaveProjectsFile_RoundTrip(t *testing.T) {
+	path := writeFile(t, `{"projects": []}`)
+	os.Chmod(path, 0600)
+	projects := []ProjectEntry{
+		{Name: "webapp", TokenHash: HashToken("tok-abc"), DataFile: "webapp.json"},
+		{Name: "old", Token: "tok-old", DataFile: "old.db", Backend: "sqlite", Archived: true},
+	}
+
+	if err := SaveProjectsFile(path, projects); err != nil {
+		t.Fatalf("SaveProjectsFile: %v", err)
+	}
+	loaded, err := LoadProjectsFile(path)
+	if err != nil {
+		t.Fatalf("LoadProjectsFile: %v", err)
+	}
+	if len(loaded) != 2 || loaded[0].TokenHash != projects[0].TokenHash || !loaded[1].Archived || loaded[1].Backend != "sqlite" {
+		t.Errorf("loaded = %+v", loaded)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want the file's 0600 kept", info.Mode().Perm())
+	}
+}
+
+func TestSaveProjectsFile_RejectsInvalid(t *testing.T) {
+	path := writeFile(t, `{"projects": []}`)
+	err := SaveProjectsFile(path, []ProjectEntry{{Name: "webapp", DataFile: "a.json"}})
+	if err == nil {
+		t.Fatal("expected error for a project without credentials")
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"projects": []}` {
+		t.Errorf("file changed to %s", data)
+	}
+}
@@ -0,0 +1,116 @@
+package project
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Roles a user can hold in a project, from most to least privileged.
+const (
+	RoleAdmin  = "admin"  // everything, including clean, import and webhooks
+	RoleWriter = "writer" // agent, plus delete, assigning others, batch and import-plan
+	RoleAgent  = "agent"  // reader, plus create, edit, claim, comment and link
+	RoleReader = "reader" // read-only
+)
+
+// Roles lists the valid roles, least privileged first.
+var Roles = []string{RoleReader, RoleAgent, RoleWriter, RoleAdmin}
+
+// RoleAtLeast reports whether role grants everything min does.
+func RoleAtLeast(role, min string) bool {
+	i := slices.Index(Roles, role)
+	return i >= 0 && i >= slices.Index(Roles, min)
+}
+
+// UserEntry is a named principal in a project. Requests made with the
+// user's token act as Name, whatever the request body or X-BS-User says.
+// Only the token's hash is stored.
+type UserEntry struct {
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	TokenHash string `json:"token_hash"`
+}
+
+// hashPrefix marks the hash algorithm in a stored token hash.
+const hashPrefix = "sha256:"
+
+// HashToken returns the form in which token is stored in the projects file.
+// Tokens are long random strings, so a plain SHA-256 is enough to keep the
+// file from leaking them, and lets the server look a token up directly.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hashPrefix + hex.EncodeToString(sum[:])
+}
+
+// GenerateToken returns a new random token.
+func GenerateToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating token: %w", err)
+	}
+	return "bs_" + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// validHash reports whether h looks like a HashToken result.
+func validHash(h string) bool {
+	digest, ok := strings.CutPrefix(h, hashPrefix)
+	if !ok || len(digest) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(digest)
+	return err == nil
+}
+
+// TokenHashes returns the hashes of every token that opens p: its project
+// token, if any, and its users' tokens.
+func (p ProjectEntry) TokenHashes() []string {
+	var hashes []string
+	if p.Token != "" {
+		hashes = append(hashes, HashToken(p.Token))
+	}
+	if p.TokenHash != "" {
+		hashes = append(hashes, p.TokenHash)
+	}
+	for _, u := range p.Users {
+		hashes = append(hashes, u.TokenHash)
+	}
+	return hashes
+}
+
+// validateUsers checks a project's credentials: it needs a project token or
+// at least one user, users need unique names, a known role and a well-formed
+// token hash.
+func validateUsers(p ProjectEntry) error {
+	if p.Token != "" && p.TokenHash != "" {
+		return fmt.Errorf("project %q: set token or token_hash, not both", p.Name)
+	}
+	if p.TokenHash != "" && !validHash(p.TokenHash) {
+		return fmt.Errorf("project %q: token_hash must be %q followed by 64 hex digits", p.Name, hashPrefix)
+	}
+	if p.Token == "" && p.TokenHash == "" && len(p.Users) == 0 {
+		return fmt.Errorf("project %q: token must not be empty unless the project has users", p.Name)
+	}
+
+	names := make(map[string]bool)
+	for i, u := range p.Users {
+		if u.Name == "" {
+			return fmt.Errorf("project %q: user %d: name must not be empty", p.Name, i)
+		}
+		if names[u.Name] {
+			return fmt.Errorf("project %q: duplicate user %q", p.Name, u.Name)
+		}
+		names[u.Name] = true
+		if !slices.Contains(Roles, u.Role) {
+			return fmt.Errorf("project %q: user %q: role must be one of %s", p.Name, u.Name, strings.Join(Roles, ", "))
+		}
+		if !validHash(u.TokenHash) {
+			return fmt.Errorf("project %q: user %q: token_hash must be %q followed by 64 hex digits", p.Name, u.Name, hashPrefix)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+
+	"github.com/vector76/beads_server/internal/project"
+	"github.com/vector76/beads_server/internal/store"
+)
+
+// AdminProject describes a project in the admin API. Tokens are never
+// included, only how many users the project has.
+type AdminProject struct {
+	Name     string `json:"name"`
+	DataFile string `json:"data_file"`
+	Backend  string `json:"backend"`
+	Archived bool   `json:"archived"`
+	Users    int    `json:"users"`
+}
+
+// AdminProjectToken is a project with its new project token, returned when
+// a project is created or its token rotated. Only the token's hash is
+// saved, so this is the one time it can be read.
+type AdminProjectToken struct {
+	AdminProject
+	Token string `json:"token"`
+}
+
+// projectNamePattern limits new project names to ones that are safe in a
+// URL path and as a file name.
+var projectNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// projectAdmin edits the projects file for the admin API and applies each
+// change to the running server.
+type projectAdmin struct {
+	mu     sync.Mutex
+	path   string
+	reload func() error
+	logger *log.Logger
+}
+
+func adminProject(e project.ProjectEntry) AdminProject {
+	backend := e.Backend
+	if backend == "" {
+		backend = store.BackendJSON
+	}
+	return AdminProject{Name: e.Name, DataFile: e.DataFile, Backend: backend, Archived: e.Archived, Users: len(e.Users)}
+}
+
+// edit loads the projects file, lets fn change its entries, then saves and
+// reloads it. If the reload fails, the file is put back as it was.
+func (a *projectAdmin) edit(fn func([]project.ProjectEntry) ([]project.ProjectEntry, error)) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entries, err := project.LoadProjectsFile(a.path)
+	if err != nil {
+		return err
+	}
+	updated, err := fn(slices.Clone(entries))
+	if err != nil {
+		return err
+	}
+	if err := project.SaveProjectsFile(a.path, updated); err != nil {
+		return err
+	}
+	if err := a.reload(); err != nil {
+		if restoreErr := project.SaveProjectsFile(a.path, entries); restoreErr != nil {
+			return fmt.Errorf("%w; restoring the projects file: %v", err, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// findProject returns the index of the named project, or a not-found error.
+func findProject(entries []project.ProjectEntry, name string) (int, error) {
+	i := slices.IndexFunc(entries, func(e project.ProjectEntry) bool { return e.Name == name })
+	if i < 0 {
+		return -1, &store.NotFoundError{Message: fmt.Sprintf("project %q not found", name)}
+	}
+	return i, nil
+}
+
+// list returns every project in the file, archived ones included.
+func (a *projectAdmin) list() ([]AdminProject, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entries, err := project.LoadProjectsFile(a.path)
+	if err != nil {
+		return nil, err
+	}
+	projects := make([]AdminProject, len(entries))
+	for i, e := range entries {
+		projects[i] = adminProject(e)
+	}
+	return projects, nil
+}
+
+// create adds a project with a new project token. An empty dataFile puts
+// the data next to the projects file, named after the project.
+func (a *projectAdmin) create(name, dataFile, backend string) (AdminProjectToken, error) {
+	if !projectNamePattern.MatchString(name) {
+		return AdminProjectToken{}, fmt.Errorf("name must start with a letter or digit and contain only letters, digits, '.', '_' and '-'")
+	}
+	if dataFile == "" {
+		ext := ".json"
+		if backend == store.BackendSQLite {
+			ext = ".db"
+		}
+		dataFile = filepath.Join(filepath.Dir(a.path), name+ext)
+	}
+	token, err := project.GenerateToken()
+	if err != nil {
+		return AdminProjectToken{}, err
+	}
+
+	entry := project.ProjectEntry{Name: name, TokenHash: project.HashToken(token), DataFile: dataFile, Backend: backend}
+	err = a.edit(func(entries []project.ProjectEntry) ([]project.ProjectEntry, error) {
+		for _, e := range entries {
+			if e.Name == name {
+				return nil, &store.ConflictError{Message: fmt.Sprintf("project %q already exists", name)}
+			}
+			if e.DataFile == dataFile {
+				return nil, &store.ConflictError{Message: fmt.Sprintf("data file %s is used by project %q", dataFile, e.Name)}
+			}
+		}
+		return append(entries, entry), nil
+	})
+	if err != nil {
+		return AdminProjectToken{}, err
+	}
+	return AdminProjectToken{AdminProject: adminProject(entry), Token: token}, nil
+}
+
+// rotateToken replaces the named project's token with a new one. The old
+// token stops working at once; user tokens are not affected.
+func (a *projectAdmin) rotateToken(name string) (AdminProjectToken, error) {
+	token, err := project.GenerateToken()
+	if err != nil {
+		return AdminProjectToken{}, err
+	}
+
+	var entry project.ProjectEntry
+	err = a.edit(func(entries []project.ProjectEntry) ([]project.ProjectEntry, error) {
+		i, err := findProject(entries, name)
+		if err != nil {
+			return nil, err
+		}
+		entries[i].Token = ""
+		entries[i].TokenHash = project.HashToken(token)
+		entry = entries[i]
+		return entries, nil
+	})
+	if err != nil {
+		return AdminProjectToken{}, err
+	}
+	return AdminProjectToken{AdminProject: adminProject(entry), Token: token}, nil
+}
+
+// archive stops serving the named project, keeping its entry and data.
+func (a *projectAdmin) archive(name string) (AdminProject, error) {
+	var entry project.ProjectEntry
+	err := a.edit(func(entries []project.ProjectEntry) ([]project.ProjectEntry, error) {
+		i, err := findProject(entries, name)
+		if err != nil {
+			return nil, err
+		}
+		entries[i].Archived = true
+		entry = entries[i]
+		return entries, nil
+	})
+	return adminProject(entry), err
+}
+
+// remove deletes an archived project from the projects file, then its data.
+// Once the project is gone from the file, failing to remove the data is
+// only logged.
+func (a *projectAdmin) remove(name string) (AdminProject, error) {
+	var entry project.ProjectEntry
+	err := a.edit(func(entries []project.ProjectEntry) ([]project.ProjectEntry, error) {
+		i, err := findProject(entries, name)
+		if err != nil {
+			return nil, err
+		}
+		entry = entries[i]
+		if !entry.Archived {
+			return nil, &store.ConflictError{Message: fmt.Sprintf("project %q must be archived before it is deleted", name)}
+		}
+		return slices.Delete(entries, i, i+1), nil
+	})
+	if err != nil {
+		return AdminProject{}, err
+	}
+	if err := store.Remove(entry.Backend, entry.DataFile); err != nil {
+		a.logger.Printf("deleting data of project %q: %v", name, err)
+	}
+	return adminProject(entry), nil
+}
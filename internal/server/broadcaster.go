@@ -1,26 +1,66 @@
 package server
 
 import (
+	"cmp"
+	"slices"
 	"sync"
 	"time"
 )
 
 const debounceDuration = 200 * time.Millisecond
 
-// broadcaster is a thread-safe pub/sub hub that coalesces rapid publish signals
-// within a debounce window before fanning out to all subscribers.
+// maxBacklog caps the events queued for a subscriber that is not reading.
+// Beyond it the oldest events are dropped and the subscriber is sent a reset.
+const maxBacklog = 1024
+
+// replaySize is how many delivered events of each project are kept for
+// replay to clients that reconnect with Last-Event-ID.
+const replaySize = 1024
+
+// broadcaster is a thread-safe pub/sub hub. Published events are queued and
+// delivered in order once publishing has been quiet for the debounce window;
+// each subscriber receives every event but only one wake-up signal per batch.
+//
+// Every event gets an ID one greater than the last. IDs start from the
+// broadcaster's creation time in nanoseconds, so they keep increasing across
+// server restarts and a stale ID from before a restart is never mistaken for
+// a recent one.
 type broadcaster struct {
 	mu          sync.Mutex
-	subscribers map[chan struct{}]struct{}
+	subscribers map[chan struct{}]*subscription
+	pending     []Event
+	nextID      uint64
+	baseID      uint64                   // IDs before this one are from an earlier run
+	replay      map[string]*replayBuffer // by project
+	lastID      uint64                   // ID of the newest delivered event
 	publishCh   chan struct{}
 	done        chan struct{}
 	wg          sync.WaitGroup
 }
 
+// replayBuffer holds one project's most recent delivered events. Each
+// project has its own, so a busy project cannot push a quiet one's events
+// out before its clients have had a chance to reconnect.
+type replayBuffer struct {
+	events  []Event // last replaySize events, oldest first
+	evicted uint64  // ID of the newest event dropped from events, if any
+}
+
+// subscription holds the events delivered to a subscriber but not yet taken.
+type subscription struct {
+	events  []Event
+	dropped bool // events were dropped for exceeding maxBacklog
+}
+
 // newBroadcaster creates and starts a new broadcaster.
 func newBroadcaster() *broadcaster {
+	base := uint64(time.Now().UnixNano())
 	b := &broadcaster{
-		subscribers: make(map[chan struct{}]struct{}),
+		subscribers: make(map[chan struct{}]*subscription),
+		nextID:      base + 1,
+		baseID:      base,
+		replay:      make(map[string]*replayBuffer),
+		lastID:      base,
 		publishCh:   make(chan struct{}, 1),
 		done:        make(chan struct{}),
 	}
@@ -29,15 +69,73 @@ func newBroadcaster() *broadcaster {
 	return b
 }
 
-// subscribe creates a buffered channel, registers it, and returns it to the caller.
+// subscribe creates a buffered channel, registers it, and returns it to the
+// caller. A signal on the channel means events are waiting; see take.
 func (b *broadcaster) subscribe() chan struct{} {
 	ch := make(chan struct{}, 1)
 	b.mu.Lock()
-	b.subscribers[ch] = struct{}{}
+	b.subscribers[ch] = &subscription{}
 	b.mu.Unlock()
 	return ch
 }
 
+// subscribeFrom is subscribe for a client of project that has seen events
+// up to lastID. It also returns the project's delivered events after lastID,
+// and any that name no project, in order. When those are no longer all in
+// the replay buffers, or lastID is unknown, it returns a single reset event
+// instead, telling the client to discard its state and re-fetch.
+func (b *broadcaster) subscribeFrom(project string, lastID uint64) (chan struct{}, []Event) {
+	ch := make(chan struct{}, 1)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers[ch] = &subscription{}
+
+	if lastID == b.lastID {
+		return ch, nil
+	}
+	if lastID < b.baseID || lastID > b.lastID {
+		return ch, []Event{resetEvent(b.lastID)}
+	}
+	names := []string{project}
+	if project != "" {
+		names = append(names, "")
+	}
+	var missed []Event
+	for _, name := range names {
+		buf := b.replay[name]
+		if buf == nil {
+			continue
+		}
+		if lastID < buf.evicted {
+			return ch, []Event{resetEvent(b.lastID)}
+		}
+		i, _ := slices.BinarySearchFunc(buf.events, lastID+1, func(ev Event, id uint64) int {
+			return cmp.Compare(ev.ID, id)
+		})
+		missed = append(missed, buf.events[i:]...)
+	}
+	slices.SortFunc(missed, func(a, c Event) int { return cmp.Compare(a.ID, c.ID) })
+	return ch, missed
+}
+
+// take returns and clears the events delivered to the subscriber ch. If
+// some were dropped, the result starts with a reset event.
+func (b *broadcaster) take(ch chan struct{}) []Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sub, ok := b.subscribers[ch]
+	if !ok {
+		return nil
+	}
+	events := sub.events
+	if sub.dropped && len(events) > 0 {
+		events = append([]Event{resetEvent(events[0].ID - 1)}, events...)
+	}
+	sub.events = nil
+	sub.dropped = false
+	return events
+}
+
 // unsubscribe removes and closes the given subscriber channel.
 func (b *broadcaster) unsubscribe(ch chan struct{}) {
 	b.mu.Lock()
@@ -46,12 +144,20 @@ func (b *broadcaster) unsubscribe(ch chan struct{}) {
 	close(ch)
 }
 
-// publish signals that a mutation occurred. Non-blocking; rapid calls are coalesced.
-func (b *broadcaster) publish() {
+// publish queues ev for delivery and returns it with its ID assigned.
+// Non-blocking; rapid calls are delivered together as one batch.
+func (b *broadcaster) publish(ev Event) Event {
+	b.mu.Lock()
+	ev.ID = b.nextID
+	b.nextID++
+	b.pending = append(b.pending, ev)
+	b.mu.Unlock()
+
 	select {
 	case b.publishCh <- struct{}{}:
 	default:
 	}
+	return ev
 }
 
 // stop terminates the background goroutine and waits for it to exit.
@@ -95,13 +201,39 @@ func (b *broadcaster) run() {
 	}
 }
 
-// fanOut delivers a signal to all subscribers in a non-blocking manner.
-// The mutex is held throughout so that unsubscribe cannot close a channel
-// while fanOut is sending to it.
+// fanOut appends the pending events to every subscriber's queue and signals
+// each in a non-blocking manner. The mutex is held throughout so that
+// unsubscribe cannot close a channel while fanOut is sending to it.
 func (b *broadcaster) fanOut() {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	for ch := range b.subscribers {
+	batch := b.pending
+	b.pending = nil
+	touched := make(map[*replayBuffer]bool)
+	for _, ev := range batch {
+		buf := b.replay[ev.Project]
+		if buf == nil {
+			buf = &replayBuffer{}
+			b.replay[ev.Project] = buf
+		}
+		buf.events = append(buf.events, ev)
+		touched[buf] = true
+	}
+	for buf := range touched {
+		if over := len(buf.events) - replaySize; over > 0 {
+			buf.evicted = buf.events[over-1].ID
+			buf.events = append([]Event(nil), buf.events[over:]...)
+		}
+	}
+	if len(batch) > 0 {
+		b.lastID = batch[len(batch)-1].ID
+	}
+	for ch, sub := range b.subscribers {
+		sub.events = append(sub.events, batch...)
+		if over := len(sub.events) - maxBacklog; over > 0 {
+			sub.events = append([]Event(nil), sub.events[over:]...)
+			sub.dropped = true
+		}
 		select {
 		case ch <- struct{}{}:
 		default:
@@ -22,7 +22,7 @@ func TestBroadcaster_SingleSubscriberReceivesSignal(t *testing.T) {
 	defer b.stop()
 
 	ch := b.subscribe()
-	b.publish()
+	b.publish(Event{Type: EventBeadUpdated})
 
 	if !waitForSignal(t, ch, testTimeout) {
 		t.Fatal("subscriber did not receive signal within timeout")
@@ -37,7 +37,7 @@ func TestBroadcaster_MultipleSubscribersAllReceive(t *testing.T) {
 	ch2 := b.subscribe()
 	ch3 := b.subscribe()
 
-	b.publish()
+	b.publish(Event{Type: EventBeadUpdated})
 
 	for i, ch := range []chan struct{}{ch1, ch2, ch3} {
 		if !waitForSignal(t, ch, testTimeout) {
@@ -53,7 +53,7 @@ func TestBroadcaster_UnsubscribedClientReceivesNothing(t *testing.T) {
 	ch := b.subscribe()
 	b.unsubscribe(ch)
 
-	b.publish()
+	b.publish(Event{Type: EventBeadUpdated})
 
 	// Give time for any (erroneous) delivery to occur.
 	time.Sleep(debounceDuration + 100*time.Millisecond)
@@ -77,7 +77,7 @@ func TestBroadcaster_RapidPublishesCoalesced(t *testing.T) {
 
 	// Publish many times in rapid succession.
 	for i := 0; i < 20; i++ {
-		b.publish()
+		b.publish(Event{Type: EventBeadUpdated})
 	}
 
 	// We expect exactly one delivery.
@@ -96,6 +96,36 @@ func TestBroadcaster_RapidPublishesCoalesced(t *testing.T) {
 	}
 }
 
+func TestBroadcaster_CoalescedPublishesKeepEveryEvent(t *testing.T) {
+	b := newBroadcaster()
+	defer b.stop()
+
+	ch := b.subscribe()
+	b.publish(Event{Type: EventBeadCreated, BeadID: "bd-0001"})
+	b.publish(Event{Type: EventBeadClaimed, BeadID: "bd-0001"})
+	b.publish(Event{Type: EventBeadCreated, BeadID: "bd-0002"})
+
+	if !waitForSignal(t, ch, testTimeout) {
+		t.Fatal("subscriber did not receive any signal")
+	}
+	events := b.take(ch)
+	want := []string{EventBeadCreated, EventBeadClaimed, EventBeadCreated}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), events)
+	}
+	for i, ev := range events {
+		if ev.Type != want[i] {
+			t.Errorf("event %d type = %q, want %q", i, ev.Type, want[i])
+		}
+	}
+	if events[2].BeadID != "bd-0002" {
+		t.Errorf("expected events in publish order, got %+v", events)
+	}
+	if again := b.take(ch); len(again) != 0 {
+		t.Errorf("expected take to clear the queue, got %+v", again)
+	}
+}
+
 func TestBroadcaster_SlowSubscriberDoesNotBlockOthers(t *testing.T) {
 	b := newBroadcaster()
 	defer b.stop()
@@ -106,7 +136,7 @@ func TestBroadcaster_SlowSubscriberDoesNotBlockOthers(t *testing.T) {
 	// Pre-fill the slow subscriber's buffer so it can't receive.
 	slow <- struct{}{}
 
-	b.publish()
+	b.publish(Event{Type: EventBeadUpdated})
 
 	// Fast subscriber should still receive despite slow being full.
 	if !waitForSignal(t, fast, testTimeout) {
@@ -119,7 +149,7 @@ func TestBroadcaster_NoSubscribers_NoPanic(t *testing.T) {
 	defer b.stop()
 
 	// Should not panic or deadlock with no subscribers.
-	b.publish()
+	b.publish(Event{Type: EventBeadUpdated})
 	time.Sleep(debounceDuration + 100*time.Millisecond)
 }
 
@@ -130,7 +160,7 @@ func TestBroadcaster_ConcurrentUnsubscribeNoPanic(t *testing.T) {
 		b := newBroadcaster()
 		ch := b.subscribe()
 
-		b.publish()
+		b.publish(Event{Type: EventBeadUpdated})
 		// Unsubscribe concurrently with fanOut delivery.
 		go b.unsubscribe(ch)
 
@@ -142,7 +172,7 @@ func TestBroadcaster_ConcurrentUnsubscribeNoPanic(t *testing.T) {
 func TestBroadcaster_StopsCleanly(t *testing.T) {
 	b := newBroadcaster()
 	b.subscribe()
-	b.publish()
+	b.publish(Event{Type: EventBeadUpdated})
 
 	done := make(chan struct{})
 	go func() {
@@ -157,3 +187,114 @@ func TestBroadcaster_StopsCleanly(t *testing.T) {
 		t.Fatal("broadcaster did not stop within timeout")
 	}
 }
+
+func TestBroadcaster_EventIDsIncrease(t *testing.T) {
+	b := newBroadcaster()
+	defer b.stop()
+
+	ch := b.subscribe()
+	for range 3 {
+		b.publish(Event{Type: EventBeadUpdated})
+	}
+	waitForSignal(t, ch, testTimeout)
+	events := b.take(ch)
+	for i := 1; i < len(events); i++ {
+		if events[i].ID != events[i-1].ID+1 {
+			t.Fatalf("expected consecutive IDs, got %d then %d", events[i-1].ID, events[i].ID)
+		}
+	}
+}
+
+func TestBroadcaster_SubscribeFromReplaysMissedEvents(t *testing.T) {
+	b := newBroadcaster()
+	defer b.stop()
+
+	ch := b.subscribe()
+	for _, id := range []string{"bd-0001", "bd-0002", "bd-0003"} {
+		b.publish(Event{Type: EventBeadCreated, Project: "alpha", BeadID: id})
+	}
+	waitForSignal(t, ch, testTimeout)
+	seen := b.take(ch)
+
+	_, missed := b.subscribeFrom("alpha", seen[0].ID)
+	if len(missed) != 2 || missed[0].BeadID != "bd-0002" || missed[1].BeadID != "bd-0003" {
+		t.Errorf("expected the two later events, got %+v", missed)
+	}
+
+	_, missed = b.subscribeFrom("alpha", seen[2].ID)
+	if len(missed) != 0 {
+		t.Errorf("expected nothing to replay for an up-to-date client, got %+v", missed)
+	}
+}
+
+func TestBroadcaster_SubscribeFromResetsOnGap(t *testing.T) {
+	b := newBroadcaster()
+	defer b.stop()
+
+	ch := b.subscribe()
+	for range replaySize + 5 {
+		b.publish(Event{Type: EventBeadUpdated, Project: "alpha"})
+	}
+	waitForSignal(t, ch, testTimeout)
+	seen := b.take(ch)
+	newest := seen[len(seen)-1].ID
+
+	for name, lastID := range map[string]uint64{
+		"evicted":        newest - replaySize - 1,
+		"before restart": 42,
+		"unknown future": newest + 100,
+	} {
+		_, missed := b.subscribeFrom("alpha", lastID)
+		if len(missed) != 1 || missed[0].Type != EventReset {
+			t.Errorf("%s: expected a single reset event, got %d events", name, len(missed))
+			continue
+		}
+		if missed[0].ID != newest {
+			t.Errorf("%s: reset ID = %d, want newest ID %d", name, missed[0].ID, newest)
+		}
+	}
+}
+
+func TestBroadcaster_ReplayIsPerProject(t *testing.T) {
+	b := newBroadcaster()
+	defer b.stop()
+
+	ch := b.subscribe()
+	seen := b.publish(Event{Type: EventBeadCreated, Project: "quiet", BeadID: "bd-seen"})
+	b.publish(Event{Type: EventBeadCreated, Project: "quiet", BeadID: "bd-missed"})
+	firstBusy := b.publish(Event{Type: EventBeadUpdated, Project: "busy"})
+	for range replaySize + 5 {
+		b.publish(Event{Type: EventBeadUpdated, Project: "busy"})
+	}
+	waitForSignal(t, ch, testTimeout)
+
+	// The busy project has pushed its own oldest events out, but not the
+	// quiet project's.
+	_, missed := b.subscribeFrom("quiet", seen.ID)
+	if len(missed) != 1 || missed[0].BeadID != "bd-missed" {
+		t.Errorf("quiet project: expected its one missed event, got %+v", missed)
+	}
+	_, missed = b.subscribeFrom("busy", firstBusy.ID)
+	if len(missed) != 1 || missed[0].Type != EventReset {
+		t.Errorf("busy project: expected a reset, got %d events", len(missed))
+	}
+}
+
+func TestBroadcaster_BacklogOverflowSendsReset(t *testing.T) {
+	b := newBroadcaster()
+	defer b.stop()
+
+	ch := b.subscribe()
+	for range maxBacklog + 10 {
+		b.publish(Event{Type: EventBeadUpdated})
+	}
+	waitForSignal(t, ch, testTimeout)
+
+	events := b.take(ch)
+	if len(events) != maxBacklog+1 || events[0].Type != EventReset {
+		t.Fatalf("expected reset followed by %d events, got %d (first %q)", maxBacklog, len(events), events[0].Type)
+	}
+	if events[0].ID != events[1].ID-1 {
+		t.Errorf("reset ID = %d, want %d", events[0].ID, events[1].ID-1)
+	}
+}
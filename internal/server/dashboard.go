@@ -1,14 +1,17 @@
 package server
 
 import (
+	"fmt"
 	"html/template"
 	"net/http"
 	"sort"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/vector76/beads_server/internal/model"
 	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
 )
 
 // dashboardProject holds the template data for one project.
@@ -69,6 +72,7 @@ type beadDetailData struct {
 	Bead             model.Bead
 	ActiveBlockers   []model.Bead
 	ResolvedBlockers []model.Bead
+	History          []model.HistoryEntry
 	Theme            string
 }
 
@@ -76,7 +80,7 @@ func (s *Server) handleBeadDetail(w http.ResponseWriter, r *http.Request) {
 	projectName := chi.URLParam(r, "project")
 	beadID := chi.URLParam(r, "id")
 
-	var st *store.Store
+	var st store.Backend
 	for _, p := range s.provider.Projects() {
 		if p.Name == projectName {
 			st = p.Store
@@ -95,12 +99,14 @@ func (s *Server) handleBeadDetail(w http.ResponseWriter, r *http.Request) {
 	}
 
 	deps, _ := st.Deps(beadID)
+	history, _ := st.History(beadID)
 
 	data := beadDetailData{
 		Project:          projectName,
 		Bead:             b,
 		ActiveBlockers:   deps.ActiveBlockers,
 		ResolvedBlockers: deps.ResolvedBlockers,
+		History:          history,
 	}
 
 	if c, err := r.Cookie("theme"); err == nil && (c.Value == "dark" || c.Value == "light") {
@@ -113,6 +119,37 @@ func (s *Server) handleBeadDetail(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// maxHistoryValueLen caps how much of a field value the timeline shows.
+const maxHistoryValueLen = 80
+
+// fmtHistoryValue renders a history field value for the timeline: lists are
+// comma-separated, empty values show as "(none)", and long text is truncated.
+func fmtHistoryValue(v any) string {
+	var s string
+	switch x := v.(type) {
+	case nil:
+	case string:
+		s = x
+	case []string:
+		s = strings.Join(x, ", ")
+	case []any:
+		parts := make([]string, len(x))
+		for i, p := range x {
+			parts[i] = fmt.Sprint(p)
+		}
+		s = strings.Join(parts, ", ")
+	default:
+		s = fmt.Sprint(x)
+	}
+	if s == "" {
+		return "(none)"
+	}
+	if utf8.RuneCountInString(s) > maxHistoryValueLen {
+		s = string([]rune(s)[:maxHistoryValueLen]) + "…"
+	}
+	return s
+}
+
 // sortByUpdatedDesc sorts beads by UpdatedAt descending (most recent first).
 func sortByUpdatedDesc(beads []store.BeadSummary) {
 	sort.Slice(beads, func(i, j int) bool {
@@ -314,6 +351,7 @@ var beadDetailTmpl = template.Must(template.New("bead-detail").Funcs(template.Fu
 		return template.HTML(`<time datetime="` + utc + `">` + display + `</time>`)
 	},
 	"renderMarkdown": renderMarkdown,
+	"fmtValue":       fmtHistoryValue,
 }).Parse(`<!DOCTYPE html>
 <html{{if .Theme}} data-theme="{{.Theme}}"{{end}}>
 <head>
@@ -363,6 +401,9 @@ var beadDetailTmpl = template.Must(template.New("bead-detail").Funcs(template.Fu
   .comment-meta { font-size: 0.85em; color: var(--color-text-secondary); margin-bottom: 0.3em; }
   .comment-text { white-space: pre-wrap; }
   .section { margin-bottom: 1.5em; }
+  .timeline { list-style: none; padding-left: 0; border-left: 2px solid var(--color-border); }
+  .timeline li { padding: 0.3em 0 0.5em 0.8em; }
+  .timeline ul { margin: 0.2em 0 0 0; padding-left: 1.2em; font-size: 0.9em; }
   .theme-toggle { position: fixed; top: 1em; right: 1em; padding: 0.4em 0.8em; border: 1px solid var(--color-border); border-radius: 4px; background: var(--color-bg-badge); color: var(--color-text); cursor: pointer; font-size: 0.9em; }
 </style>
 </head>
@@ -425,6 +466,18 @@ var beadDetailTmpl = template.Must(template.New("bead-detail").Funcs(template.Fu
 {{end}}</div>
 {{end}}
 
+{{if .History}}
+<div class="section">
+<h3>History</h3>
+<ol class="timeline">
+{{range .History}}<li>
+<div class="comment-meta"><strong>{{.Actor}}</strong> {{.Action}} &middot; {{fmtTime .At}}</div>
+{{if .Changes}}<ul>{{range .Changes}}<li><strong>{{.Field}}:</strong> {{fmtValue .Old}} &rarr; {{fmtValue .New}}</li>{{end}}</ul>{{end}}
+</li>
+{{end}}</ol>
+</div>
+{{end}}
+
 <script>
 document.querySelectorAll("time[datetime]").forEach(function(el) {
   var d = new Date(el.getAttribute("datetime"));
@@ -7,8 +7,8 @@ import (
 	"testing"
 	"time"
 
-	"github.com/vector76/beads_server/internal/model"
 	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
 )
 
 func TestSortByUpdatedDesc(t *testing.T) {
@@ -184,7 +184,7 @@ func TestListReturnsSummaryUpdatedAt(t *testing.T) {
 
 	created := createViaAPI(t, srv, map[string]any{"title": "UpdatedAt check"})
 
-	result := srv.Store.List(store.ListFilters{})
+	result := soleStore(srv).List(store.ListFilters{})
 	if len(result.Beads) == 0 {
 		t.Fatal("expected at least one bead")
 	}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// etag returns the entity tag for a bead revision: the revision as a quoted
+// decimal string, e.g. "7".
+func etag(rev int64) string {
+	return `"` + strconv.FormatInt(rev, 10) + `"`
+}
+
+// setETag sets the ETag header to the bead's current revision.
+func setETag(w http.ResponseWriter, b model.Bead) {
+	w.Header().Set("ETag", etag(b.Revision))
+}
+
+// ifMatch parses the If-Match header into the revision the client expects.
+// It returns nil when the header is absent or "*". A tag that is not a
+// revision returns -1, which matches no bead and so fails with 412. Lists of
+// tags are rejected.
+func ifMatch(r *http.Request) (*int64, error) {
+	h := strings.TrimSpace(r.Header.Get("If-Match"))
+	if h == "" || h == "*" {
+		return nil, nil
+	}
+	if strings.Contains(h, ",") {
+		return nil, errors.New("If-Match must be a single entity tag")
+	}
+	tag := strings.Trim(strings.TrimPrefix(h, "W/"), `"`)
+	rev, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil {
+		rev = -1
+	}
+	return &rev, nil
+}
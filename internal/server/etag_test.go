@@ -0,0 +1,109 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vector76/beads_server/model"
+)
+
+func ifMatchReq(method, url string, body any, tag string) *http.Request {
+	req := authReq(method, url, body)
+	req.Header.Set("If-Match", tag)
+	return req
+}
+
+func TestETag_GetAndUpdate(t *testing.T) {
+	srv := crudServer(t)
+	b := createViaAPI(t, srv, map[string]any{"title": "Versioned"})
+	if b.Revision != 1 {
+		t.Fatalf("created revision = %d, want 1", b.Revision)
+	}
+
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodGet, "/api/v1/beads/"+b.ID, nil))
+	if got := w.Header().Get("ETag"); got != `"1"` {
+		t.Fatalf("GET ETag = %q, want %q", got, `"1"`)
+	}
+
+	w = httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, ifMatchReq(http.MethodPatch, "/api/v1/beads/"+b.ID, map[string]any{"title": "v2"}, `"1"`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("PATCH with matching If-Match: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("ETag"); got != `"2"` {
+		t.Fatalf("PATCH ETag = %q, want %q", got, `"2"`)
+	}
+	var updated model.Bead
+	json.NewDecoder(w.Body).Decode(&updated)
+	if updated.Revision != 2 {
+		t.Fatalf("updated revision = %d, want 2", updated.Revision)
+	}
+}
+
+func TestIfMatch_StaleRevisionFails(t *testing.T) {
+	srv := crudServer(t)
+	b := createViaAPI(t, srv, map[string]any{"title": "Contended"})
+	blocker := createViaAPI(t, srv, map[string]any{"title": "Blocker"})
+
+	// Someone else edits the bead, moving it to revision 2.
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodPatch, "/api/v1/beads/"+b.ID, map[string]any{"title": "Theirs"}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("PATCH: expected 200, got %d", w.Code)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+	}{
+		{"update", http.MethodPatch, "/api/v1/beads/" + b.ID, map[string]any{"title": "Mine"}},
+		{"delete", http.MethodDelete, "/api/v1/beads/" + b.ID, nil},
+		{"comment", http.MethodPost, "/api/v1/beads/" + b.ID + "/comments", map[string]any{"author": "me", "text": "hi"}},
+		{"link", http.MethodPost, "/api/v1/beads/" + b.ID + "/link", map[string]any{"blocked_by": blocker.ID}},
+		{"unlink", http.MethodDelete, "/api/v1/beads/" + b.ID + "/link/" + blocker.ID, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			srv.Router.ServeHTTP(w, ifMatchReq(tt.method, tt.path, tt.body, `"1"`))
+			if w.Code != http.StatusPreconditionFailed {
+				t.Fatalf("expected 412, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+
+	got, _ := soleStore(srv).Get(b.ID)
+	if got.Title != "Theirs" || got.Status != model.StatusOpen || len(got.Comments) != 0 || got.Revision != 2 {
+		t.Errorf("bead changed by failed requests: %+v", got)
+	}
+}
+
+func TestIfMatch_Forms(t *testing.T) {
+	srv := crudServer(t)
+	b := createViaAPI(t, srv, map[string]any{"title": "Forms"})
+
+	tests := []struct {
+		tag  string
+		want int
+	}{
+		{`"1"`, http.StatusOK},
+		{`W/"2"`, http.StatusOK},
+		{`3`, http.StatusOK},
+		{`*`, http.StatusOK},
+		{`"1"`, http.StatusPreconditionFailed},
+		{`"abc"`, http.StatusPreconditionFailed},
+		{`"5", "6"`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		srv.Router.ServeHTTP(w, ifMatchReq(http.MethodPatch, "/api/v1/beads/"+b.ID, map[string]any{"description": tt.tag}, tt.tag))
+		if w.Code != tt.want {
+			t.Errorf("If-Match %s: expected %d, got %d: %s", tt.tag, tt.want, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"time"
+
+	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
+)
+
+// Event types sent on the SSE stream, one per successful mutation.
+const (
+	EventBeadCreated     = "bead.created"
+	EventBeadUpdated     = "bead.updated"
+	EventBeadClosed      = "bead.closed"
+	EventBeadDeleted     = "bead.deleted"
+	EventBeadClaimed     = "bead.claimed"
+	EventBeadReleased    = "bead.released"
+	EventBeadMoved       = "bead.moved"
+	EventBeadTransferred = "bead.transferred"
+	EventCommentAdded    = "comment.added"
+	EventDepLinked       = "dep.linked"
+	EventDepUnlinked     = "dep.unlinked"
+	EventBeadsCleaned    = "beads.cleaned"
+	EventBeadsImported   = "beads.imported"
+
+	// EventReset tells a client that events were missed and cannot be
+	// replayed, so it must re-fetch whatever state it keeps.
+	EventReset = "reset"
+)
+
+// eventTypes lists the event types that webhooks can subscribe to.
+var eventTypes = []string{
+	EventBeadCreated, EventBeadUpdated, EventBeadClosed, EventBeadDeleted,
+	EventBeadClaimed, EventBeadReleased, EventBeadMoved, EventBeadTransferred, EventCommentAdded,
+	EventDepLinked, EventDepUnlinked, EventBeadsCleaned, EventBeadsImported,
+}
+
+// Event describes one mutation: what happened, to which bead in which
+// project, who did it, and which fields changed. Events without a project
+// (resets) concern every stream.
+type Event struct {
+	ID      uint64              `json:"id"`
+	Type    string              `json:"type"`
+	Project string              `json:"project,omitempty"`
+	BeadID  string              `json:"bead_id,omitempty"`
+	Actor   string              `json:"actor,omitempty"`
+	Changes []model.FieldChange `json:"changes,omitempty"`
+	Comment *model.Comment      `json:"comment,omitempty"`
+	At      time.Time           `json:"at"`
+}
+
+// resetEvent returns a reset event carrying lastID, the ID of the newest
+// event the client can consider itself caught up to.
+func resetEvent(lastID uint64) Event {
+	return Event{ID: lastID, Type: EventReset, At: time.Now().UTC()}
+}
+
+// eventType maps a history action to its event type. An update that closes
+// the bead is reported as bead.closed.
+func eventType(action string, before, after model.Bead) string {
+	switch action {
+	case model.ActionCreated:
+		return EventBeadCreated
+	case model.ActionDeleted:
+		return EventBeadDeleted
+	case model.ActionClaimed:
+		return EventBeadClaimed
+	case model.ActionReleased:
+		return EventBeadReleased
+	case model.ActionMoved:
+		return EventBeadMoved
+	case model.ActionTransferred:
+		return EventBeadTransferred
+	case model.ActionCommented:
+		return EventCommentAdded
+	case model.ActionLinked:
+		return EventDepLinked
+	case model.ActionUnlinked:
+		return EventDepUnlinked
+	}
+	if after.Status == model.StatusClosed && before.Status != model.StatusClosed {
+		return EventBeadClosed
+	}
+	return EventBeadUpdated
+}
+
+// publish sends ev to event stream subscribers and queues it for delivery
+// to the webhooks of its project.
+func (s *Server) publish(ev Event) {
+	ev = s.broadcaster.publish(ev)
+	s.webhooks.enqueue(ev)
+}
+
+// change runs fn, a mutation of one bead in st, in a store batch together
+// with its history: an entry for the bead, comparing what fn returns with the
+// bead as it was when the batch began, and one for each parent epic whose
+// derived status fn changed. Both states are read under the store's lock, so
+// the entry describes this change and no other, and it is committed in the
+// same journal record or transaction as the change. It returns the bead
+// before and after fn, and the event to publish.
+func (s *Server) change(st store.Backend, project, actor, action string, fn func(tx store.Backend) (model.Bead, error)) (before, after model.Bead, ev Event, err error) {
+	err = st.Batch(func(tx store.Backend) error {
+		var err error
+		if after, err = fn(tx); err != nil {
+			return err
+		}
+		before, _ = tx.Prior(after.ID)
+		if ev, err = s.recordChange(tx, project, actor, action, before, after); err != nil {
+			return err
+		}
+		return recordEpics(tx, before.ParentID, after.ParentID)
+	})
+	return before, after, ev, err
+}
+
+// recordChange records a history entry for a mutation of a bead in project
+// and returns the event to publish for it. tx is the batch the mutation ran
+// in, so the entry is committed with the change or not at all.
+func (s *Server) recordChange(tx store.Backend, project, actor, action string, before, after model.Bead) (Event, error) {
+	if err := recordHistory(tx, actor, action, before, after); err != nil {
+		return Event{}, err
+	}
+
+	ev := Event{
+		Type:    eventType(action, before, after),
+		Project: project,
+		BeadID:  after.ID,
+		Actor:   actor,
+		Changes: model.Diff(before, after),
+		At:      time.Now().UTC(),
+	}
+	if action == model.ActionCommented && len(after.Comments) > 0 {
+		c := after.Comments[len(after.Comments)-1]
+		ev.Comment = &c
+	}
+	return ev, nil
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// collectEvents waits for the next broadcast on ch and returns its events.
+func collectEvents(t *testing.T, srv *Server, ch chan struct{}) []Event {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected broadcast, got none")
+	}
+	return srv.broadcaster.take(ch)
+}
+
+func TestEvents_TypedPerMutation(t *testing.T) {
+	srv := crudServer(t)
+	ch := srv.broadcaster.subscribe()
+	defer srv.broadcaster.unsubscribe(ch)
+
+	b := createViaAPI(t, srv, map[string]any{"title": "Evented"})
+	blocker := createViaAPI(t, srv, map[string]any{"title": "Blocker"})
+
+	req := authReq(http.MethodPatch, "/api/v1/beads/"+b.ID, map[string]any{"priority": "high"})
+	req.Header.Set(ActorHeader, "alice")
+	srv.Router.ServeHTTP(httptest.NewRecorder(), req)
+	srv.Router.ServeHTTP(httptest.NewRecorder(), authReq(http.MethodPost, "/api/v1/beads/"+b.ID+"/claim", map[string]any{"user": "bob"}))
+	srv.Router.ServeHTTP(httptest.NewRecorder(), authReq(http.MethodPost, "/api/v1/beads/"+b.ID+"/comments", map[string]any{"author": "bob", "text": "on it"}))
+	srv.Router.ServeHTTP(httptest.NewRecorder(), authReq(http.MethodPost, "/api/v1/beads/"+b.ID+"/link", map[string]any{"blocked_by": blocker.ID}))
+	srv.Router.ServeHTTP(httptest.NewRecorder(), authReq(http.MethodDelete, "/api/v1/beads/"+b.ID+"/link/"+blocker.ID, nil))
+	srv.Router.ServeHTTP(httptest.NewRecorder(), authReq(http.MethodPatch, "/api/v1/beads/"+b.ID, map[string]any{"status": "closed"}))
+
+	events := collectEvents(t, srv, ch)
+	want := []string{
+		EventBeadCreated, EventBeadCreated, EventBeadUpdated, EventBeadClaimed,
+		EventCommentAdded, EventDepLinked, EventDepUnlinked, EventBeadClosed,
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d: %+v", len(want), len(events), events)
+	}
+	for i, ev := range events {
+		if ev.Type != want[i] {
+			t.Errorf("event %d type = %q, want %q", i, ev.Type, want[i])
+		}
+		if ev.Project != "default" {
+			t.Errorf("event %d project = %q, want default", i, ev.Project)
+		}
+		if ev.At.IsZero() {
+			t.Errorf("event %d has no timestamp", i)
+		}
+	}
+
+	update := events[2]
+	if update.BeadID != b.ID || update.Actor != "alice" {
+		t.Errorf("unexpected update event: %+v", update)
+	}
+	if len(update.Changes) != 1 || update.Changes[0].Field != "priority" || update.Changes[0].New != model.PriorityHigh {
+		t.Errorf("unexpected update changes: %+v", update.Changes)
+	}
+	if events[3].Actor != "bob" {
+		t.Errorf("claim actor = %q, want bob", events[3].Actor)
+	}
+	if c := events[4].Comment; c == nil || c.Text != "on it" {
+		t.Errorf("expected comment in comment.added event, got %+v", c)
+	}
+}
+
+func TestEvents_Clean(t *testing.T) {
+	srv := crudServer(t)
+	ch := srv.broadcaster.subscribe()
+	defer srv.broadcaster.unsubscribe(ch)
+
+	srv.Router.ServeHTTP(httptest.NewRecorder(), authReq(http.MethodPost, "/api/v1/clean", map[string]any{"days": 0}))
+
+	events := collectEvents(t, srv, ch)
+	if len(events) != 1 || events[0].Type != EventBeadsCleaned || events[0].BeadID != "" {
+		t.Errorf("expected a single beads.cleaned event, got %+v", events)
+	}
+}
+
+func TestEvents_ProjectName(t *testing.T) {
+	s1 := loadTestStore(t)
+	s2 := loadTestStore(t)
+	srv, err := New(Config{LogOutput: io.Discard}, NewMultiStoreProvider([]ProviderEntry{
+		{Name: "alpha", Token: "tok-alpha", Store: s1},
+		{Name: "beta", Token: "tok-beta", Store: s2},
+	}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	ch := srv.broadcaster.subscribe()
+	defer srv.broadcaster.unsubscribe(ch)
+
+	req := authReq(http.MethodPost, "/api/v1/beads", map[string]any{"title": "In beta"})
+	req.Header.Set("Authorization", "Bearer tok-beta")
+	srv.Router.ServeHTTP(httptest.NewRecorder(), req)
+
+	events := collectEvents(t, srv, ch)
+	if len(events) != 1 || events[0].Project != "beta" {
+		t.Errorf("expected one event for project beta, got %+v", events)
+	}
+}
@@ -0,0 +1,26 @@
+package server
+
+import "github.com/vector76/beads_server/model"
+
+// linkProjects points each project's store at the others, which lets
+// blocked_by cross projects: a store looks up a blocker it does not hold
+// in the other stores. The lookup reads them with Peek, which never waits
+// on a store's lock, so two stores looking up each other's beads while
+// each holds its own lock cannot deadlock. Nothing is copied, so a change
+// is seen by the other projects as soon as its store has made it.
+func linkProjects(projects []ProjectInfo) {
+	for _, p := range projects {
+		self := p.Store
+		p.Store.SetForeign(func(id string) (model.Bead, bool) {
+			for _, other := range projects {
+				if other.Store == self {
+					continue
+				}
+				if b, ok := other.Store.Peek(id); ok {
+					return b, true
+				}
+			}
+			return model.Bead{}, false
+		})
+	}
+}
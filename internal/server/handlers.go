@@ -6,8 +6,9 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/vector76/beads_server/internal/model"
+	"github.com/vector76/beads_server/internal/project"
 	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
 )
 
 // jsonError writes a JSON error response with the given status code.
@@ -41,6 +42,7 @@ type createRequest struct {
 	BlockedBy   []string       `json:"blocked_by"`
 	Assignee    string         `json:"assignee"`
 	ParentID    string         `json:"parent_id"`
+	ExternalKey string         `json:"external_key"`
 }
 
 // updateRequest is the JSON body for updating a bead.
@@ -92,17 +94,11 @@ type childSummary struct {
 	Assignee string         `json:"assignee"`
 }
 
-// handleCreateBead handles POST /api/v1/beads.
-func (s *Server) handleCreateBead(w http.ResponseWriter, r *http.Request) {
-	var req createRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		jsonError(w, "invalid JSON body", http.StatusBadRequest)
-		return
-	}
-
+// newBead builds a bead from a create request, applying defaults for omitted
+// fields. ParentID is left to the caller.
+func newBead(req createRequest) (model.Bead, error) {
 	if req.Title == "" {
-		jsonError(w, "title is required", http.StatusBadRequest)
-		return
+		return model.Bead{}, errors.New("title is required")
 	}
 
 	b := model.NewBead(req.Title)
@@ -112,8 +108,7 @@ func (s *Server) handleCreateBead(w http.ResponseWriter, r *http.Request) {
 	}
 	if req.Status != "" {
 		if req.Status != model.StatusOpen && req.Status != model.StatusNotReady {
-			jsonError(w, "status at creation must be 'open' or 'not_ready'", http.StatusBadRequest)
-			return
+			return model.Bead{}, errors.New("status at creation must be 'open' or 'not_ready'")
 		}
 		b.Status = req.Status
 	}
@@ -132,36 +127,50 @@ func (s *Server) handleCreateBead(w http.ResponseWriter, r *http.Request) {
 	if req.Assignee != "" {
 		b.Assignee = req.Assignee
 	}
+	b.ExternalKey = req.ExternalKey
+	return b, nil
+}
 
-	st := s.storeFor(r)
+// handleCreateBead handles POST /api/v1/beads.
+func (s *Server) handleCreateBead(w http.ResponseWriter, r *http.Request) {
+	var req createRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
 
-	if req.ParentID != "" {
-		created, err := st.CreateWithParent(b, req.ParentID)
-		if err != nil {
-			code := errorCode(err)
-			jsonError(w, err.Error(), code)
-			return
-		}
-		jsonCreated(w, created)
-		s.broadcaster.publish()
+	b, err := newBead(req)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !mayAssign(r, "", b.Assignee) {
+		jsonError(w, errAssignOthers.Error(), http.StatusForbidden)
 		return
 	}
 
-	excluded := map[string]struct{}{}
-	for _, proj := range s.provider.Projects() {
-		for _, existing := range proj.Store.All() {
-			excluded[existing.ID] = struct{}{}
-		}
+	st := s.storeFor(r)
+	if err := s.checkBlockers(r, st, b.BlockedBy); err != nil {
+		jsonError(w, err.Error(), errorCode(err))
+		return
 	}
 
-	created, err := st.CreateExcluding(b, excluded)
+	claim := s.ids.claim()
+	_, created, ev, err := s.change(st, s.projectFor(r), actorFor(r, ""), model.ActionCreated, func(tx store.Backend) (model.Bead, error) {
+		if req.ParentID != "" {
+			return tx.CreateWithParentExcluding(b, req.ParentID, claim.reserve)
+		}
+		return tx.CreateExcluding(b, claim.reserve)
+	})
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		claim.release()
+		jsonError(w, err.Error(), errorCode(err))
 		return
 	}
 
+	setETag(w, created)
 	jsonCreated(w, created)
-	s.broadcaster.publish()
+	s.publish(ev)
 }
 
 // handleGetBead handles GET /api/v1/beads/:id.
@@ -222,6 +231,7 @@ func (s *Server) handleGetBead(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	setETag(w, b)
 	jsonOK(w, resp)
 }
 
@@ -242,36 +252,75 @@ func (s *Server) handleUpdateBead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	rev, err := ifMatch(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	var req updateRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		jsonError(w, "invalid JSON body", http.StatusBadRequest)
 		return
 	}
 
+	// Deleting and assigning others are writer operations.
+	if req.Status != nil && *req.Status == model.StatusDeleted && !project.RoleAtLeast(principalFor(r).Role, project.RoleWriter) {
+		jsonError(w, "deleting a bead needs the writer role", http.StatusForbidden)
+		return
+	}
+	// The assignee is checked against the bead as the update's batch reads
+	// it, so a claim landing after existing was read is not overlooked.
+	checkAssignee := func(tx store.Backend) error {
+		if req.Assignee == nil {
+			return nil
+		}
+		current, err := tx.Get(existing.ID)
+		if err != nil {
+			return err
+		}
+		if !mayAssign(r, current.Assignee, *req.Assignee) {
+			return errAssignOthers
+		}
+		return nil
+	}
+
 	// Handle parent_id changes (move operations) via dedicated store methods.
 	if req.ParentID != nil {
 		newParent := *req.ParentID
 		if newParent == "" {
 			// Move out
-			updated, err := st.MoveOut(existing.ID)
+			_, updated, ev, err := s.change(st, s.projectFor(r), actorFor(r, ""), model.ActionMoved, func(tx store.Backend) (model.Bead, error) {
+				if err := checkAssignee(tx); err != nil {
+					return model.Bead{}, err
+				}
+				return tx.MoveOutIfRevision(existing.ID, rev)
+			})
 			if err != nil {
 				code := errorCode(err)
 				jsonError(w, err.Error(), code)
 				return
 			}
+			setETag(w, updated)
 			jsonOK(w, updated)
-			s.broadcaster.publish()
+			s.publish(ev)
 			return
 		}
 		// Move into
-		updated, err := st.MoveInto(existing.ID, newParent)
+		_, updated, ev, err := s.change(st, s.projectFor(r), actorFor(r, ""), model.ActionMoved, func(tx store.Backend) (model.Bead, error) {
+			if err := checkAssignee(tx); err != nil {
+				return model.Bead{}, err
+			}
+			return tx.MoveIntoIfRevision(existing.ID, newParent, rev)
+		})
 		if err != nil {
 			code := errorCode(err)
 			jsonError(w, err.Error(), code)
 			return
 		}
+		setETag(w, updated)
 		jsonOK(w, updated)
-		s.broadcaster.publish()
+		s.publish(ev)
 		return
 	}
 
@@ -288,6 +337,19 @@ func (s *Server) handleUpdateBead(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if req.BlockedBy != nil {
+		s.links.Lock()
+		defer s.links.Unlock()
+		if err := s.checkBlockers(r, st, *req.BlockedBy); err != nil {
+			jsonError(w, err.Error(), errorCode(err))
+			return
+		}
+		if err := s.checkForeignCycles(st, existing, *req.BlockedBy); err != nil {
+			jsonError(w, err.Error(), errorCode(err))
+			return
+		}
+	}
+
 	fields := store.UpdateFields{
 		Title:       req.Title,
 		Description: req.Description,
@@ -297,67 +359,74 @@ func (s *Server) handleUpdateBead(w http.ResponseWriter, r *http.Request) {
 		Tags:        req.Tags,
 		BlockedBy:   req.BlockedBy,
 		Assignee:    req.Assignee,
+		IfRevision:  rev,
 	}
 
 	// Handle add_tags / remove_tags
 	if len(req.AddTags) > 0 || len(req.RemoveTags) > 0 {
-		tags := existing.Tags
-
-		// Add tags (avoid duplicates)
-		for _, t := range req.AddTags {
-			found := false
-			for _, et := range tags {
-				if et == t {
-					found = true
-					break
-				}
-			}
-			if !found {
-				tags = append(tags, t)
-			}
-		}
-
-		// Remove tags
-		if len(req.RemoveTags) > 0 {
-			removeSet := make(map[string]bool, len(req.RemoveTags))
-			for _, t := range req.RemoveTags {
-				removeSet[t] = true
-			}
-			filtered := make([]string, 0, len(tags))
-			for _, t := range tags {
-				if !removeSet[t] {
-					filtered = append(filtered, t)
-				}
-			}
-			tags = filtered
-		}
-
+		tags := editTags(existing.Tags, req.AddTags, req.RemoveTags)
 		fields.Tags = &tags
 	}
 
-	updated, err := st.Update(existing.ID, fields)
+	_, updated, ev, err := s.change(st, s.projectFor(r), actorFor(r, ""), model.ActionUpdated, func(tx store.Backend) (model.Bead, error) {
+		if err := checkAssignee(tx); err != nil {
+			return model.Bead{}, err
+		}
+		return tx.Update(existing.ID, fields)
+	})
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		jsonError(w, err.Error(), errorCode(err))
 		return
 	}
 
-	// Recompute parent epic status if this is a child and status changed.
-	if req.Status != nil && existing.ParentID != "" {
-		st.RecomputeParentStatus(existing.ID)
-	}
+	setETag(w, updated)
 
 	// Check if status changed to a terminal state and compute unblocked
 	if req.Status != nil && isTerminalStatus(*req.Status) {
-		unblocked := st.GetUnblocked(existing.ID)
+		unblocked := s.unblockedBy(r, st, existing.ID)
 		if len(unblocked) > 0 {
 			jsonOK(w, unblockedResponse{Bead: updated, Unblocked: unblocked})
-			s.broadcaster.publish()
+			s.publish(ev)
 			return
 		}
 	}
 
 	jsonOK(w, updated)
-	s.broadcaster.publish()
+	s.publish(ev)
+}
+
+// editTags returns tags with add appended (skipping duplicates) and remove
+// taken out.
+func editTags(tags, add, remove []string) []string {
+	// Add tags (avoid duplicates)
+	for _, t := range add {
+		found := false
+		for _, et := range tags {
+			if et == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			tags = append(tags, t)
+		}
+	}
+
+	// Remove tags
+	if len(remove) > 0 {
+		removeSet := make(map[string]bool, len(remove))
+		for _, t := range remove {
+			removeSet[t] = true
+		}
+		filtered := make([]string, 0, len(tags))
+		for _, t := range tags {
+			if !removeSet[t] {
+				filtered = append(filtered, t)
+			}
+		}
+		tags = filtered
+	}
+	return tags
 }
 
 // handleDeleteBead handles DELETE /api/v1/beads/:id.
@@ -377,6 +446,12 @@ func (s *Server) handleDeleteBead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	rev, err := ifMatch(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Reject delete on epics with open children.
 	if err := st.ValidateDeleteOnEpic(existing.ID); err != nil {
 		var conflictErr *store.ConflictError
@@ -388,27 +463,26 @@ func (s *Server) handleDeleteBead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	deleted, err := st.Delete(existing.ID)
+	_, deleted, ev, err := s.change(st, s.projectFor(r), actorFor(r, ""), model.ActionDeleted, func(tx store.Backend) (model.Bead, error) {
+		return tx.DeleteIfRevision(existing.ID, rev)
+	})
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		jsonError(w, err.Error(), errorCode(err))
 		return
 	}
 
-	// Recompute parent epic status if this is a child.
-	if existing.ParentID != "" {
-		st.RecomputeParentStatus(existing.ID)
-	}
+	setETag(w, deleted)
 
 	// Compute unblocked beads
-	unblocked := st.GetUnblocked(existing.ID)
+	unblocked := s.unblockedBy(r, st, existing.ID)
 	if len(unblocked) > 0 {
 		jsonOK(w, unblockedResponse{Bead: deleted, Unblocked: unblocked})
-		s.broadcaster.publish()
+		s.publish(ev)
 		return
 	}
 
 	jsonOK(w, deleted)
-	s.broadcaster.publish()
+	s.publish(ev)
 }
 
 // isTerminalStatus returns true for statuses that could unblock other beads.
@@ -420,8 +494,15 @@ func isTerminalStatus(s model.Status) bool {
 	return false
 }
 
+// errAssignOthers is returned when a token below writer tries to assign a
+// bead to someone else.
+var errAssignOthers = errors.New("assigning a bead to someone else needs the writer role")
+
 // errorCode returns the appropriate HTTP status code for a store error.
 func errorCode(err error) int {
+	if errors.Is(err, errAssignOthers) {
+		return http.StatusForbidden
+	}
 	var notFoundErr *store.NotFoundError
 	if errors.As(err, &notFoundErr) {
 		return http.StatusNotFound
@@ -430,5 +511,9 @@ func errorCode(err error) int {
 	if errors.As(err, &conflictErr) {
 		return http.StatusConflict
 	}
+	var preconditionErr *store.PreconditionError
+	if errors.As(err, &preconditionErr) {
+		return http.StatusPreconditionFailed
+	}
 	return http.StatusBadRequest
 }
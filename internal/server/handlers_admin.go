@@ -0,0 +1,96 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// createProjectRequest is the JSON body for creating a project.
+type createProjectRequest struct {
+	Name     string `json:"name"`
+	DataFile string `json:"data_file"`
+	Backend  string `json:"backend"`
+}
+
+// adminAuth lets through only requests bearing the server's admin token.
+// Project and user tokens, even admin ones, are not accepted.
+func (s *Server) adminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) != 1 {
+			jsonError(w, "invalid admin token", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleListProjects handles GET /api/v1/admin/projects.
+func (s *Server) handleListProjects(w http.ResponseWriter, r *http.Request) {
+	projects, err := s.admin.list()
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	jsonOK(w, projects)
+}
+
+// handleCreateProject handles POST /api/v1/admin/projects. The response is
+// the only place the new project's token is returned.
+func (s *Server) handleCreateProject(w http.ResponseWriter, r *http.Request) {
+	var req createProjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Name == "" {
+		jsonError(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	p, err := s.admin.create(req.Name, req.DataFile, req.Backend)
+	if err != nil {
+		jsonError(w, err.Error(), errorCode(err))
+		return
+	}
+
+	jsonCreated(w, p)
+}
+
+// handleRotateProjectToken handles POST /api/v1/admin/projects/:name/rotate-token.
+func (s *Server) handleRotateProjectToken(w http.ResponseWriter, r *http.Request) {
+	p, err := s.admin.rotateToken(chi.URLParam(r, "name"))
+	if err != nil {
+		jsonError(w, err.Error(), errorCode(err))
+		return
+	}
+
+	jsonOK(w, p)
+}
+
+// handleArchiveProject handles POST /api/v1/admin/projects/:name/archive.
+func (s *Server) handleArchiveProject(w http.ResponseWriter, r *http.Request) {
+	p, err := s.admin.archive(chi.URLParam(r, "name"))
+	if err != nil {
+		jsonError(w, err.Error(), errorCode(err))
+		return
+	}
+
+	jsonOK(w, p)
+}
+
+// handleDeleteProject handles DELETE /api/v1/admin/projects/:name.
+func (s *Server) handleDeleteProject(w http.ResponseWriter, r *http.Request) {
+	p, err := s.admin.remove(chi.URLParam(r, "name"))
+	if err != nil {
+		jsonError(w, err.Error(), errorCode(err))
+		return
+	}
+
+	jsonOK(w, p)
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/vector76/beads_server/internal/project"
+	"github.com/vector76/beads_server/internal/store"
+)
+
+const testAdminToken = "test-admin-token"
+
+// adminServer returns a multi-project server whose admin API edits a
+// projects file in a temp dir, starting with project "first". Each reload
+// reopens every store, which is enough for these tests.
+func adminServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "projects.json")
+	err := project.SaveProjectsFile(path, []project.ProjectEntry{
+		{Name: "first", Token: "tok-first", DataFile: filepath.Join(dir, "first.json")},
+	})
+	if err != nil {
+		t.Fatalf("SaveProjectsFile: %v", err)
+	}
+
+	load := func() ([]ProviderEntry, error) {
+		entries, err := project.LoadProjectsFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var pe []ProviderEntry
+		for _, e := range entries {
+			if e.Archived {
+				continue
+			}
+			s, err := store.Open(e.Backend, e.DataFile)
+			if err != nil {
+				return nil, err
+			}
+			pe = append(pe, ProviderEntry{Name: e.Name, Token: e.Token, TokenHash: e.TokenHash, Store: s})
+		}
+		return pe, nil
+	}
+	entries, err := load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	var srv *Server
+	srv, err = New(Config{
+		LogOutput:    io.Discard,
+		AdminToken:   testAdminToken,
+		ProjectsFile: path,
+		ReloadProjectsFile: func() error {
+			entries, err := load()
+			if err != nil {
+				return err
+			}
+			return srv.ReloadProjects(entries, nil)
+		},
+	}, NewMultiStoreProvider(entries))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	return srv, path
+}
+
+func decodeAdmin[T any](t *testing.T, body io.Reader) T {
+	t.Helper()
+	var v T
+	if err := json.NewDecoder(body).Decode(&v); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	return v
+}
+
+func TestAdmin_ProjectLifecycle(t *testing.T) {
+	srv, path := adminServer(t)
+
+	w := serveAs(srv, testAdminToken, http.MethodPost, "/api/v1/admin/projects", map[string]any{"name": "second"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	created := decodeAdmin[AdminProjectToken](t, w.Body)
+	if created.Token == "" || created.DataFile != filepath.Join(filepath.Dir(path), "second.json") || created.Backend != store.BackendJSON {
+		t.Errorf("created = %+v", created)
+	}
+	if got := serveAs(srv, created.Token, http.MethodGet, "/api/v1/beads", nil).Code; got != http.StatusOK {
+		t.Errorf("new project's token: got %d, want 200", got)
+	}
+
+	w = serveAs(srv, testAdminToken, http.MethodGet, "/api/v1/admin/projects", nil)
+	projects := decodeAdmin[[]AdminProject](t, w.Body)
+	if len(projects) != 2 || projects[1].Name != "second" {
+		t.Errorf("projects = %+v", projects)
+	}
+
+	w = serveAs(srv, testAdminToken, http.MethodPost, "/api/v1/admin/projects/second/rotate-token", nil)
+	rotated := decodeAdmin[AdminProjectToken](t, w.Body)
+	if got := serveAs(srv, created.Token, http.MethodGet, "/api/v1/beads", nil).Code; got != http.StatusUnauthorized {
+		t.Errorf("rotated-out token: got %d, want 401", got)
+	}
+	if got := serveAs(srv, rotated.Token, http.MethodPost, "/api/v1/beads", map[string]any{"title": "Kept"}).Code; got != http.StatusCreated {
+		t.Errorf("rotated-in token: got %d, want 201", got)
+	}
+
+	if got := serveAs(srv, testAdminToken, http.MethodDelete, "/api/v1/admin/projects/second", nil).Code; got != http.StatusConflict {
+		t.Errorf("delete before archive: got %d, want 409", got)
+	}
+	w = serveAs(srv, testAdminToken, http.MethodPost, "/api/v1/admin/projects/second/archive", nil)
+	if archived := decodeAdmin[AdminProject](t, w.Body); !archived.Archived {
+		t.Errorf("archive = %+v", archived)
+	}
+	if got := serveAs(srv, rotated.Token, http.MethodGet, "/api/v1/beads", nil).Code; got != http.StatusUnauthorized {
+		t.Errorf("archived project's token: got %d, want 401", got)
+	}
+	if kept, _ := store.Load(created.DataFile); len(kept.All()) != 1 {
+		t.Error("archived project's data should be kept")
+	}
+
+	if got := serveAs(srv, testAdminToken, http.MethodDelete, "/api/v1/admin/projects/second", nil).Code; got != http.StatusOK {
+		t.Errorf("delete: got %d, want 200", got)
+	}
+	if gone, _ := store.Load(created.DataFile); len(gone.All()) != 0 {
+		t.Error("deleted project's data should be removed")
+	}
+	entries, _ := project.LoadProjectsFile(path)
+	if len(entries) != 1 || entries[0].Name != "first" {
+		t.Errorf("projects file = %+v, want only first", entries)
+	}
+}
+
+func TestAdmin_Errors(t *testing.T) {
+	srv, _ := adminServer(t)
+
+	cases := []struct {
+		token, method, url string
+		body               any
+		want               int
+	}{
+		{"tok-first", http.MethodGet, "/api/v1/admin/projects", nil, http.StatusUnauthorized},
+		{"", http.MethodGet, "/api/v1/admin/projects", nil, http.StatusUnauthorized},
+		{testAdminToken, http.MethodPost, "/api/v1/admin/projects", map[string]any{"name": "first"}, http.StatusConflict},
+		{testAdminToken, http.MethodPost, "/api/v1/admin/projects", map[string]any{"name": "../escape"}, http.StatusBadRequest},
+		{testAdminToken, http.MethodPost, "/api/v1/admin/projects", map[string]any{}, http.StatusBadRequest},
+		{testAdminToken, http.MethodPost, "/api/v1/admin/projects", map[string]any{"name": "x", "backend": "csv"}, http.StatusBadRequest},
+		{testAdminToken, http.MethodPost, "/api/v1/admin/projects/none/archive", nil, http.StatusNotFound},
+		{testAdminToken, http.MethodPost, "/api/v1/admin/projects/none/rotate-token", nil, http.StatusNotFound},
+	}
+	for _, c := range cases {
+		if got := serveAs(srv, c.token, c.method, c.url, c.body).Code; got != c.want {
+			t.Errorf("%s %s %v: got %d, want %d", c.method, c.url, c.body, got, c.want)
+		}
+	}
+}
+
+func TestAdmin_DisabledWithoutAdminToken(t *testing.T) {
+	srv := testServer(t)
+	if got := serveAs(srv, testToken, http.MethodGet, "/api/v1/admin/projects", nil).Code; got != http.StatusNotFound {
+		t.Errorf("admin API without an admin token: got %d, want 404", got)
+	}
+}
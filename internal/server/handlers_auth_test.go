@@ -0,0 +1,240 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/vector76/beads_server/internal/project"
+	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
+)
+
+// usersServer returns a server for one project with a project token and a
+// user of each role, whose token is "tok-" plus the role.
+func usersServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := store.Load(filepath.Join(t.TempDir(), "beads.json"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var users []project.UserEntry
+	for _, role := range project.Roles {
+		users = append(users, project.UserEntry{Name: role + "-user", Role: role, TokenHash: project.HashToken("tok-" + role)})
+	}
+	p := NewMultiStoreProvider([]ProviderEntry{{Name: "proj", Token: "tok-project", Users: users, Store: s}})
+	srv, err := New(Config{LogOutput: io.Discard}, p)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return srv
+}
+
+func serveAs(srv *Server, token, method, url string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	r := httptest.NewRequest(method, url, &buf)
+	r.Header.Set("Authorization", "Bearer "+token)
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, r)
+	return w
+}
+
+func createAs(t *testing.T, srv *Server, token, title string) model.Bead {
+	t.Helper()
+	w := serveAs(srv, token, http.MethodPost, "/api/v1/beads", map[string]any{"title": title})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var b model.Bead
+	json.NewDecoder(w.Body).Decode(&b)
+	return b
+}
+
+func TestRoles_Enforced(t *testing.T) {
+	srv := usersServer(t)
+	b := createAs(t, srv, "tok-project", "Guarded")
+
+	cases := []struct {
+		method, url string
+		body        any
+		min         string
+	}{
+		{http.MethodGet, "/api/v1/beads", nil, project.RoleReader},
+		{http.MethodGet, "/api/v1/beads/" + b.ID, nil, project.RoleReader},
+		{http.MethodPost, "/api/v1/beads", map[string]any{"title": "New"}, project.RoleAgent},
+		{http.MethodPost, "/api/v1/beads/" + b.ID + "/comments", map[string]any{"text": "hi"}, project.RoleAgent},
+		{http.MethodPost, "/api/v1/batch", map[string]any{"ops": []any{}}, project.RoleWriter},
+		{http.MethodPost, "/api/v1/clean", map[string]any{}, project.RoleAdmin},
+		{http.MethodGet, "/api/v1/webhooks", nil, project.RoleAdmin},
+	}
+	for _, c := range cases {
+		for _, role := range project.Roles {
+			w := serveAs(srv, "tok-"+role, c.method, c.url, c.body)
+			allowed := project.RoleAtLeast(role, c.min)
+			if allowed && w.Code == http.StatusForbidden {
+				t.Errorf("%s %s as %s: got 403, want it allowed", c.method, c.url, role)
+			}
+			if !allowed && w.Code != http.StatusForbidden {
+				t.Errorf("%s %s as %s: got %d, want 403", c.method, c.url, role, w.Code)
+			}
+		}
+	}
+
+	if w := serveAs(srv, "tok-"+project.RoleAgent, http.MethodDelete, "/api/v1/beads/"+b.ID, nil); w.Code != http.StatusForbidden {
+		t.Errorf("delete as agent: got %d, want 403", w.Code)
+	}
+	if w := serveAs(srv, "tok-project", http.MethodDelete, "/api/v1/beads/"+b.ID, nil); w.Code != http.StatusOK {
+		t.Errorf("delete with the project token: got %d, want 200: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRoles_UserTokenSetsActor(t *testing.T) {
+	srv := usersServer(t)
+	token := "tok-" + project.RoleAgent
+	b := createAs(t, srv, token, "Mine")
+
+	// The body and header name someone else; the token's user wins.
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/beads/"+b.ID+"/claim", bytes.NewBufferString(`{"user": "mallory"}`))
+	r.Header.Set("Authorization", "Bearer "+token)
+	r.Header.Set(ActorHeader, "mallory")
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("claim: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var claimed model.Bead
+	json.NewDecoder(w.Body).Decode(&claimed)
+	if claimed.Assignee != "agent-user" {
+		t.Errorf("assignee = %q, want agent-user", claimed.Assignee)
+	}
+
+	w = serveAs(srv, token, http.MethodPost, "/api/v1/beads/"+b.ID+"/comments", map[string]any{"author": "mallory", "text": "done"})
+	var commented model.Bead
+	json.NewDecoder(w.Body).Decode(&commented)
+	if len(commented.Comments) != 1 || commented.Comments[0].Author != "agent-user" {
+		t.Errorf("comments = %+v, want one by agent-user", commented.Comments)
+	}
+
+	w = serveAs(srv, token, http.MethodGet, "/api/v1/beads/"+b.ID+"/history", nil)
+	var h historyResponse
+	json.NewDecoder(w.Body).Decode(&h)
+	for _, e := range h.History {
+		if e.Actor != "agent-user" {
+			t.Errorf("history %s actor = %q, want agent-user", e.Action, e.Actor)
+		}
+	}
+}
+
+func TestRoles_PatchDeleteNeedsWriter(t *testing.T) {
+	srv := usersServer(t)
+	for _, role := range project.Roles[1:] {
+		b := createAs(t, srv, "tok-project", "Doomed")
+		w := serveAs(srv, "tok-"+role, http.MethodPatch, "/api/v1/beads/"+b.ID, map[string]any{"status": "deleted"})
+		allowed := project.RoleAtLeast(role, project.RoleWriter)
+		if allowed && w.Code != http.StatusOK {
+			t.Errorf("delete via PATCH as %s: got %d, want 200: %s", role, w.Code, w.Body.String())
+		}
+		if !allowed && w.Code != http.StatusForbidden {
+			t.Errorf("delete via PATCH as %s: got %d, want 403", role, w.Code)
+		}
+	}
+
+	// Other status changes stay open to agents.
+	b := createAs(t, srv, "tok-project", "Done")
+	if w := serveAs(srv, "tok-"+project.RoleAgent, http.MethodPatch, "/api/v1/beads/"+b.ID, map[string]any{"status": "closed"}); w.Code != http.StatusOK {
+		t.Errorf("close as agent: got %d, want 200: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRoles_AssigningOthersNeedsWriter(t *testing.T) {
+	srv := usersServer(t)
+	agent, writer := "tok-"+project.RoleAgent, "tok-"+project.RoleWriter
+	b := createAs(t, srv, "tok-project", "Task")
+	patch := func(token, assignee string) int {
+		return serveAs(srv, token, http.MethodPatch, "/api/v1/beads/"+b.ID, map[string]any{"assignee": assignee}).Code
+	}
+
+	if code := patch(agent, "mallory"); code != http.StatusForbidden {
+		t.Errorf("agent assigning mallory: got %d, want 403", code)
+	}
+	if code := patch(agent, "agent-user"); code != http.StatusOK {
+		t.Errorf("agent assigning itself: got %d, want 200", code)
+	}
+	if code := patch(agent, ""); code != http.StatusOK {
+		t.Errorf("agent releasing its own bead: got %d, want 200", code)
+	}
+	if code := patch(writer, "mallory"); code != http.StatusOK {
+		t.Errorf("writer assigning mallory: got %d, want 200", code)
+	}
+	if code := patch(agent, ""); code != http.StatusForbidden {
+		t.Errorf("agent unassigning mallory: got %d, want 403", code)
+	}
+	if code := patch("tok-project", "someone"); code != http.StatusOK {
+		t.Errorf("project token assigning someone: got %d, want 200", code)
+	}
+
+	w := serveAs(srv, agent, http.MethodPost, "/api/v1/beads", map[string]any{"title": "New", "assignee": "mallory"})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("agent creating a bead for mallory: got %d, want 403", w.Code)
+	}
+}
+
+// stallingBody is a request body whose first Read waits until release is
+// closed, after signalling on reading.
+type stallingBody struct {
+	r                *bytes.Reader
+	reading, release chan struct{}
+	once             sync.Once
+}
+
+func (b *stallingBody) Read(p []byte) (int, error) {
+	b.once.Do(func() {
+		close(b.reading)
+		<-b.release
+	})
+	return b.r.Read(p)
+}
+
+func TestRoles_ReleaseCheckedAgainstCurrentAssignee(t *testing.T) {
+	srv := usersServer(t)
+	agent, writer := "tok-"+project.RoleAgent, "tok-"+project.RoleWriter
+	b := createAs(t, srv, "tok-project", "Task")
+	url := "/api/v1/beads/" + b.ID
+	serveAs(srv, writer, http.MethodPatch, url, map[string]any{"assignee": "agent-user"})
+
+	// The agent's release has looked the bead up, while it still held it,
+	// by the time the writer hands it to mallory.
+	body := &stallingBody{r: bytes.NewReader([]byte(`{"assignee": ""}`)), reading: make(chan struct{}), release: make(chan struct{})}
+	r := httptest.NewRequest(http.MethodPatch, url, body)
+	r.Header.Set("Authorization", "Bearer "+agent)
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		srv.Router.ServeHTTP(w, r)
+	}()
+	<-body.reading
+	if code := serveAs(srv, writer, http.MethodPatch, url, map[string]any{"assignee": "mallory"}).Code; code != http.StatusOK {
+		t.Fatalf("writer assigning mallory: got %d, want 200", code)
+	}
+	close(body.release)
+	<-done
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("agent releasing mallory's bead: got %d, want 403: %s", w.Code, w.Body.String())
+	}
+	var got model.Bead
+	json.NewDecoder(serveAs(srv, writer, http.MethodGet, url, nil).Body).Decode(&got)
+	if got.Assignee != "mallory" {
+		t.Errorf("assignee = %q, want mallory", got.Assignee)
+	}
+}
@@ -0,0 +1,378 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
+)
+
+// maxBatchOps caps the number of operations in one batch request.
+const maxBatchOps = 1000
+
+// Batch operation kinds.
+const (
+	batchCreate  = "create"
+	batchUpdate  = "update"
+	batchLink    = "link"
+	batchMove    = "move"
+	batchComment = "comment"
+)
+
+// batchRequest is the JSON body for POST /api/v1/batch.
+type batchRequest struct {
+	Ops []batchOp `json:"ops"`
+}
+
+// batchOp is one operation in a batch. Which fields apply depends on Op:
+//
+//	create:  bead, ref
+//	update:  id, fields
+//	link:    id, blocked_by
+//	move:    id, parent_id ("" moves the bead out of its epic)
+//	comment: id, author, text
+//
+// Wherever a bead ID is expected, "$name" refers to the bead created by an
+// earlier create op with ref "name".
+type batchOp struct {
+	Op        string         `json:"op"`
+	Ref       string         `json:"ref"`
+	ID        string         `json:"id"`
+	Bead      *createRequest `json:"bead"`
+	Fields    *updateRequest `json:"fields"`
+	BlockedBy string         `json:"blocked_by"`
+	ParentID  *string        `json:"parent_id"`
+	Author    string         `json:"author"`
+	Text      string         `json:"text"`
+}
+
+// batchResponse is the JSON response for a successful batch: the ID each ref
+// was given, and the bead each op left behind, in op order.
+type batchResponse struct {
+	Refs    map[string]string `json:"refs"`
+	Results []model.Bead      `json:"results"`
+}
+
+// batchError is the JSON response for a failed batch. Index is the position
+// of the op that failed.
+type batchError struct {
+	Error string `json:"error"`
+	Index int    `json:"index"`
+}
+
+// batch applies the ops of one request inside a store batch, recording
+// history as it goes and collecting the events to publish once the batch
+// has committed.
+type batch struct {
+	s       *Server
+	r       *http.Request
+	tx      store.Backend
+	project string
+	ids     *idClaim
+	refs    map[string]string
+	results []model.Bead
+	events  []Event
+	epics   []string // parents of the beads the ops changed
+}
+
+// handleBatch handles POST /api/v1/batch. The ops are applied in order and
+// all-or-nothing: if any op fails, none of them take effect.
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	var req batchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if len(req.Ops) == 0 {
+		jsonError(w, "ops is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Ops) > maxBatchOps {
+		jsonError(w, fmt.Sprintf("a batch may contain at most %d ops", maxBatchOps), http.StatusBadRequest)
+		return
+	}
+
+	b := &batch{
+		s:       s,
+		r:       r,
+		project: s.projectFor(r),
+		ids:     s.ids.claim(),
+		refs:    map[string]string{},
+	}
+
+	if addsDeps(req.Ops) {
+		s.links.Lock()
+		defer s.links.Unlock()
+	}
+
+	failed := -1
+	err := s.storeFor(r).Batch(func(tx store.Backend) error {
+		b.tx = tx
+		for i, op := range req.Ops {
+			if err := b.apply(op); err != nil {
+				failed = i
+				return err
+			}
+		}
+		return recordEpics(tx, b.epics...)
+	})
+	if err != nil {
+		b.ids.release()
+	}
+	if err != nil && failed < 0 {
+		// Every op applied but the commit failed.
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(errorCode(err))
+		json.NewEncoder(w).Encode(batchError{
+			Error: fmt.Sprintf("op %d (%s): %v", failed, req.Ops[failed].Op, err),
+			Index: failed,
+		})
+		return
+	}
+
+	jsonOK(w, batchResponse{Refs: b.refs, Results: b.results})
+	for _, ev := range b.events {
+		s.publish(ev)
+	}
+}
+
+// apply runs one op against the batch's store.
+func (b *batch) apply(op batchOp) error {
+	if op.Op == batchCreate {
+		_, err := b.create(op)
+		return err
+	}
+
+	id, err := b.resolve(op.ID)
+	if err != nil {
+		return err
+	}
+	if id == "" {
+		return errors.New("id is required")
+	}
+	existing, err := b.tx.Resolve(id)
+	if err != nil {
+		return err
+	}
+
+	switch op.Op {
+	case batchUpdate:
+		if op.Fields == nil {
+			return errors.New("fields is required")
+		}
+		return b.update(existing, *op.Fields)
+	case batchLink:
+		return b.link(existing, op.BlockedBy)
+	case batchMove:
+		if op.ParentID == nil {
+			return errors.New("parent_id is required")
+		}
+		return b.move(existing, *op.ParentID)
+	case batchComment:
+		return b.comment(existing, userFor(b.r, op.Author), op.Text)
+	}
+	return fmt.Errorf("unknown op %q", op.Op)
+}
+
+// resolve replaces a "$ref" with the ID of the bead created for it. Other
+// IDs are returned unchanged.
+func (b *batch) resolve(id string) (string, error) {
+	ref, ok := strings.CutPrefix(id, "$")
+	if !ok {
+		return id, nil
+	}
+	real, ok := b.refs[ref]
+	if !ok {
+		return "", fmt.Errorf("unknown ref %q", id)
+	}
+	return real, nil
+}
+
+// resolveAll applies resolve to each ID.
+func (b *batch) resolveAll(ids []string) ([]string, error) {
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		real, err := b.resolve(id)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = real
+	}
+	return out, nil
+}
+
+// done records the outcome of an op: a history entry, a pending event and
+// the resulting bead. It notes the bead's parents before and after, whose
+// derived status the batch may have changed.
+func (b *batch) done(actor, action string, before, after model.Bead) error {
+	ev, err := b.s.recordChange(b.tx, b.project, actor, action, before, after)
+	if err != nil {
+		return err
+	}
+	b.events = append(b.events, ev)
+	b.results = append(b.results, after)
+	b.epics = append(b.epics, before.ParentID, after.ParentID)
+	return nil
+}
+
+func (b *batch) create(op batchOp) (model.Bead, error) {
+	if op.Bead == nil {
+		return model.Bead{}, errors.New("bead is required")
+	}
+	if op.Ref != "" {
+		if _, dup := b.refs[op.Ref]; dup {
+			return model.Bead{}, fmt.Errorf("duplicate ref %q", op.Ref)
+		}
+	}
+
+	bead, err := newBead(*op.Bead)
+	if err != nil {
+		return model.Bead{}, err
+	}
+	if bead.BlockedBy, err = b.resolveAll(bead.BlockedBy); err != nil {
+		return model.Bead{}, err
+	}
+	if err := b.s.checkBlockers(b.r, b.tx, bead.BlockedBy); err != nil {
+		return model.Bead{}, err
+	}
+	parentID, err := b.resolve(op.Bead.ParentID)
+	if err != nil {
+		return model.Bead{}, err
+	}
+
+	var created model.Bead
+	if parentID != "" {
+		created, err = b.tx.CreateWithParentExcluding(bead, parentID, b.ids.reserve)
+	} else {
+		created, err = b.tx.CreateExcluding(bead, b.ids.reserve)
+	}
+	if err != nil {
+		return model.Bead{}, err
+	}
+
+	if op.Ref != "" {
+		b.refs[op.Ref] = created.ID
+	}
+	if err := b.done(actorFor(b.r, ""), model.ActionCreated, model.Bead{}, created); err != nil {
+		return model.Bead{}, err
+	}
+	return created, nil
+}
+
+// addsDeps reports whether any of ops adds dependencies to existing beads,
+// so that the batch must hold the server's links lock.
+func addsDeps(ops []batchOp) bool {
+	return slices.ContainsFunc(ops, func(op batchOp) bool {
+		return op.Op == batchLink || op.Op == batchUpdate && op.Fields != nil && op.Fields.BlockedBy != nil
+	})
+}
+
+func (b *batch) update(existing model.Bead, req updateRequest) error {
+	if req.ParentID != nil {
+		return b.move(existing, *req.ParentID)
+	}
+
+	if req.Status != nil {
+		if err := b.tx.ValidateStatusChangeOnEpic(existing.ID); err != nil {
+			return err
+		}
+	}
+
+	fields := store.UpdateFields{
+		Title:       req.Title,
+		Description: req.Description,
+		Status:      req.Status,
+		Priority:    req.Priority,
+		Type:        req.Type,
+		Tags:        req.Tags,
+		Assignee:    req.Assignee,
+	}
+	if req.BlockedBy != nil {
+		blockedBy, err := b.resolveAll(*req.BlockedBy)
+		if err != nil {
+			return err
+		}
+		if err := b.s.checkBlockers(b.r, b.tx, blockedBy); err != nil {
+			return err
+		}
+		if err := b.s.checkForeignCycles(b.tx, existing, blockedBy); err != nil {
+			return err
+		}
+		fields.BlockedBy = &blockedBy
+	}
+	if len(req.AddTags) > 0 || len(req.RemoveTags) > 0 {
+		tags := editTags(existing.Tags, req.AddTags, req.RemoveTags)
+		fields.Tags = &tags
+	}
+
+	updated, err := b.tx.Update(existing.ID, fields)
+	if err != nil {
+		return err
+	}
+	return b.done(actorFor(b.r, ""), model.ActionUpdated, existing, updated)
+}
+
+func (b *batch) link(existing model.Bead, blockedBy string) error {
+	if blockedBy == "" {
+		return errors.New("blocked_by is required")
+	}
+	blockedBy, err := b.resolve(blockedBy)
+	if err != nil {
+		return err
+	}
+	target, err := b.s.resolveBlocker(b.r, b.tx, blockedBy)
+	if err != nil {
+		return err
+	}
+	if err := b.tx.ValidateLinkParentChild(existing.ID, target.ID); err != nil {
+		return err
+	}
+
+	updated, err := b.tx.Link(existing.ID, target.ID)
+	if err != nil {
+		return err
+	}
+	return b.done(actorFor(b.r, ""), model.ActionLinked, existing, updated)
+}
+
+func (b *batch) move(existing model.Bead, parentID string) error {
+	var updated model.Bead
+	var err error
+	if parentID == "" {
+		updated, err = b.tx.MoveOut(existing.ID)
+	} else {
+		if parentID, err = b.resolve(parentID); err != nil {
+			return err
+		}
+		updated, err = b.tx.MoveInto(existing.ID, parentID)
+	}
+	if err != nil {
+		return err
+	}
+	return b.done(actorFor(b.r, ""), model.ActionMoved, existing, updated)
+}
+
+func (b *batch) comment(existing model.Bead, author, text string) error {
+	if author == "" {
+		return errors.New("author is required")
+	}
+	if text == "" {
+		return errors.New("text is required")
+	}
+
+	updated, err := b.tx.AddComment(existing.ID, model.Comment{Author: author, Text: text})
+	if err != nil {
+		return err
+	}
+	return b.done(actorFor(b.r, author), model.ActionCommented, existing, updated)
+}
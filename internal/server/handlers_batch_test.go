@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vector76/beads_server/model"
+)
+
+func postBatch(t *testing.T, srv *Server, ops ...map[string]any) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodPost, "/api/v1/batch", map[string]any{"ops": ops}))
+	return w
+}
+
+func TestBatch_EpicWithWiredChildren(t *testing.T) {
+	srv := crudServer(t)
+	ch := srv.broadcaster.subscribe()
+	defer srv.broadcaster.unsubscribe(ch)
+	existing := createViaAPI(t, srv, map[string]any{"title": "Existing"})
+
+	w := postBatch(t, srv,
+		map[string]any{"op": "create", "ref": "epic", "bead": map[string]any{"title": "Epic"}},
+		map[string]any{"op": "create", "ref": "design", "bead": map[string]any{"title": "Design", "parent_id": "$epic"}},
+		map[string]any{"op": "create", "ref": "build", "bead": map[string]any{"title": "Build", "parent_id": "$epic", "blocked_by": []string{"$design"}}},
+		map[string]any{"op": "link", "id": "$build", "blocked_by": existing.ID},
+		map[string]any{"op": "update", "id": "$design", "fields": map[string]any{"priority": "high", "add_tags": []string{"ux"}}},
+		map[string]any{"op": "comment", "id": "$epic", "author": "planner", "text": "kicked off"},
+	)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp batchResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Refs) != 3 || len(resp.Results) != 6 {
+		t.Fatalf("refs = %v, %d results; want 3 refs, 6 results", resp.Refs, len(resp.Results))
+	}
+
+	build, err := soleStore(srv).Get(resp.Refs["build"])
+	if err != nil {
+		t.Fatalf("Get build: %v", err)
+	}
+	if build.ParentID != resp.Refs["epic"] {
+		t.Errorf("build parent = %q, want %q", build.ParentID, resp.Refs["epic"])
+	}
+	if len(build.BlockedBy) != 2 || build.BlockedBy[0] != resp.Refs["design"] || build.BlockedBy[1] != existing.ID {
+		t.Errorf("build blocked_by = %v", build.BlockedBy)
+	}
+	design, _ := soleStore(srv).Get(resp.Refs["design"])
+	if design.Priority != model.PriorityHigh || len(design.Tags) != 1 {
+		t.Errorf("design = %+v", design)
+	}
+	if h, _ := soleStore(srv).History(build.ID); len(h) != 2 {
+		t.Errorf("build history = %+v, want created and linked", h)
+	}
+
+	// The batch's events follow the one for creating Existing.
+	events := collectEvents(t, srv, ch)
+	if len(events) < 6 {
+		t.Fatalf("expected an event per op, got %+v", events)
+	}
+	events = events[len(events)-6:]
+	if events[0].Type != EventBeadCreated || events[0].BeadID != resp.Refs["epic"] || events[5].Type != EventCommentAdded {
+		t.Errorf("events = %+v", events)
+	}
+}
+
+func TestBatch_FailureAppliesNothing(t *testing.T) {
+	srv := crudServer(t)
+	existing := createViaAPI(t, srv, map[string]any{"title": "Existing"})
+	ch := srv.broadcaster.subscribe()
+	defer srv.broadcaster.unsubscribe(ch)
+
+	w := postBatch(t, srv,
+		map[string]any{"op": "create", "ref": "a", "bead": map[string]any{"title": "A"}},
+		map[string]any{"op": "update", "id": existing.ID, "fields": map[string]any{"title": "Renamed"}},
+		map[string]any{"op": "link", "id": "$a", "blocked_by": "bd-nope"},
+	)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp batchError
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Index != 2 || resp.Error == "" {
+		t.Errorf("error response = %+v, want index 2", resp)
+	}
+
+	if all := soleStore(srv).All(); len(all) != 1 || all[0].Title != "Existing" {
+		t.Errorf("store after failed batch = %+v", all)
+	}
+	assertNoBroadcast(t, ch)
+}
+
+func TestBatch_Validation(t *testing.T) {
+	srv := crudServer(t)
+
+	tests := []struct {
+		name  string
+		ops   []map[string]any
+		index int
+	}{
+		{"unknown ref", []map[string]any{
+			{"op": "link", "id": "$missing", "blocked_by": "$other"},
+		}, 0},
+		{"duplicate ref", []map[string]any{
+			{"op": "create", "ref": "a", "bead": map[string]any{"title": "A"}},
+			{"op": "create", "ref": "a", "bead": map[string]any{"title": "A again"}},
+		}, 1},
+		{"unknown op", []map[string]any{
+			{"op": "create", "ref": "a", "bead": map[string]any{"title": "A"}},
+			{"op": "explode", "id": "$a"},
+		}, 1},
+		{"missing title", []map[string]any{
+			{"op": "create", "bead": map[string]any{}},
+		}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := postBatch(t, srv, tt.ops...)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+			}
+			var resp batchError
+			json.NewDecoder(w.Body).Decode(&resp)
+			if resp.Index != tt.index {
+				t.Errorf("index = %d, want %d (%s)", resp.Index, tt.index, resp.Error)
+			}
+		})
+	}
+
+	if w := postBatch(t, srv); w.Code != http.StatusBadRequest {
+		t.Errorf("empty batch: expected 400, got %d", w.Code)
+	}
+	if all := soleStore(srv).All(); len(all) != 0 {
+		t.Errorf("store after invalid batches = %+v", all)
+	}
+}
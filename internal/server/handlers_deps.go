@@ -3,11 +3,13 @@ package server
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/vector76/beads_server/internal/model"
 	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
 )
 
 // commentRequest is the JSON body for adding a comment.
@@ -36,11 +38,18 @@ func (s *Server) handleAddComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	rev, err := ifMatch(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	var req commentRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		jsonError(w, "invalid JSON body", http.StatusBadRequest)
 		return
 	}
+	req.Author = userFor(r, req.Author)
 
 	if req.Author == "" {
 		jsonError(w, "author is required", http.StatusBadRequest)
@@ -56,14 +65,17 @@ func (s *Server) handleAddComment(w http.ResponseWriter, r *http.Request) {
 		Text:   req.Text,
 	}
 
-	updated, err := s.storeFor(r).AddComment(existing.ID, comment)
+	_, updated, ev, err := s.change(s.storeFor(r), s.projectFor(r), actorFor(r, req.Author), model.ActionCommented, func(tx store.Backend) (model.Bead, error) {
+		return tx.AddCommentIfRevision(existing.ID, comment, rev)
+	})
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		jsonError(w, err.Error(), errorCode(err))
 		return
 	}
 
+	setETag(w, updated)
 	jsonCreated(w, updated)
-	s.broadcaster.publish()
+	s.publish(ev)
 }
 
 // handleLinkBead handles POST /api/v1/beads/:id/link.
@@ -82,6 +94,12 @@ func (s *Server) handleLinkBead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	rev, err := ifMatch(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	var req linkRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		jsonError(w, "invalid JSON body", http.StatusBadRequest)
@@ -93,15 +111,13 @@ func (s *Server) handleLinkBead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Resolve the target ID as well
-	target, err := st.Resolve(req.BlockedBy)
+	s.links.Lock()
+	defer s.links.Unlock()
+
+	// Resolve the target ID as well; it may be in another project.
+	target, err := s.resolveBlocker(r, st, req.BlockedBy)
 	if err != nil {
-		var notFoundErr *store.NotFoundError
-		if errors.As(err, &notFoundErr) {
-			jsonError(w, err.Error(), http.StatusNotFound)
-			return
-		}
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		jsonError(w, err.Error(), errorCode(err))
 		return
 	}
 
@@ -116,14 +132,17 @@ func (s *Server) handleLinkBead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	updated, err := st.Link(existing.ID, target.ID)
+	_, updated, ev, err := s.change(st, s.projectFor(r), actorFor(r, ""), model.ActionLinked, func(tx store.Backend) (model.Bead, error) {
+		return tx.LinkIfRevision(existing.ID, target.ID, rev)
+	})
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		jsonError(w, err.Error(), errorCode(err))
 		return
 	}
 
+	setETag(w, updated)
 	jsonOK(w, updated)
-	s.broadcaster.publish()
+	s.publish(ev)
 }
 
 // handleUnlinkBead handles DELETE /api/v1/beads/:id/link/:other_id.
@@ -142,26 +161,38 @@ func (s *Server) handleUnlinkBead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Resolve the other ID as well
-	other, err := s.storeFor(r).Resolve(otherID)
+	rev, err := ifMatch(r)
 	if err != nil {
-		var notFoundErr *store.NotFoundError
-		if errors.As(err, &notFoundErr) {
-			jsonError(w, err.Error(), http.StatusNotFound)
-			return
-		}
 		jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	updated, err := s.storeFor(r).Unlink(existing.ID, other.ID)
+	// Resolve the other ID as well, unless the bead already lists it:
+	// removing a dependency on another project's bead needs no token for
+	// that project.
+	if !slices.Contains(existing.BlockedBy, otherID) {
+		if _, _, err := s.lookupBead(r, s.storeFor(r), otherID); err != nil {
+			var notFoundErr *store.NotFoundError
+			if errors.As(err, &notFoundErr) {
+				jsonError(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	_, updated, ev, err := s.change(s.storeFor(r), s.projectFor(r), actorFor(r, ""), model.ActionUnlinked, func(tx store.Backend) (model.Bead, error) {
+		return tx.UnlinkIfRevision(existing.ID, otherID, rev)
+	})
 	if err != nil {
-		jsonError(w, err.Error(), http.StatusBadRequest)
+		jsonError(w, err.Error(), errorCode(err))
 		return
 	}
 
+	setETag(w, updated)
 	jsonOK(w, updated)
-	s.broadcaster.publish()
+	s.publish(ev)
 }
 
 // handleGetDeps handles GET /api/v1/beads/:id/deps.
@@ -185,5 +216,131 @@ func (s *Server) handleGetDeps(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jsonOK(w, deps)
+	jsonOK(w, s.crossProjectDeps(r, existing.ID, deps))
+}
+
+// lookupBead resolves id in st or, failing that, among the beads of the
+// server's other projects. It also returns the project of a bead found
+// elsewhere, or "" for one of st's own. A bead in a project the request
+// has no token for is reported as not found, so that neither it nor its
+// project is revealed.
+func (s *Server) lookupBead(r *http.Request, st store.Backend, id string) (model.Bead, string, error) {
+	b, err := st.Resolve(id)
+	var notFoundErr *store.NotFoundError
+	if err == nil || !errors.As(err, &notFoundErr) {
+		return b, "", err
+	}
+	foreign, project, ok := s.foreignBead(r, id)
+	if !ok || !granted(r, project) {
+		return b, "", err
+	}
+	return foreign, project, nil
+}
+
+// resolveBlocker resolves id, a bead to add to blocked_by, like lookupBead:
+// depending on another project's bead needs a token for that project in
+// ProjectTokensHeader.
+func (s *Server) resolveBlocker(r *http.Request, st store.Backend, id string) (model.Bead, error) {
+	b, _, err := s.lookupBead(r, st, id)
+	return b, err
+}
+
+// checkBlockers checks that the request may depend on each bead in
+// blockedBy that belongs to another project, answering not found for one
+// in a project it has no token for. IDs of no known bead are not checked;
+// blocked_by set directly has never been validated.
+func (s *Server) checkBlockers(r *http.Request, st store.Backend, blockedBy []string) error {
+	for _, id := range blockedBy {
+		if _, err := st.Resolve(id); err == nil {
+			continue
+		}
+		if _, project, ok := s.foreignBead(r, id); ok && !granted(r, project) {
+			return &store.NotFoundError{Message: fmt.Sprintf("bead %s not found", id)}
+		}
+	}
+	return nil
+}
+
+// checkForeignCycles rejects blockers in blockedBy that belong to another
+// project and would make beadID part of a dependency cycle. Blockers beadID
+// already has, and those of st's own, are not checked; blocked_by set
+// directly has never been validated. Caller must hold s.links.
+func (s *Server) checkForeignCycles(st store.Backend, existing model.Bead, blockedBy []string) error {
+	for _, id := range blockedBy {
+		if slices.Contains(existing.BlockedBy, id) {
+			continue
+		}
+		if _, err := st.Resolve(id); err == nil {
+			continue
+		}
+		if err := st.ValidateLinkCycle(existing.ID, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// foreignBead finds the bead with the given ID in a project other than the
+// request's, and returns it with that project's name. It reads each store
+// with Peek, so it may be called while a batch holds the request's store.
+func (s *Server) foreignBead(r *http.Request, id string) (model.Bead, string, bool) {
+	own := s.storeFor(r)
+	for _, p := range s.provider.Projects() {
+		if p.Store == own {
+			continue
+		}
+		if b, ok := p.Store.Peek(id); ok {
+			return b, p.Name, true
+		}
+	}
+	return model.Bead{}, "", false
+}
+
+// crossProjectDeps fills in the parts of deps that live in other projects.
+// Blockers there are shown in full if the request has a token for their
+// project, or else cut down to ID and status. Dependents in projects the
+// request has a token for are added to Blocks.
+func (s *Server) crossProjectDeps(r *http.Request, id string, deps store.DepsResult) store.DepsResult {
+	own := s.storeFor(r)
+	foreign := func(beads []model.Bead) {
+		for i, b := range beads {
+			if _, ok := own.Peek(b.ID); ok {
+				continue
+			}
+			if full, project, ok := s.foreignBead(r, b.ID); ok && granted(r, project) {
+				beads[i] = full
+			} else {
+				beads[i] = model.Bead{ID: b.ID, Status: b.Status}
+			}
+		}
+	}
+	foreign(deps.ActiveBlockers)
+	foreign(deps.ResolvedBlockers)
+
+	for _, p := range s.provider.Projects() {
+		if p.Store == own || !granted(r, p.Name) {
+			continue
+		}
+		for _, b := range p.Store.Dependents(id) {
+			if b.Status != model.StatusDeleted {
+				deps.Blocks = append(deps.Blocks, b)
+			}
+		}
+	}
+	return deps
+}
+
+// unblockedBy returns the beads that id, now closed or deleted, was the
+// last active blocker of: st's own and those in other projects the request
+// has a token for.
+func (s *Server) unblockedBy(r *http.Request, st store.Backend, id string) []model.Bead {
+	unblocked := st.GetUnblocked(id)
+	own := s.storeFor(r)
+	for _, p := range s.provider.Projects() {
+		if p.Store == own || !granted(r, p.Name) {
+			continue
+		}
+		unblocked = append(unblocked, p.Store.GetUnblocked(id)...)
+	}
+	return unblocked
 }
@@ -1,13 +1,19 @@
 package server
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
-	"github.com/vector76/beads_server/internal/model"
 	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
 )
 
 // --- Comment tests ---
@@ -176,7 +182,7 @@ func TestUnlinkBead_Success(t *testing.T) {
 	b := createViaAPI(t, srv, map[string]any{"title": "B"})
 
 	// Link first
-	srv.Store.Link(a.ID, b.ID)
+	soleStore(srv).Link(a.ID, b.ID)
 
 	req := authReq(http.MethodDelete, "/api/v1/beads/"+a.ID+"/link/"+b.ID, nil)
 	w := httptest.NewRecorder()
@@ -217,12 +223,12 @@ func TestGetDeps_Success(t *testing.T) {
 	c := createViaAPI(t, srv, map[string]any{"title": "C"})
 
 	// A blocked by B (active) and C (will close)
-	srv.Store.Link(a.ID, b.ID)
-	srv.Store.Link(a.ID, c.ID)
+	soleStore(srv).Link(a.ID, b.ID)
+	soleStore(srv).Link(a.ID, c.ID)
 
 	// Close C
 	closed := model.StatusClosed
-	srv.Store.Update(c.ID, store.UpdateFields{Status: &closed})
+	soleStore(srv).Update(c.ID, store.UpdateFields{Status: &closed})
 
 	req := authReq(http.MethodGet, "/api/v1/beads/"+a.ID+"/deps", nil)
 	w := httptest.NewRecorder()
@@ -287,3 +293,252 @@ func TestGetDeps_Empty(t *testing.T) {
 		t.Fatalf("expected empty blocks")
 	}
 }
+
+// --- Cross-project dependency tests ---
+
+// crossProjectServer returns a server with a frontend project on the JSON
+// backend and a backend project on SQLite, with tokens "tok-frontend" and
+// "tok-backend".
+func crossProjectServer(t testing.TB) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	front, err := store.Open(store.BackendJSON, filepath.Join(dir, "frontend.json"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	back, err := store.Open(store.BackendSQLite, filepath.Join(dir, "backend.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { back.Close() })
+	srv, err := New(Config{LogOutput: io.Discard}, NewMultiStoreProvider([]ProviderEntry{
+		{Name: "frontend", Token: "tok-frontend", Store: front},
+		{Name: "backend", Token: "tok-backend", Store: back},
+	}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// serveWithProjects is serveAs with tokens for other projects.
+func serveWithProjects(srv *Server, token, projectTokens, method, url string, body any) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	r := httptest.NewRequest(method, url, &buf)
+	r.Header.Set("Authorization", "Bearer "+token)
+	r.Header.Set(ProjectTokensHeader, projectTokens)
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, r)
+	return w
+}
+
+func TestCrossProjectDeps(t *testing.T) {
+	srv := crossProjectServer(t)
+	api := createAs(t, srv, "tok-backend", "Ship the API")
+	page := createAs(t, srv, "tok-frontend", "Build the page")
+
+	link := "/api/v1/beads/" + page.ID + "/link"
+	// Without a backend token the API bead does not exist, and nothing
+	// about it or its project is given away.
+	if w := serveAs(srv, "tok-frontend", http.MethodPost, link, map[string]any{"blocked_by": api.ID}); w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "backend") {
+		t.Fatalf("link without a backend token: expected 404 not naming the project, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := serveAs(srv, "tok-frontend", http.MethodPost, "/api/v1/beads", map[string]any{"title": "Sneaky", "blocked_by": []string{api.ID}}); w.Code != http.StatusNotFound {
+		t.Fatalf("create blocked by a backend bead without its token: expected 404, got %d", w.Code)
+	}
+	if w := serveWithProjects(srv, "tok-frontend", "tok-nope", http.MethodGet, "/api/v1/beads", nil); w.Code != http.StatusUnauthorized {
+		t.Fatalf("invalid project token: expected 401, got %d", w.Code)
+	}
+	if w := serveWithProjects(srv, "tok-frontend", "tok-backend", http.MethodPost, link, map[string]any{"blocked_by": api.ID}); w.Code != http.StatusOK {
+		t.Fatalf("link with a backend token: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	readyIDs := func() []string {
+		w := serveAs(srv, "tok-frontend", http.MethodGet, "/api/v1/beads?ready=true", nil)
+		var res store.ListResult
+		json.NewDecoder(w.Body).Decode(&res)
+		var ids []string
+		for _, b := range res.Beads {
+			ids = append(ids, b.ID)
+		}
+		return ids
+	}
+	if got := readyIDs(); len(got) != 0 {
+		t.Errorf("frontend ready = %v, want none while the API bead is open", got)
+	}
+
+	deps := func(token, projectTokens, id string) store.DepsResult {
+		w := serveWithProjects(srv, token, projectTokens, http.MethodGet, "/api/v1/beads/"+id+"/deps", nil)
+		var d store.DepsResult
+		json.NewDecoder(w.Body).Decode(&d)
+		return d
+	}
+	if d := deps("tok-frontend", "tok-backend", page.ID); len(d.ActiveBlockers) != 1 || d.ActiveBlockers[0].Title != "Ship the API" {
+		t.Errorf("deps with a backend token = %+v, want the API bead in full", d)
+	}
+	if d := deps("tok-frontend", "", page.ID); len(d.ActiveBlockers) != 1 || d.ActiveBlockers[0].ID != api.ID || d.ActiveBlockers[0].Title != "" {
+		t.Errorf("deps without a backend token = %+v, want only the API bead's ID and status", d)
+	}
+	if d := deps("tok-backend", "tok-frontend", api.ID); len(d.Blocks) != 1 || d.Blocks[0].ID != page.ID {
+		t.Errorf("backend deps with a frontend token = %+v, want it to block the page", d)
+	}
+	if d := deps("tok-backend", "", api.ID); len(d.Blocks) != 0 {
+		t.Errorf("backend deps without a frontend token = %+v, want no frontend beads", d)
+	}
+
+	// Cycles are caught across projects.
+	w := serveWithProjects(srv, "tok-backend", "tok-frontend", http.MethodPost, "/api/v1/beads/"+api.ID+"/link", map[string]any{"blocked_by": page.ID})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("link closing a cycle: expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = serveWithProjects(srv, "tok-backend", "tok-frontend", http.MethodPatch, "/api/v1/beads/"+api.ID, map[string]any{"status": "closed"})
+	var closed unblockedResponse
+	json.NewDecoder(w.Body).Decode(&closed)
+	if len(closed.Unblocked) != 1 || closed.Unblocked[0].ID != page.ID {
+		t.Errorf("closing the API bead: unblocked = %+v, want the page", closed.Unblocked)
+	}
+	if got := readyIDs(); len(got) != 1 || got[0] != page.ID {
+		t.Errorf("frontend ready = %v, want the page once the API bead is closed", got)
+	}
+
+	unlink := "/api/v1/beads/" + page.ID + "/link/" + api.ID
+	if w := serveAs(srv, "tok-frontend", http.MethodDelete, unlink, nil); w.Code != http.StatusOK {
+		t.Errorf("unlink without a backend token: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+// TestCrossProjectDeps_SeesChangesAtOnce checks that a change to a bead is
+// seen by the other projects as soon as its store has made it, without
+// waiting for the change to be published.
+func TestCrossProjectDeps_SeesChangesAtOnce(t *testing.T) {
+	srv := crossProjectServer(t)
+	var back store.Backend
+	for _, p := range srv.provider.Projects() {
+		if p.Name == "backend" {
+			back = p.Store
+		}
+	}
+	page := createAs(t, srv, "tok-frontend", "Build the page")
+
+	// Created straight in the store, so nothing is published.
+	api, err := back.Create(model.NewBead("Ship the API"))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	link := "/api/v1/beads/" + page.ID + "/link"
+	if w := serveWithProjects(srv, "tok-frontend", "tok-backend", http.MethodPost, link, map[string]any{"blocked_by": api.ID}); w.Code != http.StatusOK {
+		t.Fatalf("link to an unpublished bead: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	closed := model.StatusClosed
+	if _, err := back.Update(api.ID, store.UpdateFields{Status: &closed}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	w := serveAs(srv, "tok-frontend", http.MethodGet, "/api/v1/beads?ready=true", nil)
+	var res store.ListResult
+	json.NewDecoder(w.Body).Decode(&res)
+	if len(res.Beads) != 1 || res.Beads[0].ID != page.ID {
+		t.Errorf("frontend ready = %+v, want the page once the API bead is closed", res.Beads)
+	}
+}
+
+// TestCrossProjectDeps_ConcurrentLinks links beads in two JSON projects to
+// each other's from both sides at once; each store looks up the other's
+// beads while holding its own lock.
+func TestCrossProjectDeps_ConcurrentLinks(t *testing.T) {
+	dir := t.TempDir()
+	var entries []ProviderEntry
+	for _, name := range []string{"one", "two"} {
+		st, err := store.Load(filepath.Join(dir, name+".json"))
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		entries = append(entries, ProviderEntry{Name: name, Token: "tok-" + name, Store: st})
+	}
+	srv, err := New(Config{LogOutput: io.Discard}, NewMultiStoreProvider(entries))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	const n = 20
+	var ones, twos []model.Bead
+	for i := 0; i < n; i++ {
+		ones = append(ones, createAs(t, srv, "tok-one", "One"))
+		twos = append(twos, createAs(t, srv, "tok-two", "Two"))
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				serveWithProjects(srv, "tok-one", "tok-two", http.MethodPost, "/api/v1/beads/"+ones[i].ID+"/link", map[string]any{"blocked_by": twos[i].ID})
+			}()
+			go func() {
+				defer wg.Done()
+				serveWithProjects(srv, "tok-two", "tok-one", http.MethodPost, "/api/v1/beads/"+twos[(i+1)%n].ID+"/link", map[string]any{"blocked_by": ones[i].ID})
+			}()
+		}
+		wg.Wait()
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("concurrent cross-project links did not finish")
+	}
+}
+
+// TestCrossProjectDeps_ConcurrentLinksMakeNoCycle races A blocked by B
+// against B blocked by A, with A and B in different projects, through both
+// link and blocked_by updates: at most one of each pair may succeed.
+func TestCrossProjectDeps_ConcurrentLinksMakeNoCycle(t *testing.T) {
+	srv := crossProjectServer(t)
+	link := func(token, other, id, blocker string) int {
+		return serveWithProjects(srv, token, other, http.MethodPost, "/api/v1/beads/"+id+"/link", map[string]any{"blocked_by": blocker}).Code
+	}
+	patch := func(token, other, id, blocker string) int {
+		return serveWithProjects(srv, token, other, http.MethodPatch, "/api/v1/beads/"+id, map[string]any{"blocked_by": []string{blocker}}).Code
+	}
+
+	for i := 0; i < 50; i++ {
+		a := createAs(t, srv, "tok-frontend", "A")
+		b := createAs(t, srv, "tok-backend", "B")
+		second := link
+		if i%2 == 1 {
+			second = patch
+		}
+
+		start := make(chan struct{})
+		var codes [2]int
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			<-start
+			codes[0] = link("tok-frontend", "tok-backend", a.ID, b.ID)
+		}()
+		go func() {
+			defer wg.Done()
+			<-start
+			codes[1] = second("tok-backend", "tok-frontend", b.ID, a.ID)
+		}()
+		close(start)
+		wg.Wait()
+
+		if codes[0] == http.StatusOK && codes[1] == http.StatusOK {
+			t.Fatalf("round %d: both links succeeded, leaving %s and %s blocking each other", i, a.ID, b.ID)
+		}
+		if codes[0] != http.StatusOK && codes[1] != http.StatusOK {
+			t.Fatalf("round %d: neither link succeeded: %v", i, codes)
+		}
+	}
+}
@@ -6,7 +6,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
-	"github.com/vector76/beads_server/internal/model"
+	"github.com/vector76/beads_server/model"
 )
 
 // --- Create with parent_id ---
@@ -347,7 +347,7 @@ func TestDeleteBead_ChildRecomputesParent(t *testing.T) {
 	}
 
 	// Epic should now be closed (only child is deleted = terminal)
-	got, _ := srv.Store.Get(epic.ID)
+	got, _ := soleStore(srv).Get(epic.ID)
 	if got.Status != model.StatusClosed {
 		t.Errorf("expected epic status closed, got %s", got.Status)
 	}
@@ -710,7 +710,7 @@ func TestDerivedStatus_ClosingAllChildren(t *testing.T) {
 	srv.Router.ServeHTTP(w, req)
 
 	// Epic should be closed
-	got, _ := srv.Store.Get(epic.ID)
+	got, _ := soleStore(srv).Get(epic.ID)
 	if got.Status != model.StatusClosed {
 		t.Errorf("expected epic status closed, got %s", got.Status)
 	}
@@ -744,7 +744,7 @@ func TestDerivedStatus_MixedChildren(t *testing.T) {
 	srv.Router.ServeHTTP(w, req)
 
 	// Epic should be open (one closed + one open child = open under derived-status rules)
-	got, _ := srv.Store.Get(epic.ID)
+	got, _ := soleStore(srv).Get(epic.ID)
 	if got.Status != model.StatusOpen {
 		t.Errorf("expected epic status open, got %s", got.Status)
 	}
@@ -0,0 +1,425 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
+)
+
+// exportVersion is the version of the export format written by GET
+// /api/v1/export.
+const exportVersion = 1
+
+// Import modes.
+const (
+	importMerge   = "merge"
+	importReplace = "replace"
+)
+
+// maxImportBytes is the largest import body accepted; larger ones get 413.
+// The whole body is held in memory while it is checked.
+var maxImportBytes int64 = 64 << 20
+
+// Ways to handle imported IDs that are already in use.
+const (
+	conflictFail  = "fail"
+	conflictRemap = "remap"
+)
+
+// exportDocument is the JSON response for GET /api/v1/export. Its beads and
+// history are laid out as in the JSON backend's data file, so either one
+// can be imported.
+type exportDocument struct {
+	Version    int       `json:"version"`
+	Project    string    `json:"project,omitempty"`
+	ExportedAt time.Time `json:"exported_at"`
+	model.Snapshot
+}
+
+// importResponse is the JSON response for a successful import. Remapped
+// maps each imported ID that was already in use to the ID it was given.
+type importResponse struct {
+	Mode     string            `json:"mode"`
+	Imported int               `json:"imported"`
+	Removed  int               `json:"removed"`
+	Remapped map[string]string `json:"remapped"`
+}
+
+// importError is the JSON response for an import that was refused because
+// of ID conflicts or dangling references.
+type importError struct {
+	Error     string   `json:"error"`
+	Conflicts []string `json:"conflicts,omitempty"`
+	Missing   []string `json:"missing,omitempty"`
+}
+
+// importFailure is an import refused from inside its batch, with the
+// response to send.
+type importFailure struct {
+	code int
+	body importError
+}
+
+func (e *importFailure) Error() string { return e.body.Error }
+
+func writeImportError(w http.ResponseWriter, code int, e importError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(e)
+}
+
+// handleExport handles GET /api/v1/export: every bead in the project,
+// including closed and deleted ones, with its history.
+func (s *Server) handleExport(w http.ResponseWriter, r *http.Request) {
+	doc := exportDocument{
+		Version:    exportVersion,
+		Project:    s.projectFor(r),
+		ExportedAt: time.Now().UTC(),
+		Snapshot:   model.Snapshot{History: map[string][]model.HistoryEntry{}},
+	}
+
+	// Reading inside a batch gives a consistent view: no write can land
+	// between listing the beads and reading their history.
+	err := s.storeFor(r).Batch(func(tx store.Backend) error {
+		doc.Beads = tx.All()
+		for _, b := range doc.Beads {
+			h, err := tx.History(b.ID)
+			if err != nil {
+				return err
+			}
+			if len(h) > 0 {
+				doc.History[b.ID] = h
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slices.SortFunc(doc.Beads, func(a, b model.Bead) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+
+	jsonOK(w, doc)
+}
+
+// handleImport handles POST /api/v1/import. The body is an export document
+// or a JSON backend data file. In merge mode the beads are added to the
+// project; in replace mode they take the place of everything in it. Beads
+// keep their IDs unless an ID is already in use, in which case the import
+// fails (on_conflict=fail, the default) or the bead gets a new ID and every
+// reference to it in the import is rewritten (on_conflict=remap). Nesting
+// deeper than one level and blocked_by cycles are refused, and epic
+// statuses are recomputed from the imported children. Either way the
+// import is all-or-nothing.
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	mode := r.URL.Query().Get("mode")
+	if mode == "" {
+		mode = importMerge
+	}
+	if mode != importMerge && mode != importReplace {
+		jsonError(w, fmt.Sprintf("mode must be %q or %q", importMerge, importReplace), http.StatusBadRequest)
+		return
+	}
+	onConflict := r.URL.Query().Get("on_conflict")
+	if onConflict == "" {
+		onConflict = conflictFail
+	}
+	if onConflict != conflictFail && onConflict != conflictRemap {
+		jsonError(w, fmt.Sprintf("on_conflict must be %q or %q", conflictFail, conflictRemap), http.StatusBadRequest)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			jsonError(w, fmt.Sprintf("import is larger than the limit of %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		}
+		jsonError(w, "reading body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	snap, err := store.ParseSnapshot(data)
+	if err != nil {
+		jsonError(w, "invalid import: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := checkImport(snap); err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Everything that depends on the project's beads happens inside the
+	// batch, so no write can land between checking the import and applying
+	// it.
+	var claim *idClaim
+	current := map[string]model.Bead{}
+	resp := importResponse{Mode: mode, Imported: len(snap.Beads)}
+	err = s.storeFor(r).Batch(func(tx store.Backend) error {
+		for _, b := range tx.All() {
+			current[b.ID] = b
+		}
+
+		// IDs are unique across projects. In replace mode the project's own
+		// beads are about to go, so their IDs are free.
+		taken := func(id string) bool {
+			_, own := current[id]
+			return s.ids.has(id) && !(mode == importReplace && own)
+		}
+		var conflicts []string
+		for _, b := range snap.Beads {
+			if taken(b.ID) {
+				conflicts = append(conflicts, b.ID)
+			}
+		}
+		if len(conflicts) > 0 && onConflict == conflictFail {
+			return &importFailure{code: http.StatusConflict, body: importError{
+				Error:     fmt.Sprintf("%d imported bead IDs are already in use: %s", len(conflicts), strings.Join(conflicts, ", ")),
+				Conflicts: conflicts,
+			}}
+		}
+
+		resp.Remapped = remapImport(&snap, conflicts, taken)
+
+		// References must resolve within the import, or to beads the
+		// project keeps when merging.
+		kept := current
+		if mode == importReplace {
+			kept = nil
+		}
+		imported := make(map[string]model.Bead, len(snap.Beads))
+		for _, b := range snap.Beads {
+			imported[b.ID] = b
+		}
+		var missing []string
+		for _, b := range snap.Beads {
+			for _, ref := range append([]string{b.ParentID}, b.BlockedBy...) {
+				_, inImport := imported[ref]
+				_, inProject := kept[ref]
+				if ref == "" || inImport || inProject || slices.Contains(missing, ref) {
+					continue
+				}
+				missing = append(missing, ref)
+			}
+		}
+		if len(missing) > 0 {
+			return &importFailure{code: http.StatusBadRequest, body: importError{
+				Error:   fmt.Sprintf("%d referenced beads are not in the import: %s", len(missing), strings.Join(missing, ", ")),
+				Missing: missing,
+			}}
+		}
+		if err := checkImportGraph(imported, kept); err != nil {
+			return &importFailure{code: http.StatusBadRequest, body: importError{Error: err.Error()}}
+		}
+
+		// Claim the new IDs, so that no other project takes them meanwhile.
+		claim = s.ids.claim()
+		for _, b := range snap.Beads {
+			if _, own := current[b.ID]; own && mode == importReplace {
+				continue
+			}
+			if !claim.reserve(b.ID) {
+				return &importFailure{code: http.StatusConflict, body: importError{
+					Error: fmt.Sprintf("bead ID %s was taken during the import; try again", b.ID),
+				}}
+			}
+		}
+
+		if mode == importReplace {
+			for _, b := range tx.All() {
+				if err := tx.Purge(b.ID); err != nil {
+					return err
+				}
+				resp.Removed++
+			}
+		}
+		epics := map[string]string{} // epic ID -> one of its imported children
+		for _, b := range snap.Beads {
+			if b.Revision == 0 {
+				b.Revision = 1
+			}
+			if err := tx.Restore(b, snap.History[b.ID]); err != nil {
+				return err
+			}
+			if b.ParentID != "" {
+				epics[b.ParentID] = b.ID
+			}
+		}
+		// Epic statuses are derived from their children; the imported ones
+		// may be stale, and a merge can add children to an existing epic.
+		for _, child := range epics {
+			if err := tx.RecomputeParentStatus(child); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if claim != nil {
+			claim.release()
+		}
+		var failure *importFailure
+		if errors.As(err, &failure) {
+			writeImportError(w, failure.code, failure.body)
+			return
+		}
+		jsonError(w, err.Error(), errorCode(err))
+		return
+	}
+	if mode == importReplace {
+		imported := make(map[string]bool, len(snap.Beads))
+		for _, b := range snap.Beads {
+			imported[b.ID] = true
+		}
+		for id := range current {
+			if !imported[id] {
+				s.ids.release(id)
+			}
+		}
+	}
+
+	jsonOK(w, resp)
+	s.publish(Event{
+		Type:    EventBeadsImported,
+		Project: s.projectFor(r),
+		Actor:   actorFor(r, ""),
+		At:      time.Now().UTC(),
+	})
+}
+
+// checkImport rejects beads without an ID or title, and duplicate IDs.
+func checkImport(snap model.Snapshot) error {
+	seen := make(map[string]bool, len(snap.Beads))
+	for i, b := range snap.Beads {
+		switch {
+		case b.ID == "":
+			return fmt.Errorf("bead %d has no id", i)
+		case strings.TrimSpace(b.Title) == "":
+			return fmt.Errorf("bead %s has no title", b.ID)
+		case seen[b.ID]:
+			return fmt.Errorf("duplicate bead id %s", b.ID)
+		}
+		if !b.Status.Valid() || !b.Priority.Valid() || !b.Type.Valid() {
+			return fmt.Errorf("bead %s has an invalid status, priority or type", b.ID)
+		}
+		seen[b.ID] = true
+	}
+	return nil
+}
+
+// checkImportGraph checks the hierarchy and dependencies of the imported
+// beads, whose references all resolve to imported or kept beads: nesting
+// is a single level, so a parent must not itself have a parent, and
+// blocked_by must not form a cycle.
+func checkImportGraph(imported, kept map[string]model.Bead) error {
+	lookup := func(id string) model.Bead {
+		if b, ok := imported[id]; ok {
+			return b
+		}
+		return kept[id]
+	}
+
+	ids := slices.Sorted(maps.Keys(imported))
+	for _, id := range ids {
+		b := imported[id]
+		if b.ParentID == "" {
+			continue
+		}
+		if parent := lookup(b.ParentID); parent.ParentID != "" {
+			return fmt.Errorf("bead %s has parent %s, which is itself a child of %s; epics cannot be nested", b.ID, parent.ID, parent.ParentID)
+		}
+	}
+
+	// Depth-first search over blocked_by. Kept beads cannot depend on
+	// imported ones, so a cycle lies entirely within the import.
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int, len(imported))
+	var path []string
+	var visit func(id string) error
+	visit = func(id string) error {
+		switch state[id] {
+		case visiting:
+			cycle := append(path[slices.Index(path, id):], id)
+			return fmt.Errorf("blocked_by forms a cycle: %s", strings.Join(cycle, " -> "))
+		case done:
+			return nil
+		}
+		state[id] = visiting
+		path = append(path, id)
+		for _, dep := range imported[id].BlockedBy {
+			if _, ok := imported[dep]; !ok {
+				continue
+			}
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = done
+		return nil
+	}
+	for _, id := range ids {
+		if err := visit(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remapImport gives each conflicting bead a fresh ID, avoiding the IDs
+// taken reports and the import's own IDs, and rewrites parent links,
+// dependencies and history keys to match. It returns the old-to-new mapping.
+func remapImport(snap *model.Snapshot, conflicts []string, taken func(id string) bool) map[string]string {
+	remapped := map[string]string{}
+	if len(conflicts) == 0 {
+		return remapped
+	}
+
+	used := make(map[string]bool, len(snap.Beads)+len(conflicts))
+	for _, b := range snap.Beads {
+		used[b.ID] = true
+	}
+	inUse := func(id string) bool { return used[id] || taken(id) }
+	for _, id := range conflicts {
+		newID := store.NewID(inUse)
+		remapped[id] = newID
+		used[newID] = true
+	}
+
+	rename := func(id string) string {
+		if newID, ok := remapped[id]; ok {
+			return newID
+		}
+		return id
+	}
+	for i := range snap.Beads {
+		b := &snap.Beads[i]
+		b.ID = rename(b.ID)
+		b.ParentID = rename(b.ParentID)
+		blockedBy := make([]string, len(b.BlockedBy))
+		for j, dep := range b.BlockedBy {
+			blockedBy[j] = rename(dep)
+		}
+		b.BlockedBy = blockedBy
+	}
+	history := make(map[string][]model.HistoryEntry, len(snap.History))
+	for id, entries := range snap.History {
+		history[rename(id)] = entries
+	}
+	snap.History = history
+	return remapped
+}
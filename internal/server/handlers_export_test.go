@@ -0,0 +1,248 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vector76/beads_server/model"
+)
+
+func exportProject(t *testing.T, srv *Server) []byte {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodGet, "/api/v1/export", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("export: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	return w.Body.Bytes()
+}
+
+func importProject(t *testing.T, srv *Server, query string, data []byte) (*httptest.ResponseRecorder, importResponse) {
+	t.Helper()
+	req := authReq(http.MethodPost, "/api/v1/import"+query, nil)
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, req)
+	var resp importResponse
+	if w.Code == http.StatusOK {
+		json.NewDecoder(w.Body).Decode(&resp)
+	}
+	return w, resp
+}
+
+// seedProject creates an epic with two children, one blocked by the other,
+// and a comment.
+func seedProject(t *testing.T, srv *Server) (epic, first, second model.Bead) {
+	t.Helper()
+	epic = createViaAPI(t, srv, map[string]any{"title": "Epic"})
+	first = createViaAPI(t, srv, map[string]any{"title": "First", "parent_id": epic.ID})
+	second = createViaAPI(t, srv, map[string]any{"title": "Second", "parent_id": epic.ID, "blocked_by": []string{first.ID}})
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodPost, "/api/v1/beads/"+first.ID+"/comments", map[string]any{"author": "alice", "text": "on it"}))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("comment: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	return epic, first, second
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	src := crudServer(t)
+	epic, first, second := seedProject(t, src)
+	data := exportProject(t, src)
+
+	var doc exportDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("decoding export: %v", err)
+	}
+	if doc.Version != exportVersion || len(doc.Beads) != 3 || len(doc.History[first.ID]) != 2 {
+		t.Fatalf("export = %+v", doc)
+	}
+
+	dst := crudServer(t)
+	createViaAPI(t, dst, map[string]any{"title": "Replaced"})
+	w, resp := importProject(t, dst, "?mode=replace", data)
+	if w.Code != http.StatusOK {
+		t.Fatalf("import: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if resp.Imported != 3 || resp.Removed != 1 || len(resp.Remapped) != 0 {
+		t.Errorf("import response = %+v", resp)
+	}
+
+	if all := soleStore(dst).All(); len(all) != 3 {
+		t.Fatalf("store after import has %d beads, want 3", len(all))
+	}
+	got, _ := soleStore(dst).Get(second.ID)
+	if got.ParentID != epic.ID || len(got.BlockedBy) != 1 || got.BlockedBy[0] != first.ID || got.Revision != second.Revision {
+		t.Errorf("second = %+v", got)
+	}
+	got, _ = soleStore(dst).Get(first.ID)
+	if len(got.Comments) != 1 || got.Comments[0].Author != "alice" {
+		t.Errorf("first comments = %+v", got.Comments)
+	}
+	if h, _ := soleStore(dst).History(first.ID); len(h) != 2 {
+		t.Errorf("first history = %+v, want created and commented", h)
+	}
+}
+
+func TestImport_MergeConflictFails(t *testing.T) {
+	srv := crudServer(t)
+	_, first, _ := seedProject(t, srv)
+	data := exportProject(t, srv)
+
+	w, _ := importProject(t, srv, "", data)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp importError
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Conflicts) != 3 {
+		t.Errorf("conflicts = %v, want all 3 IDs", resp.Conflicts)
+	}
+	if len(soleStore(srv).All()) != 3 {
+		t.Errorf("failed import changed the store: %d beads", len(soleStore(srv).All()))
+	}
+	if got, _ := soleStore(srv).Get(first.ID); len(got.Comments) != 1 {
+		t.Errorf("first = %+v", got)
+	}
+}
+
+func TestImport_MergeRemapsConflicts(t *testing.T) {
+	srv := crudServer(t)
+	epic, first, second := seedProject(t, srv)
+	data := exportProject(t, srv)
+
+	w, resp := importProject(t, srv, "?mode=merge&on_conflict=remap", data)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(resp.Remapped) != 3 {
+		t.Fatalf("remapped = %v, want all 3 IDs", resp.Remapped)
+	}
+	if len(soleStore(srv).All()) != 6 {
+		t.Errorf("store has %d beads, want 6", len(soleStore(srv).All()))
+	}
+
+	copied, err := soleStore(srv).Get(resp.Remapped[second.ID])
+	if err != nil {
+		t.Fatalf("Get remapped second: %v", err)
+	}
+	if copied.ParentID != resp.Remapped[epic.ID] || len(copied.BlockedBy) != 1 || copied.BlockedBy[0] != resp.Remapped[first.ID] {
+		t.Errorf("remapped second = %+v, want references rewritten", copied)
+	}
+	if h, _ := soleStore(srv).History(resp.Remapped[first.ID]); len(h) != 2 {
+		t.Errorf("remapped first history = %+v", h)
+	}
+	if original, _ := soleStore(srv).Get(second.ID); original.ParentID != epic.ID {
+		t.Errorf("original second changed: %+v", original)
+	}
+}
+
+func TestImport_Validation(t *testing.T) {
+	srv := crudServer(t)
+
+	tests := []struct {
+		name  string
+		query string
+		body  string
+	}{
+		{"bad mode", "?mode=overwrite", `{"beads": []}`},
+		{"bad on_conflict", "?on_conflict=skip", `{"beads": []}`},
+		{"not JSON", "", `beads`},
+		{"missing id", "", `{"beads": [{"title": "A", "status": "open", "priority": "low", "type": "task"}]}`},
+		{"duplicate id", "", `{"beads": [
+			{"id": "bd-aaaa", "title": "A", "status": "open", "priority": "low", "type": "task"},
+			{"id": "bd-aaaa", "title": "B", "status": "open", "priority": "low", "type": "task"}]}`},
+		{"dangling reference", "", `{"beads": [
+			{"id": "bd-aaaa", "title": "A", "status": "open", "priority": "low", "type": "task", "blocked_by": ["bd-zzzz"]}]}`},
+		{"nested epics", "", `{"beads": [
+			{"id": "bd-aaaa", "title": "A", "status": "open", "priority": "low", "type": "task"},
+			{"id": "bd-bbbb", "title": "B", "status": "open", "priority": "low", "type": "task", "parent_id": "bd-aaaa"},
+			{"id": "bd-cccc", "title": "C", "status": "open", "priority": "low", "type": "task", "parent_id": "bd-bbbb"}]}`},
+		{"dependency cycle", "", `{"beads": [
+			{"id": "bd-aaaa", "title": "A", "status": "open", "priority": "low", "type": "task", "blocked_by": ["bd-cccc"]},
+			{"id": "bd-bbbb", "title": "B", "status": "open", "priority": "low", "type": "task", "blocked_by": ["bd-aaaa"]},
+			{"id": "bd-cccc", "title": "C", "status": "open", "priority": "low", "type": "task", "blocked_by": ["bd-bbbb"]}]}`},
+		{"blocked by itself", "", `{"beads": [
+			{"id": "bd-aaaa", "title": "A", "status": "open", "priority": "low", "type": "task", "blocked_by": ["bd-aaaa"]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, _ := importProject(t, srv, tt.query, []byte(tt.body))
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+	if len(soleStore(srv).All()) != 0 {
+		t.Errorf("invalid imports left %d beads", len(soleStore(srv).All()))
+	}
+}
+
+func TestImport_BodyTooLarge(t *testing.T) {
+	limit := maxImportBytes
+	maxImportBytes = 64
+	t.Cleanup(func() { maxImportBytes = limit })
+
+	srv := crudServer(t)
+	data := []byte(`{"beads": [{"id": "bd-aaaa", "title": "A long enough title to pass the limit", "status": "open", "priority": "low", "type": "task"}]}`)
+	w, _ := importProject(t, srv, "", data)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(soleStore(srv).All()) != 0 {
+		t.Errorf("an oversized import left %d beads", len(soleStore(srv).All()))
+	}
+}
+
+func TestImport_LegacyDataFile(t *testing.T) {
+	srv := crudServer(t)
+	data := []byte(`{"beads": [{"id": "bd-old1", "title": "Old", "status": "resolved", "priority": "high", "type": "epic"}]}`)
+
+	w, _ := importProject(t, srv, "", data)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	got, _ := soleStore(srv).Get("bd-old1")
+	if got.Status != model.StatusClosed || got.Type != model.TypeTask || got.Revision != 1 {
+		t.Errorf("imported legacy bead = %+v", got)
+	}
+}
+
+func TestImport_MergeUnderExistingChildFails(t *testing.T) {
+	srv := crudServer(t)
+	_, first, _ := seedProject(t, srv)
+	data := []byte(`{"beads": [{"id": "bd-new1", "title": "Grandchild", "status": "open", "priority": "low", "type": "task", "parent_id": "` + first.ID + `"}]}`)
+
+	w, _ := importProject(t, srv, "", data)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := soleStore(srv).Get("bd-new1"); err == nil {
+		t.Error("refused import left its bead behind")
+	}
+}
+
+func TestImport_RecomputesEpicStatus(t *testing.T) {
+	srv := crudServer(t)
+	epic := createViaAPI(t, srv, map[string]any{"title": "Existing epic"})
+	createViaAPI(t, srv, map[string]any{"title": "Waiting", "parent_id": epic.ID})
+	data := []byte(`{"beads": [
+		{"id": "bd-epi1", "title": "Stale epic", "status": "open", "priority": "low", "type": "task"},
+		{"id": "bd-chi1", "title": "Closed child", "status": "closed", "priority": "low", "type": "task", "parent_id": "bd-epi1"},
+		{"id": "bd-chi2", "title": "New work", "status": "in_progress", "priority": "low", "type": "task", "parent_id": "` + epic.ID + `"}]}`)
+
+	w, _ := importProject(t, srv, "", data)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if got, _ := soleStore(srv).Get("bd-epi1"); got.Status != model.StatusClosed {
+		t.Errorf("imported epic status = %s, want closed", got.Status)
+	}
+	if got, _ := soleStore(srv).Get(epic.ID); got.Status != model.StatusInProgress {
+		t.Errorf("existing epic status = %s, want in_progress", got.Status)
+	}
+}
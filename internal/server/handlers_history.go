@@ -0,0 +1,103 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
+)
+
+// ActorHeader carries the caller's identity (the CLI sends BS_USER) so that
+// mutations can be attributed in bead history. It is ignored for user
+// tokens, which carry their own identity.
+const ActorHeader = "X-BS-User"
+
+// actorFor returns the identity to record for r: the name of the user the
+// token belongs to, else the ActorHeader value, else fallback (e.g. a
+// claim's user or a comment's author), else "anonymous".
+func actorFor(r *http.Request, fallback string) string {
+	if p := principalFor(r); p.Name != "" {
+		return p.Name
+	}
+	if a := r.Header.Get(ActorHeader); a != "" {
+		return a
+	}
+	if fallback != "" {
+		return fallback
+	}
+	return "anonymous"
+}
+
+// userFor returns the user a claim, heartbeat or comment acts as: the name
+// of the user the token belongs to, else the one the request names.
+func userFor(r *http.Request, requested string) string {
+	if p := principalFor(r); p.Name != "" {
+		return p.Name
+	}
+	return requested
+}
+
+// recordHistory appends a history entry to after.ID describing the change
+// from before. Entries with no field changes are skipped, except comments.
+// tx is the batch the mutation ran in.
+func recordHistory(tx store.Backend, actor, action string, before, after model.Bead) error {
+	changes := model.Diff(before, after)
+	if len(changes) == 0 && action != model.ActionCommented {
+		return nil
+	}
+	return tx.RecordHistory(after.ID, model.HistoryEntry{Actor: actor, Action: action, Changes: changes})
+}
+
+// recordEpics appends a history entry by the system to each of the epics
+// ids whose derived status changed in the batch tx, which recomputes it
+// along with the children's changes. Empty and repeated IDs are skipped.
+func recordEpics(tx store.Backend, ids ...string) error {
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		before, ok := tx.Prior(id)
+		after, err := tx.Get(id)
+		if !ok || err != nil || before.Status == after.Status {
+			continue
+		}
+		e := model.HistoryEntry{
+			Actor:   store.SystemAuthor,
+			Action:  model.ActionUpdated,
+			Changes: []model.FieldChange{{Field: "status", Old: before.Status, New: after.Status}},
+		}
+		if err := tx.RecordHistory(id, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// historyResponse is the JSON response for GET /beads/:id/history.
+type historyResponse struct {
+	ID      string               `json:"id"`
+	History []model.HistoryEntry `json:"history"`
+}
+
+// handleGetHistory handles GET /api/v1/beads/:id/history.
+func (s *Server) handleGetHistory(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	st := s.storeFor(r)
+
+	existing, err := st.Resolve(id)
+	if err != nil {
+		jsonError(w, err.Error(), errorCode(err))
+		return
+	}
+
+	entries, err := st.History(existing.ID)
+	if err != nil {
+		jsonError(w, err.Error(), errorCode(err))
+		return
+	}
+
+	jsonOK(w, historyResponse{ID: existing.ID, History: entries})
+}
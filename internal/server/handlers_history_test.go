@@ -0,0 +1,165 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
+)
+
+func getHistory(t *testing.T, srv *Server, id string) []model.HistoryEntry {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodGet, "/api/v1/beads/"+id+"/history", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("history: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp historyResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode history: %v", err)
+	}
+	if resp.ID != id {
+		t.Errorf("history id = %q, want %q", resp.ID, id)
+	}
+	return resp.History
+}
+
+func TestHistory_RecordsMutations(t *testing.T) {
+	srv := crudServer(t)
+	b := createViaAPI(t, srv, map[string]any{"title": "Tracked"})
+	blocker := createViaAPI(t, srv, map[string]any{"title": "Blocker"})
+
+	req := authReq(http.MethodPatch, "/api/v1/beads/"+b.ID, map[string]any{"title": "Renamed", "priority": "high"})
+	req.Header.Set(ActorHeader, "alice")
+	srv.Router.ServeHTTP(httptest.NewRecorder(), req)
+
+	srv.Router.ServeHTTP(httptest.NewRecorder(), authReq(http.MethodPost, "/api/v1/beads/"+b.ID+"/claim", map[string]any{"user": "bob"}))
+	srv.Router.ServeHTTP(httptest.NewRecorder(), authReq(http.MethodPost, "/api/v1/beads/"+b.ID+"/link", map[string]any{"blocked_by": blocker.ID}))
+	srv.Router.ServeHTTP(httptest.NewRecorder(), authReq(http.MethodPost, "/api/v1/beads/"+b.ID+"/comments", map[string]any{"author": "carol", "text": "note"}))
+
+	entries := getHistory(t, srv, b.ID)
+	wantActions := []string{model.ActionCreated, model.ActionUpdated, model.ActionClaimed, model.ActionLinked, model.ActionCommented}
+	if len(entries) != len(wantActions) {
+		t.Fatalf("expected %d entries, got %d: %+v", len(wantActions), len(entries), entries)
+	}
+	for i, want := range wantActions {
+		if entries[i].Action != want {
+			t.Errorf("entry %d action = %q, want %q", i, entries[i].Action, want)
+		}
+		if entries[i].Seq != i+1 {
+			t.Errorf("entry %d seq = %d, want %d", i, entries[i].Seq, i+1)
+		}
+	}
+
+	update := entries[1]
+	if update.Actor != "alice" {
+		t.Errorf("update actor = %q, want alice", update.Actor)
+	}
+	if len(update.Changes) != 2 || update.Changes[0].Field != "title" || update.Changes[0].Old != "Tracked" || update.Changes[0].New != "Renamed" {
+		t.Errorf("unexpected update changes: %+v", update.Changes)
+	}
+	if entries[2].Actor != "bob" {
+		t.Errorf("claim actor = %q, want bob (from claim body)", entries[2].Actor)
+	}
+	if entries[4].Actor != "carol" {
+		t.Errorf("comment actor = %q, want carol (from comment author)", entries[4].Actor)
+	}
+}
+
+func TestHistory_NoOpUpdateNotRecorded(t *testing.T) {
+	srv := crudServer(t)
+	b := createViaAPI(t, srv, map[string]any{"title": "Same"})
+
+	srv.Router.ServeHTTP(httptest.NewRecorder(), authReq(http.MethodPatch, "/api/v1/beads/"+b.ID, map[string]any{"title": "Same"}))
+
+	if entries := getHistory(t, srv, b.ID); len(entries) != 1 {
+		t.Errorf("expected only the created entry, got %+v", entries)
+	}
+}
+
+func TestHistory_DerivedEpicStatusRecorded(t *testing.T) {
+	srv := crudServer(t)
+	epic := createViaAPI(t, srv, map[string]any{"title": "Epic"})
+	child := createViaAPI(t, srv, map[string]any{"title": "Only child", "parent_id": epic.ID})
+
+	req := authReq(http.MethodPatch, "/api/v1/beads/"+child.ID, map[string]any{"status": "closed"})
+	req.Header.Set(ActorHeader, "alice")
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("close child: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	entries := getHistory(t, srv, epic.ID)
+	last := entries[len(entries)-1]
+	if last.Actor != store.SystemAuthor || last.Action != model.ActionUpdated {
+		t.Fatalf("last epic entry = %+v, want an update by %q", last, store.SystemAuthor)
+	}
+	if len(last.Changes) != 1 || last.Changes[0].Field != "status" || last.Changes[0].New != string(model.StatusClosed) {
+		t.Errorf("unexpected epic changes: %+v", last.Changes)
+	}
+}
+
+func TestHistory_ConcurrentUpdatesChainUp(t *testing.T) {
+	srv := crudServer(t)
+	b := createViaAPI(t, srv, map[string]any{"title": "t0"})
+
+	const n = 20
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := authReq(http.MethodPatch, "/api/v1/beads/"+b.ID, map[string]any{"title": fmt.Sprintf("t%d", i+1)})
+			req.Header.Set(ActorHeader, fmt.Sprintf("user%d", i+1))
+			srv.Router.ServeHTTP(httptest.NewRecorder(), req)
+		}()
+	}
+	wg.Wait()
+
+	// Each entry must start from the title the previous one left, and be
+	// credited to the actor who set its new title.
+	prev := "t0"
+	for _, e := range getHistory(t, srv, b.ID)[1:] {
+		if len(e.Changes) != 1 || e.Changes[0].Old != prev {
+			t.Fatalf("entry %d = %+v, want a title change from %q", e.Seq, e.Changes, prev)
+		}
+		prev = e.Changes[0].New.(string)
+		if want := "user" + strings.TrimPrefix(prev, "t"); e.Actor != want {
+			t.Errorf("entry %d actor = %q, want %q", e.Seq, e.Actor, want)
+		}
+	}
+}
+
+func TestHistory_NotFound(t *testing.T) {
+	srv := crudServer(t)
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodGet, "/api/v1/beads/bd-none/history", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
+func TestBeadDetailRendersHistory(t *testing.T) {
+	srv := crudServer(t)
+	b := createViaAPI(t, srv, map[string]any{"title": "Timeline"})
+
+	req := authReq(http.MethodPatch, "/api/v1/beads/"+b.ID, map[string]any{"status": "closed"})
+	req.Header.Set(ActorHeader, "dave")
+	srv.Router.ServeHTTP(httptest.NewRecorder(), req)
+
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/bead/default/"+b.ID, nil))
+	body := w.Body.String()
+	for _, want := range []string{"History", "dave", "updated", "status:", "open &rarr; closed"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("detail page missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
+)
+
+// leaseServer is crudServer with claim leases enabled.
+func leaseServer(t *testing.T, ttl time.Duration) *Server {
+	t.Helper()
+	dir := t.TempDir()
+	s, err := store.Load(filepath.Join(dir, "beads.json"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	srv, err := New(Config{LogOutput: io.Discard, LeaseTTL: ttl}, NewSingleStoreProvider(testToken, s))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func claimViaAPI(t *testing.T, srv *Server, id, user string) model.Bead {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodPost, "/api/v1/beads/"+id+"/claim", map[string]any{"user": user}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("claim: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var b model.Bead
+	json.NewDecoder(w.Body).Decode(&b)
+	return b
+}
+
+func TestClaim_ReturnsLease(t *testing.T) {
+	srv := leaseServer(t, time.Hour)
+	b := createViaAPI(t, srv, map[string]any{"title": "Leased"})
+
+	claimed := claimViaAPI(t, srv, b.ID, "agent-1")
+	if claimed.LeaseExpiresAt == nil {
+		t.Fatal("expected lease_expires_at in claim response")
+	}
+}
+
+func TestHeartbeat_ExtendsLease(t *testing.T) {
+	srv := leaseServer(t, time.Hour)
+	b := createViaAPI(t, srv, map[string]any{"title": "Leased"})
+	claimed := claimViaAPI(t, srv, b.ID, "agent-1")
+
+	time.Sleep(5 * time.Millisecond)
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodPost, "/api/v1/beads/"+b.ID+"/heartbeat", map[string]any{"user": "agent-1"}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("heartbeat: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var renewed model.Bead
+	json.NewDecoder(w.Body).Decode(&renewed)
+	if !renewed.LeaseExpiresAt.After(*claimed.LeaseExpiresAt) {
+		t.Error("expected heartbeat to extend lease")
+	}
+}
+
+func TestHeartbeat_Errors(t *testing.T) {
+	srv := leaseServer(t, time.Hour)
+	b := createViaAPI(t, srv, map[string]any{"title": "Leased"})
+	claimViaAPI(t, srv, b.ID, "agent-1")
+
+	tests := []struct {
+		name string
+		id   string
+		body map[string]any
+		code int
+	}{
+		{"other user", b.ID, map[string]any{"user": "agent-2"}, http.StatusConflict},
+		{"missing user", b.ID, map[string]any{}, http.StatusBadRequest},
+		{"unknown bead", "bd-none", map[string]any{"user": "agent-1"}, http.StatusNotFound},
+	}
+	for _, tc := range tests {
+		w := httptest.NewRecorder()
+		srv.Router.ServeHTTP(w, authReq(http.MethodPost, "/api/v1/beads/"+tc.id+"/heartbeat", tc.body))
+		if w.Code != tc.code {
+			t.Errorf("%s: expected %d, got %d: %s", tc.name, tc.code, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestReaper_ReleasesExpiredClaims(t *testing.T) {
+	srv := leaseServer(t, time.Minute)
+	epic := createViaAPI(t, srv, map[string]any{"title": "Epic"})
+	child := createViaAPI(t, srv, map[string]any{"title": "Child", "parent_id": epic.ID})
+	claimViaAPI(t, srv, child.ID, "agent-1")
+
+	if n := srv.reapExpiredClaims(time.Now().UTC()); n != 0 {
+		t.Fatalf("expected nothing released before expiry, got %d", n)
+	}
+	if n := srv.reapExpiredClaims(time.Now().UTC().Add(2 * time.Minute)); n != 1 {
+		t.Fatalf("expected 1 release, got %d", n)
+	}
+
+	got, _ := soleStore(srv).Get(child.ID)
+	if got.Status != model.StatusOpen || got.Assignee != "" {
+		t.Errorf("expected child open and unassigned, got status=%q assignee=%q", got.Status, got.Assignee)
+	}
+	parent, _ := soleStore(srv).Get(epic.ID)
+	if parent.Status != model.StatusOpen {
+		t.Errorf("expected epic status recomputed to open, got %q", parent.Status)
+	}
+
+	entries := getHistory(t, srv, child.ID)
+	last := entries[len(entries)-1]
+	if last.Action != model.ActionReleased || last.Actor != store.SystemAuthor {
+		t.Errorf("expected released entry by system, got %+v", last)
+	}
+}
+
+func TestReapInterval(t *testing.T) {
+	if got := reapInterval(time.Minute); got != 15*time.Second {
+		t.Errorf("reapInterval(1m) = %v, want 15s", got)
+	}
+	if got := reapInterval(time.Hour); got != maxReapInterval {
+		t.Errorf("reapInterval(1h) = %v, want %v", got, maxReapInterval)
+	}
+}
@@ -0,0 +1,298 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
+)
+
+// planItem is one bead in an imported plan: the epic at the root, or one of
+// its children. Key names the item within the plan. BlockedBy lists the keys
+// of other items in the plan or the IDs of existing beads.
+type planItem struct {
+	Key         string         `json:"key"`
+	Title       string         `json:"title"`
+	Description string         `json:"description"`
+	Status      model.Status   `json:"status"`
+	Priority    model.Priority `json:"priority"`
+	Type        model.BeadType `json:"type"`
+	Tags        []string       `json:"tags"`
+	Assignee    string         `json:"assignee"`
+	BlockedBy   []string       `json:"blocked_by"`
+	Children    []planItem     `json:"children"`
+}
+
+// importPlanRequest is the JSON body for POST /api/v1/import-plan.
+type importPlanRequest struct {
+	Plan   json.RawMessage `json:"plan"`
+	DryRun bool            `json:"dry_run"`
+}
+
+// importPlanResponse reports what an import did, or would do for a dry run.
+// Keys maps plan keys to bead IDs; a dry run only knows the IDs of beads
+// that already exist. Created, Updated and Unchanged list plan keys.
+type importPlanResponse struct {
+	DryRun    bool              `json:"dry_run"`
+	Keys      map[string]string `json:"keys"`
+	Created   []string          `json:"created"`
+	Updated   []string          `json:"updated"`
+	Unchanged []string          `json:"unchanged"`
+}
+
+// errDryRun aborts a dry-run import's batch once every step has succeeded,
+// so that nothing is committed.
+var errDryRun = errors.New("dry run")
+
+// planExternalKey returns the external key stored on the bead for a plan
+// item: the root key for the epic and "root/child" for its children.
+func planExternalKey(root, key string) string {
+	if key == root {
+		return root
+	}
+	return root + "/" + key
+}
+
+// parsePlan decodes and checks a plan: the root and each child need a key
+// and a title, keys are unique, children have no children of their own, and
+// blocked_by names plan keys or bead IDs.
+func parsePlan(data json.RawMessage) (planItem, error) {
+	var root planItem
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&root); err != nil {
+		return planItem{}, fmt.Errorf("invalid plan: %v", err)
+	}
+
+	items := append([]planItem{root}, root.Children...)
+	keys := make(map[string]bool, len(items))
+	for i, item := range items {
+		switch {
+		case item.Key == "":
+			return planItem{}, errors.New("every plan item needs a key")
+		case strings.Contains(item.Key, "/"):
+			return planItem{}, fmt.Errorf("plan key %q must not contain '/'", item.Key)
+		case strings.HasPrefix(item.Key, model.IDPrefix):
+			return planItem{}, fmt.Errorf("plan key %q must not start with %q", item.Key, model.IDPrefix)
+		case item.Title == "":
+			return planItem{}, fmt.Errorf("%s: title is required", item.Key)
+		case keys[item.Key]:
+			return planItem{}, fmt.Errorf("duplicate plan key %q", item.Key)
+		case i > 0 && len(item.Children) > 0:
+			return planItem{}, fmt.Errorf("%s: epics cannot be nested", item.Key)
+		}
+		keys[item.Key] = true
+	}
+	for _, item := range items {
+		for _, dep := range item.BlockedBy {
+			if !keys[dep] && !strings.HasPrefix(dep, model.IDPrefix) {
+				return planItem{}, fmt.Errorf("%s: blocked_by %q is neither a plan key nor a bead ID", item.Key, dep)
+			}
+		}
+	}
+	return root, nil
+}
+
+// planImport applies a plan inside a store batch.
+type planImport struct {
+	*batch
+	root     string
+	existing map[string]model.Bead // by external key
+	resp     importPlanResponse
+	updated  map[string]bool
+}
+
+// handleImportPlan handles POST /api/v1/import-plan. The plan becomes an
+// epic with one child per item, created with the same rules as the batch
+// endpoint. Items are matched to beads from earlier imports by external key,
+// so importing the same plan twice changes nothing. Status is only applied
+// to new beads, and dependencies are only ever added.
+func (s *Server) handleImportPlan(w http.ResponseWriter, r *http.Request) {
+	var req importPlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Plan) == 0 {
+		jsonError(w, "plan is required", http.StatusBadRequest)
+		return
+	}
+
+	root, err := parsePlan(req.Plan)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p := &planImport{
+		batch: &batch{
+			s:       s,
+			r:       r,
+			project: s.projectFor(r),
+			ids:     s.ids.claim(),
+			refs:    map[string]string{},
+		},
+		root:    root.Key,
+		resp:    importPlanResponse{DryRun: req.DryRun, Keys: map[string]string{}},
+		updated: map[string]bool{},
+	}
+
+	err = s.storeFor(r).Batch(func(tx store.Backend) error {
+		p.tx = tx
+		if err := p.apply(root); err != nil {
+			return err
+		}
+		if req.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil {
+		p.ids.release()
+	}
+	if err != nil && !errors.Is(err, errDryRun) {
+		jsonError(w, err.Error(), errorCode(err))
+		return
+	}
+
+	if req.DryRun {
+		for _, key := range p.resp.Created {
+			delete(p.resp.Keys, key)
+		}
+	}
+	jsonOK(w, p.resp)
+	if !req.DryRun {
+		for _, ev := range p.events {
+			s.publish(ev)
+		}
+	}
+}
+
+// apply creates or updates every item in the plan, then adds the missing
+// dependencies. Links are added after all beads exist so that items can
+// depend on items later in the plan; Link rejects any that form a cycle.
+func (p *planImport) apply(root planItem) error {
+	p.existing = map[string]model.Bead{}
+	for _, b := range p.tx.All() {
+		if b.ExternalKey != "" {
+			p.existing[b.ExternalKey] = b
+		}
+	}
+
+	if err := p.upsert(root, ""); err != nil {
+		return fmt.Errorf("%s: %w", root.Key, err)
+	}
+	for _, child := range root.Children {
+		if err := p.upsert(child, p.resp.Keys[root.Key]); err != nil {
+			return fmt.Errorf("%s: %w", child.Key, err)
+		}
+	}
+
+	items := append([]planItem{root}, root.Children...)
+	for _, item := range items {
+		if err := p.linkDeps(item); err != nil {
+			return fmt.Errorf("%s: %w", item.Key, err)
+		}
+	}
+
+	for _, item := range items {
+		switch {
+		case slices.Contains(p.resp.Created, item.Key):
+		case p.updated[item.Key]:
+			p.resp.Updated = append(p.resp.Updated, item.Key)
+		default:
+			p.resp.Unchanged = append(p.resp.Unchanged, item.Key)
+		}
+	}
+	return nil
+}
+
+// upsert creates the bead for item under parentID, or brings the bead from
+// an earlier import up to date with it. Fields the item leaves empty are not
+// changed on an existing bead.
+func (p *planImport) upsert(item planItem, parentID string) error {
+	ek := planExternalKey(p.root, item.Key)
+	existing, ok := p.existing[ek]
+	if !ok {
+		created, err := p.create(batchOp{Bead: &createRequest{
+			Title:       item.Title,
+			Description: item.Description,
+			Status:      item.Status,
+			Priority:    item.Priority,
+			Type:        item.Type,
+			Tags:        item.Tags,
+			Assignee:    item.Assignee,
+			ParentID:    parentID,
+			ExternalKey: ek,
+		}})
+		if err != nil {
+			return err
+		}
+		p.resp.Keys[item.Key] = created.ID
+		p.resp.Created = append(p.resp.Created, item.Key)
+		return nil
+	}
+
+	p.resp.Keys[item.Key] = existing.ID
+	if parentID != "" && existing.ParentID != parentID {
+		if err := p.move(existing, parentID); err != nil {
+			return err
+		}
+		p.updated[item.Key] = true
+		existing, _ = p.tx.Get(existing.ID)
+	}
+
+	var req updateRequest
+	changed := false
+	if item.Title != existing.Title {
+		req.Title, changed = &item.Title, true
+	}
+	if item.Description != "" && item.Description != existing.Description {
+		req.Description, changed = &item.Description, true
+	}
+	if item.Priority != "" && item.Priority != existing.Priority {
+		req.Priority, changed = &item.Priority, true
+	}
+	if item.Type != "" && item.Type != existing.Type {
+		req.Type, changed = &item.Type, true
+	}
+	if item.Tags != nil && !slices.Equal(item.Tags, existing.Tags) {
+		req.Tags, changed = &item.Tags, true
+	}
+	if item.Assignee != "" && item.Assignee != existing.Assignee {
+		req.Assignee, changed = &item.Assignee, true
+	}
+	if !changed {
+		return nil
+	}
+	p.updated[item.Key] = true
+	return p.update(existing, req)
+}
+
+// linkDeps adds each of item's blockers that its bead does not already have.
+func (p *planImport) linkDeps(item planItem) error {
+	for _, dep := range item.BlockedBy {
+		depID := dep
+		if id, ok := p.resp.Keys[dep]; ok {
+			depID = id
+		}
+		b, err := p.tx.Get(p.resp.Keys[item.Key])
+		if err != nil {
+			return err
+		}
+		if slices.Contains(b.BlockedBy, depID) {
+			continue
+		}
+		if err := p.link(b, depID); err != nil {
+			return fmt.Errorf("blocked_by %s: %w", dep, err)
+		}
+		p.updated[item.Key] = true
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vector76/beads_server/model"
+)
+
+func importPlan(t *testing.T, srv *Server, plan map[string]any, dryRun bool) (*httptest.ResponseRecorder, importPlanResponse) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodPost, "/api/v1/import-plan", map[string]any{"plan": plan, "dry_run": dryRun}))
+	var resp importPlanResponse
+	if w.Code == http.StatusOK {
+		json.NewDecoder(w.Body).Decode(&resp)
+	}
+	return w, resp
+}
+
+func testPlan() map[string]any {
+	return map[string]any{
+		"key":   "auth",
+		"title": "Auth rewrite",
+		"tags":  []string{"auth"},
+		"children": []map[string]any{
+			{"key": "schema", "title": "Design schema", "priority": "high"},
+			{"key": "api", "title": "Build API", "blocked_by": []string{"schema"}},
+			{"key": "rollout", "title": "Roll out", "status": "not_ready", "blocked_by": []string{"api", "schema"}},
+		},
+	}
+}
+
+func TestImportPlan_CreatesEpicTree(t *testing.T) {
+	srv := crudServer(t)
+
+	w, resp := importPlan(t, srv, testPlan(), false)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(resp.Created) != 4 || len(resp.Keys) != 4 {
+		t.Fatalf("response = %+v, want 4 created", resp)
+	}
+
+	epic, _ := soleStore(srv).Get(resp.Keys["auth"])
+	if epic.ExternalKey != "auth" || len(epic.Tags) != 1 {
+		t.Errorf("epic = %+v", epic)
+	}
+	rollout, _ := soleStore(srv).Get(resp.Keys["rollout"])
+	if rollout.ParentID != epic.ID || rollout.Status != model.StatusNotReady || rollout.ExternalKey != "auth/rollout" {
+		t.Errorf("rollout = %+v", rollout)
+	}
+	if len(rollout.BlockedBy) != 2 || rollout.BlockedBy[0] != resp.Keys["api"] || rollout.BlockedBy[1] != resp.Keys["schema"] {
+		t.Errorf("rollout blocked_by = %v", rollout.BlockedBy)
+	}
+	schema, _ := soleStore(srv).Get(resp.Keys["schema"])
+	if schema.Priority != model.PriorityHigh {
+		t.Errorf("schema priority = %q", schema.Priority)
+	}
+}
+
+func TestImportPlan_ReimportIsIdempotent(t *testing.T) {
+	srv := crudServer(t)
+	_, first := importPlan(t, srv, testPlan(), false)
+
+	w, second := importPlan(t, srv, testPlan(), false)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if len(second.Created) != 0 || len(second.Updated) != 0 || len(second.Unchanged) != 4 {
+		t.Errorf("re-import = %+v, want everything unchanged", second)
+	}
+	if len(soleStore(srv).All()) != 4 {
+		t.Errorf("store has %d beads after re-import, want 4", len(soleStore(srv).All()))
+	}
+	for key, id := range first.Keys {
+		if second.Keys[key] != id {
+			t.Errorf("key %s matched %s, want %s", key, second.Keys[key], id)
+		}
+	}
+
+	// Changing one item and adding another touches only those.
+	plan := testPlan()
+	children := plan["children"].([]map[string]any)
+	children[0]["title"] = "Design the schema"
+	plan["children"] = append(children, map[string]any{"key": "docs", "title": "Write docs", "blocked_by": []string{"api"}})
+	_, third := importPlan(t, srv, plan, false)
+	if len(third.Created) != 1 || third.Created[0] != "docs" || len(third.Updated) != 1 || third.Updated[0] != "schema" {
+		t.Errorf("third import = %+v", third)
+	}
+}
+
+func TestImportPlan_DryRun(t *testing.T) {
+	srv := crudServer(t)
+
+	w, resp := importPlan(t, srv, testPlan(), true)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !resp.DryRun || len(resp.Created) != 4 || len(resp.Keys) != 0 {
+		t.Errorf("dry run = %+v", resp)
+	}
+	if len(soleStore(srv).All()) != 0 {
+		t.Errorf("dry run created %d beads", len(soleStore(srv).All()))
+	}
+}
+
+func TestImportPlan_RejectsCycles(t *testing.T) {
+	srv := crudServer(t)
+	plan := testPlan()
+	children := plan["children"].([]map[string]any)
+	children[0]["blocked_by"] = []string{"rollout"}
+
+	for _, dryRun := range []bool{true, false} {
+		w, _ := importPlan(t, srv, plan, dryRun)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "circular dependency") {
+			t.Errorf("dry_run=%v: expected 400 for a circular dependency, got %d: %s", dryRun, w.Code, w.Body.String())
+		}
+	}
+	if len(soleStore(srv).All()) != 0 {
+		t.Errorf("failed import left %d beads", len(soleStore(srv).All()))
+	}
+}
+
+func TestImportPlan_Validation(t *testing.T) {
+	srv := crudServer(t)
+
+	tests := []struct {
+		name string
+		plan map[string]any
+	}{
+		{"missing key", map[string]any{"title": "No key"}},
+		{"unknown field", map[string]any{"key": "a", "title": "A", "colour": "red"}},
+		{"duplicate key", map[string]any{"key": "a", "title": "A", "children": []map[string]any{{"key": "a", "title": "Again"}}}},
+		{"nested epic", map[string]any{"key": "a", "title": "A", "children": []map[string]any{
+			{"key": "b", "title": "B", "children": []map[string]any{{"key": "c", "title": "C"}}},
+		}}},
+		{"unknown blocker", map[string]any{"key": "a", "title": "A", "blocked_by": []string{"nope"}}},
+		{"bad status", map[string]any{"key": "a", "title": "A", "status": "closed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, _ := importPlan(t, srv, tt.plan, false)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected 400, got %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+	if len(soleStore(srv).All()) != 0 {
+		t.Errorf("invalid plans left %d beads", len(soleStore(srv).All()))
+	}
+}
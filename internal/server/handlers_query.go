@@ -3,20 +3,59 @@ package server
 import (
 	"encoding/json"
 	"errors"
+	"math"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/vector76/beads_server/internal/model"
 	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
 )
 
 // handleListBeads handles GET /api/v1/beads.
 func (s *Server) handleListBeads(w http.ResponseWriter, r *http.Request) {
-	q := r.URL.Query()
+	result := s.storeFor(r).List(listFilters(r.URL.Query()))
+	jsonOK(w, result)
+}
+
+// handleListAllBeads handles GET /api/v1/all/beads: List over every project
+// the request may read, merged and paginated as one list, with each bead
+// tagged with its project.
+func (s *Server) handleListAllBeads(w http.ResponseWriter, r *http.Request) {
+	filters := listFilters(r.URL.Query())
+	page, perPage := filters.Page, filters.PerPage
+
+	// The merged page can come from the first page*perPage beads of any
+	// one project.
+	filters.Page = 1
+	if page > math.MaxInt/perPage {
+		filters.PerPage = math.MaxInt
+	} else {
+		filters.PerPage = page * perPage
+	}
 
+	var results []store.ListResult
+	for _, p := range s.provider.Projects() {
+		if !mayReadAll(r) && !granted(r, p.Name) {
+			continue
+		}
+		res := p.Store.List(filters)
+		for i := range res.Beads {
+			res.Beads[i].Project = p.Name
+			for j := range res.Beads[i].Children {
+				res.Beads[i].Children[j].Project = p.Name
+			}
+		}
+		results = append(results, res)
+	}
+	jsonOK(w, store.MergeLists(results, page, perPage))
+}
+
+// listFilters parses the query parameters of the list endpoints.
+func listFilters(q url.Values) store.ListFilters {
 	filters := store.ListFilters{
 		Page:    intParam(q.Get("page"), 1),
 		PerPage: intParam(q.Get("per_page"), 100),
@@ -72,8 +111,7 @@ func (s *Server) handleListBeads(w http.ResponseWriter, r *http.Request) {
 		filters.Ready = true
 	}
 
-	result := s.storeFor(r).List(filters)
-	jsonOK(w, result)
+	return filters
 }
 
 // handleSearch handles GET /api/v1/search.
@@ -86,10 +124,23 @@ func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	page := intParam(q.Get("page"), 1)
-	perPage := intParam(q.Get("per_page"), 100)
+	opts := store.SearchOptions{
+		Query:   query,
+		Sort:    q.Get("sort"),
+		Page:    intParam(q.Get("page"), 1),
+		PerPage: intParam(q.Get("per_page"), 100),
+	}
+	if v := q.Get("in"); v != "" {
+		for _, f := range strings.Split(v, ",") {
+			opts.In = append(opts.In, store.SearchField(strings.TrimSpace(f)))
+		}
+	}
 
-	result := s.storeFor(r).Search(query, page, perPage)
+	result, err := s.storeFor(r).Search(opts)
+	if err != nil {
+		jsonError(w, err.Error(), errorCode(err))
+		return
+	}
 	jsonOK(w, result)
 }
 
@@ -131,13 +182,16 @@ func (s *Server) handleClaimBead(w http.ResponseWriter, r *http.Request) {
 		jsonError(w, "invalid JSON body", http.StatusBadRequest)
 		return
 	}
+	req.User = userFor(r, req.User)
 
 	if req.User == "" {
 		jsonError(w, "user is required", http.StatusBadRequest)
 		return
 	}
 
-	claimed, err := st.Claim(existing.ID, req.User)
+	_, claimed, ev, err := s.change(st, s.projectFor(r), actorFor(r, req.User), model.ActionClaimed, func(tx store.Backend) (model.Bead, error) {
+		return tx.Claim(existing.ID, req.User, s.config.LeaseTTL)
+	})
 	if err != nil {
 		var conflictErr *store.ConflictError
 		if errors.As(err, &conflictErr) {
@@ -148,8 +202,54 @@ func (s *Server) handleClaimBead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	setETag(w, claimed)
+	jsonOK(w, claimed)
+	s.publish(ev)
+}
+
+// claimNextRequest is the JSON body for claiming the next ready bead. The
+// filters match those of GET /api/v1/beads?ready=true.
+type claimNextRequest struct {
+	User     string          `json:"user"`
+	Tags     []string        `json:"tags"`
+	Type     *model.BeadType `json:"type"`
+	Priority *model.Priority `json:"priority"`
+	Assignee *string         `json:"assignee"`
+}
+
+// handleClaimNext handles POST /api/v1/claim-next.
+func (s *Server) handleClaimNext(w http.ResponseWriter, r *http.Request) {
+	var req claimNextRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	req.User = userFor(r, req.User)
+
+	if req.User == "" {
+		jsonError(w, "user is required", http.StatusBadRequest)
+		return
+	}
+
+	filters := store.ListFilters{
+		Tags:     req.Tags,
+		Type:     req.Type,
+		Priority: req.Priority,
+		Assignee: req.Assignee,
+	}
+
+	_, claimed, ev, err := s.change(s.storeFor(r), s.projectFor(r), actorFor(r, req.User), model.ActionClaimed, func(tx store.Backend) (model.Bead, error) {
+		_, claimed, err := tx.ClaimNext(filters, req.User, s.config.LeaseTTL)
+		return claimed, err
+	})
+	if err != nil {
+		jsonError(w, err.Error(), errorCode(err))
+		return
+	}
+
+	setETag(w, claimed)
 	jsonOK(w, claimed)
-	s.broadcaster.publish()
+	s.publish(ev)
 }
 
 // cleanRequest is the JSON body for the clean operation.
@@ -181,14 +281,35 @@ func (s *Server) handleClean(w http.ResponseWriter, r *http.Request) {
 
 	cutoff := time.Now().UTC().Add(-time.Duration(days * 24 * float64(time.Hour)))
 
-	removed, err := s.storeFor(r).Clean(cutoff)
+	st := s.storeFor(r)
+	var done []string
+	for _, b := range st.All() {
+		if b.Status == model.StatusClosed || b.Status == model.StatusDeleted {
+			done = append(done, b.ID)
+		}
+	}
+
+	removed, err := st.Clean(cutoff)
 	if err != nil {
 		jsonError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// The IDs of the beads cleaned out are free again.
+	var notFound *store.NotFoundError
+	for _, id := range done {
+		if _, err := st.Get(id); errors.As(err, &notFound) {
+			s.ids.release(id)
+		}
+	}
+
 	jsonOK(w, cleanResponse{Removed: removed})
-	s.broadcaster.publish()
+	s.publish(Event{
+		Type:    EventBeadsCleaned,
+		Project: s.projectFor(r),
+		Actor:   actorFor(r, ""),
+		At:      time.Now().UTC(),
+	})
 }
 
 // statusUnknown is the sentinel returned for IDs not found in any project store.
@@ -244,3 +365,41 @@ func intParam(val string, defaultVal int) int {
 	}
 	return n
 }
+
+// heartbeatRequest is the JSON body for renewing a claim's lease.
+type heartbeatRequest struct {
+	User string `json:"user"`
+}
+
+// handleHeartbeat handles POST /api/v1/beads/:id/heartbeat.
+func (s *Server) handleHeartbeat(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	st := s.storeFor(r)
+
+	existing, err := st.Resolve(id)
+	if err != nil {
+		jsonError(w, err.Error(), errorCode(err))
+		return
+	}
+
+	var req heartbeatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	req.User = userFor(r, req.User)
+
+	if req.User == "" {
+		jsonError(w, "user is required", http.StatusBadRequest)
+		return
+	}
+
+	updated, err := st.Heartbeat(existing.ID, req.User, s.config.LeaseTTL)
+	if err != nil {
+		jsonError(w, err.Error(), errorCode(err))
+		return
+	}
+
+	setETag(w, updated)
+	jsonOK(w, updated)
+}
@@ -3,12 +3,15 @@ package server
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"sync"
 	"testing"
 
-	"github.com/vector76/beads_server/internal/model"
 	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
 )
 
 // patchStatus is a test helper that updates the status of a bead via PATCH.
@@ -234,6 +237,61 @@ func TestSearch_Pagination(t *testing.T) {
 	}
 }
 
+func TestSearch_InCommentsByRelevance(t *testing.T) {
+	srv := crudServer(t)
+	titled := createViaAPI(t, srv, map[string]any{"title": "Flaky upload test"})
+	commented := createViaAPI(t, srv, map[string]any{"title": "CI is slow"})
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodPost, "/api/v1/beads/"+commented.ID+"/comments",
+		map[string]any{"author": "alice", "text": "The upload test is flaky again"}))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("add comment: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodGet, "/api/v1/search?q=flaky&in=comments&sort=relevance", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var result store.ListResult
+	json.NewDecoder(w.Body).Decode(&result)
+	if result.Total != 1 || result.Beads[0].ID != commented.ID {
+		t.Fatalf("in=comments found %+v, want only %s", result.Beads, commented.ID)
+	}
+	m := result.Beads[0].Matches
+	if len(m) != 1 || m[0].Field != store.SearchComments || m[0].Snippet != "The upload test is **flaky** again" {
+		t.Errorf("matches = %+v", m)
+	}
+
+	w = httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodGet, "/api/v1/search?q=flaky+upload&sort=relevance", nil))
+	json.NewDecoder(w.Body).Decode(&result)
+	if result.Total != 2 || result.Beads[0].ID != titled.ID {
+		t.Errorf("by relevance = %+v, want the title match first", result.Beads)
+	}
+}
+
+func TestSearch_BadParams(t *testing.T) {
+	srv := crudServer(t)
+	for _, q := range []string{"q=", "q=x&sort=oldest", "q=x&in=tags"} {
+		w := httptest.NewRecorder()
+		srv.Router.ServeHTTP(w, authReq(http.MethodGet, "/api/v1/search?"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", q, w.Code)
+		}
+	}
+
+	// A query with no words is not an error; it matches nothing.
+	createViaAPI(t, srv, map[string]any{"title": "Anything"})
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodGet, "/api/v1/search?q=%22-%22", nil))
+	var result store.ListResult
+	json.NewDecoder(w.Body).Decode(&result)
+	if w.Code != http.StatusOK || result.Total != 0 {
+		t.Errorf("query with no words: got %d with %d beads, want 200 with none", w.Code, result.Total)
+	}
+}
+
 // --- Claim tests ---
 
 func TestClaimBead_Success(t *testing.T) {
@@ -389,7 +447,7 @@ func TestClean_DaysZeroRemovesAll(t *testing.T) {
 	}
 
 	// Open bead should still exist
-	all := srv.Store.All()
+	all := soleStore(srv).All()
 	if len(all) != 1 {
 		t.Fatalf("expected 1 remaining bead, got %d", len(all))
 	}
@@ -491,3 +549,180 @@ func TestClean_RequiresAuth(t *testing.T) {
 		t.Fatalf("expected 401, got %d", w.Code)
 	}
 }
+
+// --- Claim next ---
+
+func TestClaimNext_ClaimsHighestPriority(t *testing.T) {
+	srv := crudServer(t)
+	createViaAPI(t, srv, map[string]any{"title": "Low", "priority": "low"})
+	high := createViaAPI(t, srv, map[string]any{"title": "High", "priority": "high"})
+
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodPost, "/api/v1/claim-next", map[string]any{"user": "agent-1"}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var b model.Bead
+	json.NewDecoder(w.Body).Decode(&b)
+	if b.ID != high.ID || b.Status != model.StatusInProgress || b.Assignee != "agent-1" {
+		t.Errorf("expected %s claimed by agent-1, got %+v", high.ID, b)
+	}
+
+	entries := getHistory(t, srv, high.ID)
+	last := entries[len(entries)-1]
+	if last.Action != model.ActionClaimed || last.Actor != "agent-1" {
+		t.Errorf("expected claimed history entry by agent-1, got %+v", last)
+	}
+}
+
+func TestClaimNext_Filters(t *testing.T) {
+	srv := crudServer(t)
+	createViaAPI(t, srv, map[string]any{"title": "Critical bug", "priority": "critical", "type": "bug"})
+	tagged := createViaAPI(t, srv, map[string]any{"title": "Tagged", "priority": "low", "tags": []string{"backend"}})
+
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodPost, "/api/v1/claim-next", map[string]any{
+		"user": "agent-1", "tags": []string{"backend"}, "type": "task",
+	}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var b model.Bead
+	json.NewDecoder(w.Body).Decode(&b)
+	if b.ID != tagged.ID {
+		t.Errorf("expected %s, got %s", tagged.ID, b.ID)
+	}
+
+	w = httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodPost, "/api/v1/claim-next", map[string]any{"user": "agent-1", "priority": "high"}))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 when nothing matches, got %d", w.Code)
+	}
+}
+
+func TestClaimNext_BadRequest(t *testing.T) {
+	srv := crudServer(t)
+	for _, body := range []map[string]any{
+		{},
+		{"user": "agent-1", "priority": "urgent"},
+	} {
+		w := httptest.NewRecorder()
+		srv.Router.ServeHTTP(w, authReq(http.MethodPost, "/api/v1/claim-next", body))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("body %v: expected 400, got %d", body, w.Code)
+		}
+	}
+}
+
+func TestClaimNext_ConcurrentCallersGetDistinctBeads(t *testing.T) {
+	srv := crudServer(t)
+	const n = 8
+	for i := range n {
+		createViaAPI(t, srv, map[string]any{"title": fmt.Sprintf("Bead %d", i)})
+	}
+
+	var mu sync.Mutex
+	won := make(map[string]string)
+	var wg sync.WaitGroup
+	for i := range n + 2 {
+		wg.Add(1)
+		go func(user string) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			srv.Router.ServeHTTP(w, authReq(http.MethodPost, "/api/v1/claim-next", map[string]any{"user": user}))
+			if w.Code != http.StatusOK {
+				return
+			}
+			var b model.Bead
+			json.NewDecoder(w.Body).Decode(&b)
+			mu.Lock()
+			defer mu.Unlock()
+			if prev, ok := won[b.ID]; ok {
+				t.Errorf("bead %s claimed by both %s and %s", b.ID, prev, user)
+			}
+			won[b.ID] = user
+		}(fmt.Sprintf("agent-%d", i))
+	}
+	wg.Wait()
+
+	if len(won) != n {
+		t.Errorf("expected %d distinct beads claimed, got %d", n, len(won))
+	}
+}
+
+func TestListAllBeads(t *testing.T) {
+	dir := t.TempDir()
+	var entries []ProviderEntry
+	for _, name := range []string{"alpha", "beta", "gamma"} {
+		st, err := store.Load(filepath.Join(dir, name+".json"))
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		entries = append(entries, ProviderEntry{Name: name, Token: "tok-" + name, Store: st})
+	}
+	srv, err := New(Config{LogOutput: io.Discard, AdminToken: "admin-secret"}, NewMultiStoreProvider(entries))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	for _, name := range []string{"alpha", "beta", "gamma"} {
+		for i := range 2 {
+			serveAs(srv, "tok-"+name, http.MethodPost, "/api/v1/beads", map[string]any{
+				"title": fmt.Sprintf("%s %d", name, i), "assignee": "agent-3",
+			})
+		}
+	}
+
+	list := func(token, projectTokens, query string) store.ListResult {
+		t.Helper()
+		w := serveWithProjects(srv, token, projectTokens, http.MethodGet, "/api/v1/all/beads"+query, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("GET %s: expected 200, got %d: %s", query, w.Code, w.Body.String())
+		}
+		var res store.ListResult
+		json.NewDecoder(w.Body).Decode(&res)
+		return res
+	}
+	projects := func(res store.ListResult) map[string]int {
+		seen := map[string]int{}
+		for _, b := range res.Beads {
+			seen[b.Project]++
+		}
+		return seen
+	}
+
+	all := list("admin-secret", "", "?assignee=agent-3")
+	if all.Total != 6 || len(all.Beads) != 6 {
+		t.Fatalf("admin listing = %d of %d beads, want all 6", len(all.Beads), all.Total)
+	}
+	if got := projects(all); got["alpha"] != 2 || got["beta"] != 2 || got["gamma"] != 2 {
+		t.Errorf("projects = %v, want two beads tagged with each", got)
+	}
+
+	scoped := list("tok-alpha", "tok-gamma", "")
+	if got := projects(scoped); scoped.Total != 4 || got["alpha"] != 2 || got["gamma"] != 2 {
+		t.Errorf("alpha with a gamma token: total %d, projects %v", scoped.Total, got)
+	}
+
+	// Pages of the union hold the same beads as the whole list, in order.
+	var paged []string
+	for page := 1; page <= 3; page++ {
+		res := list("admin-secret", "", fmt.Sprintf("?per_page=2&page=%d", page))
+		if res.TotalPages != 3 {
+			t.Errorf("page %d: total_pages = %d, want 3", page, res.TotalPages)
+		}
+		for _, b := range res.Beads {
+			paged = append(paged, b.ID)
+		}
+	}
+	for i, b := range all.Beads {
+		if i >= len(paged) || paged[i] != b.ID {
+			t.Fatalf("paged = %v, want the order of %v", paged, all.Beads)
+		}
+	}
+
+	if w := serveAs(srv, "wrong", http.MethodGet, "/api/v1/all/beads", nil); w.Code != http.StatusUnauthorized {
+		t.Errorf("unknown token: expected 401, got %d", w.Code)
+	}
+}
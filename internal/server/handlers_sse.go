@@ -1,14 +1,34 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 )
 
-// handleSSE streams server-sent events to the client. It sends a minimal
-// "update" event whenever the broadcaster fires, and exits when the request
-// context is cancelled.
+// handleSSE serves the dashboard stream at /events. It is unauthenticated
+// and covers every project, so it only sends a minimal "update" message per
+// batch of changes, without any event details.
 func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
+	s.streamEvents(w, r, "", false)
+}
+
+// handleEvents serves GET /api/v1/events. Each mutation in the caller's
+// project is sent as a named event (e.g. "bead.updated") with its event ID
+// and the JSON Event as data, and every batch ends with an unnamed "update"
+// message for clients that only need to know something changed. Events from
+// other projects are never sent. A client reconnecting with Last-Event-ID is
+// first sent the events it missed, or a reset event if they are gone.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	s.streamEvents(w, r, s.projectFor(r), true)
+}
+
+// streamEvents writes broadcaster batches to w as server-sent events until
+// the request context is cancelled. A non-empty project restricts the stream
+// to that project's events; typed includes the events themselves rather than
+// only the trailing "update" message.
+func (s *Server) streamEvents(w http.ResponseWriter, r *http.Request, project string, typed bool) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		http.Error(w, "streaming not supported", http.StatusInternalServerError)
@@ -19,16 +39,64 @@ func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 
-	ch := s.broadcaster.subscribe()
+	// writeBatch sends the events that pass the project filter, followed
+	// by the "update" message if there were any.
+	writeBatch := func(events []Event) {
+		sent := 0
+		for _, ev := range events {
+			if project != "" && ev.Project != "" && ev.Project != project {
+				continue
+			}
+			sent++
+			if !typed {
+				continue
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", ev.ID, ev.Type, data)
+		}
+		if sent > 0 {
+			fmt.Fprint(w, "data: update\n\n")
+		}
+		flusher.Flush()
+	}
+
+	var ch chan struct{}
+	if lastID, ok := lastEventID(r); ok && typed {
+		var missed []Event
+		ch, missed = s.broadcaster.subscribeFrom(project, lastID)
+		writeBatch(missed)
+	} else {
+		ch = s.broadcaster.subscribe()
+	}
 	defer s.broadcaster.unsubscribe(ch)
 
+	// The stream only reads the broadcaster from here on, so it need not
+	// hold back the closing of stores retired by a reload.
+	untrackRequest(r)
+
 	for {
 		select {
 		case <-ch:
-			fmt.Fprint(w, "data: update\n\n")
-			flusher.Flush()
+			writeBatch(s.broadcaster.take(ch))
 		case <-r.Context().Done():
 			return
 		}
 	}
 }
+
+// lastEventID parses the Last-Event-ID header sent by a reconnecting client.
+// An unparseable value is treated as an unknown ID, which yields a reset.
+func lastEventID(r *http.Request) (uint64, bool) {
+	v := r.Header.Get("Last-Event-ID")
+	if v == "" {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return 0, true
+	}
+	return id, true
+}
@@ -2,8 +2,11 @@ package server
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -55,7 +58,7 @@ func TestSSE_ReceivesUpdateAfterPublish(t *testing.T) {
 
 	// Wait for handler to subscribe, then publish.
 	time.Sleep(50 * time.Millisecond)
-	srv.broadcaster.publish()
+	srv.broadcaster.publish(Event{Type: EventBeadUpdated, Project: "default", BeadID: "bd-test"})
 
 	// Wait for debounce (200ms) plus a little margin.
 	time.Sleep(300 * time.Millisecond)
@@ -64,6 +67,9 @@ func TestSSE_ReceivesUpdateAfterPublish(t *testing.T) {
 	<-done
 
 	body := w.Body.String()
+	if strings.Contains(body, "event:") || strings.Contains(body, "bd-test") {
+		t.Errorf("expected the dashboard stream to carry no event details, got %q", body)
+	}
 	if !strings.Contains(body, "data: update\n\n") {
 		t.Errorf("expected body to contain 'data: update\\n\\n', got %q", body)
 	}
@@ -118,3 +124,115 @@ func TestSSE_NoAuthRequired(t *testing.T) {
 		t.Fatalf("expected 200 without auth, got %d", w.Code)
 	}
 }
+
+func TestEventsStream_RequiresAuth(t *testing.T) {
+	srv := crudServer(t)
+
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/events", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without auth, got %d", w.Code)
+	}
+}
+
+func TestEventsStream_ScopedToProject(t *testing.T) {
+	srv, err := New(Config{LogOutput: io.Discard}, NewMultiStoreProvider([]ProviderEntry{
+		{Name: "alpha", Token: "tok-alpha", Store: loadTestStore(t)},
+		{Name: "beta", Token: "tok-beta", Store: loadTestStore(t)},
+	}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/events", nil).WithContext(ctx)
+	req.Header.Set("Authorization", "Bearer tok-alpha")
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		srv.Router.ServeHTTP(w, req)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	srv.broadcaster.publish(Event{Type: EventBeadCreated, Project: "beta", BeadID: "bd-beta"})
+	time.Sleep(300 * time.Millisecond)
+	srv.broadcaster.publish(Event{Type: EventBeadCreated, Project: "alpha", BeadID: "bd-alpha"})
+	time.Sleep(300 * time.Millisecond)
+
+	cancel()
+	<-done
+
+	body := w.Body.String()
+	if strings.Contains(body, "bd-beta") {
+		t.Errorf("alpha stream received a beta event: %q", body)
+	}
+	if !strings.Contains(body, "event: bead.created\ndata: {") || !strings.Contains(body, `"bead_id":"bd-alpha"`) {
+		t.Errorf("expected the alpha bead.created event, got %q", body)
+	}
+	if n := strings.Count(body, "data: update\n\n"); n != 1 {
+		t.Errorf("expected one update message (for alpha's batch only), got %d in %q", n, body)
+	}
+}
+
+func TestEventsStream_ReplaysAfterLastEventID(t *testing.T) {
+	srv := crudServer(t)
+
+	ch := srv.broadcaster.subscribe()
+	defer srv.broadcaster.unsubscribe(ch)
+	for _, id := range []string{"bd-seen", "bd-missed"} {
+		srv.broadcaster.publish(Event{Type: EventBeadCreated, Project: "default", BeadID: id})
+	}
+	assertBroadcast(t, ch)
+	published := srv.broadcaster.take(ch)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := authReq(http.MethodGet, "/api/v1/events", nil).WithContext(ctx)
+	req.Header.Set("Last-Event-ID", strconv.FormatUint(published[0].ID, 10))
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		srv.Router.ServeHTTP(w, req)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	body := w.Body.String()
+	if strings.Contains(body, "bd-seen") {
+		t.Errorf("replayed an event the client had already seen: %q", body)
+	}
+	wantID := fmt.Sprintf("id: %d\nevent: bead.created\n", published[1].ID)
+	if !strings.Contains(body, wantID) || !strings.Contains(body, "bd-missed") {
+		t.Errorf("expected the missed event with its ID, got %q", body)
+	}
+}
+
+func TestEventsStream_ResetForUnknownLastEventID(t *testing.T) {
+	srv := crudServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := authReq(http.MethodGet, "/api/v1/events", nil).WithContext(ctx)
+	req.Header.Set("Last-Event-ID", "7")
+	w := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		srv.Router.ServeHTTP(w, req)
+		close(done)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+
+	body := w.Body.String()
+	if !strings.Contains(body, "event: reset\n") || !strings.Contains(body, "data: update\n\n") {
+		t.Errorf("expected a reset event followed by an update, got %q", body)
+	}
+}
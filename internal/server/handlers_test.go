@@ -9,8 +9,8 @@ import (
 	"path/filepath"
 	"testing"
 
-	"github.com/vector76/beads_server/internal/model"
 	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
 )
 
 func authReq(method, url string, body any) *http.Request {
@@ -36,10 +36,15 @@ func crudServer(t *testing.T) *Server {
 	if err != nil {
 		t.Fatalf("New: %v", err)
 	}
-	srv.Store = s
 	return srv
 }
 
+// soleStore returns the store of a single-project test server, for checking
+// state directly.
+func soleStore(srv *Server) store.Backend {
+	return srv.provider.Projects()[0].Store
+}
+
 func createViaAPI(t *testing.T, srv *Server, body map[string]any) model.Bead {
 	t.Helper()
 	req := authReq(http.MethodPost, "/api/v1/beads", body)
@@ -288,7 +293,7 @@ func TestDeleteBead(t *testing.T) {
 	}
 
 	// Verify it's actually deleted in the store
-	got, _ := srv.Store.Get(created.ID)
+	got, _ := soleStore(srv).Get(created.ID)
 	if got.Status != model.StatusDeleted {
 		t.Fatal("bead not actually soft-deleted in store")
 	}
@@ -314,7 +319,7 @@ func TestUpdateBead_UnblockedField(t *testing.T) {
 	blocked := createViaAPI(t, srv, map[string]any{"title": "Blocked"})
 
 	// Link them
-	srv.Store.Link(blocked.ID, blocker.ID)
+	soleStore(srv).Link(blocked.ID, blocker.ID)
 
 	// Close the blocker
 	req := authReq(http.MethodPatch, "/api/v1/beads/"+blocker.ID, map[string]any{
@@ -431,7 +436,7 @@ func TestDeleteBead_UnblockedField(t *testing.T) {
 	blocked := createViaAPI(t, srv, map[string]any{"title": "Blocked"})
 
 	// Link them
-	srv.Store.Link(blocked.ID, blocker.ID)
+	soleStore(srv).Link(blocked.ID, blocker.ID)
 
 	// Delete the blocker
 	req := authReq(http.MethodDelete, "/api/v1/beads/"+blocker.ID, nil)
@@ -502,7 +507,6 @@ func TestCreateBead_MultiProject_CrossProjectUniqueness(t *testing.T) {
 	if err != nil {
 		t.Fatalf("New: %v", err)
 	}
-	srv.Store = storeB
 
 	// Create many beads via the HTTP API (targeting store B) and verify none get the seeded ID.
 	for i := range 50 {
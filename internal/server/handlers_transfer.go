@@ -0,0 +1,289 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vector76/beads_server/internal/project"
+	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
+)
+
+// transferRequest is the JSON body for moving a bead to another project.
+type transferRequest struct {
+	To     string `json:"to"`
+	Unlink bool   `json:"unlink"`
+}
+
+// transferEdge is one blocked_by entry: bead ID is blocked by BlockedBy.
+type transferEdge struct {
+	ID        string `json:"id"`
+	BlockedBy string `json:"blocked_by"`
+}
+
+// transferResponse is the JSON response for POST /beads/:id/transfer.
+// CrossProject lists the dependencies between moved beads and beads left
+// behind, which now cross projects; with unlink they are removed instead
+// and listed in Unlinked.
+type transferResponse struct {
+	Bead         model.Bead     `json:"bead"`
+	Project      string         `json:"project"`
+	Moved        []string       `json:"moved"`
+	CrossProject []transferEdge `json:"cross_project,omitempty"`
+	Unlinked     []transferEdge `json:"unlinked,omitempty"`
+}
+
+// handleTransferBead handles POST /api/v1/beads/:id/transfer. It moves a
+// bead, or an epic with its children, to another project on the server,
+// keeping IDs, comments, timestamps and history. The request needs a
+// writer token for the destination in ProjectTokensHeader. The beads are
+// added to the destination before they are removed from the source; if the
+// removal fails, the additions are undone, so the move is all-or-nothing.
+func (s *Server) handleTransferBead(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	src := s.storeFor(r)
+	srcProject := s.projectFor(r)
+
+	existing, err := src.Resolve(id)
+	if err != nil {
+		jsonError(w, err.Error(), errorCode(err))
+		return
+	}
+
+	rev, err := ifMatch(r)
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if rev != nil && *rev != existing.Revision {
+		jsonError(w, fmt.Sprintf("bead %s is at revision %d, not %d", existing.ID, existing.Revision, *rev), http.StatusPreconditionFailed)
+		return
+	}
+
+	var req transferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.To == "" {
+		jsonError(w, "to is required", http.StatusBadRequest)
+		return
+	}
+	if req.To == srcProject {
+		jsonError(w, fmt.Sprintf("bead %s is already in project %q", existing.ID, req.To), http.StatusBadRequest)
+		return
+	}
+
+	var dest store.Backend
+	for _, p := range s.provider.Projects() {
+		if p.Name == req.To {
+			dest = p.Store
+		}
+	}
+	if dest == nil {
+		jsonError(w, fmt.Sprintf("project %q not found", req.To), http.StatusNotFound)
+		return
+	}
+	if p, ok := grantsFor(r)[req.To]; !ok || !project.RoleAtLeast(p.Role, project.RoleWriter) {
+		jsonError(w, fmt.Sprintf("transferring into project %q needs a %s token for it in %s", req.To, project.RoleWriter, ProjectTokensHeader), http.StatusForbidden)
+		return
+	}
+
+	if existing.ParentID != "" {
+		jsonError(w, fmt.Sprintf("bead %s is a child of %s; transfer the epic, or move the bead out of it first", existing.ID, existing.ParentID), http.StatusConflict)
+		return
+	}
+
+	// The unit moved is the bead and its children.
+	unit := append([]model.Bead{existing}, src.ChildrenOf(existing.ID)...)
+	moved := make(map[string]bool, len(unit))
+	ids := make([]string, len(unit))
+	for i, b := range unit {
+		moved[b.ID] = true
+		ids[i] = b.ID
+	}
+	for _, b := range unit {
+		if _, err := dest.Get(b.ID); err == nil {
+			jsonError(w, fmt.Sprintf("project %q already has a bead %s", req.To, b.ID), http.StatusConflict)
+			return
+		}
+	}
+
+	// Dependencies between the unit and beads staying in the source are
+	// the ones the move turns into cross-project edges. The source's
+	// dependents index finds the beads that wait on the unit.
+	var dependents []model.Bead
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		for _, b := range src.Dependents(id) {
+			if !moved[b.ID] && !seen[b.ID] {
+				seen[b.ID] = true
+				dependents = append(dependents, b)
+			}
+		}
+	}
+	var edges []transferEdge
+	kept := make(map[string]bool) // the unit's blockers that stay in the source
+	for _, b := range unit {
+		for _, blocker := range b.BlockedBy {
+			if moved[blocker] {
+				continue
+			}
+			if _, err := src.Get(blocker); err == nil {
+				kept[blocker] = true
+				edges = append(edges, transferEdge{ID: b.ID, BlockedBy: blocker})
+			}
+		}
+	}
+	for _, b := range dependents {
+		for _, blocker := range b.BlockedBy {
+			if moved[blocker] {
+				edges = append(edges, transferEdge{ID: b.ID, BlockedBy: blocker})
+			}
+		}
+	}
+
+	// Copy each bead with its history, plus an entry for the move.
+	actor := actorFor(r, "")
+	now := time.Now().UTC()
+	before := make(map[string]model.Bead, len(unit))
+	after := make(map[string]model.Bead, len(unit))
+	changes := make(map[string][]model.FieldChange, len(unit))
+	history := make(map[string][]model.HistoryEntry, len(unit))
+	for _, b := range unit {
+		full, err := src.Get(b.ID)
+		if err != nil {
+			jsonError(w, err.Error(), errorCode(err))
+			return
+		}
+		entries, err := src.History(b.ID)
+		if err != nil {
+			jsonError(w, err.Error(), errorCode(err))
+			return
+		}
+
+		next := full
+		if req.Unlink {
+			next.BlockedBy = slices.DeleteFunc(slices.Clone(full.BlockedBy), func(blocker string) bool { return kept[blocker] })
+		}
+		next.Revision++
+		next.UpdatedAt = now
+
+		change := append([]model.FieldChange{{Field: "project", Old: srcProject, New: req.To}}, model.Diff(full, next)...)
+		before[b.ID], after[b.ID], changes[b.ID] = full, next, change
+		history[b.ID] = append(entries, model.HistoryEntry{At: now, Actor: actor, Action: model.ActionTransferred, Changes: change})
+	}
+
+	err = dest.Batch(func(tx store.Backend) error {
+		for _, id := range ids {
+			if err := tx.Restore(after[id], history[id]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		jsonError(w, err.Error(), errorCode(err))
+		return
+	}
+
+	var events []Event
+	err = src.Batch(func(tx store.Backend) error {
+		events = nil
+		for _, id := range ids {
+			b, err := tx.Get(id)
+			if err != nil {
+				return err
+			}
+			if b.Revision != before[id].Revision {
+				return &store.ConflictError{Message: fmt.Sprintf("bead %s changed during the transfer; try again", id)}
+			}
+		}
+		for _, c := range tx.ChildrenOf(existing.ID) {
+			if !moved[c.ID] {
+				return &store.ConflictError{Message: fmt.Sprintf("bead %s changed during the transfer; try again", existing.ID)}
+			}
+		}
+		if req.Unlink {
+			for _, dep := range dependents {
+				b, err := tx.Get(dep.ID)
+				if err != nil {
+					return err
+				}
+				unlinked := b
+				for _, blocker := range b.BlockedBy {
+					if !moved[blocker] {
+						continue
+					}
+					if unlinked, err = tx.Unlink(dep.ID, blocker); err != nil {
+						return err
+					}
+				}
+				ev, err := s.recordChange(tx, srcProject, actor, model.ActionUnlinked, b, unlinked)
+				if err != nil {
+					return err
+				}
+				events = append(events, ev)
+			}
+		}
+		for _, id := range ids {
+			if err := tx.Purge(id); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		rollback := dest.Batch(func(tx store.Backend) error {
+			for _, id := range ids {
+				if err := tx.Purge(id); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if rollback != nil {
+			// The beads are now in both projects. Say so, rather than
+			// report an error that suggests nothing changed.
+			msg := fmt.Sprintf("transfer of %s failed (%v) and could not be undone (%v): beads %s are now in both project %q and project %q; remove them from one of the two",
+				existing.ID, err, rollback, strings.Join(ids, ", "), srcProject, req.To)
+			s.logger.Print(msg)
+			jsonError(w, msg, http.StatusInternalServerError)
+			return
+		}
+		jsonError(w, err.Error(), errorCode(err))
+		return
+	}
+
+	resp := transferResponse{Bead: after[existing.ID], Project: req.To, Moved: ids}
+	if req.Unlink {
+		resp.Unlinked = edges
+	} else {
+		resp.CrossProject = edges
+	}
+
+	// Both projects hear of the move: the source as the beads leaving, the
+	// destination as them arriving.
+	for _, project := range []string{srcProject, req.To} {
+		for _, id := range ids {
+			events = append(events, Event{
+				Type:    EventBeadTransferred,
+				Project: project,
+				BeadID:  id,
+				Actor:   actor,
+				Changes: changes[id],
+				At:      now,
+			})
+		}
+	}
+
+	jsonOK(w, resp)
+	for _, ev := range events {
+		s.publish(ev)
+	}
+}
@@ -0,0 +1,219 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
+)
+
+// createWith creates a bead from body as the given token.
+func createWith(t *testing.T, srv *Server, token string, body map[string]any) model.Bead {
+	t.Helper()
+	w := serveAs(srv, token, http.MethodPost, "/api/v1/beads", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var b model.Bead
+	json.NewDecoder(w.Body).Decode(&b)
+	return b
+}
+
+func TestTransferBead(t *testing.T) {
+	srv := crossProjectServer(t)
+	blocker := createAs(t, srv, "tok-frontend", "Pick a framework")
+	epic := createAs(t, srv, "tok-frontend", "Storage layer")
+	child := createWith(t, srv, "tok-frontend", map[string]any{"title": "Schema", "parent_id": epic.ID, "blocked_by": []string{blocker.ID}})
+	after := createWith(t, srv, "tok-frontend", map[string]any{"title": "Wire it up", "blocked_by": []string{epic.ID}})
+	serveAs(srv, "tok-frontend", http.MethodPost, "/api/v1/beads/"+child.ID+"/comments", map[string]any{"author": "alice", "text": "use sqlite"})
+
+	transfer := func(projectTokens, id string, body map[string]any) (*transferResponse, int) {
+		w := serveWithProjects(srv, "tok-frontend", projectTokens, http.MethodPost, "/api/v1/beads/"+id+"/transfer", body)
+		if w.Code != http.StatusOK {
+			return nil, w.Code
+		}
+		var resp transferResponse
+		json.NewDecoder(w.Body).Decode(&resp)
+		return &resp, w.Code
+	}
+
+	if _, code := transfer("", epic.ID, map[string]any{"to": "backend"}); code != http.StatusForbidden {
+		t.Fatalf("transfer without a backend token: expected 403, got %d", code)
+	}
+	if _, code := transfer("tok-backend", epic.ID, map[string]any{"to": "nowhere"}); code != http.StatusNotFound {
+		t.Fatalf("transfer to an unknown project: expected 404, got %d", code)
+	}
+	if _, code := transfer("tok-backend", child.ID, map[string]any{"to": "backend"}); code != http.StatusConflict {
+		t.Fatalf("transfer of a child: expected 409, got %d", code)
+	}
+
+	resp, code := transfer("tok-backend", epic.ID, map[string]any{"to": "backend"})
+	if code != http.StatusOK {
+		t.Fatalf("transfer: expected 200, got %d", code)
+	}
+	if len(resp.Moved) != 2 || resp.Bead.ID != epic.ID || resp.Project != "backend" {
+		t.Fatalf("transfer = %+v, want the epic and its child moved to backend", resp)
+	}
+	want := map[transferEdge]bool{
+		{ID: child.ID, BlockedBy: blocker.ID}: true,
+		{ID: after.ID, BlockedBy: epic.ID}:    true,
+	}
+	if len(resp.CrossProject) != len(want) || !want[resp.CrossProject[0]] || !want[resp.CrossProject[1]] {
+		t.Errorf("cross_project = %+v, want %v", resp.CrossProject, want)
+	}
+
+	if w := serveAs(srv, "tok-frontend", http.MethodGet, "/api/v1/beads/"+child.ID, nil); w.Code != http.StatusNotFound {
+		t.Errorf("moved bead in the source: expected 404, got %d", w.Code)
+	}
+	w := serveAs(srv, "tok-backend", http.MethodGet, "/api/v1/beads/"+child.ID, nil)
+	var moved model.Bead
+	json.NewDecoder(w.Body).Decode(&moved)
+	if moved.ParentID != epic.ID || len(moved.Comments) != 1 || !moved.CreatedAt.Equal(child.CreatedAt) {
+		t.Errorf("moved child = %+v, want its parent, comment and created_at kept", moved)
+	}
+
+	w = serveAs(srv, "tok-backend", http.MethodGet, "/api/v1/beads/"+child.ID+"/history", nil)
+	var hist historyResponse
+	json.NewDecoder(w.Body).Decode(&hist)
+	if n := len(hist.History); n < 2 || hist.History[n-1].Action != model.ActionTransferred {
+		t.Errorf("history = %+v, want the earlier entries and a transfer", hist.History)
+	}
+
+	// The edges left behind still apply across projects.
+	w = serveAs(srv, "tok-frontend", http.MethodGet, "/api/v1/beads?ready=true", nil)
+	var list struct {
+		Beads []model.Bead `json:"beads"`
+	}
+	json.NewDecoder(w.Body).Decode(&list)
+	for _, b := range list.Beads {
+		if b.ID == after.ID {
+			t.Errorf("%s is ready, but the moved epic still blocks it", after.ID)
+		}
+	}
+}
+
+func TestTransferBead_Unlink(t *testing.T) {
+	srv := crossProjectServer(t)
+	blocker := createAs(t, srv, "tok-frontend", "Pick a framework")
+	b := createWith(t, srv, "tok-frontend", map[string]any{"title": "Schema", "blocked_by": []string{blocker.ID}})
+	dependent := createWith(t, srv, "tok-frontend", map[string]any{"title": "Wire it up", "blocked_by": []string{b.ID}})
+
+	w := serveWithProjects(srv, "tok-frontend", "tok-backend", http.MethodPost, "/api/v1/beads/"+b.ID+"/transfer", map[string]any{"to": "backend", "unlink": true})
+	if w.Code != http.StatusOK {
+		t.Fatalf("transfer: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp transferResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if len(resp.Unlinked) != 2 || len(resp.CrossProject) != 0 {
+		t.Errorf("transfer = %+v, want both edges unlinked", resp)
+	}
+	if len(resp.Bead.BlockedBy) != 0 {
+		t.Errorf("moved bead blocked_by = %v, want none", resp.Bead.BlockedBy)
+	}
+
+	w = serveAs(srv, "tok-frontend", http.MethodGet, "/api/v1/beads/"+dependent.ID, nil)
+	var dep model.Bead
+	json.NewDecoder(w.Body).Decode(&dep)
+	if len(dep.BlockedBy) != 0 {
+		t.Errorf("dependent blocked_by = %v, want none", dep.BlockedBy)
+	}
+}
+
+// TestTransferBead_DependentOfSeveralMovedBeads moves an epic out of the
+// SQLite project while a bead there waits on both the epic and its child.
+func TestTransferBead_DependentOfSeveralMovedBeads(t *testing.T) {
+	srv := crossProjectServer(t)
+	epic := createAs(t, srv, "tok-backend", "Storage layer")
+	child := createWith(t, srv, "tok-backend", map[string]any{"title": "Schema", "parent_id": epic.ID})
+	dependent := createWith(t, srv, "tok-backend", map[string]any{"title": "Wire it up", "blocked_by": []string{epic.ID, child.ID}})
+
+	w := serveWithProjects(srv, "tok-backend", "tok-frontend", http.MethodPost, "/api/v1/beads/"+epic.ID+"/transfer", map[string]any{"to": "frontend", "unlink": true})
+	if w.Code != http.StatusOK {
+		t.Fatalf("transfer: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp transferResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	want := map[transferEdge]bool{
+		{ID: dependent.ID, BlockedBy: epic.ID}:  true,
+		{ID: dependent.ID, BlockedBy: child.ID}: true,
+	}
+	if len(resp.Unlinked) != len(want) || !want[resp.Unlinked[0]] || !want[resp.Unlinked[1]] {
+		t.Errorf("unlinked = %+v, want %v", resp.Unlinked, want)
+	}
+
+	w = serveAs(srv, "tok-backend", http.MethodGet, "/api/v1/beads/"+dependent.ID+"/history", nil)
+	var hist historyResponse
+	json.NewDecoder(w.Body).Decode(&hist)
+	unlinks := 0
+	for _, e := range hist.History {
+		if e.Action == model.ActionUnlinked {
+			unlinks++
+		}
+	}
+	if unlinks != 1 {
+		t.Errorf("dependent has %d unlink entries, want one for the transfer", unlinks)
+	}
+}
+
+// failingBatches is a store whose batches fail once fail is set, after
+// letting through the given number.
+type failingBatches struct {
+	store.Backend
+	fail    bool
+	letPass int
+}
+
+func (f *failingBatches) Batch(fn func(tx store.Backend) error) error {
+	if f.fail {
+		if f.letPass == 0 {
+			return errors.New("disk full")
+		}
+		f.letPass--
+	}
+	return f.Backend.Batch(fn)
+}
+
+func TestTransferBead_FailedUndoNamesBothProjects(t *testing.T) {
+	dir := t.TempDir()
+	var stores []*failingBatches
+	var entries []ProviderEntry
+	for _, name := range []string{"frontend", "backend"} {
+		st, err := store.Open(store.BackendJSON, filepath.Join(dir, name+".json"))
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		f := &failingBatches{Backend: st}
+		stores = append(stores, f)
+		entries = append(entries, ProviderEntry{Name: name, Token: "tok-" + name, Store: f})
+	}
+	srv, err := New(Config{LogOutput: io.Discard}, NewMultiStoreProvider(entries))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	b := createAs(t, srv, "tok-frontend", "Moving")
+
+	// The beads reach the destination, the purge from the source fails,
+	// and so does taking them back out of the destination.
+	stores[0].fail = true
+	stores[1].fail, stores[1].letPass = true, 1
+	w := serveWithProjects(srv, "tok-frontend", "tok-backend", http.MethodPost, "/api/v1/beads/"+b.ID+"/transfer", map[string]any{"to": "backend"})
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("transfer: expected 500, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Error string `json:"error"`
+	}
+	json.NewDecoder(w.Body).Decode(&resp)
+	for _, want := range []string{b.ID, `"frontend"`, `"backend"`} {
+		if !strings.Contains(resp.Error, want) {
+			t.Errorf("error %q does not mention %s", resp.Error, want)
+		}
+	}
+}
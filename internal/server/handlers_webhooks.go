@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// webhookRequest is the JSON body for creating a webhook.
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// handleListWebhooks handles GET /api/v1/webhooks.
+func (s *Server) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	jsonOK(w, s.webhooks.list(s.projectFor(r)))
+}
+
+// handleCreateWebhook handles POST /api/v1/webhooks. The response is the only
+// place the secret is returned.
+func (s *Server) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		jsonError(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	if req.URL == "" {
+		jsonError(w, "url is required", http.StatusBadRequest)
+		return
+	}
+
+	h, err := s.webhooks.create(Webhook{
+		Project: s.projectFor(r),
+		URL:     req.URL,
+		Secret:  req.Secret,
+		Events:  req.Events,
+	})
+	if err != nil {
+		jsonError(w, err.Error(), errorCode(err))
+		return
+	}
+
+	jsonCreated(w, h)
+}
+
+// handleDeleteWebhook handles DELETE /api/v1/webhooks/:id.
+func (s *Server) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	h, err := s.webhooks.remove(s.projectFor(r), chi.URLParam(r, "id"))
+	if err != nil {
+		jsonError(w, err.Error(), errorCode(err))
+		return
+	}
+
+	jsonOK(w, h)
+}
+
+// handleWebhookDeliveries handles GET /api/v1/webhooks/:id/deliveries.
+func (s *Server) handleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := s.webhooks.deliveryLog(s.projectFor(r), chi.URLParam(r, "id"))
+	if err != nil {
+		jsonError(w, err.Error(), errorCode(err))
+		return
+	}
+
+	jsonOK(w, deliveries)
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// idRegistry holds the ID of every bead in every project, so that a new
+// bead's ID can be kept unique across projects without reading each store.
+// Stores claim IDs through it as they create beads (see
+// store.ReserveFunc); handlers add imported IDs and give back the IDs of
+// beads that are cleaned out or removed by an import. A transfer keeps its
+// beads' IDs, so it leaves the registry as it is.
+//
+// Stores call reserve while holding their own lock, so the registry never
+// calls into a store while holding its lock.
+type idRegistry struct {
+	mu     sync.Mutex
+	ids    map[string]struct{}
+	recent map[string]struct{} // IDs reserved during a rebuild; nil otherwise
+
+	rebuilding sync.Mutex // one rebuild at a time
+}
+
+func newIDRegistry() *idRegistry {
+	return &idRegistry{ids: make(map[string]struct{})}
+}
+
+// rebuild replaces the registry with the IDs of projects' beads. IDs
+// reserved while the stores are being read are kept, since the read may
+// have missed them. It returns the IDs held by more than one project, with
+// the names of those projects; a transfer that crashed part way leaves its
+// beads in both.
+func (x *idRegistry) rebuild(projects []ProjectInfo) map[string][]string {
+	x.rebuilding.Lock()
+	defer x.rebuilding.Unlock()
+
+	x.mu.Lock()
+	x.recent = make(map[string]struct{})
+	x.mu.Unlock()
+
+	ids := make(map[string]struct{})
+	owners := make(map[string]string)
+	dups := make(map[string][]string)
+	for _, p := range projects {
+		for _, b := range p.Store.All() {
+			ids[b.ID] = struct{}{}
+			first, ok := owners[b.ID]
+			if !ok {
+				owners[b.ID] = p.Name
+				continue
+			}
+			if dups[b.ID] == nil {
+				dups[b.ID] = []string{first}
+			}
+			dups[b.ID] = append(dups[b.ID], p.Name)
+		}
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for id := range x.recent {
+		ids[id] = struct{}{}
+	}
+	x.ids, x.recent = ids, nil
+	return dups
+}
+
+// rebuildIDs rebuilds s.ids from projects and logs every ID found in more
+// than one project, since requests for it reach only one of the copies.
+func (s *Server) rebuildIDs(projects []ProjectInfo) {
+	dups := s.ids.rebuild(projects)
+	for _, id := range slices.Sorted(maps.Keys(dups)) {
+		s.logger.Printf("bead %s is in more than one project (%s); remove it from all but one", id, strings.Join(dups[id], ", "))
+	}
+}
+
+// reserve marks id as taken, reporting false if it already was.
+func (x *idRegistry) reserve(id string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if _, ok := x.ids[id]; ok {
+		return false
+	}
+	x.ids[id] = struct{}{}
+	if x.recent != nil {
+		x.recent[id] = struct{}{}
+	}
+	return true
+}
+
+// release frees ids for reuse.
+func (x *idRegistry) release(ids ...string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, id := range ids {
+		delete(x.ids, id)
+		delete(x.recent, id)
+	}
+}
+
+// has reports whether id is taken.
+func (x *idRegistry) has(id string) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	_, ok := x.ids[id]
+	return ok
+}
+
+// idClaim is the IDs one request has reserved, so that they can be given
+// back if the request fails.
+type idClaim struct {
+	registry *idRegistry
+	ids      []string
+}
+
+// claim starts a claim on x.
+func (x *idRegistry) claim() *idClaim {
+	return &idClaim{registry: x}
+}
+
+// reserve is a store.ReserveFunc that records the IDs it reserves.
+func (c *idClaim) reserve(id string) bool {
+	if !c.registry.reserve(id) {
+		return false
+	}
+	c.ids = append(c.ids, id)
+	return true
+}
+
+// release gives back every ID reserved through c.
+func (c *idClaim) release() {
+	c.registry.release(c.ids...)
+	c.ids = nil
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
+)
+
+// checkIDs fails the test unless srv's ID registry holds exactly the IDs of
+// every project's beads.
+func checkIDs(t *testing.T, srv *Server) {
+	t.Helper()
+	want := map[string]struct{}{}
+	for _, p := range srv.provider.Projects() {
+		for _, b := range p.Store.All() {
+			want[b.ID] = struct{}{}
+		}
+	}
+	srv.ids.mu.Lock()
+	defer srv.ids.mu.Unlock()
+	if !reflect.DeepEqual(srv.ids.ids, want) {
+		t.Fatalf("ID registry out of step with the stores:\n got %v\nwant %v", srv.ids.ids, want)
+	}
+}
+
+func TestIDRegistry_KeptInStep(t *testing.T) {
+	srv := crossProjectServer(t)
+	checkIDs(t, srv)
+
+	epic := createAs(t, srv, "tok-frontend", "Storage layer")
+	createWith(t, srv, "tok-frontend", map[string]any{"title": "Schema", "parent_id": epic.ID})
+	done := createAs(t, srv, "tok-backend", "Pick a framework")
+	checkIDs(t, srv)
+
+	// A batch that fails gives back the IDs its creates reserved.
+	w := serveAs(srv, "tok-frontend", http.MethodPost, "/api/v1/batch", map[string]any{"ops": []map[string]any{
+		{"op": "create", "bead": map[string]any{"title": "Kept?"}},
+		{"op": "create", "bead": map[string]any{"title": "Child", "parent_id": epic.ID}},
+		{"op": "link", "id": "bd-none", "blocked_by": epic.ID},
+	}})
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("batch: expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+	checkIDs(t, srv)
+
+	// So does a dry-run plan import.
+	w = serveAs(srv, "tok-backend", http.MethodPost, "/api/v1/import-plan", map[string]any{"plan": testPlan(), "dry_run": true})
+	if w.Code != http.StatusOK {
+		t.Fatalf("import-plan: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	checkIDs(t, srv)
+
+	// Cleaning a bead out frees its ID.
+	serveAs(srv, "tok-backend", http.MethodPatch, "/api/v1/beads/"+done.ID, map[string]any{"status": "closed"})
+	w = serveAs(srv, "tok-backend", http.MethodPost, "/api/v1/clean", map[string]any{"days": 0})
+	if w.Code != http.StatusOK {
+		t.Fatalf("clean: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if srv.ids.has(done.ID) {
+		t.Errorf("%s is still reserved after being cleaned out", done.ID)
+	}
+	checkIDs(t, srv)
+
+	// A replacing import frees the IDs it removes and takes the ones it
+	// brings in, remapping those another project has.
+	createAs(t, srv, "tok-backend", "Replaced")
+	export := serveAs(srv, "tok-frontend", http.MethodGet, "/api/v1/export", nil)
+	w = serveAs(srv, "tok-backend", http.MethodPost, "/api/v1/import?mode=replace&on_conflict=remap", json.RawMessage(export.Body.Bytes()))
+	if w.Code != http.StatusOK {
+		t.Fatalf("import: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp importResponse
+	json.NewDecoder(w.Body).Decode(&resp)
+	if resp.Removed != 1 || len(resp.Remapped) != 2 {
+		t.Errorf("import = %+v, want one bead removed and both imported ones remapped", resp)
+	}
+	checkIDs(t, srv)
+
+	// A transfer keeps IDs.
+	w = serveWithProjects(srv, "tok-frontend", "tok-backend", http.MethodPost, "/api/v1/beads/"+epic.ID+"/transfer", map[string]any{"to": "backend"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("transfer: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	checkIDs(t, srv)
+}
+
+func TestIDRegistry_ReportsDuplicates(t *testing.T) {
+	dir := t.TempDir()
+	dup := model.NewBead("In both")
+	dup.ID, dup.Revision = "bd-dup1", 1
+	var entries []ProviderEntry
+	for _, name := range []string{"frontend", "backend", "ops"} {
+		st, err := store.Open(store.BackendJSON, filepath.Join(dir, name+".json"))
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		if name != "ops" {
+			if err := st.Restore(dup, nil); err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+		}
+		entries = append(entries, ProviderEntry{Name: name, Token: "tok-" + name, Store: st})
+	}
+
+	var logs bytes.Buffer
+	srv, err := New(Config{LogOutput: &logs}, NewMultiStoreProvider(entries))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	if !strings.Contains(logs.String(), "bead bd-dup1 is in more than one project (frontend, backend)") {
+		t.Errorf("log = %q, want the duplicate reported with both projects", logs.String())
+	}
+	if dups := srv.ids.rebuild(srv.provider.Projects()); !reflect.DeepEqual(dups, map[string][]string{"bd-dup1": {"frontend", "backend"}}) {
+		t.Errorf("rebuild = %v, want bd-dup1 in frontend and backend", dups)
+	}
+}
+
+// BenchmarkCreate_OtherProjectLarge creates beads next to a project of 50k
+// beads, which new IDs must not collide with.
+func BenchmarkCreate_OtherProjectLarge(b *testing.B) {
+	srv := crossProjectServer(b)
+	var back store.Backend
+	for _, p := range srv.provider.Projects() {
+		if p.Name == "backend" {
+			back = p.Store
+		}
+	}
+	err := back.Batch(func(tx store.Backend) error {
+		for i := 0; i < 50_000; i++ {
+			bead := model.NewBead(fmt.Sprintf("Bead %d", i))
+			bead.ID, bead.Revision = fmt.Sprintf("bd-%06d", i), 1
+			if err := tx.Restore(bead, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatalf("seeding: %v", err)
+	}
+	srv.ids.rebuild(srv.provider.Projects())
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if w := serveAs(srv, "tok-frontend", http.MethodPost, "/api/v1/beads", map[string]any{"title": "New"}); w.Code != http.StatusCreated {
+			b.Fatalf("create: expected 201, got %d", w.Code)
+		}
+	}
+}
@@ -1,72 +1,141 @@
 package server
 
-import "github.com/vector76/beads_server/internal/store"
+import (
+	"sync"
+
+	"github.com/vector76/beads_server/internal/project"
+	"github.com/vector76/beads_server/internal/store"
+)
 
 // ProjectInfo exposes a project's name and store without auth details.
 type ProjectInfo struct {
 	Name  string
-	Store *store.Store
+	Store store.Backend
 }
 
 // ProviderEntry is the input to NewMultiStoreProvider: a named project with
-// its auth token and backing store.
+// its auth token and backing store. The project token may be given in the
+// clear (Token) or hashed (TokenHash); Users add named principals.
 type ProviderEntry struct {
-	Name  string
-	Token string
-	Store *store.Store
+	Name      string
+	Token     string
+	TokenHash string
+	Users     []project.UserEntry
+	Store     store.Backend
+}
+
+// Principal is who a request authenticated as. A project token has no Name
+// and the admin role; the caller's identity is then taken from the request,
+// as the ActorHeader or a claim's user. A user token carries its own name,
+// which overrides anything the request says.
+type Principal struct {
+	Name string
+	Role string
 }
 
-// StoreProvider resolves a bearer token to a Store.
-// Returns nil if the token is not recognized.
+// StoreProvider resolves a bearer token to a storage backend, and
+// Authenticate also to the principal the token stands for. Both return a nil
+// backend if the token is not recognized.
 type StoreProvider interface {
-	Resolve(token string) *store.Store
+	Resolve(token string) store.Backend
+	Authenticate(token string) (store.Backend, Principal)
 	Projects() []ProjectInfo
 }
 
 // singleStoreProvider maps exactly one token to one store.
 type singleStoreProvider struct {
 	token string
-	store *store.Store
+	store store.Backend
 }
 
 // NewSingleStoreProvider returns a StoreProvider that accepts a single token.
-func NewSingleStoreProvider(token string, s *store.Store) StoreProvider {
+func NewSingleStoreProvider(token string, s store.Backend) StoreProvider {
 	return &singleStoreProvider{token: token, store: s}
 }
 
-func (p *singleStoreProvider) Resolve(token string) *store.Store {
+func (p *singleStoreProvider) Resolve(token string) store.Backend {
+	st, _ := p.Authenticate(token)
+	return st
+}
+
+func (p *singleStoreProvider) Authenticate(token string) (store.Backend, Principal) {
 	if token == p.token {
-		return p.store
+		return p.store, Principal{Role: project.RoleAdmin}
 	}
-	return nil
+	return nil, Principal{}
 }
 
 func (p *singleStoreProvider) Projects() []ProjectInfo {
 	return []ProjectInfo{{Name: "default", Store: p.store}}
 }
 
-// multiStoreProvider maps multiple tokens to their respective stores.
+// credential is what a token hash unlocks.
+type credential struct {
+	store     store.Backend
+	principal Principal
+}
+
+// multiStoreProvider maps multiple tokens, by hash, to their respective
+// stores and principals. Its projects can be replaced while it is in use.
 type multiStoreProvider struct {
-	stores   map[string]*store.Store
+	mu       sync.RWMutex
+	creds    map[string]credential
 	projects []ProjectInfo
 }
 
 // NewMultiStoreProvider returns a StoreProvider backed by a slice of ProviderEntry values.
 func NewMultiStoreProvider(entries []ProviderEntry) StoreProvider {
-	m := make(map[string]*store.Store, len(entries))
+	p := &multiStoreProvider{}
+	p.replace(entries)
+	return p
+}
+
+// replace swaps the provider's projects for entries in one step, so a
+// request sees either the old set or the new one.
+func (p *multiStoreProvider) replace(entries []ProviderEntry) {
+	creds := make(map[string]credential)
 	projects := make([]ProjectInfo, len(entries))
 	for i, e := range entries {
-		m[e.Token] = e.Store
+		admin := credential{store: e.Store, principal: Principal{Role: project.RoleAdmin}}
+		if e.Token != "" {
+			creds[project.HashToken(e.Token)] = admin
+		}
+		if e.TokenHash != "" {
+			creds[e.TokenHash] = admin
+		}
+		for _, u := range e.Users {
+			creds[u.TokenHash] = credential{store: e.Store, principal: Principal{Name: u.Name, Role: u.Role}}
+		}
 		projects[i] = ProjectInfo{Name: e.Name, Store: e.Store}
 	}
-	return &multiStoreProvider{stores: m, projects: projects}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.creds = creds
+	p.projects = projects
 }
 
-func (p *multiStoreProvider) Resolve(token string) *store.Store {
-	return p.stores[token]
+func (p *multiStoreProvider) Resolve(token string) store.Backend {
+	st, _ := p.Authenticate(token)
+	return st
+}
+
+func (p *multiStoreProvider) Authenticate(token string) (store.Backend, Principal) {
+	if token == "" {
+		return nil, Principal{}
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	c, ok := p.creds[project.HashToken(token)]
+	if !ok {
+		return nil, Principal{}
+	}
+	return c.store, c.principal
 }
 
 func (p *multiStoreProvider) Projects() []ProjectInfo {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
 	result := make([]ProjectInfo, len(p.projects))
 	copy(result, p.projects)
 	return result
@@ -4,6 +4,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/vector76/beads_server/internal/project"
 	"github.com/vector76/beads_server/internal/store"
 )
 
@@ -167,3 +168,30 @@ func TestMultiProvider_IsolatedFromExternalMutation(t *testing.T) {
 		t.Fatal("expected s1 for tok-one after external mutation")
 	}
 }
+
+func TestMultiProvider_AuthenticateUsers(t *testing.T) {
+	s1 := loadTestStore(t)
+	s2 := loadTestStore(t)
+
+	p := NewMultiStoreProvider([]ProviderEntry{
+		{Name: "proj-one", Token: "tok-one", Store: s1, Users: []project.UserEntry{
+			{Name: "alice", Role: project.RoleAgent, TokenHash: project.HashToken("tok-alice")},
+		}},
+		{Name: "proj-two", TokenHash: project.HashToken("tok-two"), Store: s2},
+	})
+
+	st, who := p.Authenticate("tok-alice")
+	if st != s1 || who != (Principal{Name: "alice", Role: project.RoleAgent}) {
+		t.Errorf("tok-alice = %v, %+v; want s1 as alice the agent", st, who)
+	}
+	st, who = p.Authenticate("tok-one")
+	if st != s1 || who != (Principal{Role: project.RoleAdmin}) {
+		t.Errorf("tok-one = %v, %+v; want s1 as an anonymous admin", st, who)
+	}
+	if st, _ := p.Authenticate("tok-two"); st != s2 {
+		t.Error("expected s2 for tok-two, configured by hash")
+	}
+	if st, _ := p.Authenticate(project.HashToken("tok-alice")); st != nil {
+		t.Error("a token hash must not work as a token")
+	}
+}
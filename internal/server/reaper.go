@@ -0,0 +1,97 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/vector76/beads_server/internal/store"
+	"github.com/vector76/beads_server/model"
+)
+
+// maxReapInterval bounds how long an expired claim can go unnoticed.
+const maxReapInterval = 30 * time.Second
+
+// reapInterval returns how often to check for expired claims: a quarter of
+// the lease, capped at maxReapInterval.
+func reapInterval(lease time.Duration) time.Duration {
+	interval := lease / 4
+	if interval > maxReapInterval || interval <= 0 {
+		interval = maxReapInterval
+	}
+	return interval
+}
+
+// reaper periodically returns expired claims to open across all projects.
+type reaper struct {
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// startReaper starts a reaper that runs every interval.
+func (s *Server) startReaper(interval time.Duration) *reaper {
+	r := &reaper{done: make(chan struct{})}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.done:
+				return
+			case now := <-ticker.C:
+				s.reapExpiredClaims(now.UTC())
+			}
+		}
+	}()
+	return r
+}
+
+// stop terminates the reaper goroutine and waits for it to exit.
+func (r *reaper) stop() {
+	close(r.done)
+	r.wg.Wait()
+}
+
+// reapExpiredClaims releases every claim whose lease ended before now,
+// records the release in each bead's history, and publishes events. The
+// store updates parent epics along with the release, and the history of
+// both is written in the same batch.
+// Returns the number of claims released.
+func (s *Server) reapExpiredClaims(now time.Time) int {
+	// Count as a request, so no store is closed under the sweep.
+	defer s.requests.begin()()
+
+	total := 0
+	for _, p := range s.provider.Projects() {
+		var released []store.Release
+		var events []Event
+		err := p.Store.Batch(func(tx store.Backend) error {
+			var err error
+			if released, err = tx.ReleaseExpired(now); err != nil {
+				return err
+			}
+			events = nil
+			var epics []string
+			for _, rel := range released {
+				ev, err := s.recordChange(tx, p.Name, store.SystemAuthor, model.ActionReleased, rel.Before, rel.After)
+				if err != nil {
+					return err
+				}
+				events = append(events, ev)
+				epics = append(epics, rel.After.ParentID)
+			}
+			return recordEpics(tx, epics...)
+		})
+		if err != nil {
+			s.logger.Printf("releasing expired claims in project %s: %v", p.Name, err)
+			continue
+		}
+		for i, rel := range released {
+			s.logger.Printf("released expired claim on %s held by %s", rel.After.ID, rel.Before.Assignee)
+			s.publish(events[i])
+		}
+		total += len(released)
+	}
+	return total
+}
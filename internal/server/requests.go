@@ -0,0 +1,104 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// requestTracker counts the requests in flight, so that work which must
+// not overlap them, such as closing the stores a reload retired, can wait
+// for the ones already running to finish. Requests are grouped in
+// generations: each call to after starts a new one, and its function runs
+// once the generations before it have no requests left.
+type requestTracker struct {
+	mu      sync.Mutex
+	current *generation
+	waiting []*generation // ended by after but not yet drained, oldest first
+}
+
+// generation is a group of requests begun between two calls to after.
+type generation struct {
+	active int
+	then   []func() // run once this and every older generation is drained
+}
+
+func newRequestTracker() *requestTracker {
+	return &requestTracker{current: &generation{}}
+}
+
+// begin counts a request in and returns the function that counts it out.
+// That function may be called more than once.
+func (t *requestTracker) begin() func() {
+	t.mu.Lock()
+	g := t.current
+	g.active++
+	t.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.mu.Lock()
+			g.active--
+			ready := t.drained()
+			t.mu.Unlock()
+			runAll(ready)
+		})
+	}
+}
+
+// after runs fn once every request begun before the call has ended: at
+// once if there are none, else on the goroutine of the last to end.
+func (t *requestTracker) after(fn func()) {
+	t.mu.Lock()
+	t.current.then = append(t.current.then, fn)
+	t.waiting = append(t.waiting, t.current)
+	t.current = &generation{}
+	ready := t.drained()
+	t.mu.Unlock()
+	runAll(ready)
+}
+
+// drained pops the leading generations with no requests left and returns
+// their functions. The caller holds t.mu.
+func (t *requestTracker) drained() []func() {
+	var ready []func()
+	for len(t.waiting) > 0 && t.waiting[0].active == 0 {
+		ready = append(ready, t.waiting[0].then...)
+		t.waiting = t.waiting[1:]
+	}
+	return ready
+}
+
+func runAll(fns []func()) {
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+// trackRequests counts each request in the server's requestTracker while
+// it runs.
+func (s *Server) trackRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		done := s.requests.begin()
+		defer done()
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestDoneContextKey, done)))
+	})
+}
+
+// untrackRequest counts r out of the tracker before it ends, for requests
+// such as event streams that stay open long after they stop using a store.
+func untrackRequest(r *http.Request) {
+	if done, ok := r.Context().Value(requestDoneContextKey).(func()); ok {
+		done()
+	}
+}
+
+// AfterRequests runs fn once every request the server has begun so far has
+// finished, so that stores retired by ReloadProjects can be closed without
+// pulling them from under a request still using them. It does not wait:
+// fn runs at once if nothing is in flight, else when the last such request
+// ends. Event streams count as finished once they are set up.
+func (s *Server) AfterRequests(fn func()) {
+	s.requests.after(fn)
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,17 +10,31 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/vector76/beads_server/internal/project"
 	"github.com/vector76/beads_server/internal/store"
 )
 
 // contextKey is an unexported type for context keys in this package.
 type contextKey int
 
-const storeContextKey contextKey = iota
+const (
+	storeContextKey contextKey = iota
+	projectContextKey
+	principalContextKey
+	grantsContextKey
+	readAllContextKey
+	requestDoneContextKey
+)
+
+// ProjectTokensHeader carries tokens for other projects on the same server,
+// comma-separated, alongside the request's own token. They let the request
+// depend on, and see dependencies in, those projects' beads.
+const ProjectTokensHeader = "X-BS-Project-Tokens"
 
 // Config holds the server configuration.
 type Config struct {
@@ -27,16 +42,44 @@ type Config struct {
 	DataFile  string
 	LogOutput io.Writer // destination for request logs; nil defaults to os.Stdout
 	Version   string    // reported by GET /api/v1/version
+
+	// LeaseTTL is how long a claim lasts without a heartbeat. Zero disables
+	// leases: claims never expire and the reaper does not run.
+	LeaseTTL time.Duration
+
+	// WebhookFile is where webhooks created through the API, the delivery
+	// queue and the delivery log are saved. Empty keeps them in memory only.
+	WebhookFile string
+
+	// Webhooks are the subscriptions defined in the projects file. Each
+	// names its project; IDs and sources are assigned by New.
+	Webhooks []Webhook
+
+	// AdminToken enables the admin API under /api/v1/admin, which edits
+	// ProjectsFile and then calls ReloadProjectsFile to apply the change.
+	// All three must be set for the API to be served.
+	AdminToken         string
+	ProjectsFile       string
+	ReloadProjectsFile func() error
 }
 
 // Server is the HTTP server for the beads API.
 type Server struct {
 	Router      *chi.Mux
-	Store       *store.Store // exported for direct store access in tests
 	provider    StoreProvider
 	config      Config
 	logger      *log.Logger
 	broadcaster *broadcaster
+	reaper      *reaper
+	webhooks    *webhookManager
+	admin       *projectAdmin // nil unless the admin API is enabled
+	ids         *idRegistry   // every project's bead IDs
+	requests    *requestTracker
+
+	// links serializes the changes that add dependencies. Each store checks
+	// for cycles under its own lock only, so without it two links made at
+	// once in different projects could close a cycle through both.
+	links sync.Mutex
 }
 
 // New creates a new Server with the given config and provider.
@@ -50,16 +93,35 @@ func New(cfg Config, p StoreProvider) (*Server, error) {
 		logOut = os.Stdout
 	}
 
+	logger := log.New(logOut, "", log.LstdFlags)
+	webhooks, err := newWebhookManager(cfg.WebhookFile, cfg.Webhooks, logger)
+	if err != nil {
+		return nil, err
+	}
+
 	srv := &Server{
 		Router:      chi.NewRouter(),
 		provider:    p,
 		config:      cfg,
-		logger:      log.New(logOut, "", log.LstdFlags),
+		logger:      logger,
 		broadcaster: newBroadcaster(),
+		webhooks:    webhooks,
+		ids:         newIDRegistry(),
+		requests:    newRequestTracker(),
+	}
+	srv.rebuildIDs(p.Projects())
+
+	if _, ok := p.(*multiStoreProvider); ok {
+		linkProjects(p.Projects())
+	}
+
+	if cfg.LeaseTTL > 0 {
+		srv.reaper = srv.startReaper(reapInterval(cfg.LeaseTTL))
 	}
 
 	srv.Router.Use(middleware.Recoverer)
 	srv.Router.Use(srv.requestLogger)
+	srv.Router.Use(srv.trackRequests)
 
 	// Unauthenticated endpoints
 	srv.Router.Get("/", srv.handleDashboard)
@@ -69,38 +131,150 @@ func New(cfg Config, p StoreProvider) (*Server, error) {
 	srv.Router.Get("/api/v1/beads/status", srv.handleBeadsStatus)
 	srv.Router.Get("/events", srv.handleSSE)
 
-	// All other API routes require auth
+	// Listing across projects takes the admin token, or a project token
+	// with tokens for other projects in ProjectTokensHeader.
+	srv.Router.With(srv.allProjectsAuth).Get("/api/v1/all/beads", srv.handleListAllBeads)
+
+	// All other API routes require auth. Reading needs any role; changes
+	// need at least the role of the group they are in.
 	srv.Router.Group(func(r chi.Router) {
 		r.Use(srv.authMiddleware)
+		r.Get("/api/v1/events", srv.handleEvents)
 		r.Get("/api/v1/beads", srv.handleListBeads)
-		r.Post("/api/v1/beads", srv.handleCreateBead)
 		r.Get("/api/v1/beads/{id}", srv.handleGetBead)
-		r.Patch("/api/v1/beads/{id}", srv.handleUpdateBead)
-		r.Delete("/api/v1/beads/{id}", srv.handleDeleteBead)
-		r.Post("/api/v1/beads/{id}/claim", srv.handleClaimBead)
-		r.Post("/api/v1/beads/{id}/comments", srv.handleAddComment)
-		r.Post("/api/v1/beads/{id}/link", srv.handleLinkBead)
-		r.Delete("/api/v1/beads/{id}/link/{other_id}", srv.handleUnlinkBead)
 		r.Get("/api/v1/beads/{id}/deps", srv.handleGetDeps)
+		r.Get("/api/v1/beads/{id}/history", srv.handleGetHistory)
 		r.Get("/api/v1/search", srv.handleSearch)
-		r.Post("/api/v1/clean", srv.handleClean)
+		r.Get("/api/v1/export", srv.handleExport)
+
+		r.Group(func(r chi.Router) {
+			r.Use(requireRole(project.RoleAgent))
+			r.Post("/api/v1/beads", srv.handleCreateBead)
+			r.Patch("/api/v1/beads/{id}", srv.handleUpdateBead)
+			r.Post("/api/v1/beads/{id}/claim", srv.handleClaimBead)
+			r.Post("/api/v1/beads/{id}/heartbeat", srv.handleHeartbeat)
+			r.Post("/api/v1/claim-next", srv.handleClaimNext)
+			r.Post("/api/v1/beads/{id}/comments", srv.handleAddComment)
+			r.Post("/api/v1/beads/{id}/link", srv.handleLinkBead)
+			r.Delete("/api/v1/beads/{id}/link/{other_id}", srv.handleUnlinkBead)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(requireRole(project.RoleWriter))
+			r.Delete("/api/v1/beads/{id}", srv.handleDeleteBead)
+			r.Post("/api/v1/beads/{id}/transfer", srv.handleTransferBead)
+			r.Post("/api/v1/batch", srv.handleBatch)
+			r.Post("/api/v1/import-plan", srv.handleImportPlan)
+		})
+
+		r.Group(func(r chi.Router) {
+			r.Use(requireRole(project.RoleAdmin))
+			r.Post("/api/v1/clean", srv.handleClean)
+			r.Post("/api/v1/import", srv.handleImport)
+			r.Get("/api/v1/webhooks", srv.handleListWebhooks)
+			r.Post("/api/v1/webhooks", srv.handleCreateWebhook)
+			r.Delete("/api/v1/webhooks/{id}", srv.handleDeleteWebhook)
+			r.Get("/api/v1/webhooks/{id}/deliveries", srv.handleWebhookDeliveries)
+		})
 	})
 
+	if cfg.AdminToken != "" && cfg.ProjectsFile != "" && cfg.ReloadProjectsFile != nil {
+		srv.admin = &projectAdmin{path: cfg.ProjectsFile, reload: cfg.ReloadProjectsFile, logger: logger}
+		srv.Router.Group(func(r chi.Router) {
+			r.Use(srv.adminAuth)
+			r.Get("/api/v1/admin/projects", srv.handleListProjects)
+			r.Post("/api/v1/admin/projects", srv.handleCreateProject)
+			r.Post("/api/v1/admin/projects/{name}/rotate-token", srv.handleRotateProjectToken)
+			r.Post("/api/v1/admin/projects/{name}/archive", srv.handleArchiveProject)
+			r.Delete("/api/v1/admin/projects/{name}", srv.handleDeleteProject)
+		})
+	}
+
 	return srv, nil
 }
 
+// Close stops the server's background goroutines. It does not close the
+// stores.
+func (s *Server) Close() {
+	if s.reaper != nil {
+		s.reaper.stop()
+	}
+	s.webhooks.stop()
+	s.broadcaster.stop()
+}
+
+// ReloadProjects replaces the projects of a multi-project server with
+// entries, and the webhooks from the projects file with hooks. Requests
+// already running finish against the project they started with, so stores
+// entries no longer list should be closed through AfterRequests. Nothing
+// changes if a webhook is invalid or the server has a single project.
+func (s *Server) ReloadProjects(entries []ProviderEntry, hooks []Webhook) error {
+	p, ok := s.provider.(*multiStoreProvider)
+	if !ok {
+		return fmt.Errorf("only a multi-project server can reload its projects")
+	}
+	configured, err := configuredHooks(hooks)
+	if err != nil {
+		return err
+	}
+	p.replace(entries)
+	linkProjects(p.Projects())
+	s.rebuildIDs(p.Projects())
+	s.webhooks.replaceConfigured(configured)
+	return nil
+}
+
 // ListenAddr returns the address the server should listen on.
 func (s *Server) ListenAddr() string {
 	return fmt.Sprintf(":%d", s.config.Port)
 }
 
 // storeFor retrieves the store from the request context (set by authMiddleware).
-func (s *Server) storeFor(r *http.Request) *store.Store {
-	return r.Context().Value(storeContextKey).(*store.Store)
+func (s *Server) storeFor(r *http.Request) store.Backend {
+	return r.Context().Value(storeContextKey).(store.Backend)
+}
+
+// projectFor returns the name of the project the request was authenticated
+// for (set by authMiddleware).
+func (s *Server) projectFor(r *http.Request) string {
+	name, _ := r.Context().Value(projectContextKey).(string)
+	return name
+}
+
+// projectName returns the name under which the provider lists st.
+func (s *Server) projectName(st store.Backend) string {
+	for _, p := range s.provider.Projects() {
+		if p.Store == st {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+// principalFor returns who the request authenticated as (set by
+// authMiddleware).
+func principalFor(r *http.Request) Principal {
+	p, _ := r.Context().Value(principalContextKey).(Principal)
+	return p
+}
+
+// grantsFor returns the principal the request holds in each project it has
+// a token for: its own, and those in ProjectTokensHeader (set by
+// authMiddleware).
+func grantsFor(r *http.Request) map[string]Principal {
+	g, _ := r.Context().Value(grantsContextKey).(map[string]Principal)
+	return g
+}
+
+// granted reports whether the request holds a token for the named project.
+func granted(r *http.Request, project string) bool {
+	_, ok := grantsFor(r)[project]
+	return ok
 }
 
 // authMiddleware authenticates via the StoreProvider and stores the resolved
-// store in the request context.
+// store, its project name and the principal in the request context, along
+// with the projects opened by ProjectTokensHeader.
 func (s *Server) authMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		auth := r.Header.Get("Authorization")
@@ -115,17 +289,82 @@ func (s *Server) authMiddleware(next http.Handler) http.Handler {
 		}
 
 		token := strings.TrimPrefix(auth, "Bearer ")
-		st := s.provider.Resolve(token)
+		st, principal := s.provider.Authenticate(token)
 		if st == nil {
 			http.Error(w, `{"error":"invalid token"}`, http.StatusUnauthorized)
 			return
 		}
 
+		name := s.projectName(st)
+		grants := map[string]Principal{name: principal}
+		if extra := r.Header.Get(ProjectTokensHeader); extra != "" {
+			for _, t := range strings.Split(extra, ",") {
+				other, p := s.provider.Authenticate(strings.TrimSpace(t))
+				if other == nil {
+					http.Error(w, `{"error":"invalid token in `+ProjectTokensHeader+`"}`, http.StatusUnauthorized)
+					return
+				}
+				grants[s.projectName(other)] = p
+			}
+		}
+
 		ctx := context.WithValue(r.Context(), storeContextKey, st)
+		ctx = context.WithValue(ctx, projectContextKey, name)
+		ctx = context.WithValue(ctx, principalContextKey, principal)
+		ctx = context.WithValue(ctx, grantsContextKey, grants)
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
 
+// allProjectsAuth accepts the admin token, which may read every project,
+// and otherwise authenticates like authMiddleware.
+func (s *Server) allProjectsAuth(next http.Handler) http.Handler {
+	project := s.authMiddleware(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if ok && s.config.AdminToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) == 1 {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), readAllContextKey, true)))
+			return
+		}
+		project.ServeHTTP(w, r)
+	})
+}
+
+// mayReadAll reports whether the request was made with the admin token
+// (set by allProjectsAuth).
+func mayReadAll(r *http.Request) bool {
+	all, _ := r.Context().Value(readAllContextKey).(bool)
+	return all
+}
+
+// requireRole rejects requests whose principal's role is below min with 403.
+func requireRole(min string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := principalFor(r)
+			if !project.RoleAtLeast(p.Role, min) {
+				jsonError(w, fmt.Sprintf("role %s may not do this; it needs %s", p.Role, min), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// mayAssign reports whether r may change a bead's assignee from current to
+// assignee. A user token below writer may only assign itself or release a
+// bead it holds; setting someone else needs writer.
+func mayAssign(r *http.Request, current, assignee string) bool {
+	p := principalFor(r)
+	if p.Name == "" || project.RoleAtLeast(p.Role, project.RoleWriter) {
+		return true
+	}
+	if assignee == current || assignee == p.Name {
+		return true
+	}
+	return assignee == "" && current == p.Name
+}
+
 // handleHealth returns a simple health check response.
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
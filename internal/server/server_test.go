@@ -28,7 +28,6 @@ func testServer(t *testing.T) *Server {
 	if err != nil {
 		t.Fatalf("New: %v", err)
 	}
-	srv.Store = s
 
 	// Add a test route behind the auth middleware to verify auth works
 	srv.Router.Group(func(r chi.Router) {
@@ -276,3 +275,67 @@ func TestMultiProjectAuth(t *testing.T) {
 		t.Fatalf("unknown token: expected 401, got %d", w.Code)
 	}
 }
+
+func TestReloadProjects(t *testing.T) {
+	dir := t.TempDir()
+	s1, _ := store.Load(filepath.Join(dir, "project1.json"))
+	s2, _ := store.Load(filepath.Join(dir, "project2.json"))
+
+	p := NewMultiStoreProvider([]ProviderEntry{{Name: "project1", Token: "tok-old", Store: s1}})
+	srv, err := New(Config{LogOutput: io.Discard, Webhooks: []Webhook{{Project: "project1", URL: "http://old.example.com"}}}, p)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	status := func(token string) int {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/beads", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		srv.Router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	err = srv.ReloadProjects([]ProviderEntry{
+		{Name: "project1", Token: "tok-new", Store: s1},
+		{Name: "project2", Token: "tok-two", Store: s2},
+	}, []Webhook{{Project: "project2", URL: "http://new.example.com"}})
+	if err != nil {
+		t.Fatalf("ReloadProjects: %v", err)
+	}
+
+	if got := status("tok-old"); got != http.StatusUnauthorized {
+		t.Errorf("rotated-out token: got %d, want 401", got)
+	}
+	if got := status("tok-new"); got != http.StatusOK {
+		t.Errorf("rotated-in token: got %d, want 200", got)
+	}
+	if got := status("tok-two"); got != http.StatusOK {
+		t.Errorf("added project: got %d, want 200", got)
+	}
+	if got := len(srv.provider.Projects()); got != 2 {
+		t.Errorf("projects = %d, want 2", got)
+	}
+	if hooks := srv.webhooks.list("project1"); len(hooks) != 0 {
+		t.Errorf("project1 webhooks = %+v, want none", hooks)
+	}
+	if hooks := srv.webhooks.list("project2"); len(hooks) != 1 || hooks[0].URL != "http://new.example.com" {
+		t.Errorf("project2 webhooks = %+v", hooks)
+	}
+
+	err = srv.ReloadProjects([]ProviderEntry{{Name: "project1", Token: "tok-x", Store: s1}},
+		[]Webhook{{Project: "project1", URL: "http://x.example.com", Events: []string{"bead.exploded"}}})
+	if err == nil {
+		t.Fatal("expected error for an invalid webhook")
+	}
+	if got := status("tok-new"); got != http.StatusOK {
+		t.Errorf("after a failed reload: got %d, want the old projects kept", got)
+	}
+}
+
+func TestReloadProjects_SingleProject(t *testing.T) {
+	srv := testServer(t)
+	if err := srv.ReloadProjects(nil, nil); err == nil {
+		t.Fatal("expected error reloading a single-project server")
+	}
+}
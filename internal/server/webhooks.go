@@ -0,0 +1,666 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vector76/beads_server/internal/store"
+)
+
+// Webhook sources: defined in the projects file, or created through the API.
+const (
+	WebhookSourceConfig = "config"
+	WebhookSourceAPI    = "api"
+)
+
+// Delivery statuses.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Headers sent with every webhook delivery.
+const (
+	WebhookEventHeader     = "X-Beads-Event"
+	WebhookDeliveryHeader  = "X-Beads-Delivery"
+	WebhookSignatureHeader = "X-Beads-Signature"
+)
+
+// Retry schedule for failed deliveries: the wait doubles after every attempt,
+// starting at webhookRetryBase and capped at webhookMaxBackoff. A delivery
+// that still fails after webhookMaxAttempts is marked failed. Variables so
+// tests can shorten them.
+var (
+	webhookRetryBase   = 10 * time.Second
+	webhookMaxBackoff  = time.Hour
+	webhookMaxAttempts = 10
+)
+
+// maxPendingDeliveries bounds each webhook's queue. Events for a webhook
+// with this many deliveries pending are logged as failed deliveries instead
+// of being queued. A variable so tests can shorten it.
+var maxPendingDeliveries = 1000
+
+// webhookTimeout bounds a single delivery attempt.
+const webhookTimeout = 10 * time.Second
+
+// deliveryLogSize is how many finished deliveries are kept per webhook.
+// Pending deliveries are always kept.
+const deliveryLogSize = 100
+
+// Webhook is a subscription that POSTs a project's events to a URL.
+type Webhook struct {
+	ID        string    `json:"id"`
+	Project   string    `json:"project"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events,omitempty"` // event types or "prefix.*"; empty means all
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// wants reports whether the webhook subscribes to events of type t.
+func (h Webhook) wants(t string) bool {
+	if len(h.Events) == 0 {
+		return true
+	}
+	for _, f := range h.Events {
+		if f == t || (strings.HasSuffix(f, ".*") && strings.HasPrefix(t, strings.TrimSuffix(f, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// redacted returns h without its secret, for listing.
+func (h Webhook) redacted() Webhook {
+	h.Secret = ""
+	return h
+}
+
+// Delivery is one event queued for, or sent to, one webhook.
+type Delivery struct {
+	ID           string     `json:"id"`
+	WebhookID    string     `json:"webhook_id"`
+	Event        Event      `json:"event"`
+	Status       string     `json:"status"`
+	Attempts     int        `json:"attempts"`
+	NextAttempt  *time.Time `json:"next_attempt,omitempty"`
+	ResponseCode int        `json:"response_code,omitempty"`
+	Error        string     `json:"error,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// webhookState is the on-disk format of the webhook file. Webhooks from the
+// projects file are not saved; they are re-read from it on every start.
+// Changes to the queue since the file was written are in its journal.
+type webhookState struct {
+	Webhooks   []Webhook  `json:"webhooks"`
+	Deliveries []Delivery `json:"deliveries"`
+}
+
+// webhookManager holds webhook subscriptions and their delivery queue. Each
+// webhook has a worker goroutine that sends its due deliveries one at a
+// time, so a slow endpoint only holds up its own queue. Every change to the
+// queue is appended to a journal before the call making it returns.
+type webhookManager struct {
+	mu             sync.Mutex // also serializes writes to the webhook file and journal
+	path           string     // empty keeps state in memory only
+	hooks          []Webhook
+	deliveries     []Delivery             // oldest first
+	workers        map[string]*hookWorker // by webhook ID
+	journalRecords int                    // records appended since the file was written
+	logger         *log.Logger
+	client         *http.Client
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// hookWorker is the goroutine delivering one webhook's queue.
+type hookWorker struct {
+	wake   chan struct{}
+	cancel context.CancelFunc
+}
+
+// newWebhookManager loads the webhook file at path, adds the webhooks from
+// the projects file, and starts delivering. Queued deliveries for webhooks
+// that no longer exist are dropped.
+func newWebhookManager(path string, configured []Webhook, logger *log.Logger) (*webhookManager, error) {
+	m := &webhookManager{
+		path:    path,
+		logger:  logger,
+		client:  &http.Client{Timeout: webhookTimeout},
+		workers: make(map[string]*hookWorker),
+	}
+
+	hooks, err := configuredHooks(configured)
+	if err != nil {
+		return nil, err
+	}
+	m.hooks = hooks
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("reading webhook file: %w", err)
+		}
+		if err == nil {
+			var state webhookState
+			if err := json.Unmarshal(data, &state); err != nil {
+				return nil, fmt.Errorf("parsing webhook file: %w", err)
+			}
+			m.hooks = append(m.hooks, state.Webhooks...)
+			m.deliveries = state.Deliveries
+		}
+		if m.journalRecords, err = m.replayJournal(); err != nil {
+			return nil, err
+		}
+		m.deliveries = slices.DeleteFunc(m.deliveries, func(d Delivery) bool {
+			_, ok := m.hook(d.WebhookID)
+			return !ok
+		})
+	}
+
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	m.syncWorkers()
+	return m, nil
+}
+
+// configuredHooks checks the webhooks from the projects file and assigns
+// their IDs and source.
+func configuredHooks(configured []Webhook) ([]Webhook, error) {
+	var hooks []Webhook
+	for _, h := range configured {
+		for _, f := range h.Events {
+			if !validEventFilter(f) {
+				return nil, fmt.Errorf("project %q: webhook %s: unknown event type %q", h.Project, h.URL, f)
+			}
+		}
+		h.ID = configWebhookID(h.Project, h.URL)
+		h.Source = WebhookSourceConfig
+		hooks = append(hooks, h)
+	}
+	return hooks, nil
+}
+
+// replaceConfigured swaps the webhooks from the projects file for hooks,
+// keeping those created through the API. Queued deliveries for webhooks
+// that are gone are dropped; a webhook whose project and URL are unchanged
+// keeps its ID and so its queue and log.
+func (m *webhookManager) replaceConfigured(hooks []Webhook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := hooks
+	for _, h := range m.hooks {
+		if h.Source != WebhookSourceConfig {
+			kept = append(kept, h)
+		}
+	}
+	m.hooks = kept
+
+	var deliveries []Delivery
+	for _, d := range m.deliveries {
+		if _, ok := m.hook(d.WebhookID); ok {
+			deliveries = append(deliveries, d)
+		}
+	}
+	m.deliveries = deliveries
+	m.syncWorkers()
+	if err := m.compact(); err != nil {
+		m.logger.Printf("saving webhook queue: %v", err)
+	}
+}
+
+// stop cancels any delivery in flight, waits for the workers to exit and
+// folds the journal into the webhook file. An interrupted delivery stays
+// pending and is retried on the next start.
+func (m *webhookManager) stop() {
+	m.mu.Lock()
+	m.cancel() // under m.mu, so syncWorkers starts no worker after this
+	m.mu.Unlock()
+	m.wg.Wait()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.compact(); err != nil {
+		m.logger.Printf("saving webhook queue: %v", err)
+	}
+}
+
+// syncWorkers starts a worker for every webhook without one, and stops the
+// workers of webhooks that are gone, cancelling their delivery in flight.
+// Caller must hold m.mu.
+func (m *webhookManager) syncWorkers() {
+	if m.ctx.Err() != nil {
+		return // stopped
+	}
+	for id, w := range m.workers {
+		if _, ok := m.hook(id); !ok {
+			w.cancel()
+			delete(m.workers, id)
+		}
+	}
+	for _, h := range m.hooks {
+		if _, ok := m.workers[h.ID]; ok {
+			continue
+		}
+		ctx, cancel := context.WithCancel(m.ctx)
+		w := &hookWorker{wake: make(chan struct{}, 1), cancel: cancel}
+		m.workers[h.ID] = w
+		m.wg.Add(1)
+		go m.run(ctx, h.ID, w.wake)
+	}
+}
+
+// configWebhookID derives a stable ID for a webhook from the projects file,
+// so its queued deliveries still match it after a restart.
+func configWebhookID(project, url string) string {
+	sum := sha256.Sum256([]byte(project + "\n" + url))
+	return "wh-" + hex.EncodeToString(sum[:4])
+}
+
+// randomHex returns n random bytes, hex-encoded.
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// validEventFilter reports whether f names an event type, or is a prefix
+// wildcard such as "bead.*" that matches at least one.
+func validEventFilter(f string) bool {
+	if prefix, ok := strings.CutSuffix(f, "*"); ok && strings.HasSuffix(prefix, ".") {
+		return slices.ContainsFunc(eventTypes, func(t string) bool { return strings.HasPrefix(t, prefix) })
+	}
+	return slices.Contains(eventTypes, f)
+}
+
+// validWebhookURL reports whether raw is an absolute http or https URL.
+func validWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// signPayload returns the signature header value for body: the hex HMAC-SHA256
+// of the body keyed with secret, prefixed with "sha256=".
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryDelay returns how long to wait after the given number of failed
+// attempts before trying again.
+func retryDelay(attempts int) time.Duration {
+	d := webhookRetryBase
+	for i := 1; i < attempts && d < webhookMaxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, webhookMaxBackoff)
+}
+
+// hook returns the webhook with the given ID.
+// Caller must hold m.mu.
+func (m *webhookManager) hook(id string) (Webhook, bool) {
+	for _, h := range m.hooks {
+		if h.ID == id {
+			return h, true
+		}
+	}
+	return Webhook{}, false
+}
+
+// list returns the webhooks of a project, without secrets.
+func (m *webhookManager) list(project string) []Webhook {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := []Webhook{}
+	for _, h := range m.hooks {
+		if h.Project == project {
+			result = append(result, h.redacted())
+		}
+	}
+	return result
+}
+
+// create adds a webhook to a project and returns it, secret included. A
+// missing secret is generated.
+func (m *webhookManager) create(h Webhook) (Webhook, error) {
+	if !validWebhookURL(h.URL) {
+		return Webhook{}, fmt.Errorf("url must be an absolute http or https URL")
+	}
+	for _, f := range h.Events {
+		if !validEventFilter(f) {
+			return Webhook{}, fmt.Errorf("unknown event type %q", f)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, existing := range m.hooks {
+		if existing.Project == h.Project && existing.URL == h.URL {
+			return Webhook{}, &store.ConflictError{Message: fmt.Sprintf("webhook %s already delivers to %s", existing.ID, h.URL)}
+		}
+	}
+
+	h.ID = "wh-" + randomHex(4)
+	for _, taken := m.hook(h.ID); taken; _, taken = m.hook(h.ID) {
+		h.ID = "wh-" + randomHex(4)
+	}
+	if h.Secret == "" {
+		h.Secret = randomHex(16)
+	}
+	h.Source = WebhookSourceAPI
+	h.CreatedAt = time.Now().UTC()
+
+	state := m.snapshot()
+	state.Webhooks = append(state.Webhooks, h)
+	if err := m.save(state); err != nil {
+		return Webhook{}, err
+	}
+	m.hooks = append(m.hooks, h)
+	m.syncWorkers()
+	return h, nil
+}
+
+// remove deletes a project's webhook created through the API, along with its
+// queued deliveries and delivery log.
+func (m *webhookManager) remove(project, id string) (Webhook, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := slices.IndexFunc(m.hooks, func(h Webhook) bool { return h.ID == id && h.Project == project })
+	if i < 0 {
+		return Webhook{}, &store.NotFoundError{Message: fmt.Sprintf("webhook %s not found", id)}
+	}
+	h := m.hooks[i]
+	if h.Source == WebhookSourceConfig {
+		return Webhook{}, &store.ConflictError{Message: fmt.Sprintf("webhook %s is defined in the projects file", id)}
+	}
+
+	state := m.snapshot()
+	isHook := func(x Webhook) bool { return x.ID == id }
+	ofHook := func(d Delivery) bool { return d.WebhookID == id }
+	state.Webhooks = slices.DeleteFunc(state.Webhooks, isHook)
+	state.Deliveries = slices.DeleteFunc(state.Deliveries, ofHook)
+	if err := m.save(state); err != nil {
+		return Webhook{}, err
+	}
+	m.hooks = slices.DeleteFunc(m.hooks, isHook)
+	m.deliveries = slices.DeleteFunc(m.deliveries, ofHook)
+	m.syncWorkers()
+	return h.redacted(), nil
+}
+
+// deliveryLog returns the deliveries of a project's webhook, newest first.
+func (m *webhookManager) deliveryLog(project, id string) ([]Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if h, ok := m.hook(id); !ok || h.Project != project {
+		return nil, &store.NotFoundError{Message: fmt.Sprintf("webhook %s not found", id)}
+	}
+	result := []Delivery{}
+	for i := len(m.deliveries) - 1; i >= 0; i-- {
+		if m.deliveries[i].WebhookID == id {
+			result = append(result, m.deliveries[i])
+		}
+	}
+	return result, nil
+}
+
+// enqueue queues ev for every webhook of its project that subscribes to it,
+// saves the new deliveries to the journal and wakes their workers. A webhook
+// whose queue is full gets a failed delivery instead.
+func (m *webhookManager) enqueue(ev Event) {
+	m.mu.Lock()
+	var wake []chan struct{}
+	var ops []deliveryOp
+	now := time.Now().UTC()
+	for _, h := range m.hooks {
+		if h.Project != ev.Project || !h.wants(ev.Type) {
+			continue
+		}
+		next := now
+		d := Delivery{
+			ID:          "dl-" + randomHex(6),
+			WebhookID:   h.ID,
+			Event:       ev,
+			Status:      DeliveryPending,
+			NextAttempt: &next,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if m.pending(h.ID) >= maxPendingDeliveries {
+			d.Status = DeliveryFailed
+			d.NextAttempt = nil
+			d.Error = fmt.Sprintf("queue full: %d deliveries pending", maxPendingDeliveries)
+			m.logger.Printf("webhook %s: dropped %s event, %d deliveries pending", h.ID, ev.Type, maxPendingDeliveries)
+		}
+		m.deliveries = append(m.deliveries, d)
+		ops = append(ops, putDelivery(d))
+		if d.Status != DeliveryPending {
+			for _, id := range m.trimLog(h.ID) {
+				ops = append(ops, deleteDelivery(id))
+			}
+			continue
+		}
+		if w, ok := m.workers[h.ID]; ok {
+			wake = append(wake, w.wake)
+		}
+	}
+	if err := m.appendJournal(ops...); err != nil {
+		// The deliveries are still queued, and saved with the next
+		// compaction or on shutdown.
+		m.logger.Printf("saving webhook queue: %v", err)
+	}
+	m.mu.Unlock()
+
+	for _, ch := range wake {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// run sends a webhook's deliveries as they fall due, in order, sleeping
+// until the next one or until enqueue wakes it.
+func (m *webhookManager) run(ctx context.Context, hookID string, wake <-chan struct{}) {
+	defer m.wg.Done()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-wake:
+		case <-timer.C:
+		}
+
+		for _, d := range m.due(hookID, time.Now()) {
+			m.attempt(ctx, d)
+			if ctx.Err() != nil {
+				return
+			}
+		}
+		timer.Reset(m.untilNext(hookID, time.Now()))
+	}
+}
+
+// pending returns the number of a webhook's pending deliveries.
+// Caller must hold m.mu.
+func (m *webhookManager) pending(hookID string) int {
+	n := 0
+	for _, d := range m.deliveries {
+		if d.WebhookID == hookID && d.Status == DeliveryPending {
+			n++
+		}
+	}
+	return n
+}
+
+// due returns a webhook's pending deliveries whose next attempt is at or
+// before now.
+func (m *webhookManager) due(hookID string, now time.Time) []Delivery {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var result []Delivery
+	for _, d := range m.deliveries {
+		if d.WebhookID == hookID && d.Status == DeliveryPending && !d.NextAttempt.After(now) {
+			result = append(result, d)
+		}
+	}
+	return result
+}
+
+// untilNext returns how long until a webhook's next pending delivery is due.
+func (m *webhookManager) untilNext(hookID string, now time.Time) time.Duration {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wait := time.Hour
+	for _, d := range m.deliveries {
+		if d.WebhookID == hookID && d.Status == DeliveryPending {
+			wait = min(wait, max(d.NextAttempt.Sub(now), 0))
+		}
+	}
+	return wait
+}
+
+// attempt POSTs one delivery and records the outcome.
+func (m *webhookManager) attempt(ctx context.Context, d Delivery) {
+	m.mu.Lock()
+	h, ok := m.hook(d.WebhookID)
+	m.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	code, err := m.send(ctx, h, d)
+	if ctx.Err() != nil {
+		// Shutting down or webhook removed: leave the delivery pending for
+		// the next start.
+		return
+	}
+	m.finish(d.ID, code, err)
+}
+
+// send POSTs the delivery's event to the webhook and returns the response
+// status. A non-2xx status is returned as an error.
+func (m *webhookManager) send(ctx context.Context, h Webhook, d Delivery) (int, error) {
+	body, err := json.Marshal(d.Event)
+	if err != nil {
+		return 0, fmt.Errorf("marshaling event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "beads-server")
+	req.Header.Set(WebhookEventHeader, d.Event.Type)
+	req.Header.Set(WebhookDeliveryHeader, d.ID)
+	if h.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, signPayload(h.Secret, body))
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// finish records the result of an attempt: delivered, scheduled for a retry,
+// or failed for good once the attempts run out. The result is saved to the
+// journal before it returns.
+func (m *webhookManager) finish(id string, code int, sendErr error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.deliveries, func(d Delivery) bool { return d.ID == id })
+	if i < 0 {
+		return // webhook removed while sending
+	}
+	d := &m.deliveries[i]
+	now := time.Now().UTC()
+	d.Attempts++
+	d.ResponseCode = code
+	d.UpdatedAt = now
+	d.NextAttempt = nil
+
+	switch {
+	case sendErr == nil:
+		d.Status = DeliveryDelivered
+		d.Error = ""
+	case d.Attempts >= webhookMaxAttempts:
+		d.Status = DeliveryFailed
+		d.Error = sendErr.Error()
+		m.logger.Printf("webhook delivery %s to %s failed after %d attempts: %v", d.ID, d.WebhookID, d.Attempts, sendErr)
+	default:
+		next := now.Add(retryDelay(d.Attempts))
+		d.NextAttempt = &next
+		d.Error = sendErr.Error()
+	}
+
+	ops := []deliveryOp{putDelivery(*d)}
+	if d.Status != DeliveryPending {
+		for _, trimmed := range m.trimLog(d.WebhookID) {
+			ops = append(ops, deleteDelivery(trimmed))
+		}
+	}
+	if err := m.appendJournal(ops...); err != nil {
+		m.logger.Printf("saving webhook queue: %v", err)
+	}
+}
+
+// trimLog drops the oldest finished deliveries of a webhook beyond
+// deliveryLogSize and returns their IDs.
+// Caller must hold m.mu.
+func (m *webhookManager) trimLog(webhookID string) []string {
+	finished := 0
+	for _, d := range m.deliveries {
+		if d.WebhookID == webhookID && d.Status != DeliveryPending {
+			finished++
+		}
+	}
+	excess := finished - deliveryLogSize
+	if excess <= 0 {
+		return nil
+	}
+	var trimmed []string
+	m.deliveries = slices.DeleteFunc(m.deliveries, func(d Delivery) bool {
+		if excess > 0 && d.WebhookID == webhookID && d.Status != DeliveryPending {
+			excess--
+			trimmed = append(trimmed, d.ID)
+			return true
+		}
+		return false
+	})
+	return trimmed
+}
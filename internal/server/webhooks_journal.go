@@ -0,0 +1,220 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// webhookCompactThreshold is the number of journal records after which the
+// webhook file is rewritten and the journal removed.
+const webhookCompactThreshold = 1000
+
+// deliveryOp is a single change to the delivery queue within a journal
+// record. A "put" op carries the full delivery, so replaying it is
+// idempotent; a "delete" op drops a delivery trimmed from the log.
+type deliveryOp struct {
+	Op       string    `json:"op"`
+	ID       string    `json:"id"`
+	Delivery *Delivery `json:"delivery,omitempty"`
+}
+
+// deliveryRecord is one line of the webhook journal.
+type deliveryRecord struct {
+	Ops []deliveryOp `json:"ops"`
+}
+
+func putDelivery(d Delivery) deliveryOp {
+	return deliveryOp{Op: "put", ID: d.ID, Delivery: &d}
+}
+
+func deleteDelivery(id string) deliveryOp {
+	return deliveryOp{Op: "delete", ID: id}
+}
+
+// journalPath returns the path of the journal next to the webhook file.
+func (m *webhookManager) journalPath() string {
+	return m.path + ".wal"
+}
+
+// replayJournal applies every complete record in the journal to
+// m.deliveries and returns the number applied. As with the store's journal,
+// a torn final record is an interrupted write and is truncated away, while a
+// corrupt record followed by others is an error.
+// Caller must have exclusive access to m.
+func (m *webhookManager) replayJournal() (int, error) {
+	path := m.journalPath()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("reading webhook journal: %w", err)
+	}
+
+	count := 0
+	offset := 0
+	for offset < len(data) {
+		end := bytes.IndexByte(data[offset:], '\n')
+		if end < 0 {
+			break
+		}
+		next := offset + end + 1
+
+		var rec deliveryRecord
+		if err := json.Unmarshal(data[offset:offset+end], &rec); err != nil {
+			if next < len(data) {
+				return 0, fmt.Errorf("parsing webhook journal record %d: %w", count+1, err)
+			}
+			break
+		}
+		m.applyOps(rec.Ops)
+		count++
+		offset = next
+	}
+
+	if offset < len(data) {
+		if err := os.Truncate(path, int64(offset)); err != nil {
+			return 0, fmt.Errorf("truncating torn webhook journal record: %w", err)
+		}
+	}
+	return count, nil
+}
+
+// applyOps applies journal ops to m.deliveries.
+// Caller must hold m.mu or have exclusive access to m.
+func (m *webhookManager) applyOps(ops []deliveryOp) {
+	for _, op := range ops {
+		i := slices.IndexFunc(m.deliveries, func(d Delivery) bool { return d.ID == op.ID })
+		switch {
+		case op.Op == "put" && op.Delivery != nil && i >= 0:
+			m.deliveries[i] = *op.Delivery
+		case op.Op == "put" && op.Delivery != nil:
+			m.deliveries = append(m.deliveries, *op.Delivery)
+		case op.Op == "delete" && i >= 0:
+			m.deliveries = slices.Delete(m.deliveries, i, i+1)
+		}
+	}
+}
+
+// appendJournal writes ops as one journal line and syncs it, so the changes
+// survive a crash from the moment it returns. Once enough records
+// accumulate, the journal is folded into the webhook file.
+// Caller must hold m.mu.
+func (m *webhookManager) appendJournal(ops ...deliveryOp) error {
+	if m.path == "" || len(ops) == 0 {
+		return nil
+	}
+
+	line, err := json.Marshal(deliveryRecord{Ops: ops})
+	if err != nil {
+		return fmt.Errorf("marshaling webhook journal record: %w", err)
+	}
+	line = append(line, '\n')
+
+	f, err := os.OpenFile(m.journalPath(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening webhook journal: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat webhook journal: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		// Drop any partial record so later appends stay parseable.
+		f.Truncate(info.Size())
+		f.Close()
+		return fmt.Errorf("writing webhook journal: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Truncate(info.Size())
+		f.Close()
+		return fmt.Errorf("syncing webhook journal: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing webhook journal: %w", err)
+	}
+
+	m.journalRecords++
+	if m.journalRecords >= webhookCompactThreshold {
+		// The record is durable already; if compaction fails, try again
+		// after another threshold's worth of records.
+		if err := m.compact(); err != nil {
+			m.logger.Printf("compacting webhook journal: %v", err)
+			m.journalRecords = 0
+		}
+	}
+	return nil
+}
+
+// compact writes the current state to the webhook file and removes the
+// journal.
+// Caller must hold m.mu.
+func (m *webhookManager) compact() error {
+	return m.save(m.snapshot())
+}
+
+// save writes state to the webhook file and removes the journal, whose
+// records state must already include. A crash between the two is harmless:
+// replaying the journal over the file yields the same deliveries.
+// Caller must hold m.mu.
+func (m *webhookManager) save(state webhookState) error {
+	if m.path == "" {
+		return nil
+	}
+	if err := m.write(state); err != nil {
+		return err
+	}
+	if err := os.Remove(m.journalPath()); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing webhook journal: %w", err)
+	}
+	m.journalRecords = 0
+	return nil
+}
+
+// snapshot returns a copy of the state to save: the webhooks created through
+// the API and all deliveries.
+// Caller must hold m.mu.
+func (m *webhookManager) snapshot() webhookState {
+	state := webhookState{Webhooks: []Webhook{}, Deliveries: slices.Clone(m.deliveries)}
+	for _, h := range m.hooks {
+		if h.Source == WebhookSourceAPI {
+			state.Webhooks = append(state.Webhooks, h)
+		}
+	}
+	return state
+}
+
+// write writes state to the webhook file atomically (temp file + rename).
+// Caller must hold m.mu.
+func (m *webhookManager) write(state webhookState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling webhooks: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(m.path), "webhooks-*.json.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	tmpPath := tmp.Name()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("writing temp file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("closing temp file: %w", err)
+	}
+	if err := os.Rename(tmpPath, m.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("renaming temp file: %w", err)
+	}
+	return nil
+}
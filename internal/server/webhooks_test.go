@@ -0,0 +1,449 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// received is one request captured by a webhook receiver.
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookReceiver starts a local HTTP server that answers each request with
+// the next status from statuses (repeating the last) and passes it on ch.
+func webhookReceiver(t *testing.T, statuses ...int) (*httptest.Server, chan received) {
+	t.Helper()
+	ch := make(chan received, 16)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(calls.Add(1))
+		body, _ := io.ReadAll(r.Body)
+		ch <- received{header: r.Header.Clone(), body: body}
+		w.WriteHeader(statuses[min(n, len(statuses))-1])
+	}))
+	t.Cleanup(srv.Close)
+	return srv, ch
+}
+
+// webhookServer returns a single-project server that keeps webhook state in
+// path, with the given webhooks from the projects file.
+func webhookServer(t *testing.T, path string, configured ...Webhook) *Server {
+	t.Helper()
+	srv, err := New(Config{LogOutput: io.Discard, WebhookFile: path, Webhooks: configured},
+		NewSingleStoreProvider(testToken, loadTestStore(t)))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// createWebhook creates a webhook through the API.
+func createWebhook(t *testing.T, srv *Server, body map[string]any) Webhook {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodPost, "/api/v1/webhooks", body))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create webhook: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var h Webhook
+	json.NewDecoder(w.Body).Decode(&h)
+	return h
+}
+
+// getDeliveries fetches a webhook's delivery log.
+func getDeliveries(t *testing.T, srv *Server, id string) []Delivery {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodGet, "/api/v1/webhooks/"+id+"/deliveries", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("deliveries: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var ds []Delivery
+	json.NewDecoder(w.Body).Decode(&ds)
+	return ds
+}
+
+// waitForDelivery waits until the newest delivery of a webhook has status.
+func waitForDelivery(t *testing.T, srv *Server, id, status string) Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		ds := getDeliveries(t, srv, id)
+		if len(ds) > 0 && ds[0].Status == status {
+			return ds[0]
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for a %s delivery, got %+v", status, ds)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// nextRequest waits for the receiver's next request.
+func nextRequest(t *testing.T, ch chan received) received {
+	t.Helper()
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for webhook request")
+		return received{}
+	}
+}
+
+// shortenRetries makes failed deliveries retry quickly for one test.
+func shortenRetries(t *testing.T, maxAttempts int) {
+	t.Helper()
+	base, attempts := webhookRetryBase, webhookMaxAttempts
+	webhookRetryBase, webhookMaxAttempts = 10*time.Millisecond, maxAttempts
+	t.Cleanup(func() { webhookRetryBase, webhookMaxAttempts = base, attempts })
+}
+
+func TestWebhooks_DeliversSignedEvent(t *testing.T) {
+	receiver, ch := webhookReceiver(t, http.StatusOK)
+	srv := webhookServer(t, "")
+	h := createWebhook(t, srv, map[string]any{"url": receiver.URL, "secret": "s3cret", "events": []string{"bead.created"}})
+
+	b := createViaAPI(t, srv, map[string]any{"title": "Hooked"})
+	req := nextRequest(t, ch)
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(req.body)
+	if got, want := req.header.Get(WebhookSignatureHeader), "sha256="+hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("signature = %q, want %q", got, want)
+	}
+	if got := req.header.Get(WebhookEventHeader); got != EventBeadCreated {
+		t.Errorf("event header = %q, want %q", got, EventBeadCreated)
+	}
+	var ev Event
+	if err := json.Unmarshal(req.body, &ev); err != nil {
+		t.Fatalf("parsing payload: %v", err)
+	}
+	if ev.Type != EventBeadCreated || ev.BeadID != b.ID || ev.Project != "default" || ev.ID == 0 {
+		t.Errorf("unexpected payload: %+v", ev)
+	}
+
+	d := waitForDelivery(t, srv, h.ID, DeliveryDelivered)
+	if d.ID != req.header.Get(WebhookDeliveryHeader) || d.Attempts != 1 || d.ResponseCode != http.StatusOK {
+		t.Errorf("unexpected delivery: %+v", d)
+	}
+
+	// Updates are filtered out.
+	srv.Router.ServeHTTP(httptest.NewRecorder(), authReq(http.MethodPatch, "/api/v1/beads/"+b.ID, map[string]any{"priority": "high"}))
+	if n := len(getDeliveries(t, srv, h.ID)); n != 1 {
+		t.Errorf("expected the update to be filtered out, got %d deliveries", n)
+	}
+}
+
+func TestWebhooks_RetriesFailedDelivery(t *testing.T) {
+	shortenRetries(t, 5)
+	receiver, _ := webhookReceiver(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK)
+	srv := webhookServer(t, "")
+	h := createWebhook(t, srv, map[string]any{"url": receiver.URL})
+
+	createViaAPI(t, srv, map[string]any{"title": "Flaky"})
+
+	d := waitForDelivery(t, srv, h.ID, DeliveryDelivered)
+	if d.Attempts != 3 || d.Error != "" {
+		t.Errorf("expected delivery on the third attempt, got %+v", d)
+	}
+}
+
+func TestWebhooks_GivesUpAfterMaxAttempts(t *testing.T) {
+	shortenRetries(t, 2)
+	receiver, _ := webhookReceiver(t, http.StatusInternalServerError)
+	srv := webhookServer(t, "")
+	h := createWebhook(t, srv, map[string]any{"url": receiver.URL})
+
+	createViaAPI(t, srv, map[string]any{"title": "Doomed"})
+
+	d := waitForDelivery(t, srv, h.ID, DeliveryFailed)
+	if d.Attempts != 2 || d.ResponseCode != http.StatusInternalServerError || d.Error != "HTTP 500" || d.NextAttempt != nil {
+		t.Errorf("unexpected failed delivery: %+v", d)
+	}
+}
+
+func TestWebhooks_QueueSurvivesRestart(t *testing.T) {
+	// The first request hangs until the server shuts down; later ones succeed.
+	first := make(chan struct{})
+	receiver, got := webhookReceiver(t, http.StatusOK)
+	hanging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-first:
+			receiver.Config.Handler.ServeHTTP(w, r)
+		default:
+			close(first)
+			io.Copy(io.Discard, r.Body)
+			<-r.Context().Done()
+		}
+	}))
+	defer hanging.Close()
+
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	srv1, err := New(Config{LogOutput: io.Discard, WebhookFile: path}, NewSingleStoreProvider(testToken, loadTestStore(t)))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	h := createWebhook(t, srv1, map[string]any{"url": hanging.URL})
+	b := createViaAPI(t, srv1, map[string]any{"title": "Survivor"})
+	select {
+	case <-first:
+	case <-time.After(5 * time.Second):
+		t.Fatal("delivery never started")
+	}
+	srv1.Close()
+
+	srv2 := webhookServer(t, path)
+	req := nextRequest(t, got)
+	var ev Event
+	json.Unmarshal(req.body, &ev)
+	if ev.BeadID != b.ID {
+		t.Errorf("expected the queued event for %s, got %+v", b.ID, ev)
+	}
+	d := waitForDelivery(t, srv2, h.ID, DeliveryDelivered)
+	if d.Attempts != 1 {
+		t.Errorf("interrupted attempt should not count, got %d attempts", d.Attempts)
+	}
+}
+
+func TestWebhooks_DeadEndpointDoesNotDelayOthers(t *testing.T) {
+	dead := hangingEndpoint(t)
+	receiver, ch := webhookReceiver(t, http.StatusOK)
+	srv := webhookServer(t, "")
+	createWebhook(t, srv, map[string]any{"url": dead.URL})
+	h := createWebhook(t, srv, map[string]any{"url": receiver.URL})
+
+	start := time.Now()
+	createViaAPI(t, srv, map[string]any{"title": "First"})
+	createViaAPI(t, srv, map[string]any{"title": "Second"})
+	nextRequest(t, ch)
+	nextRequest(t, ch)
+	if elapsed := time.Since(start); elapsed >= webhookTimeout/2 {
+		t.Errorf("deliveries to a live endpoint took %v behind a dead one", elapsed)
+	}
+	if ds := getDeliveries(t, srv, h.ID); len(ds) != 2 {
+		t.Errorf("expected 2 deliveries, got %+v", ds)
+	}
+}
+
+// hangingEndpoint starts a local HTTP server that never answers, so
+// deliveries to it stay pending until the manager stops.
+func hangingEndpoint(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close) // after the managers stop, which ends the hanging requests
+	return srv
+}
+
+func TestWebhooks_QueueSurvivesCrashAfterEnqueue(t *testing.T) {
+	hanging := hangingEndpoint(t)
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	hooks := []Webhook{{Project: "p", URL: hanging.URL}}
+	logger := log.New(io.Discard, "", 0)
+
+	m1, err := newWebhookManager(path, hooks, logger)
+	if err != nil {
+		t.Fatalf("newWebhookManager: %v", err)
+	}
+	t.Cleanup(m1.stop)
+	m1.enqueue(Event{Type: EventBeadCreated, Project: "p", BeadID: "bd-1"})
+
+	// Start again from the files without stopping the first manager, as
+	// after a crash.
+	m2, err := newWebhookManager(path, hooks, logger)
+	if err != nil {
+		t.Fatalf("newWebhookManager: %v", err)
+	}
+	t.Cleanup(m2.stop)
+	ds, err := m2.deliveryLog("p", configWebhookID("p", hanging.URL))
+	if err != nil {
+		t.Fatalf("deliveryLog: %v", err)
+	}
+	if len(ds) != 1 || ds[0].Event.BeadID != "bd-1" || ds[0].Status != DeliveryPending {
+		t.Errorf("expected the queued delivery after the crash, got %+v", ds)
+	}
+}
+
+func TestWebhooks_OutcomeSavedWithoutShutdown(t *testing.T) {
+	receiver, _ := webhookReceiver(t, http.StatusOK)
+	path := filepath.Join(t.TempDir(), "webhooks.json")
+	srv := webhookServer(t, path)
+	h := createWebhook(t, srv, map[string]any{"url": receiver.URL})
+	createViaAPI(t, srv, map[string]any{"title": "Saved"})
+	d := waitForDelivery(t, srv, h.ID, DeliveryDelivered)
+
+	m, err := newWebhookManager(path, nil, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("newWebhookManager: %v", err)
+	}
+	defer m.stop()
+	ds, err := m.deliveryLog("default", h.ID)
+	if err != nil {
+		t.Fatalf("deliveryLog: %v", err)
+	}
+	if len(ds) != 1 || ds[0].ID != d.ID || ds[0].Status != DeliveryDelivered {
+		t.Errorf("expected the delivered delivery in the saved state, got %+v", ds)
+	}
+}
+
+func TestWebhooks_PendingQueueIsCapped(t *testing.T) {
+	limit := maxPendingDeliveries
+	maxPendingDeliveries = 2
+	t.Cleanup(func() { maxPendingDeliveries = limit })
+
+	hanging := hangingEndpoint(t)
+	m, err := newWebhookManager("", []Webhook{{Project: "p", URL: hanging.URL}}, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("newWebhookManager: %v", err)
+	}
+	t.Cleanup(m.stop)
+	for i := 0; i < 4; i++ {
+		m.enqueue(Event{Type: EventBeadCreated, Project: "p"})
+	}
+
+	ds, _ := m.deliveryLog("p", configWebhookID("p", hanging.URL))
+	var pending, failed int
+	for _, d := range ds {
+		switch d.Status {
+		case DeliveryPending:
+			pending++
+		case DeliveryFailed:
+			failed++
+			if !strings.Contains(d.Error, "queue full") {
+				t.Errorf("dropped delivery error = %q", d.Error)
+			}
+		}
+	}
+	if pending != 2 || failed != 2 {
+		t.Errorf("expected 2 pending and 2 failed deliveries, got %+v", ds)
+	}
+}
+
+func TestWebhooks_API(t *testing.T) {
+	srv := webhookServer(t, filepath.Join(t.TempDir(), "webhooks.json"),
+		Webhook{Project: "default", URL: "http://ci.example.com/hook", Events: []string{"bead.*"}})
+
+	for name, body := range map[string]map[string]any{
+		"missing url":   {},
+		"relative url":  {"url": "/hook"},
+		"unknown event": {"url": "http://example.com/a", "events": []string{"bead.exploded"}},
+	} {
+		w := httptest.NewRecorder()
+		srv.Router.ServeHTTP(w, authReq(http.MethodPost, "/api/v1/webhooks", body))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d", name, w.Code)
+		}
+	}
+
+	h := createWebhook(t, srv, map[string]any{"url": "http://example.com/a"})
+	if h.Secret == "" || h.Source != WebhookSourceAPI {
+		t.Errorf("expected a generated secret and api source, got %+v", h)
+	}
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodPost, "/api/v1/webhooks", map[string]any{"url": "http://example.com/a"}))
+	if w.Code != http.StatusConflict {
+		t.Errorf("duplicate url: expected 409, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodGet, "/api/v1/webhooks", nil))
+	var hooks []Webhook
+	json.NewDecoder(w.Body).Decode(&hooks)
+	if len(hooks) != 2 || hooks[0].Source != WebhookSourceConfig || hooks[1].ID != h.ID {
+		t.Fatalf("unexpected webhook list: %+v", hooks)
+	}
+	for _, listed := range hooks {
+		if listed.Secret != "" {
+			t.Errorf("list exposed the secret of %s", listed.ID)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodDelete, "/api/v1/webhooks/"+hooks[0].ID, nil))
+	if w.Code != http.StatusConflict {
+		t.Errorf("deleting a configured webhook: expected 409, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodDelete, "/api/v1/webhooks/"+h.ID, nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("delete: expected 200, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, authReq(http.MethodGet, "/api/v1/webhooks/"+h.ID+"/deliveries", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("deliveries of deleted webhook: expected 404, got %d", w.Code)
+	}
+}
+
+func TestWebhooks_ScopedToProject(t *testing.T) {
+	receiver, ch := webhookReceiver(t, http.StatusOK)
+	srv, err := New(Config{
+		LogOutput: io.Discard,
+		Webhooks:  []Webhook{{Project: "alpha", URL: receiver.URL}},
+	}, NewMultiStoreProvider([]ProviderEntry{
+		{Name: "alpha", Token: "tok-alpha", Store: loadTestStore(t)},
+		{Name: "beta", Token: "tok-beta", Store: loadTestStore(t)},
+	}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	t.Cleanup(srv.Close)
+
+	for _, token := range []string{"tok-beta", "tok-alpha"} {
+		req := authReq(http.MethodPost, "/api/v1/beads", map[string]any{"title": "In " + token})
+		req.Header.Set("Authorization", "Bearer "+token)
+		srv.Router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	var ev Event
+	json.Unmarshal(nextRequest(t, ch).body, &ev)
+	if ev.Project != "alpha" {
+		t.Errorf("expected only alpha's event, got %+v", ev)
+	}
+
+	// Beta's token cannot see alpha's webhook.
+	req := authReq(http.MethodGet, "/api/v1/webhooks/"+configWebhookID("alpha", receiver.URL)+"/deliveries", nil)
+	req.Header.Set("Authorization", "Bearer tok-beta")
+	w := httptest.NewRecorder()
+	srv.Router.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for another project's webhook, got %d", w.Code)
+	}
+}
+
+func TestWebhooks_UnknownConfiguredEvent(t *testing.T) {
+	_, err := New(Config{LogOutput: io.Discard, Webhooks: []Webhook{{Project: "default", URL: "http://example.com", Events: []string{"nope"}}}},
+		NewSingleStoreProvider(testToken, loadTestStore(t)))
+	if err == nil {
+		t.Fatal("expected error for unknown event type")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	base, maxBackoff := webhookRetryBase, webhookMaxBackoff
+	webhookRetryBase, webhookMaxBackoff = time.Second, 5*time.Second
+	t.Cleanup(func() { webhookRetryBase, webhookMaxBackoff = base, maxBackoff })
+
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 3: 4 * time.Second, 4: 5 * time.Second, 10: 5 * time.Second} {
+		if got := retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// Storage backend names accepted by Open.
+const (
+	BackendJSON   = "json"
+	BackendSQLite = "sqlite"
+)
+
+// Backend is the storage interface used by the HTTP server. *Store (JSON
+// snapshot + journal) and *SQLiteStore both implement it with identical
+// semantics; see the methods on *Store for the documented behavior.
+type Backend interface {
+	Create(b model.Bead) (model.Bead, error)
+	CreateExcluding(b model.Bead, reserve ReserveFunc) (model.Bead, error)
+	CreateWithParent(b model.Bead, parentID string) (model.Bead, error)
+	CreateWithParentExcluding(b model.Bead, parentID string, reserve ReserveFunc) (model.Bead, error)
+	Get(id string) (model.Bead, error)
+	Resolve(id string) (model.Bead, error)
+	Update(id string, fields UpdateFields) (model.Bead, error)
+	Delete(id string) (model.Bead, error)
+	DeleteIfRevision(id string, rev *int64) (model.Bead, error)
+	All() []model.Bead
+
+	List(filters ListFilters) ListResult
+	Search(opts SearchOptions) (ListResult, error)
+	StatusMap(ids []string) map[string]string
+
+	AddComment(beadID string, comment model.Comment) (model.Bead, error)
+	AddCommentIfRevision(beadID string, comment model.Comment, rev *int64) (model.Bead, error)
+	Claim(beadID, user string, lease time.Duration) (model.Bead, error)
+	ClaimNext(filters ListFilters, user string, lease time.Duration) (before, claimed model.Bead, err error)
+	Heartbeat(beadID, user string, lease time.Duration) (model.Bead, error)
+	ReleaseExpired(now time.Time) ([]Release, error)
+	Clean(cutoff time.Time) (int, error)
+
+	Link(beadID, blockedByID string) (model.Bead, error)
+	LinkIfRevision(beadID, blockedByID string, rev *int64) (model.Bead, error)
+	Unlink(beadID, blockedByID string) (model.Bead, error)
+	UnlinkIfRevision(beadID, blockedByID string, rev *int64) (model.Bead, error)
+	Deps(beadID string) (DepsResult, error)
+	GetUnblocked(beadID string) []model.Bead
+	Dependents(blockerID string) []model.Bead
+
+	ChildrenOf(parentID string) []model.Bead
+	IsEpic(id string) bool
+	MoveInto(beadID, targetID string) (model.Bead, error)
+	MoveIntoIfRevision(beadID, targetID string, rev *int64) (model.Bead, error)
+	MoveOut(beadID string) (model.Bead, error)
+	MoveOutIfRevision(beadID string, rev *int64) (model.Bead, error)
+	RecomputeParentStatus(childID string) error
+	ValidateStatusChangeOnEpic(beadID string) error
+	ValidateClaimOnEpic(beadID string) error
+	ValidateDeleteOnEpic(beadID string) error
+	ValidateLinkParentChild(beadID, blockedByID string) error
+	ValidateLinkCycle(beadID, blockedByID string) error
+
+	RecordHistory(beadID string, e model.HistoryEntry) error
+	History(beadID string) ([]model.HistoryEntry, error)
+
+	Restore(b model.Bead, entries []model.HistoryEntry) error
+	Purge(id string) error
+
+	Batch(fn func(tx Backend) error) error
+	Prior(id string) (model.Bead, bool)
+	SetForeign(foreign func(id string) (model.Bead, bool))
+	Peek(id string) (model.Bead, bool)
+
+	Close() error
+}
+
+var (
+	_ Backend = (*Store)(nil)
+	_ Backend = (*SQLiteStore)(nil)
+)
+
+// Open opens the named storage backend at path. An empty name selects the
+// JSON backend.
+func Open(backend, path string) (Backend, error) {
+	switch backend {
+	case "", BackendJSON:
+		s, err := Load(path)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	case BackendSQLite:
+		s, err := OpenSQLite(path)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
+
+// Remove deletes the data of the named storage backend at path: the data
+// file and the files kept beside it (the JSON journal, or SQLite's WAL).
+// Files that do not exist are skipped. The store must be closed first.
+func Remove(backend, path string) error {
+	var paths []string
+	switch backend {
+	case "", BackendJSON:
+		paths = []string{path, journalPath(path)}
+	case BackendSQLite:
+		paths = []string{path, path + "-wal", path + "-shm"}
+	default:
+		return fmt.Errorf("unknown storage backend %q", backend)
+	}
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("removing %s: %w", p, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package store
+
+import "github.com/vector76/beads_server/model"
+
+// Batch applies a group of mutations atomically. fn runs against a scratch
+// store while the write lock is held, so no other mutation can interleave;
+// if it returns nil, every bead and history entry it changed is committed in
+// a single journal record. If fn or the write fails, the store is left
+// unchanged.
+//
+// The scratch store works on the store's own maps and indexes, recording
+// the prior state of each bead and history it touches in an undo log, so a
+// batch costs in proportion to what it changes rather than to the size of
+// the project.
+func (s *Store) Batch(fn func(tx Backend) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	scratch := &Store{
+		beads:   s.beads,
+		beadsMu: s.beadsMu,
+		index:   s.index,
+		text:    s.text,
+		history: s.history,
+		pending: &journalRecord{},
+		undo:    newUndoLog(),
+		foreign: s.foreign,
+	}
+	if err := fn(scratch); err != nil {
+		scratch.rollback()
+		return err
+	}
+
+	rec := collapsePuts(scratch.pending.Ops)
+	if len(rec.Ops) == 0 {
+		return nil
+	}
+	if err := s.appendRecord(rec); err != nil {
+		scratch.rollback()
+		return err
+	}
+	// In a nested batch, s is itself a scratch store that may still roll back.
+	s.undo.merge(scratch.undo)
+	return nil
+}
+
+// Prior returns the bead with the given ID as it was when the batch s
+// belongs to began, or as it is now if s is not a batch's scratch store. It
+// lets the caller of a batch describe what the batch changed.
+func (s *Store) Prior(id string) (model.Bead, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.undo != nil {
+		if p, ok := s.undo.beads[id]; ok {
+			return p.bead, p.had
+		}
+	}
+	b, ok := s.beads[id]
+	return b, ok
+}
+
+// undoLog records, for a batch's scratch store, the state each bead and
+// history had before the batch first changed it.
+type undoLog struct {
+	beads   map[string]priorBead
+	history map[string]priorHistory
+}
+
+type priorBead struct {
+	bead model.Bead
+	had  bool
+}
+
+type priorHistory struct {
+	entries []model.HistoryEntry
+	had     bool
+}
+
+func newUndoLog() *undoLog {
+	return &undoLog{
+		beads:   make(map[string]priorBead),
+		history: make(map[string]priorHistory),
+	}
+}
+
+// saveBead records the current state of bead id in s, unless the log
+// already holds an earlier one. A nil log records nothing.
+func (u *undoLog) saveBead(s *Store, id string) {
+	if u == nil {
+		return
+	}
+	if _, ok := u.beads[id]; !ok {
+		b, had := s.beads[id]
+		u.beads[id] = priorBead{bead: b, had: had}
+	}
+}
+
+// saveHistory is saveBead for the history of bead id.
+func (u *undoLog) saveHistory(s *Store, id string) {
+	if u == nil {
+		return
+	}
+	if _, ok := u.history[id]; !ok {
+		entries, had := s.history[id]
+		u.history[id] = priorHistory{entries: entries, had: had}
+	}
+}
+
+// merge adds the entries of a committed nested batch's log that u does not
+// already hold. A nil log takes nothing.
+func (u *undoLog) merge(inner *undoLog) {
+	if u == nil {
+		return
+	}
+	for id, p := range inner.beads {
+		if _, ok := u.beads[id]; !ok {
+			u.beads[id] = p
+		}
+	}
+	for id, p := range inner.history {
+		if _, ok := u.history[id]; !ok {
+			u.history[id] = p
+		}
+	}
+}
+
+// rollback puts back every bead and history recorded in the scratch store's
+// undo log, with their indexes, and stops recording.
+// Caller must hold the write lock of the store the batch runs on.
+func (s *Store) rollback() {
+	u := s.undo
+	s.undo = nil
+	for id, p := range u.beads {
+		if p.had {
+			s.put(p.bead)
+		} else {
+			s.remove(id)
+		}
+	}
+	for id, p := range u.history {
+		if p.had {
+			s.setHistory(id, p.entries)
+		} else {
+			s.dropHistory(id)
+		}
+	}
+}
+
+// collapsePuts keeps only the last put for each bead, since it carries the
+// bead's final state. Other ops are kept in order.
+func collapsePuts(ops []journalOp) journalRecord {
+	last := make(map[string]int)
+	for i, op := range ops {
+		if op.Op == "put" {
+			last[op.ID] = i
+		}
+	}
+	rec := journalRecord{Ops: make([]journalOp, 0, len(ops))}
+	for i, op := range ops {
+		if op.Op == "put" && last[op.ID] != i {
+			continue
+		}
+		rec.Ops = append(rec.Ops, op)
+	}
+	return rec
+}
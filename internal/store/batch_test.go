@@ -0,0 +1,198 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// --- Batch tests ---
+
+// openBackend opens a fresh backend of the given kind at a temp path and
+// returns it with a function that reopens the same data.
+func openBackend(t *testing.T, kind string) (Backend, func() Backend) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "beads."+kind)
+	open := func() Backend {
+		s, err := Open(kind, path)
+		if err != nil {
+			t.Fatalf("Open %s: %v", kind, err)
+		}
+		t.Cleanup(func() { s.Close() })
+		return s
+	}
+	return open(), open
+}
+
+func TestBatch_CommitsAllChanges(t *testing.T) {
+	for _, kind := range []string{BackendJSON, BackendSQLite} {
+		t.Run(kind, func(t *testing.T) {
+			s, reopen := openBackend(t, kind)
+			existing := mustCreate(t, s, model.NewBead("Existing"))
+
+			var epic, child model.Bead
+			err := s.Batch(func(tx Backend) error {
+				var err error
+				if epic, err = tx.Create(model.NewBead("Epic")); err != nil {
+					return err
+				}
+				if child, err = tx.CreateWithParent(model.NewBead("Child"), epic.ID); err != nil {
+					return err
+				}
+				if _, err = tx.Link(child.ID, existing.ID); err != nil {
+					return err
+				}
+				return tx.RecordHistory(child.ID, model.HistoryEntry{Actor: "a", Action: model.ActionLinked})
+			})
+			if err != nil {
+				t.Fatalf("Batch: %v", err)
+			}
+
+			for _, st := range []Backend{s, reopen()} {
+				got, err := st.Get(child.ID)
+				if err != nil {
+					t.Fatalf("Get child: %v", err)
+				}
+				if got.ParentID != epic.ID || len(got.BlockedBy) != 1 || got.BlockedBy[0] != existing.ID {
+					t.Errorf("child = %+v", got)
+				}
+				if h, _ := st.History(child.ID); len(h) != 1 {
+					t.Errorf("child history = %v, want 1 entry", h)
+				}
+			}
+		})
+	}
+}
+
+func TestBatch_FailureLeavesStoreUnchanged(t *testing.T) {
+	for _, kind := range []string{BackendJSON, BackendSQLite} {
+		t.Run(kind, func(t *testing.T) {
+			s, reopen := openBackend(t, kind)
+			existing := mustCreate(t, s, model.NewBead("Existing"))
+
+			var created model.Bead
+			err := s.Batch(func(tx Backend) error {
+				var err error
+				if created, err = tx.Create(model.NewBead("Doomed")); err != nil {
+					return err
+				}
+				title := "Renamed"
+				if _, err = tx.Update(existing.ID, UpdateFields{Title: &title}); err != nil {
+					return err
+				}
+				_, err = tx.Link(existing.ID, "bd-missing")
+				return err
+			})
+			var nf *NotFoundError
+			if !errors.As(err, &nf) {
+				t.Fatalf("Batch err = %v, want NotFoundError", err)
+			}
+
+			for _, st := range []Backend{s, reopen()} {
+				if _, err := st.Get(created.ID); err == nil {
+					t.Errorf("bead created in failed batch exists")
+				}
+				if got, _ := st.Get(existing.ID); got.Title != "Existing" || got.Revision != 1 {
+					t.Errorf("existing bead changed by failed batch: %+v", got)
+				}
+			}
+
+			// The store still accepts writes afterwards.
+			mustCreate(t, s, model.NewBead("After"))
+		})
+	}
+}
+
+func TestBatch_FailureRestoresIndexesAndHistory(t *testing.T) {
+	for _, kind := range []string{BackendJSON, BackendSQLite} {
+		t.Run(kind, func(t *testing.T) {
+			s, _ := openBackend(t, kind)
+			epic := mustCreate(t, s, model.NewBead("Epic"))
+			child, err := s.CreateWithParent(model.NewBead("Child"), epic.ID)
+			if err != nil {
+				t.Fatalf("CreateWithParent: %v", err)
+			}
+			if err := s.RecordHistory(child.ID, model.HistoryEntry{Actor: "a", Action: model.ActionCreated}); err != nil {
+				t.Fatalf("RecordHistory: %v", err)
+			}
+
+			err = s.Batch(func(tx Backend) error {
+				title := "Zeppelin"
+				if _, err := tx.Update(child.ID, UpdateFields{Title: &title}); err != nil {
+					return err
+				}
+				if _, err := tx.MoveOut(child.ID); err != nil {
+					return err
+				}
+				if err := tx.RecordHistory(child.ID, model.HistoryEntry{Actor: "a", Action: model.ActionUpdated}); err != nil {
+					return err
+				}
+				// A nested batch that commits is still undone by the outer one.
+				if err := tx.Batch(func(inner Backend) error {
+					return inner.Purge(epic.ID)
+				}); err != nil {
+					return err
+				}
+				return errors.New("abort")
+			})
+			if err == nil || err.Error() != "abort" {
+				t.Fatalf("Batch err = %v, want abort", err)
+			}
+
+			if children := s.ChildrenOf(epic.ID); len(children) != 1 || children[0].ID != child.ID {
+				t.Errorf("children of epic = %v, want the child back", children)
+			}
+			if res, err := s.Search(SearchOptions{Query: "zeppelin"}); err != nil || res.Total != 0 {
+				t.Errorf("search for the rolled-back title: total=%d err=%v", res.Total, err)
+			}
+			if res, err := s.Search(SearchOptions{Query: "child"}); err != nil || res.Total != 1 {
+				t.Errorf("search for the original title: total=%d err=%v", res.Total, err)
+			}
+			if h, _ := s.History(child.ID); len(h) != 1 {
+				t.Errorf("child history = %v, want 1 entry", h)
+			}
+			if !s.IsEpic(epic.ID) {
+				t.Errorf("epic purged in a rolled-back batch is gone")
+			}
+		})
+	}
+}
+
+func TestBatch_PriorIsStateBeforeBatch(t *testing.T) {
+	for _, kind := range []string{BackendJSON, BackendSQLite} {
+		t.Run(kind, func(t *testing.T) {
+			s, _ := openBackend(t, kind)
+			a := mustCreate(t, s, model.NewBead("Before"))
+			if got, ok := s.Prior(a.ID); !ok || got.Title != "Before" {
+				t.Errorf("Prior outside a batch = %v, %v; want the bead as it is", got, ok)
+			}
+
+			err := s.Batch(func(tx Backend) error {
+				for _, title := range []string{"Middle", "After"} {
+					if _, err := tx.Update(a.ID, UpdateFields{Title: &title}); err != nil {
+						return err
+					}
+				}
+				created, err := tx.Create(model.NewBead("New"))
+				if err != nil {
+					return err
+				}
+				if got, ok := tx.Prior(a.ID); !ok || got.Title != "Before" || got.Revision != a.Revision {
+					t.Errorf("Prior in the batch = %v, %v; want the bead as the batch found it", got, ok)
+				}
+				if _, ok := tx.Prior(created.ID); ok {
+					t.Error("Prior of a bead the batch created reported a bead")
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Batch: %v", err)
+			}
+			if got, _ := s.Prior(a.ID); got.Title != "After" {
+				t.Errorf("Prior after the batch = %q, want After", got.Title)
+			}
+		})
+	}
+}
@@ -3,7 +3,7 @@ package store
 import (
 	"fmt"
 
-	"github.com/vector76/beads_server/internal/model"
+	"github.com/vector76/beads_server/model"
 )
 
 // DepsResult holds the dependency information for a bead.
@@ -16,6 +16,12 @@ type DepsResult struct {
 // Link adds blockedByID to beadID's blocked_by list.
 // Rejects self-links, non-existent/deleted targets, duplicates, and circular dependencies.
 func (s *Store) Link(beadID, blockedByID string) (model.Bead, error) {
+	return s.LinkIfRevision(beadID, blockedByID, nil)
+}
+
+// LinkIfRevision is Link, failing with a PreconditionError unless rev is nil
+// or the bead is at revision *rev.
+func (s *Store) LinkIfRevision(beadID, blockedByID string, rev *int64) (model.Bead, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -28,51 +34,64 @@ func (s *Store) Link(beadID, blockedByID string) (model.Bead, error) {
 	if !ok {
 		return model.Bead{}, &NotFoundError{Message: fmt.Sprintf("bead %s not found", beadID)}
 	}
+	if err := checkRevision(b, rev); err != nil {
+		return model.Bead{}, err
+	}
 
-	target, ok := s.beads[blockedByID]
+	target, ok := s.depLookup(blockedByID)
 	if !ok {
 		return model.Bead{}, &NotFoundError{Message: fmt.Sprintf("bead %s not found", blockedByID)}
 	}
 
-	// Reject deleted targets
-	if target.Status == model.StatusDeleted {
-		return model.Bead{}, fmt.Errorf("cannot link to deleted bead %s", blockedByID)
-	}
-
-	// Duplicate check
-	for _, id := range b.BlockedBy {
-		if id == blockedByID {
-			return model.Bead{}, fmt.Errorf("bead %s already blocked by %s", beadID, blockedByID)
-		}
+	if err := validateLink(b, target); err != nil {
+		return model.Bead{}, err
 	}
 
 	// Circular dependency check: would blockedByID be transitively blocked by beadID?
 	if s.wouldCreateCycle(beadID, blockedByID) {
-		return model.Bead{}, fmt.Errorf("circular dependency: %s is already blocked by %s (directly or transitively)", blockedByID, beadID)
+		return model.Bead{}, cycleError(beadID, blockedByID)
 	}
 
 	old := s.beads[beadID]
+	b.BlockedBy = withBlocker(b.BlockedBy, blockedByID)
+	b.Revision++
 
-	// Build a new slice to avoid mutating the shared backing array.
-	newBlocked := make([]string, len(b.BlockedBy)+1)
-	copy(newBlocked, b.BlockedBy)
-	newBlocked[len(b.BlockedBy)] = blockedByID
-	b.BlockedBy = newBlocked
-
-	s.beads[beadID] = b
+	s.put(b)
 
-	if err := s.save(); err != nil {
-		s.beads[beadID] = old
+	if err := s.persist(beadID); err != nil {
+		s.put(old)
 		return model.Bead{}, err
 	}
 
 	return b, nil
 }
 
+// ValidateLinkCycle returns an error if making beadID blocked by blockedByID
+// would create a circular dependency, following blocked_by into foreign
+// beads as Link does.
+func (s *Store) ValidateLinkCycle(beadID, blockedByID string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if beadID == blockedByID || s.wouldCreateCycle(beadID, blockedByID) {
+		return cycleError(beadID, blockedByID)
+	}
+	return nil
+}
+
+// cycleError is the error for a link that would create a circular dependency.
+func cycleError(beadID, blockedByID string) error {
+	return fmt.Errorf("circular dependency: %s is already blocked by %s (directly or transitively)", blockedByID, beadID)
+}
+
 // wouldCreateCycle checks whether adding beadID->blockedByID would create a cycle.
-// It walks the blocked_by chain starting from blockedByID to see if beadID is reachable.
 // Caller must hold s.mu.
 func (s *Store) wouldCreateCycle(beadID, blockedByID string) bool {
+	return wouldCreateCycle(beadID, blockedByID, s.depLookup)
+}
+
+// wouldCreateCycle walks the blocked_by chain starting from blockedByID to
+// see if beadID is reachable.
+func wouldCreateCycle(beadID, blockedByID string, get lookupFunc) bool {
 	visited := make(map[string]bool)
 	queue := []string{blockedByID}
 
@@ -88,7 +107,7 @@ func (s *Store) wouldCreateCycle(beadID, blockedByID string) bool {
 		}
 		visited[current] = true
 
-		if b, ok := s.beads[current]; ok {
+		if b, ok := get(current); ok {
 			queue = append(queue, b.BlockedBy...)
 		}
 	}
@@ -96,8 +115,56 @@ func (s *Store) wouldCreateCycle(beadID, blockedByID string) bool {
 	return false
 }
 
+// validateLink applies the checks shared by every backend's Link: no
+// self-links, no deleted targets, no duplicates. b and target must exist.
+func validateLink(b, target model.Bead) error {
+	if target.Status == model.StatusDeleted {
+		return fmt.Errorf("cannot link to deleted bead %s", target.ID)
+	}
+	for _, id := range b.BlockedBy {
+		if id == target.ID {
+			return fmt.Errorf("bead %s already blocked by %s", b.ID, target.ID)
+		}
+	}
+	return nil
+}
+
+// withBlocker returns a copy of blockedBy with id appended, so the shared
+// backing array is never mutated.
+func withBlocker(blockedBy []string, id string) []string {
+	out := make([]string, len(blockedBy)+1)
+	copy(out, blockedBy)
+	out[len(blockedBy)] = id
+	return out
+}
+
+// withoutBlocker returns a copy of blockedBy with id removed, and whether it
+// was present.
+func withoutBlocker(blockedBy []string, id string) ([]string, bool) {
+	idx := -1
+	for i, b := range blockedBy {
+		if b == id {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return blockedBy, false
+	}
+	out := make([]string, 0, len(blockedBy)-1)
+	out = append(out, blockedBy[:idx]...)
+	out = append(out, blockedBy[idx+1:]...)
+	return out, true
+}
+
 // Unlink removes blockedByID from beadID's blocked_by list.
 func (s *Store) Unlink(beadID, blockedByID string) (model.Bead, error) {
+	return s.UnlinkIfRevision(beadID, blockedByID, nil)
+}
+
+// UnlinkIfRevision is Unlink, failing with a PreconditionError unless rev is
+// nil or the bead is at revision *rev.
+func (s *Store) UnlinkIfRevision(beadID, blockedByID string, rev *int64) (model.Bead, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -105,31 +172,22 @@ func (s *Store) Unlink(beadID, blockedByID string) (model.Bead, error) {
 	if !ok {
 		return model.Bead{}, &NotFoundError{Message: fmt.Sprintf("bead %s not found", beadID)}
 	}
-
-	idx := -1
-	for i, id := range b.BlockedBy {
-		if id == blockedByID {
-			idx = i
-			break
-		}
+	if err := checkRevision(b, rev); err != nil {
+		return model.Bead{}, err
 	}
 
-	if idx == -1 {
+	newBlocked, found := withoutBlocker(b.BlockedBy, blockedByID)
+	if !found {
 		return model.Bead{}, fmt.Errorf("bead %s is not blocked by %s", beadID, blockedByID)
 	}
 
 	old := s.beads[beadID]
-
-	// Build a new slice to avoid mutating the shared backing array.
-	newBlocked := make([]string, 0, len(b.BlockedBy)-1)
-	newBlocked = append(newBlocked, b.BlockedBy[:idx]...)
-	newBlocked = append(newBlocked, b.BlockedBy[idx+1:]...)
 	b.BlockedBy = newBlocked
+	b.Revision++
+	s.put(b)
 
-	s.beads[beadID] = b
-
-	if err := s.save(); err != nil {
-		s.beads[beadID] = old
+	if err := s.persist(beadID); err != nil {
+		s.put(old)
 		return model.Bead{}, err
 	}
 
@@ -147,9 +205,17 @@ func (s *Store) Deps(beadID string) (DepsResult, error) {
 		return DepsResult{}, &NotFoundError{Message: fmt.Sprintf("bead %s not found", beadID)}
 	}
 
-	var active, resolved []model.Bead
+	// Inverse lookup: the beads that have beadID in their blocked_by list.
+	return buildDeps(b, s.depLookup, s.beadsIn(s.index.dependents[beadID])), nil
+}
+
+// buildDeps splits b's blockers into active and resolved, and lists the
+// non-deleted dependents as the beads b blocks.
+func buildDeps(b model.Bead, get lookupFunc, dependents []model.Bead) DepsResult {
+	active := []model.Bead{}
+	resolved := []model.Bead{}
 	for _, blockerID := range b.BlockedBy {
-		blocker, ok := s.beads[blockerID]
+		blocker, ok := get(blockerID)
 		if !ok {
 			continue
 		}
@@ -160,36 +226,19 @@ func (s *Store) Deps(beadID string) (DepsResult, error) {
 		}
 	}
 
-	// Inverse lookup: find beads that have beadID in their blocked_by list.
 	// Only include active (non-deleted) beads.
-	var blocks []model.Bead
-	for _, other := range s.beads {
-		if other.Status == model.StatusDeleted {
-			continue
+	blocks := []model.Bead{}
+	for _, other := range dependents {
+		if other.Status != model.StatusDeleted {
+			blocks = append(blocks, other)
 		}
-		for _, depID := range other.BlockedBy {
-			if depID == beadID {
-				blocks = append(blocks, other)
-				break
-			}
-		}
-	}
-
-	if active == nil {
-		active = []model.Bead{}
-	}
-	if resolved == nil {
-		resolved = []model.Bead{}
-	}
-	if blocks == nil {
-		blocks = []model.Bead{}
 	}
 
 	return DepsResult{
 		ActiveBlockers:   active,
 		ResolvedBlockers: resolved,
 		Blocks:           blocks,
-	}, nil
+	}
 }
 
 // GetUnblocked is a public wrapper around ComputeUnblocked that acquires the read lock.
@@ -199,37 +248,36 @@ func (s *Store) GetUnblocked(beadID string) []model.Bead {
 	return s.ComputeUnblocked(beadID)
 }
 
+// Dependents returns the beads whose blocked_by list contains blockerID,
+// which need not be one of the store's own beads.
+func (s *Store) Dependents(blockerID string) []model.Bead {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.beadsIn(s.index.dependents[blockerID])
+}
+
 // ComputeUnblocked finds beads that were blocked only by the given bead and are now
 // unblocked because that bead reached a terminal state (closed/deleted).
 // Caller must hold s.mu (at least RLock).
 func (s *Store) ComputeUnblocked(beadID string) []model.Bead {
-	var unblocked []model.Bead
+	return unblockedAmong(s.beadsIn(s.index.dependents[beadID]), s.depLookup)
+}
 
-	for _, b := range s.beads {
+// unblockedAmong returns the non-deleted dependents whose blockers are all
+// inactive.
+func unblockedAmong(dependents []model.Bead, get lookupFunc) []model.Bead {
+	unblocked := []model.Bead{}
+	for _, b := range dependents {
 		if b.Status == model.StatusDeleted {
 			continue
 		}
 
-		// Only consider beads that reference the changed bead
-		referencesChanged := false
-		for _, depID := range b.BlockedBy {
-			if depID == beadID {
-				referencesChanged = true
-				break
-			}
-		}
-		if !referencesChanged {
-			continue
-		}
-
 		// Check if all remaining blockers are now inactive
 		hasActiveBlocker := false
 		for _, depID := range b.BlockedBy {
-			if dep, ok := s.beads[depID]; ok {
-				if isActiveBlocker(dep.Status) {
-					hasActiveBlocker = true
-					break
-				}
+			if dep, ok := get(depID); ok && isActiveBlocker(dep.Status) {
+				hasActiveBlocker = true
+				break
 			}
 		}
 
@@ -237,10 +285,5 @@ func (s *Store) ComputeUnblocked(beadID string) []model.Bead {
 			unblocked = append(unblocked, b)
 		}
 	}
-
-	if unblocked == nil {
-		unblocked = []model.Bead{}
-	}
-
 	return unblocked
 }
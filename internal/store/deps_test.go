@@ -2,9 +2,10 @@ package store
 
 import (
 	"path/filepath"
+	"strings"
 	"testing"
 
-	"github.com/vector76/beads_server/internal/model"
+	"github.com/vector76/beads_server/model"
 )
 
 func tempStore(t *testing.T) *Store {
@@ -390,3 +391,66 @@ func TestLink_Persistence(t *testing.T) {
 		t.Fatalf("link not persisted across reload: blocked_by = %v", got.BlockedBy)
 	}
 }
+
+func TestDependentsAndPeek(t *testing.T) {
+	for _, kind := range []string{BackendJSON, BackendSQLite} {
+		t.Run(kind, func(t *testing.T) {
+			s, _ := openBackend(t, kind)
+			a := mustCreate(t, s, model.NewBead("A"))
+			b := model.NewBead("B")
+			b.BlockedBy = []string{a.ID, "bd-elsewhere"}
+			b = mustCreate(t, s, b)
+
+			if deps := s.Dependents(a.ID); len(deps) != 1 || deps[0].ID != b.ID {
+				t.Errorf("Dependents(A) = %v, want B", deps)
+			}
+			if deps := s.Dependents("bd-elsewhere"); len(deps) != 1 || deps[0].ID != b.ID {
+				t.Errorf("Dependents of a bead the store does not hold = %v, want B", deps)
+			}
+			if got, ok := s.Peek(a.ID); !ok || got.Title != "A" {
+				t.Errorf("Peek(A) = %v, %v", got, ok)
+			}
+			if _, ok := s.Peek("bd-none"); ok {
+				t.Error("Peek of an unknown ID reported a bead")
+			}
+
+			// Peek does not wait for the store's lock, which a batch holds.
+			err := s.Batch(func(tx Backend) error {
+				if _, ok := s.Peek(a.ID); !ok {
+					t.Error("Peek inside a batch did not find A")
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Batch: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateLinkCycle(t *testing.T) {
+	for _, kind := range []string{BackendJSON, BackendSQLite} {
+		t.Run(kind, func(t *testing.T) {
+			s, _ := openBackend(t, kind)
+			foreign := model.NewBead("Foreign")
+			foreign.ID = "bd-far"
+			a := mustCreate(t, s, model.NewBead("A"))
+			foreign.BlockedBy = []string{a.ID}
+			s.SetForeign(func(id string) (model.Bead, bool) { return foreign, id == foreign.ID })
+			b := model.NewBead("B")
+			b.BlockedBy = []string{foreign.ID}
+			b = mustCreate(t, s, b)
+
+			// B is blocked by the foreign bead, which is blocked by A.
+			if err := s.ValidateLinkCycle(a.ID, b.ID); err == nil || !strings.Contains(err.Error(), "circular dependency") {
+				t.Errorf("A blocked by B through a foreign bead: err = %v, want a circular dependency", err)
+			}
+			if err := s.ValidateLinkCycle(b.ID, a.ID); err != nil {
+				t.Errorf("B blocked by A: %v", err)
+			}
+			if err := s.ValidateLinkCycle(a.ID, a.ID); err == nil {
+				t.Error("expected an error for a bead blocking itself")
+			}
+		})
+	}
+}
@@ -2,32 +2,22 @@ package store
 
 import (
 	"fmt"
+	"slices"
 	"time"
 
-	"github.com/vector76/beads_server/internal/model"
+	"github.com/vector76/beads_server/model"
 )
 
 // childrenOf returns all beads whose ParentID equals the given id.
 // Caller must hold s.mu (at least RLock).
 func (s *Store) childrenOf(parentID string) []model.Bead {
-	var children []model.Bead
-	for _, b := range s.beads {
-		if b.ParentID == parentID {
-			children = append(children, b)
-		}
-	}
-	return children
+	return s.beadsIn(s.index.children[parentID])
 }
 
 // hasChildren returns true if any bead has parentID == id.
 // Caller must hold s.mu (at least RLock).
 func (s *Store) hasChildren(id string) bool {
-	for _, b := range s.beads {
-		if b.ParentID == id {
-			return true
-		}
-	}
-	return false
+	return len(s.index.children[id]) > 0
 }
 
 // IsEpic returns true if the bead with the given ID has any children.
@@ -51,7 +41,11 @@ func (s *Store) ChildrenOf(parentID string) []model.Bead {
 // deriveEpicStatus computes what the epic's status should be based on its children.
 // Caller must hold s.mu (at least RLock).
 func (s *Store) deriveEpicStatus(epicID string) model.Status {
-	children := s.childrenOf(epicID)
+	return deriveStatus(s.childrenOf(epicID))
+}
+
+// deriveStatus computes an epic's status from its children.
+func deriveStatus(children []model.Bead) model.Status {
 	if len(children) == 0 {
 		// No children => not an epic; this shouldn't be called, but return open as default.
 		return model.StatusOpen
@@ -91,30 +85,55 @@ func (s *Store) deriveEpicStatus(epicID string) model.Status {
 	return model.StatusInProgress
 }
 
-// recomputeEpicStatus recomputes and persists the derived status for the given epic.
-// If the epic has no children left, it reverts to a regular bead with status "open".
+// refreshEpic recomputes the derived status of epicID in memory, without
+// persisting it. If the epic has no children left, it reverts to a regular
+// bead with status "open". It reports whether the epic changed.
 // Caller must hold s.mu (write lock).
-func (s *Store) recomputeEpicStatus(epicID string) error {
+func (s *Store) refreshEpic(epicID string) bool {
 	epic, ok := s.beads[epicID]
 	if !ok {
-		return nil
+		return false
 	}
 
-	children := s.childrenOf(epicID)
-	if len(children) == 0 {
-		// No children remain — revert to a regular bead with status open.
-		epic.Status = model.StatusOpen
-		epic.UpdatedAt = time.Now().UTC()
-		s.beads[epicID] = epic
-		return s.save()
+	newStatus := model.StatusOpen // no children left: a regular bead again
+	if s.hasChildren(epicID) {
+		newStatus = s.deriveEpicStatus(epicID)
+		if epic.Status == newStatus {
+			return false
+		}
 	}
+	epic.Status = newStatus
+	touch(&epic, time.Now().UTC())
+	s.put(epic)
+	return true
+}
 
-	newStatus := s.deriveEpicStatus(epicID)
-	if epic.Status != newStatus {
-		epic.Status = newStatus
-		epic.UpdatedAt = time.Now().UTC()
-		s.beads[epicID] = epic
-		return s.save()
+// persistWithEpics persists ids, as persist does, after recomputing the
+// derived status of epicIDs (empty IDs are skipped). Epics that change go in
+// the same journal record as ids, so replay never applies a child's change
+// without its epic's. If the write fails the epics are put back as they
+// were; undoing the change to ids is left to the caller.
+// Caller must hold s.mu (write lock).
+func (s *Store) persistWithEpics(ids []string, epicIDs ...string) error {
+	var olds []model.Bead
+	for _, id := range epicIDs {
+		old, ok := s.beads[id]
+		if !ok || slices.Contains(ids, id) {
+			continue
+		}
+		if s.refreshEpic(id) {
+			olds = append(olds, old)
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if err := s.persist(ids...); err != nil {
+		for _, old := range olds {
+			s.put(old)
+		}
+		return err
 	}
 	return nil
 }
@@ -123,6 +142,12 @@ func (s *Store) recomputeEpicStatus(epicID string) error {
 // Caller must hold s.mu (at least RLock).
 func (s *Store) validateCreateWithParent(parentID string) error {
 	parent, ok := s.beads[parentID]
+	return validateParent(parentID, parent, ok)
+}
+
+// validateParent checks that parent (looked up by parentID) can accept a
+// new child.
+func validateParent(parentID string, parent model.Bead, ok bool) error {
 	if !ok {
 		return &NotFoundError{Message: fmt.Sprintf("parent bead %s not found", parentID)}
 	}
@@ -138,6 +163,12 @@ func (s *Store) validateCreateWithParent(parentID string) error {
 
 // CreateWithParent creates a bead with a parent_id, validating nesting constraints.
 func (s *Store) CreateWithParent(b model.Bead, parentID string) (model.Bead, error) {
+	return s.CreateWithParentExcluding(b, parentID, nil)
+}
+
+// CreateWithParentExcluding is CreateWithParent, generating an ID that
+// reserve also accepts, as CreateExcluding does.
+func (s *Store) CreateWithParentExcluding(b model.Bead, parentID string, reserve ReserveFunc) (model.Bead, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -147,21 +178,16 @@ func (s *Store) CreateWithParent(b model.Bead, parentID string) (model.Bead, err
 
 	b.ParentID = parentID
 	if b.ID == "" {
-		b.ID = s.generateUniqueID()
+		b.ID = s.generateUniqueIDExcluding(reserve)
 	} else if _, exists := s.beads[b.ID]; exists {
 		return model.Bead{}, fmt.Errorf("bead %s already exists", b.ID)
 	}
+	b.Revision = 1
 
-	s.beads[b.ID] = b
-	if err := s.save(); err != nil {
-		delete(s.beads, b.ID)
-		return model.Bead{}, err
-	}
-
-	// Recompute parent epic status (new open child may change it).
-	if err := s.recomputeEpicStatus(parentID); err != nil {
-		// Rollback the creation
-		delete(s.beads, b.ID)
+	s.put(b)
+	// A new open child may change the parent's status.
+	if err := s.persistWithEpics([]string{b.ID}, parentID); err != nil {
+		s.remove(b.ID)
 		return model.Bead{}, err
 	}
 
@@ -171,6 +197,12 @@ func (s *Store) CreateWithParent(b model.Bead, parentID string) (model.Bead, err
 // MoveInto moves a bead into an epic (sets parent_id).
 // Validates nesting constraints and parent-child blocking rules.
 func (s *Store) MoveInto(beadID, targetID string) (model.Bead, error) {
+	return s.MoveIntoIfRevision(beadID, targetID, nil)
+}
+
+// MoveIntoIfRevision is MoveInto, failing with a PreconditionError unless rev
+// is nil or the bead is at revision *rev.
+func (s *Store) MoveIntoIfRevision(beadID, targetID string, rev *int64) (model.Bead, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -178,78 +210,83 @@ func (s *Store) MoveInto(beadID, targetID string) (model.Bead, error) {
 	if !ok {
 		return model.Bead{}, &NotFoundError{Message: fmt.Sprintf("bead %s not found", beadID)}
 	}
+	if err := checkRevision(b, rev); err != nil {
+		return model.Bead{}, err
+	}
 
 	target, ok := s.beads[targetID]
 	if !ok {
 		return model.Bead{}, &NotFoundError{Message: fmt.Sprintf("bead %s not found", targetID)}
 	}
 
+	if err := validateMoveInto(b, target, s.hasChildren(beadID)); err != nil {
+		return model.Bead{}, err
+	}
+
+	oldParent := b.ParentID
+	old := b
+
+	b.ParentID = targetID
+	touch(&b, time.Now().UTC())
+	s.put(b)
+
+	// Recompute the new parent's status, and the old one's if any.
+	if err := s.persistWithEpics([]string{beadID}, targetID, oldParent); err != nil {
+		s.put(old)
+		return model.Bead{}, err
+	}
+
+	return s.beads[beadID], nil
+}
+
+// validateMoveInto checks nesting constraints and parent-child blocking
+// rules for moving b into target.
+func validateMoveInto(b, target model.Bead, sourceHasChildren bool) error {
 	if target.Status == model.StatusDeleted {
-		return model.Bead{}, fmt.Errorf("cannot move into deleted bead %s", targetID)
+		return fmt.Errorf("cannot move into deleted bead %s", target.ID)
 	}
 
 	// Source must not have children (cannot nest an epic inside another epic).
-	if s.hasChildren(beadID) {
-		return model.Bead{}, &ConflictError{Message: "cannot nest epics; bead already has children"}
+	if sourceHasChildren {
+		return &ConflictError{Message: "cannot nest epics; bead already has children"}
 	}
 
 	// Target must not itself be a child (single-level nesting).
 	if target.ParentID != "" {
-		return model.Bead{}, &ConflictError{Message: "cannot nest epics; target is already a child of another bead"}
+		return &ConflictError{Message: "cannot nest epics; target is already a child of another bead"}
 	}
 
 	// Already a child of this target?
-	if b.ParentID == targetID {
-		return model.Bead{}, &ConflictError{Message: "bead is already a child of this epic"}
+	if b.ParentID == target.ID {
+		return &ConflictError{Message: "bead is already a child of this epic"}
 	}
 
 	// Check for blocking relationships between bead and target in either direction.
 	for _, blockerID := range b.BlockedBy {
-		if blockerID == targetID {
-			return model.Bead{}, &ConflictError{
+		if blockerID == target.ID {
+			return &ConflictError{
 				Message: "cannot move into an epic that blocks or is blocked by this bead; this creates a deadlock",
 			}
 		}
 	}
 	for _, blockerID := range target.BlockedBy {
-		if blockerID == beadID {
-			return model.Bead{}, &ConflictError{
+		if blockerID == b.ID {
+			return &ConflictError{
 				Message: "cannot move into an epic that blocks or is blocked by this bead; this creates a deadlock",
 			}
 		}
 	}
-
-	oldParent := b.ParentID
-	old := b
-
-	b.ParentID = targetID
-	b.UpdatedAt = time.Now().UTC()
-	s.beads[beadID] = b
-
-	if err := s.save(); err != nil {
-		s.beads[beadID] = old
-		return model.Bead{}, err
-	}
-
-	// Recompute new parent's status.
-	if err := s.recomputeEpicStatus(targetID); err != nil {
-		s.beads[beadID] = old
-		return model.Bead{}, err
-	}
-
-	// Recompute old parent's status if applicable.
-	if oldParent != "" && oldParent != targetID {
-		if err := s.recomputeEpicStatus(oldParent); err != nil {
-			// Best effort — the move itself succeeded.
-			return b, nil
-		}
-	}
-
-	return s.beads[beadID], nil
+	return nil
 }
 
 // MoveOut detaches a bead from its parent epic (clears parent_id).
 func (s *Store) MoveOut(beadID string) (model.Bead, error) {
+	return s.MoveOutIfRevision(beadID, nil)
+}
+
+// MoveOutIfRevision is MoveOut, failing with a PreconditionError unless rev
+// is nil or the bead is at revision *rev.
+func (s *Store) MoveOutIfRevision(beadID string, rev *int64) (model.Bead, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -257,6 +294,9 @@ func (s *Store) MoveOut(beadID string) (model.Bead, error) {
 	if !ok {
 		return model.Bead{}, &NotFoundError{Message: fmt.Sprintf("bead %s not found", beadID)}
 	}
+	if err := checkRevision(b, rev); err != nil {
+		return model.Bead{}, err
+	}
 
 	if b.ParentID == "" {
 		return model.Bead{}, fmt.Errorf("bead %s has no parent", beadID)
@@ -266,17 +306,12 @@ func (s *Store) MoveOut(beadID string) (model.Bead, error) {
 	old := b
 
 	b.ParentID = ""
-	b.UpdatedAt = time.Now().UTC()
-	s.beads[beadID] = b
-
-	if err := s.save(); err != nil {
-		s.beads[beadID] = old
-		return model.Bead{}, err
-	}
+	touch(&b, time.Now().UTC())
+	s.put(b)
 
-	// Recompute old parent's status.
-	if err := s.recomputeEpicStatus(oldParent); err != nil {
-		s.beads[beadID] = old
+	// Recompute the old parent's status.
+	if err := s.persistWithEpics([]string{beadID}, oldParent); err != nil {
+		s.put(old)
 		return model.Bead{}, err
 	}
 
@@ -314,7 +349,11 @@ func (s *Store) ValidateDeleteOnEpic(beadID string) error {
 	if !s.hasChildren(beadID) {
 		return nil
 	}
-	children := s.childrenOf(beadID)
+	return validateDeleteEpic(s.childrenOf(beadID))
+}
+
+// validateDeleteEpic rejects deleting an epic while any child is still open.
+func validateDeleteEpic(children []model.Bead) error {
 	for _, c := range children {
 		if c.Status == model.StatusOpen || c.Status == model.StatusInProgress || c.Status == model.StatusNotReady {
 			return &ConflictError{Message: "cannot delete epic with open children; close or delete children first"}
@@ -337,7 +376,13 @@ func (s *Store) ValidateLinkParentChild(beadID, blockedByID string) error {
 		return nil
 	}
 
-	if a.ParentID == blockedByID || b.ParentID == beadID {
+	return validateLinkParentChild(a, b)
+}
+
+// validateLinkParentChild rejects a dependency between an epic and one of
+// its own children, in either direction.
+func validateLinkParentChild(a, b model.Bead) error {
+	if a.ParentID == b.ID || b.ParentID == a.ID {
 		return &ConflictError{
 			Message: "cannot add dependency between an epic and its own children; this creates a deadlock",
 		}
@@ -345,8 +390,9 @@ func (s *Store) ValidateLinkParentChild(beadID, blockedByID string) error {
 	return nil
 }
 
-// RecomputeParentStatus recomputes the parent epic status after a child mutation.
-// This is the public entry point called after Update/Delete operations on a child.
+// RecomputeParentStatus recomputes the parent epic status of a child. The
+// store's own mutations already keep parents up to date; this repairs an
+// epic whose status was left out of step.
 func (s *Store) RecomputeParentStatus(childID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -358,5 +404,5 @@ func (s *Store) RecomputeParentStatus(childID string) error {
 	if child.ParentID == "" {
 		return nil
 	}
-	return s.recomputeEpicStatus(child.ParentID)
+	return s.persistWithEpics(nil, child.ParentID)
 }
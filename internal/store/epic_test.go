@@ -6,7 +6,7 @@ import (
 	"testing"
 	"time"
 
-	"github.com/vector76/beads_server/internal/model"
+	"github.com/vector76/beads_server/model"
 )
 
 // --- CreateWithParent tests ---
@@ -1094,7 +1094,7 @@ func TestSearch_IncludesParentContext(t *testing.T) {
 	child.Description = "Fix the login authentication issue"
 	s.CreateWithParent(child, epic.ID)
 
-	result := s.Search("login", 1, 100)
+	result := mustSearch(t, s, SearchOptions{Query: "login", Page: 1, PerPage: 100})
 	if result.Total != 1 {
 		t.Fatalf("expected 1 result, got %d", result.Total)
 	}
@@ -1111,7 +1111,7 @@ func TestSearch_EpicShowsIsEpic(t *testing.T) {
 	epic := createBead(t, s, "Auth Rewrite")
 	s.CreateWithParent(model.NewBead("Subtask"), epic.ID)
 
-	result := s.Search("Auth", 1, 100)
+	result := mustSearch(t, s, SearchOptions{Query: "Auth", Page: 1, PerPage: 100})
 	if result.Total != 1 {
 		t.Fatalf("expected 1 result, got %d", result.Total)
 	}
@@ -1119,3 +1119,22 @@ func TestSearch_EpicShowsIsEpic(t *testing.T) {
 		t.Error("expected is_epic=true in search result")
 	}
 }
+
+func TestCreateWithParentExcluding_AsksReserve(t *testing.T) {
+	for name, s := range map[string]Backend{"json": tempStore(t), "sqlite": tempSQLite(t)} {
+		t.Run(name, func(t *testing.T) {
+			epic := mustCreate(t, s, model.NewBead("Epic"))
+			var asked []string
+			child, err := s.CreateWithParentExcluding(model.NewBead("Child"), epic.ID, func(id string) bool {
+				asked = append(asked, id)
+				return len(asked) > 3 // the first three are taken elsewhere
+			})
+			if err != nil {
+				t.Fatalf("CreateWithParentExcluding: %v", err)
+			}
+			if len(asked) != 4 || child.ID != asked[3] {
+				t.Errorf("child ID = %s after asking about %v, want the fourth", child.ID, asked)
+			}
+		})
+	}
+}
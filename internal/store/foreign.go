@@ -0,0 +1,100 @@
+package store
+
+import (
+	"sync/atomic"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// foreignLookup holds where a store finds blockers it does not contain
+// itself, such as beads of other projects on the same server. A store and
+// its batch views share one, so a batch sees the same beads.
+type foreignLookup struct {
+	fn atomic.Pointer[lookupFunc]
+}
+
+func newForeignLookup() *foreignLookup {
+	return &foreignLookup{}
+}
+
+// set replaces the lookup; nil removes it.
+func (f *foreignLookup) set(fn lookupFunc) {
+	if fn == nil {
+		f.fn.Store(nil)
+		return
+	}
+	f.fn.Store(&fn)
+}
+
+// get returns the foreign bead with the given ID, if there is one.
+func (f *foreignLookup) get(id string) (model.Bead, bool) {
+	if f == nil {
+		return model.Bead{}, false
+	}
+	fn := f.fn.Load()
+	if fn == nil {
+		return model.Bead{}, false
+	}
+	return (*fn)(id)
+}
+
+// with returns a lookupFunc that tries local first, then the foreign beads.
+// The dependency rules (readiness, block depth, cycles, Deps) use it so that
+// blocked_by may name beads held elsewhere.
+func (f *foreignLookup) with(local lookupFunc) lookupFunc {
+	return func(id string) (model.Bead, bool) {
+		if b, ok := local(id); ok {
+			return b, true
+		}
+		return f.get(id)
+	}
+}
+
+// SetForeign sets where the store looks up blockers that are not among its
+// own beads. The server points it at the other projects it serves, which
+// lets blocked_by cross projects. The function must not call back into
+// this store, and may be called while the store's lock is held; looking
+// beads up with other stores' Peek is safe.
+func (s *Store) SetForeign(foreign func(id string) (model.Bead, bool)) {
+	s.foreign.set(foreign)
+}
+
+// depLookup is the lookupFunc for following blocked_by edges: the store's
+// own beads, then foreign ones.
+// Caller must hold s.mu (at least RLock).
+func (s *Store) depLookup(id string) (model.Bead, bool) {
+	if b, ok := s.beads[id]; ok {
+		return b, true
+	}
+	return s.foreign.get(id)
+}
+
+// Peek returns the bead with the given ID, if the store holds it, without
+// waiting for s.mu: it is what other stores' foreign lookups call, possibly
+// while holding their own lock, so it must never wait on a lock that is
+// held while calling out. It may see the changes of a batch that has not
+// yet committed.
+func (s *Store) Peek(id string) (model.Bead, bool) {
+	s.beadsMu.RLock()
+	defer s.beadsMu.RUnlock()
+	b, ok := s.beads[id]
+	return b, ok
+}
+
+// SetForeign sets where the store looks up blockers that are not among its
+// own beads. See Store.SetForeign.
+func (s *SQLiteStore) SetForeign(foreign func(id string) (model.Bead, bool)) {
+	s.foreign.set(foreign)
+}
+
+// Peek returns the bead with the given ID, if the store holds it. It reads
+// through the connection pool rather than a batch's transaction and never
+// takes s.mu, so it sees committed beads only. See Store.Peek.
+func (s *SQLiteStore) Peek(id string) (model.Bead, bool) {
+	return sqlLookup(s.db)(id)
+}
+
+// depLookup is sqlLookup(q) extended with the foreign beads.
+func (s *SQLiteStore) depLookup(q querier) lookupFunc {
+	return s.foreign.with(sqlLookup(q))
+}
@@ -0,0 +1,63 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// RecordHistory appends an entry to a bead's history and persists it. The
+// entry's Seq is assigned here; a zero At is set to the current time.
+// Returns NotFoundError if the bead does not exist.
+func (s *Store) RecordHistory(beadID string, e model.HistoryEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.beads[beadID]; !ok {
+		return &NotFoundError{Message: fmt.Sprintf("bead %s not found", beadID)}
+	}
+
+	old := s.history[beadID]
+	e.Seq = len(old) + 1
+	if e.At.IsZero() {
+		e.At = time.Now().UTC()
+	}
+	s.setHistory(beadID, append(old, e))
+
+	rec := journalRecord{Ops: []journalOp{{Op: "history", ID: beadID, Entry: &e}}}
+	if err := s.appendRecord(rec); err != nil {
+		s.setHistory(beadID, old)
+		return err
+	}
+	return nil
+}
+
+// setHistory replaces a bead's history.
+// Caller must hold s.mu (write lock).
+func (s *Store) setHistory(beadID string, entries []model.HistoryEntry) {
+	s.undo.saveHistory(s, beadID)
+	s.history[beadID] = entries
+}
+
+// dropHistory deletes a bead's history.
+// Caller must hold s.mu (write lock).
+func (s *Store) dropHistory(beadID string) {
+	s.undo.saveHistory(s, beadID)
+	delete(s.history, beadID)
+}
+
+// History returns a bead's history entries, oldest first.
+// Returns NotFoundError if the bead does not exist.
+func (s *Store) History(beadID string) ([]model.HistoryEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if _, ok := s.beads[beadID]; !ok {
+		return nil, &NotFoundError{Message: fmt.Sprintf("bead %s not found", beadID)}
+	}
+
+	entries := make([]model.HistoryEntry, len(s.history[beadID]))
+	copy(entries, s.history[beadID])
+	return entries, nil
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// --- History tests ---
+
+func TestHistory_RecordAndReload(t *testing.T) {
+	path := tempPath(t)
+	s, _ := Load(path)
+	b := createBead(t, s, "Tracked")
+
+	if err := s.RecordHistory(b.ID, model.HistoryEntry{Actor: "alice", Action: model.ActionCreated}); err != nil {
+		t.Fatalf("RecordHistory: %v", err)
+	}
+	if err := s.RecordHistory(b.ID, model.HistoryEntry{Actor: "bob", Action: model.ActionUpdated}); err != nil {
+		t.Fatalf("RecordHistory: %v", err)
+	}
+
+	entries, err := s.History(b.ID)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Seq != 1 || entries[1].Seq != 2 {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+	if entries[0].At.IsZero() {
+		t.Error("expected At to be set")
+	}
+
+	s2, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	entries, _ = s2.History(b.ID)
+	if len(entries) != 2 || entries[1].Actor != "bob" {
+		t.Errorf("expected history to survive reload, got %+v", entries)
+	}
+}
+
+func TestHistory_UnknownBead(t *testing.T) {
+	s := tempStore(t)
+	if err := s.RecordHistory("bd-none", model.HistoryEntry{Action: model.ActionUpdated}); err == nil {
+		t.Error("expected error recording history for unknown bead")
+	}
+	if _, err := s.History("bd-none"); err == nil {
+		t.Error("expected error reading history for unknown bead")
+	}
+}
+
+func TestHistory_SurvivesCompactionWithoutDuplicates(t *testing.T) {
+	path := tempPath(t)
+	s, _ := Load(path)
+	b := createBead(t, s, "Tracked")
+	s.RecordHistory(b.ID, model.HistoryEntry{Action: model.ActionCreated})
+
+	// Snapshot written but journal left behind, as after a crash mid-compaction.
+	s.mu.Lock()
+	if err := s.writeSnapshot(); err != nil {
+		t.Fatalf("writeSnapshot: %v", err)
+	}
+	s.mu.Unlock()
+
+	s2, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	entries, _ := s2.History(b.ID)
+	if len(entries) != 1 {
+		t.Errorf("expected 1 entry after replay over snapshot, got %d", len(entries))
+	}
+}
+
+func TestHistory_RemovedByClean(t *testing.T) {
+	path := tempPath(t)
+	s, _ := Load(path)
+	old := model.NewBead("Old")
+	old.Status = model.StatusClosed
+	old.UpdatedAt = time.Now().UTC().Add(-48 * time.Hour)
+	created, _ := s.Create(old)
+	s.RecordHistory(created.ID, model.HistoryEntry{Action: model.ActionCreated})
+
+	if _, err := s.Clean(time.Now().UTC().Add(-24 * time.Hour)); err != nil {
+		t.Fatalf("Clean: %v", err)
+	}
+	s.mu.RLock()
+	_, ok := s.history[created.ID]
+	s.mu.RUnlock()
+	if ok {
+		t.Error("expected history of cleaned bead to be dropped")
+	}
+}
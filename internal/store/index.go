@@ -0,0 +1,166 @@
+package store
+
+import "github.com/vector76/beads_server/model"
+
+// idSet is a set of bead IDs.
+type idSet map[string]struct{}
+
+// storeIndex holds secondary indexes over a Store's beads, so that finding
+// an epic's children, a bead's dependents, or the beads with a status or tag
+// does not scan every bead. Every change to Store.beads goes through put
+// and remove, which keep the indexes in step.
+type storeIndex struct {
+	children   map[string]idSet       // parent ID -> child IDs
+	dependents map[string]idSet       // blocker ID -> IDs it blocks
+	byStatus   map[model.Status]idSet // status -> IDs
+	byTag      map[string]idSet       // tag -> IDs
+}
+
+func newStoreIndex() *storeIndex {
+	return &storeIndex{
+		children:   make(map[string]idSet),
+		dependents: make(map[string]idSet),
+		byStatus:   make(map[model.Status]idSet),
+		byTag:      make(map[string]idSet),
+	}
+}
+
+func addID[K comparable](m map[K]idSet, key K, id string) {
+	if m[key] == nil {
+		m[key] = make(idSet)
+	}
+	m[key][id] = struct{}{}
+}
+
+func removeID[K comparable](m map[K]idSet, key K, id string) {
+	delete(m[key], id)
+	if len(m[key]) == 0 {
+		delete(m, key)
+	}
+}
+
+// add indexes b.
+func (x *storeIndex) add(b model.Bead) {
+	if b.ParentID != "" {
+		addID(x.children, b.ParentID, b.ID)
+	}
+	for _, blocker := range b.BlockedBy {
+		addID(x.dependents, blocker, b.ID)
+	}
+	addID(x.byStatus, b.Status, b.ID)
+	for _, tag := range b.Tags {
+		addID(x.byTag, tag, b.ID)
+	}
+}
+
+// remove undoes add for b.
+func (x *storeIndex) remove(b model.Bead) {
+	if b.ParentID != "" {
+		removeID(x.children, b.ParentID, b.ID)
+	}
+	for _, blocker := range b.BlockedBy {
+		removeID(x.dependents, blocker, b.ID)
+	}
+	removeID(x.byStatus, b.Status, b.ID)
+	for _, tag := range b.Tags {
+		removeID(x.byTag, tag, b.ID)
+	}
+}
+
+// put stores b in s.beads, replacing any bead with its ID, and updates the
+// indexes and the text index. The map write itself also takes s.beadsMu,
+// so that Peek can read s.beads without s.mu.
+// Caller must hold s.mu (write lock).
+func (s *Store) put(b model.Bead) {
+	s.undo.saveBead(s, b.ID)
+	old, had := s.beads[b.ID]
+	if had {
+		s.index.remove(old)
+	}
+	s.beadsMu.Lock()
+	s.beads[b.ID] = b
+	s.beadsMu.Unlock()
+	s.index.add(b)
+	s.text.replace(old, had, b)
+}
+
+// remove deletes the bead with the given ID from s.beads and the indexes.
+// Caller must hold s.mu (write lock).
+func (s *Store) remove(id string) {
+	if old, ok := s.beads[id]; ok {
+		s.undo.saveBead(s, id)
+		s.index.remove(old)
+		s.text.remove(old)
+		s.beadsMu.Lock()
+		delete(s.beads, id)
+		s.beadsMu.Unlock()
+	}
+}
+
+// beadsIn returns the beads whose IDs are in ids.
+// Caller must hold s.mu (at least RLock).
+func (s *Store) beadsIn(ids idSet) []model.Bead {
+	if len(ids) == 0 {
+		return nil
+	}
+	out := make([]model.Bead, 0, len(ids))
+	for id := range ids {
+		if b, ok := s.beads[id]; ok {
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+// candidates returns the beads that may have one of statuses (any status if
+// statuses is empty) and one of tags (any tags if empty), read from
+// whichever index narrows them down most. Callers still apply the filters.
+// Caller must hold s.mu (at least RLock).
+func (s *Store) candidates(statuses map[model.Status]bool, tags []string) []model.Bead {
+	size := func(sets []idSet) int {
+		n := 0
+		for _, set := range sets {
+			n += len(set)
+		}
+		return n
+	}
+
+	var sets []idSet
+	n := len(s.beads)
+	if len(statuses) > 0 {
+		for st := range statuses {
+			sets = append(sets, s.index.byStatus[st])
+		}
+		n = size(sets)
+	}
+	if len(tags) > 0 {
+		var tagSets []idSet
+		for _, tag := range tags {
+			tagSets = append(tagSets, s.index.byTag[tag])
+		}
+		if m := size(tagSets); m < n {
+			sets, n = tagSets, m
+		}
+	}
+
+	out := make([]model.Bead, 0, n)
+	if sets == nil {
+		for _, b := range s.beads {
+			out = append(out, b)
+		}
+		return out
+	}
+	seen := make(idSet, n)
+	for _, set := range sets {
+		for id := range set {
+			if _, dup := seen[id]; dup {
+				continue
+			}
+			seen[id] = struct{}{}
+			if b, ok := s.beads[id]; ok {
+				out = append(out, b)
+			}
+		}
+	}
+	return out
+}
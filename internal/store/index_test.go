@@ -0,0 +1,210 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// checkIndex fails the test unless s's indexes match ones rebuilt from its
+// beads.
+func checkIndex(t *testing.T, s *Store) {
+	t.Helper()
+	want := newStoreIndex()
+	for _, b := range s.beads {
+		want.add(b)
+	}
+	if !reflect.DeepEqual(s.index, want) {
+		t.Fatalf("index out of step with beads:\n got %+v\nwant %+v", s.index, want)
+	}
+}
+
+func TestStoreIndex_KeptInStep(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "beads.json")
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	a := createBead(t, s, "A")
+	b := createBead(t, s, "B")
+	epic := createBead(t, s, "Epic")
+	child, err := s.CreateWithParent(model.NewBead("Child"), epic.ID)
+	if err != nil {
+		t.Fatalf("CreateWithParent: %v", err)
+	}
+	checkIndex(t, s)
+
+	tags := []string{"backend", "urgent"}
+	s.Update(a.ID, UpdateFields{Tags: &tags})
+	s.Link(b.ID, a.ID)
+	s.Link(child.ID, a.ID)
+	s.Claim(child.ID, "agent-1", time.Millisecond)
+	s.AddComment(b.ID, model.Comment{Author: "alice", Text: "hi"})
+	checkIndex(t, s)
+
+	if got := s.ChildrenOf(epic.ID); len(got) != 1 || got[0].ID != child.ID {
+		t.Errorf("ChildrenOf = %v, want the child", got)
+	}
+	if deps, _ := s.Deps(a.ID); len(deps.Blocks) != 2 {
+		t.Errorf("Deps(a).Blocks = %v, want b and the child", deps.Blocks)
+	}
+
+	s.ReleaseExpired(time.Now().Add(time.Second))
+	s.Unlink(b.ID, a.ID)
+	s.MoveOut(child.ID)
+	s.MoveInto(child.ID, epic.ID)
+	closed := model.StatusClosed
+	s.Update(a.ID, UpdateFields{Status: &closed})
+	if got := s.GetUnblocked(a.ID); len(got) != 1 || got[0].ID != child.ID {
+		t.Errorf("GetUnblocked = %v, want the child", got)
+	}
+	checkIndex(t, s)
+
+	err = s.Batch(func(tx Backend) error {
+		if err := tx.Purge(b.ID); err != nil {
+			return err
+		}
+		restored := model.NewBead("Restored")
+		restored.ID, restored.Tags, restored.Revision = "bd-rest", []string{"urgent"}, 1
+		return tx.Restore(restored, nil)
+	})
+	if err != nil {
+		t.Fatalf("Batch: %v", err)
+	}
+	s.Batch(func(tx Backend) error {
+		tx.Purge(epic.ID)
+		return fmt.Errorf("abandoned")
+	})
+	checkIndex(t, s)
+
+	if _, err := s.Clean(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Clean: %v", err)
+	}
+	checkIndex(t, s)
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	checkIndex(t, reloaded)
+	if !reflect.DeepEqual(reloaded.index, s.index) {
+		t.Errorf("index after reload differs from before")
+	}
+}
+
+// benchBeads is the size of the project the benchmarks run against.
+const benchBeads = 50_000
+
+// seedBench fills s with benchBeads beads: one in ten is an epic with four
+// children, a third are blocked by an earlier bead, and statuses and tags
+// vary.
+func seedBench(b *testing.B, s Backend) {
+	b.Helper()
+	statuses := []model.Status{model.StatusOpen, model.StatusOpen, model.StatusInProgress, model.StatusClosed, model.StatusNotReady}
+	now := time.Now().UTC()
+	err := s.Batch(func(tx Backend) error {
+		parent := ""
+		for i := 0; i < benchBeads; i++ {
+			bead := model.NewBead(fmt.Sprintf("Bead %d handles request routing", i))
+			bead.ID = fmt.Sprintf("bd-%06d", i)
+			bead.Description = "Part of the benchmark project."
+			bead.Status = statuses[i%len(statuses)]
+			bead.Tags = []string{fmt.Sprintf("tag-%d", i%20)}
+			bead.CreatedAt = now.Add(time.Duration(i) * time.Second)
+			bead.Revision = 1
+			switch {
+			case i%10 == 0:
+				parent = bead.ID
+			case i%10 <= 4:
+				bead.ParentID = parent
+			}
+			if i%3 == 0 && i > 10 {
+				bead.BlockedBy = []string{fmt.Sprintf("bd-%06d", i-7)}
+			}
+			if err := tx.Restore(bead, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Fatalf("seeding: %v", err)
+	}
+}
+
+// benchBackends opens each backend, seeded, once per benchmark.
+func benchBackends(b *testing.B) map[string]Backend {
+	b.Helper()
+	dir := b.TempDir()
+	js, err := Load(filepath.Join(dir, "beads.json"))
+	if err != nil {
+		b.Fatalf("Load: %v", err)
+	}
+	sq, err := OpenSQLite(filepath.Join(dir, "beads.db"))
+	if err != nil {
+		b.Fatalf("OpenSQLite: %v", err)
+	}
+	b.Cleanup(func() { sq.Close() })
+	backends := map[string]Backend{"json": js, "sqlite": sq}
+	for _, s := range backends {
+		seedBench(b, s)
+	}
+	return backends
+}
+
+func BenchmarkList50k(b *testing.B) {
+	cases := []struct {
+		name    string
+		filters ListFilters
+	}{
+		{"default", ListFilters{}},
+		{"ready", ListFilters{Ready: true}},
+		{"tag", ListFilters{Tags: []string{"tag-3"}}},
+		{"all", ListFilters{All: true}},
+	}
+	for name, s := range benchBackends(b) {
+		for _, c := range cases {
+			b.Run(name+"/"+c.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					s.List(c.filters)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkSearch50k(b *testing.B) {
+	cases := []struct {
+		name string
+		opts SearchOptions
+	}{
+		{"rare", SearchOptions{Query: "bead 4999"}},
+		{"common", SearchOptions{Query: "request rout*", Sort: SortRelevance}},
+		{"phrase", SearchOptions{Query: `"bead 4999 handles"`}},
+	}
+	for name, s := range benchBackends(b) {
+		for _, c := range cases {
+			b.Run(name+"/"+c.name, func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					s.Search(c.opts)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkDeps50k(b *testing.B) {
+	for name, s := range benchBackends(b) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				s.Deps("bd-000700")
+				s.GetUnblocked("bd-000700")
+			}
+		})
+	}
+}
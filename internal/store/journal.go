@@ -0,0 +1,199 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// defaultCompactThreshold is the number of journal records after which the
+// store folds the journal into a fresh snapshot.
+const defaultCompactThreshold = 1000
+
+// journalOp is a single change within a journal record. A "put" op carries
+// the full bead state, so replaying it is idempotent; a "delete" op removes
+// the bead and its history permanently (used by Clean); a "history" op
+// appends one history entry, skipped on replay if its Seq is already present.
+type journalOp struct {
+	Op    string              `json:"op"`
+	ID    string              `json:"id"`
+	Bead  *model.Bead         `json:"bead,omitempty"`
+	Entry *model.HistoryEntry `json:"entry,omitempty"`
+}
+
+// journalRecord is one line of the journal. All ops in a record belong to the
+// same mutation and are applied together on replay.
+type journalRecord struct {
+	Ops []journalOp `json:"ops"`
+}
+
+// journalPath returns the path of the write-ahead journal for a data file.
+func journalPath(dataFile string) string {
+	return dataFile + ".wal"
+}
+
+// replayJournal applies every complete record in the journal to s.beads and
+// returns the number of records applied. A torn final record (missing
+// newline or unparseable) is treated as an interrupted write and truncated
+// away; a corrupt record followed by further records is an error.
+// Caller must hold s.mu or have exclusive access to s.
+func (s *Store) replayJournal() (int, error) {
+	path := journalPath(s.filePath)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("reading journal: %w", err)
+	}
+
+	count := 0
+	offset := 0
+	for offset < len(data) {
+		end := bytes.IndexByte(data[offset:], '\n')
+		if end < 0 {
+			// No trailing newline: the final write was interrupted.
+			break
+		}
+		line := data[offset : offset+end]
+		next := offset + end + 1
+
+		var rec journalRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			if next < len(data) {
+				return 0, fmt.Errorf("parsing journal record %d: %w", count+1, err)
+			}
+			break
+		}
+		s.applyOps(rec.Ops)
+		count++
+		offset = next
+	}
+
+	if offset < len(data) {
+		if err := os.Truncate(path, int64(offset)); err != nil {
+			return 0, fmt.Errorf("truncating torn journal record: %w", err)
+		}
+	}
+
+	return count, nil
+}
+
+// applyOps applies journal ops to the in-memory map.
+// Caller must hold s.mu (write lock).
+func (s *Store) applyOps(ops []journalOp) {
+	for _, op := range ops {
+		switch op.Op {
+		case "put":
+			if op.Bead != nil {
+				s.put(*op.Bead)
+			}
+		case "delete":
+			s.remove(op.ID)
+			delete(s.history, op.ID)
+		case "history":
+			if op.Entry != nil && op.Entry.Seq > len(s.history[op.ID]) {
+				s.history[op.ID] = append(s.history[op.ID], *op.Entry)
+			}
+		}
+	}
+}
+
+// persist appends one journal record describing the current state of the
+// given bead IDs: a put for each ID present in the map and a delete for each
+// ID that is absent. The record is written with a single append and synced
+// before returning. Once enough records accumulate, the journal is compacted
+// into a snapshot.
+// Caller must hold s.mu (write lock).
+func (s *Store) persist(ids ...string) error {
+	rec := journalRecord{Ops: make([]journalOp, 0, len(ids))}
+	for _, id := range ids {
+		if b, ok := s.beads[id]; ok {
+			b := b
+			rec.Ops = append(rec.Ops, journalOp{Op: "put", ID: id, Bead: &b})
+		} else {
+			rec.Ops = append(rec.Ops, journalOp{Op: "delete", ID: id})
+		}
+	}
+	return s.appendRecord(rec)
+}
+
+// appendRecord writes rec as one journal line, syncs it, and compacts the
+// journal once enough records accumulate. On a batch's scratch store the ops
+// are only collected.
+// Caller must hold s.mu (write lock).
+func (s *Store) appendRecord(rec journalRecord) error {
+	if s.pending != nil {
+		s.pending.Ops = append(s.pending.Ops, rec.Ops...)
+		return nil
+	}
+
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("marshaling journal record: %w", err)
+	}
+	line = append(line, '\n')
+
+	f, err := os.OpenFile(journalPath(s.filePath), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("opening journal: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return fmt.Errorf("stat journal: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		// Drop any partial record so later appends stay parseable.
+		f.Truncate(info.Size())
+		f.Close()
+		return fmt.Errorf("writing journal: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Truncate(info.Size())
+		f.Close()
+		return fmt.Errorf("syncing journal: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing journal: %w", err)
+	}
+
+	s.journalRecords++
+	if s.journalRecords >= s.compactThreshold {
+		// The record is already durable, so the mutation has succeeded
+		// even if compaction fails. Rather than rewrite the snapshot on
+		// every later mutation, try again after another threshold's worth
+		// of records.
+		if err := s.compact(); err != nil {
+			log.Printf("compacting journal of %s: %v", s.filePath, err)
+			s.journalRecords = 0
+		}
+	}
+	return nil
+}
+
+// compact writes a full snapshot of the store and removes the journal.
+// A crash between the two steps is harmless: replaying put/delete records
+// over a snapshot that already contains them yields the same state.
+// Caller must hold s.mu (write lock).
+func (s *Store) compact() error {
+	if err := s.writeSnapshot(); err != nil {
+		return err
+	}
+	if err := os.Remove(journalPath(s.filePath)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing journal: %w", err)
+	}
+	s.journalRecords = 0
+	return nil
+}
+
+// Compact folds the journal into the snapshot file immediately.
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.compact()
+}
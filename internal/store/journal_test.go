@@ -0,0 +1,278 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// --- Journal tests ---
+
+func TestJournal_ReplayOnLoad(t *testing.T) {
+	path := tempPath(t)
+	s, _ := Load(path)
+
+	a := createBead(t, s, "A")
+	b := createBead(t, s, "B")
+	title := "A renamed"
+	if _, err := s.Update(a.ID, UpdateFields{Title: &title}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if _, err := s.Link(b.ID, a.ID); err != nil {
+		t.Fatalf("Link: %v", err)
+	}
+
+	// No snapshot has been written yet; state lives in the journal only.
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected no snapshot before compaction, stat err = %v", err)
+	}
+
+	s2, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	got, _ := s2.Get(a.ID)
+	if got.Title != "A renamed" {
+		t.Errorf("expected replayed title, got %q", got.Title)
+	}
+	gotB, _ := s2.Get(b.ID)
+	if len(gotB.BlockedBy) != 1 || gotB.BlockedBy[0] != a.ID {
+		t.Errorf("expected replayed link, got %v", gotB.BlockedBy)
+	}
+}
+
+func TestJournal_TornLastRecordIgnored(t *testing.T) {
+	path := tempPath(t)
+	s, _ := Load(path)
+	a := createBead(t, s, "Survivor")
+
+	// Simulate a crash mid-append: a partial record with no trailing newline.
+	f, err := os.OpenFile(journalPath(path), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatalf("open journal: %v", err)
+	}
+	f.WriteString(`{"ops":[{"op":"put","id":"bd-torn","bead":{"id":"bd-to`)
+	f.Close()
+
+	s2, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload with torn record: %v", err)
+	}
+	if _, err := s2.Get(a.ID); err != nil {
+		t.Errorf("expected committed bead to survive: %v", err)
+	}
+	if _, err := s2.Get("bd-torn"); err == nil {
+		t.Error("expected torn record to be discarded")
+	}
+
+	// The torn tail is truncated, so new appends remain parseable.
+	b := createBead(t, s2, "After crash")
+	s3, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload after append: %v", err)
+	}
+	if _, err := s3.Get(b.ID); err != nil {
+		t.Errorf("expected bead written after recovery: %v", err)
+	}
+}
+
+func TestJournal_TornLastLineWithNewline(t *testing.T) {
+	path := tempPath(t)
+	s, _ := Load(path)
+	a := createBead(t, s, "Survivor")
+
+	f, _ := os.OpenFile(journalPath(path), os.O_WRONLY|os.O_APPEND, 0644)
+	f.WriteString("{\"ops\":[\n")
+	f.Close()
+
+	s2, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if _, err := s2.Get(a.ID); err != nil {
+		t.Errorf("expected committed bead to survive: %v", err)
+	}
+}
+
+func TestJournal_CorruptMiddleRecordIsError(t *testing.T) {
+	path := tempPath(t)
+	s, _ := Load(path)
+	createBead(t, s, "First")
+
+	f, _ := os.OpenFile(journalPath(path), os.O_WRONLY|os.O_APPEND, 0644)
+	f.WriteString("garbage\n")
+	f.Close()
+	createBead(t, s, "Second")
+
+	if _, err := Load(path); err == nil {
+		t.Error("expected error for corrupt record followed by valid records")
+	}
+}
+
+func TestJournal_CompactsAtThreshold(t *testing.T) {
+	path := tempPath(t)
+	s, _ := Load(path)
+	s.compactThreshold = 3
+
+	for i := 0; i < 3; i++ {
+		createBead(t, s, "bead")
+	}
+
+	if _, err := os.Stat(journalPath(path)); !os.IsNotExist(err) {
+		t.Errorf("expected journal removed after compaction, stat err = %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected snapshot after compaction: %v", err)
+	}
+
+	createBead(t, s, "post-compaction")
+	s2, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if len(s2.All()) != 4 {
+		t.Errorf("expected 4 beads after snapshot + journal replay, got %d", len(s2.All()))
+	}
+}
+
+func TestJournal_FailedCompactionIsLoggedAndRetriedLater(t *testing.T) {
+	path := tempPath(t)
+	s, _ := Load(path)
+	s.compactThreshold = 2
+	// A directory where the snapshot should go makes the rename fail.
+	if err := os.Mkdir(path, 0755); err != nil {
+		t.Fatalf("Mkdir: %v", err)
+	}
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	createBead(t, s, "one")
+	createBead(t, s, "two")
+	if !strings.Contains(logged.String(), "compacting journal") {
+		t.Errorf("failed compaction not logged; log = %q", logged.String())
+	}
+	if s.journalRecords != 0 {
+		t.Errorf("journalRecords = %d, want 0 so the next attempt waits", s.journalRecords)
+	}
+
+	// The records stay in the journal, and the next attempt succeeds.
+	os.Remove(path)
+	createBead(t, s, "three")
+	createBead(t, s, "four")
+	if _, err := os.Stat(journalPath(path)); !os.IsNotExist(err) {
+		t.Errorf("expected journal removed after compaction, stat err = %v", err)
+	}
+	s2, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if len(s2.All()) != 4 {
+		t.Errorf("expected 4 beads, got %d", len(s2.All()))
+	}
+}
+
+// TestJournal_EpicStatusInChildRecord checks that an epic's recomputed
+// status is journaled in the same record as the child change behind it.
+func TestJournal_EpicStatusInChildRecord(t *testing.T) {
+	path := tempPath(t)
+	s, _ := Load(path)
+	epic := createBead(t, s, "Epic")
+	child, err := s.CreateWithParent(model.NewBead("Child"), epic.ID)
+	if err != nil {
+		t.Fatalf("CreateWithParent: %v", err)
+	}
+	closed := model.StatusClosed
+	if _, err := s.Update(child.ID, UpdateFields{Status: &closed}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	data, err := os.ReadFile(journalPath(path))
+	if err != nil {
+		t.Fatalf("reading journal: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	var rec journalRecord
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &rec); err != nil {
+		t.Fatalf("parsing last record: %v", err)
+	}
+	if len(rec.Ops) != 2 || rec.Ops[0].ID != child.ID || rec.Ops[1].ID != epic.ID || rec.Ops[1].Bead.Status != model.StatusClosed {
+		t.Errorf("last record = %+v, want the child and the closed epic", rec.Ops)
+	}
+	if len(lines) != 3 {
+		t.Errorf("journal has %d records, want 3: one per mutation", len(lines))
+	}
+}
+
+func TestJournal_ReplayOverSnapshotIsIdempotent(t *testing.T) {
+	path := tempPath(t)
+	s, _ := Load(path)
+	a := createBead(t, s, "A")
+
+	// Simulate a crash after the snapshot was written but before the journal
+	// was removed: the snapshot already contains every journaled change.
+	s.mu.Lock()
+	if err := s.writeSnapshot(); err != nil {
+		t.Fatalf("writeSnapshot: %v", err)
+	}
+	s.mu.Unlock()
+
+	s2, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if len(s2.All()) != 1 {
+		t.Errorf("expected 1 bead, got %d", len(s2.All()))
+	}
+	if _, err := s2.Get(a.ID); err != nil {
+		t.Errorf("Get: %v", err)
+	}
+}
+
+func TestJournal_CleanRecordsDeletes(t *testing.T) {
+	path := tempPath(t)
+	s, _ := Load(path)
+	b := model.NewBead("Old closed")
+	b.Status = model.StatusClosed
+	b.UpdatedAt = time.Now().UTC().Add(-48 * time.Hour)
+	created, _ := s.Create(b)
+	s.Compact()
+
+	if n, err := s.Clean(time.Now().UTC().Add(-24 * time.Hour)); err != nil || n != 1 {
+		t.Fatalf("Clean: n=%d err=%v", n, err)
+	}
+
+	s2, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if _, err := s2.Get(created.ID); err == nil {
+		t.Error("expected cleaned bead to stay removed after reload")
+	}
+}
+
+func TestJournal_SnapshotKeepsRevision(t *testing.T) {
+	path := tempPath(t)
+	s, _ := Load(path)
+	a := createBead(t, s, "Versioned")
+	title := "Versioned again"
+	if _, err := s.Update(a.ID, UpdateFields{Title: &title}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	s.Compact()
+
+	s2, err := Load(path)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	got, _ := s2.Get(a.ID)
+	if got.Revision != 2 {
+		t.Errorf("revision after reload = %d, want 2", got.Revision)
+	}
+}
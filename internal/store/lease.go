@@ -0,0 +1,120 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// Release describes a claim returned to open by ReleaseExpired.
+type Release struct {
+	Before model.Bead
+	After  model.Bead
+}
+
+// SystemAuthor is the comment author and history actor used for changes the
+// server makes on its own, such as releasing expired claims.
+const SystemAuthor = "system"
+
+// leaseUntil returns the expiry for a lease of the given length starting at
+// now, or nil if lease is not positive.
+func leaseUntil(now time.Time, lease time.Duration) *time.Time {
+	if lease <= 0 {
+		return nil
+	}
+	t := now.Add(lease)
+	return &t
+}
+
+// checkHeartbeat verifies that b is currently claimed by user.
+func checkHeartbeat(b model.Bead, user string) error {
+	if b.Status != model.StatusInProgress {
+		return &ConflictError{Message: fmt.Sprintf("bead %s is not claimed (status %s)", b.ID, b.Status)}
+	}
+	if b.Assignee != user {
+		return &ConflictError{Message: fmt.Sprintf("bead %s is claimed by %s, not %s", b.ID, b.Assignee, user)}
+	}
+	return nil
+}
+
+// leaseExpired reports whether b holds a claim whose lease ended before now.
+func leaseExpired(b model.Bead, now time.Time) bool {
+	return b.Status == model.StatusInProgress && b.LeaseExpiresAt != nil && b.LeaseExpiresAt.Before(now)
+}
+
+// releaseClaim returns an expired claim to open and appends a system comment
+// explaining why.
+func releaseClaim(b *model.Bead, now time.Time) {
+	text := fmt.Sprintf("Claim by %s expired at %s without a heartbeat; returned to open.",
+		b.Assignee, b.LeaseExpiresAt.UTC().Format(time.RFC3339))
+	comments := make([]model.Comment, len(b.Comments), len(b.Comments)+1)
+	copy(comments, b.Comments)
+	b.Comments = append(comments, model.Comment{Author: SystemAuthor, Text: text, CreatedAt: now})
+	b.Status = model.StatusOpen
+	b.Assignee = ""
+	b.LeaseExpiresAt = nil
+	touch(b, now)
+}
+
+// Heartbeat renews the lease on a bead claimed by user, extending
+// lease_expires_at to lease from now. It does not change updated_at.
+// Returns ConflictError if the bead is not in progress or is claimed by
+// someone else.
+func (s *Store) Heartbeat(beadID, user string, lease time.Duration) (model.Bead, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.beads[beadID]
+	if !ok {
+		return model.Bead{}, &NotFoundError{Message: fmt.Sprintf("bead %s not found", beadID)}
+	}
+	if err := checkHeartbeat(b, user); err != nil {
+		return model.Bead{}, err
+	}
+
+	old := b
+	b.LeaseExpiresAt = leaseUntil(time.Now().UTC(), lease)
+	s.put(b)
+
+	if err := s.persist(beadID); err != nil {
+		s.put(old)
+		return model.Bead{}, err
+	}
+	return b, nil
+}
+
+// ReleaseExpired returns every claim whose lease expired before now to open,
+// clearing the assignee and adding a system comment. All releases, and the
+// parent epics whose status they change, are persisted together.
+func (s *Store) ReleaseExpired(now time.Time) ([]Release, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var released []Release
+	var ids, parents []string
+	for _, b := range s.beadsIn(s.index.byStatus[model.StatusInProgress]) {
+		if !leaseExpired(b, now) {
+			continue
+		}
+		rel := Release{Before: b, After: b}
+		releaseClaim(&rel.After, now)
+		s.put(rel.After)
+		released = append(released, rel)
+		ids = append(ids, b.ID)
+		if b.ParentID != "" {
+			parents = append(parents, b.ParentID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	if err := s.persistWithEpics(ids, parents...); err != nil {
+		for _, rel := range released {
+			s.put(rel.Before)
+		}
+		return nil, err
+	}
+	return released, nil
+}
@@ -0,0 +1,124 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// --- Lease tests ---
+
+func TestClaim_SetsLease(t *testing.T) {
+	s := tempStore(t)
+	b := createBead(t, s, "Leased")
+
+	claimed, err := s.Claim(b.ID, "agent-1", time.Minute)
+	if err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if claimed.LeaseExpiresAt == nil {
+		t.Fatal("expected lease_expires_at to be set")
+	}
+	if d := time.Until(*claimed.LeaseExpiresAt); d <= 0 || d > time.Minute {
+		t.Errorf("unexpected lease remaining: %v", d)
+	}
+
+	unleased, _ := s.Claim(createBead(t, s, "No lease").ID, "agent-1", 0)
+	if unleased.LeaseExpiresAt != nil {
+		t.Error("expected no lease when lease duration is zero")
+	}
+}
+
+func TestClaim_SameUserRenewsLease(t *testing.T) {
+	s := tempStore(t)
+	b := createBead(t, s, "Leased")
+
+	first, _ := s.Claim(b.ID, "agent-1", time.Minute)
+	second, err := s.Claim(b.ID, "agent-1", time.Hour)
+	if err != nil {
+		t.Fatalf("re-claim: %v", err)
+	}
+	if !second.LeaseExpiresAt.After(*first.LeaseExpiresAt) {
+		t.Error("expected re-claim to extend the lease")
+	}
+	if !second.UpdatedAt.Equal(first.UpdatedAt) {
+		t.Error("expected re-claim not to change updated_at")
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	s := tempStore(t)
+	b := createBead(t, s, "Leased")
+	claimed, _ := s.Claim(b.ID, "agent-1", time.Minute)
+
+	renewed, err := s.Heartbeat(b.ID, "agent-1", time.Hour)
+	if err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+	if !renewed.LeaseExpiresAt.After(*claimed.LeaseExpiresAt) {
+		t.Error("expected heartbeat to extend the lease")
+	}
+	if !renewed.UpdatedAt.Equal(claimed.UpdatedAt) {
+		t.Error("expected heartbeat not to change updated_at")
+	}
+
+	if _, err := s.Heartbeat(b.ID, "agent-2", time.Hour); err == nil {
+		t.Error("expected conflict for heartbeat by another user")
+	} else if _, ok := err.(*ConflictError); !ok {
+		t.Errorf("expected ConflictError, got %T", err)
+	}
+
+	open := createBead(t, s, "Unclaimed")
+	if _, err := s.Heartbeat(open.ID, "agent-1", time.Hour); err == nil {
+		t.Error("expected conflict for heartbeat on unclaimed bead")
+	}
+}
+
+func TestReleaseExpired(t *testing.T) {
+	path := tempPath(t)
+	s, _ := Load(path)
+	expired := createBead(t, s, "Expired")
+	live := createBead(t, s, "Live")
+	s.Claim(expired.ID, "agent-1", time.Minute)
+	s.Claim(live.ID, "agent-2", time.Hour)
+
+	released, err := s.ReleaseExpired(time.Now().UTC().Add(2 * time.Minute))
+	if err != nil {
+		t.Fatalf("ReleaseExpired: %v", err)
+	}
+	if len(released) != 1 || released[0].After.ID != expired.ID {
+		t.Fatalf("expected only %s released, got %+v", expired.ID, released)
+	}
+	if released[0].Before.Assignee != "agent-1" {
+		t.Errorf("expected Before to hold the claim, got assignee %q", released[0].Before.Assignee)
+	}
+
+	s2, _ := Load(path)
+	got, _ := s2.Get(expired.ID)
+	if got.Status != model.StatusOpen || got.Assignee != "" || got.LeaseExpiresAt != nil {
+		t.Errorf("expected released bead to be open and unassigned, got %+v", got)
+	}
+	if len(got.Comments) != 1 || got.Comments[0].Author != SystemAuthor {
+		t.Errorf("expected a system comment, got %+v", got.Comments)
+	}
+	stillClaimed, _ := s2.Get(live.ID)
+	if stillClaimed.Status != model.StatusInProgress {
+		t.Errorf("expected live claim to remain, got %q", stillClaimed.Status)
+	}
+}
+
+func TestUpdate_StatusChangeClearsLease(t *testing.T) {
+	s := tempStore(t)
+	b := createBead(t, s, "Leased")
+	s.Claim(b.ID, "agent-1", time.Minute)
+
+	closed := model.StatusClosed
+	updated, err := s.Update(b.ID, UpdateFields{Status: &closed})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.LeaseExpiresAt != nil {
+		t.Error("expected lease to be cleared when the bead leaves in_progress")
+	}
+}
@@ -4,7 +4,7 @@ import (
 	"sort"
 	"time"
 
-	"github.com/vector76/beads_server/internal/model"
+	"github.com/vector76/beads_server/model"
 )
 
 // BeadSummary contains the key fields returned by list and search.
@@ -23,6 +23,9 @@ type BeadSummary struct {
 	Blocked     bool           `json:"blocked,omitempty"`
 	BlockDepth  int            `json:"block_depth,omitempty"`
 	CreatedAt   time.Time      `json:"created_at"`
+	Project     string         `json:"project,omitempty"` // set only in listings across projects
+	Score       float64        `json:"score,omitempty"`   // set only in search results
+	Matches     []SearchMatch  `json:"matches,omitempty"` // set only in search results
 }
 
 // ListFilters specifies filtering criteria for listing beads.
@@ -47,10 +50,25 @@ type ListResult struct {
 	TotalPages int           `json:"total_pages"`
 }
 
+// lookupFunc returns the bead with the given ID, if present. It lets the
+// dependency and epic rules below run against any backend.
+type lookupFunc func(id string) (model.Bead, bool)
+
+// lookup is the lookupFunc for the in-memory map.
+// Caller must hold s.mu (at least RLock).
+func (s *Store) lookup(id string) (model.Bead, bool) {
+	b, ok := s.beads[id]
+	return b, ok
+}
+
 // summaryFromBead builds a BeadSummary for b, including blocked status and depth.
 // Caller must hold s.mu (at least RLock).
 func (s *Store) summaryFromBead(b model.Bead, memo map[string]int) BeadSummary {
-	depth := s.computeBlockDepth(b, memo)
+	return newSummary(b, blockDepth(b, s.depLookup, memo))
+}
+
+// newSummary builds a BeadSummary for b with the given block depth.
+func newSummary(b model.Bead, depth int) BeadSummary {
 	return BeadSummary{
 		ID:         b.ID,
 		Title:      b.Title,
@@ -65,11 +83,10 @@ func (s *Store) summaryFromBead(b model.Bead, memo map[string]int) BeadSummary {
 	}
 }
 
-// computeBlockDepth returns the dependency depth of a bead.
+// blockDepth returns the dependency depth of a bead.
 // Depth 0 means unblocked. Depth N means blocked, where N is 1 + the max
 // depth of any active blocker (direct or inherited from parent epic).
-// Caller must hold s.mu (at least RLock).
-func (s *Store) computeBlockDepth(b model.Bead, memo map[string]int) int {
+func blockDepth(b model.Bead, get lookupFunc, memo map[string]int) int {
 	if v, ok := memo[b.ID]; ok {
 		return v
 	}
@@ -77,32 +94,23 @@ func (s *Store) computeBlockDepth(b model.Bead, memo map[string]int) int {
 	memo[b.ID] = 0
 
 	depth := 0
-	// Check own blockers.
-	for _, bid := range b.BlockedBy {
-		blocker, ok := s.beads[bid]
+	blockers := b.BlockedBy
+	// Include parent epic's blockers (inherited blocking).
+	if b.ParentID != "" {
+		if parent, ok := get(b.ParentID); ok {
+			blockers = append(blockers[:len(blockers):len(blockers)], parent.BlockedBy...)
+		}
+	}
+	for _, bid := range blockers {
+		blocker, ok := get(bid)
 		if !ok || !isActiveBlocker(blocker.Status) {
 			continue
 		}
-		d := s.computeBlockDepth(blocker, memo) + 1
+		d := blockDepth(blocker, get, memo) + 1
 		if d > depth {
 			depth = d
 		}
 	}
-	// Check parent epic's blockers (inherited blocking).
-	if b.ParentID != "" {
-		if parent, ok := s.beads[b.ParentID]; ok {
-			for _, bid := range parent.BlockedBy {
-				blocker, ok := s.beads[bid]
-				if !ok || !isActiveBlocker(blocker.Status) {
-					continue
-				}
-				d := s.computeBlockDepth(blocker, memo) + 1
-				if d > depth {
-					depth = d
-				}
-			}
-		}
-	}
 
 	memo[b.ID] = depth
 	return depth
@@ -154,7 +162,7 @@ func (s *Store) List(filters ListFilters) ListResult {
 // Used for --ready and --mine modes.
 func (s *Store) listFlat(filters ListFilters, statusSet map[model.Status]bool) ListResult {
 	var matched []model.Bead
-	for _, b := range s.beads {
+	for _, b := range s.candidates(statusSet, filters.Tags) {
 		// Skip epics in flat mode — they are containers, not claimable.
 		if s.hasChildren(b.ID) {
 			continue
@@ -202,7 +210,7 @@ func (s *Store) listHierarchical(filters ListFilters, statusSet map[model.Status
 	// Collect top-level items: standalone beads and epics.
 	// Children are excluded from top-level and nested under their parent.
 	var topLevel []model.Bead
-	for _, b := range s.beads {
+	for _, b := range s.candidates(statusSet, filters.Tags) {
 		// Skip children — they will be nested under their parent.
 		if b.ParentID != "" {
 			continue
@@ -311,30 +319,28 @@ func (s *Store) matchesFilters(b model.Bead, statusSet map[model.Status]bool, fi
 // either directly or inherited from its parent epic.
 // Caller must hold s.mu (at least RLock).
 func (s *Store) hasActiveBlocker(b model.Bead) bool {
-	// Check own blockers
-	for _, blockerID := range b.BlockedBy {
-		if blocker, ok := s.beads[blockerID]; ok {
-			if isActiveBlocker(blocker.Status) {
-				return true
-			}
+	return hasActiveBlocker(b, s.depLookup)
+}
+
+// hasActiveBlocker reports whether any of b's own or inherited (parent
+// epic) blockers is still active.
+func hasActiveBlocker(b model.Bead, get lookupFunc) bool {
+	blockers := b.BlockedBy
+	if b.ParentID != "" {
+		if parent, ok := get(b.ParentID); ok {
+			blockers = append(blockers[:len(blockers):len(blockers)], parent.BlockedBy...)
 		}
 	}
-	// Check parent epic's blockers
-	if b.ParentID != "" {
-		if parent, ok := s.beads[b.ParentID]; ok {
-			for _, blockerID := range parent.BlockedBy {
-				if blocker, ok := s.beads[blockerID]; ok {
-					if isActiveBlocker(blocker.Status) {
-						return true
-					}
-				}
-			}
+	for _, blockerID := range blockers {
+		if blocker, ok := get(blockerID); ok && isActiveBlocker(blocker.Status) {
+			return true
 		}
 	}
 	return false
 }
 
-// sortBeads sorts beads by priority (critical first), then created_at (newest first).
+// sortBeads sorts beads by priority (critical first), then created_at (newest
+// first), then ID so the order is stable across calls.
 func sortBeads(beads []model.Bead) {
 	sort.Slice(beads, func(i, j int) bool {
 		ri := beads[i].Priority.Rank()
@@ -342,7 +348,10 @@ func sortBeads(beads []model.Bead) {
 		if ri != rj {
 			return ri < rj
 		}
-		return beads[j].CreatedAt.Before(beads[i].CreatedAt)
+		if !beads[i].CreatedAt.Equal(beads[j].CreatedAt) {
+			return beads[j].CreatedAt.Before(beads[i].CreatedAt)
+		}
+		return beads[i].ID < beads[j].ID
 	})
 }
 
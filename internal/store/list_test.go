@@ -1,10 +1,11 @@
 package store
 
 import (
+	"slices"
 	"testing"
 	"time"
 
-	"github.com/vector76/beads_server/internal/model"
+	"github.com/vector76/beads_server/model"
 )
 
 func newBeadWithFields(id, title string, status model.Status, priority model.Priority, beadType model.BeadType, assignee string, tags []string, blockedBy []string, createdAt time.Time) model.Bead {
@@ -739,3 +740,36 @@ func TestSummaryBlocked_MixedResolvedDirectAndActiveParent(t *testing.T) {
 		t.Error("expected Blocked=true: parent epic has active blocker even though direct blocker is resolved")
 	}
 }
+
+func TestMergeLists(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	sum := func(id string, p model.Priority, minute int) BeadSummary {
+		return BeadSummary{ID: id, Priority: p, CreatedAt: base.Add(time.Duration(minute) * time.Minute)}
+	}
+	a := ListResult{Beads: []BeadSummary{sum("bd-a1", model.PriorityCritical, 1), sum("bd-a2", model.PriorityLow, 5)}, Total: 2}
+	b := ListResult{Beads: []BeadSummary{sum("bd-b1", model.PriorityHigh, 3), sum("bd-b2", model.PriorityHigh, 4), sum("bd-b3", model.PriorityLow, 2)}, Total: 7}
+
+	first := MergeLists([]ListResult{a, b}, 1, 3)
+	var got []string
+	for _, s := range first.Beads {
+		got = append(got, s.ID)
+	}
+	if want := []string{"bd-a1", "bd-b2", "bd-b1"}; !slices.Equal(got, want) {
+		t.Errorf("page 1 = %v, want %v", got, want)
+	}
+	if first.Total != 9 || first.TotalPages != 3 || first.PerPage != 3 {
+		t.Errorf("page 1 totals = %+v", first)
+	}
+
+	second := MergeLists([]ListResult{a, b}, 2, 3)
+	got = nil
+	for _, s := range second.Beads {
+		got = append(got, s.ID)
+	}
+	if want := []string{"bd-a2", "bd-b3"}; !slices.Equal(got, want) {
+		t.Errorf("page 2 = %v, want %v", got, want)
+	}
+	if beyond := MergeLists([]ListResult{a, b}, 5, 3); beyond.Beads == nil || len(beyond.Beads) != 0 {
+		t.Errorf("page past the end = %+v, want an empty list", beyond.Beads)
+	}
+}
@@ -0,0 +1,58 @@
+package store
+
+import (
+	"slices"
+)
+
+// MergeLists combines List results from several stores into one page of
+// their union, in List's order. Each result must hold the first
+// page*perPage beads of its store, so that every bead of the merged page
+// is among them: List with Page 1 and PerPage page*perPage gives that.
+func MergeLists(results []ListResult, page, perPage int) ListResult {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 100
+	}
+
+	var all []BeadSummary
+	total := 0
+	for _, r := range results {
+		all = append(all, r.Beads...)
+		total += r.Total
+	}
+	slices.SortStableFunc(all, compareSummaries)
+
+	start := min((page-1)*perPage, len(all))
+	end := min(start+perPage, len(all))
+	beads := slices.Clone(all[start:end])
+	if beads == nil {
+		beads = []BeadSummary{}
+	}
+	return ListResult{
+		Beads:      beads,
+		Page:       page,
+		PerPage:    perPage,
+		Total:      total,
+		TotalPages: totalPages(total, perPage),
+	}
+}
+
+// compareSummaries orders summaries like sortBeads: priority (critical
+// first), then created_at (newest first), then ID.
+func compareSummaries(a, b BeadSummary) int {
+	if ra, rb := a.Priority.Rank(), b.Priority.Rank(); ra != rb {
+		return ra - rb
+	}
+	if c := b.CreatedAt.Compare(a.CreatedAt); c != 0 {
+		return c
+	}
+	switch {
+	case a.ID < b.ID:
+		return -1
+	case a.ID > b.ID:
+		return 1
+	}
+	return 0
+}
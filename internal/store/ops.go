@@ -2,10 +2,9 @@ package store
 
 import (
 	"fmt"
-	"strings"
 	"time"
 
-	"github.com/vector76/beads_server/internal/model"
+	"github.com/vector76/beads_server/model"
 )
 
 // NotFoundError represents a 404 Not Found error for bead lookups.
@@ -26,80 +25,44 @@ func (e *ConflictError) Error() string {
 	return e.Message
 }
 
-// Search performs a case-insensitive substring search across title and description.
-// Deleted beads are excluded. Results use the same pagination and summary fields as List.
-func (s *Store) Search(query string, page, perPage int) ListResult {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-
-	if page < 1 {
-		page = 1
-	}
-	if perPage < 1 {
-		perPage = 100
-	}
-
-	queryLower := strings.ToLower(query)
-
-	var matched []model.Bead
-	for _, b := range s.beads {
-		if b.Status == model.StatusDeleted {
-			continue
-		}
-		titleMatch := strings.Contains(strings.ToLower(b.Title), queryLower)
-		descMatch := strings.Contains(strings.ToLower(b.Description), queryLower)
-		if titleMatch || descMatch {
-			matched = append(matched, b)
-		}
-	}
-
-	// Sort same as list: priority then created_at descending
-	sortBeads(matched)
-
-	// Pagination
-	total := len(matched)
-	totalPages := (total + perPage - 1) / perPage
-	if totalPages < 1 {
-		totalPages = 1
-	}
+// PreconditionError represents a 412 Precondition Failed error: a conditional
+// write expected a revision the bead is no longer at.
+type PreconditionError struct {
+	Message  string
+	Revision int64 // the bead's current revision
+}
 
-	start := (page - 1) * perPage
-	if start > total {
-		start = total
-	}
-	end := start + perPage
-	if end > total {
-		end = total
-	}
+func (e *PreconditionError) Error() string {
+	return e.Message
+}
 
-	pageSlice := matched[start:end]
-	memo := make(map[string]int)
-	summaries := make([]BeadSummary, len(pageSlice))
-	for i, b := range pageSlice {
-		sum := s.summaryFromBead(b, memo)
-		if b.ParentID != "" {
-			sum.ParentID = b.ParentID
-			if parent, ok := s.beads[b.ParentID]; ok {
-				sum.ParentTitle = parent.Title
-			}
-		}
-		if s.hasChildren(b.ID) {
-			sum.IsEpic = true
+// checkRevision returns a PreconditionError if rev is set and b is at a
+// different revision.
+func checkRevision(b model.Bead, rev *int64) error {
+	if rev != nil && *rev != b.Revision {
+		return &PreconditionError{
+			Message:  fmt.Sprintf("bead %s is at revision %d, not %d", b.ID, b.Revision, *rev),
+			Revision: b.Revision,
 		}
-		summaries[i] = sum
 	}
+	return nil
+}
 
-	return ListResult{
-		Beads:      summaries,
-		Page:       page,
-		PerPage:    perPage,
-		Total:      total,
-		TotalPages: totalPages,
-	}
+// touch records a change to b made at now: it sets updated_at and bumps the
+// revision.
+func touch(b *model.Bead, now time.Time) {
+	b.UpdatedAt = now
+	b.Revision++
 }
 
 // AddComment appends a comment to a bead and persists.
 func (s *Store) AddComment(beadID string, comment model.Comment) (model.Bead, error) {
+	return s.AddCommentIfRevision(beadID, comment, nil)
+}
+
+// AddCommentIfRevision is AddComment, failing with a PreconditionError unless
+// rev is nil or the bead is at revision *rev.
+func (s *Store) AddCommentIfRevision(beadID string, comment model.Comment, rev *int64) (model.Bead, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -107,16 +70,19 @@ func (s *Store) AddComment(beadID string, comment model.Comment) (model.Bead, er
 	if !ok {
 		return model.Bead{}, &NotFoundError{Message: fmt.Sprintf("bead %s not found", beadID)}
 	}
+	if err := checkRevision(b, rev); err != nil {
+		return model.Bead{}, err
+	}
 
 	comment.CreatedAt = time.Now().UTC()
 	b.Comments = append(b.Comments, comment)
-	b.UpdatedAt = time.Now().UTC()
+	touch(&b, time.Now().UTC())
 
 	old := s.beads[beadID]
-	s.beads[beadID] = b
+	s.put(b)
 
-	if err := s.save(); err != nil {
-		s.beads[beadID] = old
+	if err := s.persist(beadID); err != nil {
+		s.put(old)
 		return model.Bead{}, err
 	}
 
@@ -133,31 +99,60 @@ func (s *Store) Clean(cutoff time.Time) (int, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	removeSet := make(map[string]bool)
+	terminal := s.candidates(map[model.Status]bool{model.StatusClosed: true, model.StatusDeleted: true}, nil)
+	removeSet := selectCleanable(terminal, s.lookup, s.childrenOf, cutoff)
 
-	for id, b := range s.beads {
-		// Skip beads that aren't in a terminal state.
-		if b.Status != model.StatusClosed && b.Status != model.StatusDeleted {
-			continue
+	if len(removeSet) == 0 {
+		return 0, nil
+	}
+
+	// Backup for rollback
+	removed := make(map[string]model.Bead, len(removeSet))
+	removedIDs := make([]string, 0, len(removeSet))
+	for id := range removeSet {
+		removed[id] = s.beads[id]
+		removedIDs = append(removedIDs, id)
+		s.remove(id)
+	}
+
+	if err := s.persist(removedIDs...); err != nil {
+		// Rollback
+		for _, b := range removed {
+			s.put(b)
 		}
+		return 0, err
+	}
+	for _, id := range removedIDs {
+		s.dropHistory(id)
+	}
+
+	return len(removeSet), nil
+}
+
+// selectCleanable applies the epic-aware clean rules to the given terminal
+// (closed or deleted) beads and returns the IDs to remove.
+func selectCleanable(terminal []model.Bead, get lookupFunc, childrenOf func(string) []model.Bead, cutoff time.Time) map[string]bool {
+	removeSet := make(map[string]bool)
+
+	for _, b := range terminal {
 		// Skip children — they are handled as part of their parent epic unit.
 		// Exception: orphaned children (parent no longer exists) are cleaned individually.
 		if b.ParentID != "" {
-			if _, parentExists := s.beads[b.ParentID]; parentExists {
+			if _, parentExists := get(b.ParentID); parentExists {
 				continue
 			}
 			// Orphaned child: parent was hard-deleted. Clean individually.
 			if b.UpdatedAt.Before(cutoff) {
-				removeSet[id] = true
+				removeSet[b.ID] = true
 			}
 			continue
 		}
 
-		children := s.childrenOf(id)
+		children := childrenOf(b.ID)
 		if len(children) == 0 {
 			// Standalone bead: clean if old enough.
 			if b.UpdatedAt.Before(cutoff) {
-				removeSet[id] = true
+				removeSet[b.ID] = true
 			}
 			continue
 		}
@@ -182,79 +177,121 @@ func (s *Store) Clean(cutoff time.Time) (int, error) {
 			}
 		}
 		if latest.Before(cutoff) {
-			removeSet[id] = true
+			removeSet[b.ID] = true
 			for _, c := range children {
 				removeSet[c.ID] = true
 			}
 		}
 	}
 
-	if len(removeSet) == 0 {
-		return 0, nil
+	return removeSet
+}
+
+// Claim atomically sets a bead's status to in_progress and assignee to the given user.
+// A positive lease sets lease_expires_at that far in the future; zero means the
+// claim never expires.
+// Returns ConflictError if the bead is already claimed by a different user, in a terminal state, or not_ready.
+// Idempotent: claiming a bead already claimed by the same user succeeds and renews its lease.
+func (s *Store) Claim(beadID, user string, lease time.Duration) (model.Bead, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.beads[beadID]
+	if !ok {
+		return model.Bead{}, &NotFoundError{Message: fmt.Sprintf("bead %s not found", beadID)}
 	}
 
-	// Backup for rollback
-	removed := make(map[string]model.Bead, len(removeSet))
-	for id := range removeSet {
-		removed[id] = s.beads[id]
-		delete(s.beads, id)
+	done, err := checkClaim(b, user)
+	if err != nil {
+		return model.Bead{}, err
+	}
+	if done && lease <= 0 {
+		return b, nil
 	}
 
-	if err := s.save(); err != nil {
-		// Rollback
-		for id, b := range removed {
-			s.beads[id] = b
-		}
-		return 0, err
+	// Perform the claim
+	now := time.Now().UTC()
+	if !done {
+		b.Status = model.StatusInProgress
+		b.Assignee = user
+		touch(&b, now)
 	}
+	b.LeaseExpiresAt = leaseUntil(now, lease)
 
-	return len(removeSet), nil
+	old := s.beads[beadID]
+	s.put(b)
+
+	if err := s.persist(beadID); err != nil {
+		s.put(old)
+		return model.Bead{}, err
+	}
+
+	return b, nil
 }
 
-// Claim atomically sets a bead's status to in_progress and assignee to the given user.
-// Returns ConflictError if the bead is already claimed by a different user, in a terminal state, or not_ready.
-// Idempotent: claiming a bead already claimed by the same user succeeds.
-func (s *Store) Claim(beadID, user string) (model.Bead, error) {
+// ClaimNext claims the first ready bead matching filters for user, choosing
+// and claiming under a single lock so concurrent callers never receive the
+// same bead. Candidates and their order are those of List with Ready set;
+// Statuses, All and pagination in filters are ignored. Returns a
+// NotFoundError when no bead is ready. before is the bead as it was prior to
+// the claim.
+func (s *Store) ClaimNext(filters ListFilters, user string, lease time.Duration) (before, claimed model.Bead, err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	b, ok := s.beads[beadID]
-	if !ok {
-		return model.Bead{}, &NotFoundError{Message: fmt.Sprintf("bead %s not found", beadID)}
+	filters.Ready = true
+	statusSet := map[model.Status]bool{model.StatusOpen: true}
+
+	var ready []model.Bead
+	for _, b := range s.candidates(statusSet, filters.Tags) {
+		if s.hasChildren(b.ID) {
+			continue
+		}
+		if s.matchesFilters(b, statusSet, filters) {
+			ready = append(ready, b)
+		}
+	}
+	if len(ready) == 0 {
+		return model.Bead{}, model.Bead{}, &NotFoundError{Message: "no ready bead matches the filters"}
 	}
+	sortBeads(ready)
+
+	old := ready[0]
+	b := old
+	now := time.Now().UTC()
+	b.Status = model.StatusInProgress
+	b.Assignee = user
+	touch(&b, now)
+	b.LeaseExpiresAt = leaseUntil(now, lease)
+	s.put(b)
+
+	if err := s.persist(b.ID); err != nil {
+		s.put(old)
+		return model.Bead{}, model.Bead{}, err
+	}
+
+	return old, b, nil
+}
 
+// checkClaim validates a claim of b by user. It returns done=true when the
+// bead is already claimed by the same user (idempotent success), or a
+// ConflictError when the claim must be rejected.
+func checkClaim(b model.Bead, user string) (done bool, err error) {
 	// Check terminal states and not_ready
 	switch b.Status {
 	case model.StatusClosed, model.StatusDeleted, model.StatusNotReady:
-		return model.Bead{}, &ConflictError{
-			Message: fmt.Sprintf("bead %s has status %s and cannot be claimed", beadID, b.Status),
+		return false, &ConflictError{
+			Message: fmt.Sprintf("bead %s has status %s and cannot be claimed", b.ID, b.Status),
 		}
 	}
 
 	// Check if already in_progress and assigned to different user
 	if b.Status == model.StatusInProgress && b.Assignee != "" && b.Assignee != user {
-		return model.Bead{}, &ConflictError{
-			Message: fmt.Sprintf("bead %s is already claimed by %s", beadID, b.Assignee),
+		return false, &ConflictError{
+			Message: fmt.Sprintf("bead %s is already claimed by %s", b.ID, b.Assignee),
 		}
 	}
 
 	// Idempotent: already claimed by same user
-	if b.Status == model.StatusInProgress && b.Assignee == user {
-		return b, nil
-	}
-
-	// Perform the claim
-	b.Status = model.StatusInProgress
-	b.Assignee = user
-	b.UpdatedAt = time.Now().UTC()
-
-	old := s.beads[beadID]
-	s.beads[beadID] = b
-
-	if err := s.save(); err != nil {
-		s.beads[beadID] = old
-		return model.Bead{}, err
-	}
-
-	return b, nil
+	return b.Status == model.StatusInProgress && b.Assignee == user, nil
 }
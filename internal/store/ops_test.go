@@ -5,7 +5,7 @@ import (
 	"testing"
 	"time"
 
-	"github.com/vector76/beads_server/internal/model"
+	"github.com/vector76/beads_server/model"
 )
 
 // --- Search tests ---
@@ -17,7 +17,7 @@ func TestSearchMatchesTitle(t *testing.T) {
 	b := newBeadWithFields("bd-srch0001", "Fix login bug", model.StatusOpen, model.PriorityMedium, model.TypeBug, "", nil, nil, now)
 	s.Create(b)
 
-	result := s.Search("login", 1, 100)
+	result := mustSearch(t, s, SearchOptions{Query: "login", Page: 1, PerPage: 100})
 	if result.Total != 1 {
 		t.Fatalf("expected 1 result, got %d", result.Total)
 	}
@@ -34,7 +34,7 @@ func TestSearchMatchesDescription(t *testing.T) {
 	b.Description = "Users cannot authenticate properly"
 	s.Create(b)
 
-	result := s.Search("authenticate", 1, 100)
+	result := mustSearch(t, s, SearchOptions{Query: "authenticate", Page: 1, PerPage: 100})
 	if result.Total != 1 {
 		t.Fatalf("expected 1 result, got %d", result.Total)
 	}
@@ -50,12 +50,12 @@ func TestSearchCaseInsensitive(t *testing.T) {
 	b := newBeadWithFields("bd-srch0001", "Fix LOGIN Bug", model.StatusOpen, model.PriorityMedium, model.TypeBug, "", nil, nil, now)
 	s.Create(b)
 
-	result := s.Search("login", 1, 100)
+	result := mustSearch(t, s, SearchOptions{Query: "login", Page: 1, PerPage: 100})
 	if result.Total != 1 {
 		t.Errorf("expected 1 result for case-insensitive search, got %d", result.Total)
 	}
 
-	result = s.Search("LOGIN", 1, 100)
+	result = mustSearch(t, s, SearchOptions{Query: "LOGIN", Page: 1, PerPage: 100})
 	if result.Total != 1 {
 		t.Errorf("expected 1 result for uppercase search, got %d", result.Total)
 	}
@@ -68,7 +68,7 @@ func TestSearchExcludesDeleted(t *testing.T) {
 	b := newBeadWithFields("bd-srch0001", "Deleted task", model.StatusDeleted, model.PriorityMedium, model.TypeTask, "", nil, nil, now)
 	s.Create(b)
 
-	result := s.Search("Deleted", 1, 100)
+	result := mustSearch(t, s, SearchOptions{Query: "Deleted", Page: 1, PerPage: 100})
 	if result.Total != 0 {
 		t.Errorf("expected 0 results (deleted excluded), got %d", result.Total)
 	}
@@ -81,7 +81,7 @@ func TestSearchNoResults(t *testing.T) {
 	b := newBeadWithFields("bd-srch0001", "Some task", model.StatusOpen, model.PriorityMedium, model.TypeTask, "", nil, nil, now)
 	s.Create(b)
 
-	result := s.Search("nonexistent", 1, 100)
+	result := mustSearch(t, s, SearchOptions{Query: "nonexistent", Page: 1, PerPage: 100})
 	if result.Total != 0 {
 		t.Errorf("expected 0 results, got %d", result.Total)
 	}
@@ -96,7 +96,7 @@ func TestSearchPagination(t *testing.T) {
 		s.Create(b)
 	}
 
-	result := s.Search("Searchable", 1, 2)
+	result := mustSearch(t, s, SearchOptions{Query: "Searchable", Page: 1, PerPage: 2})
 	if result.Total != 5 {
 		t.Errorf("expected total 5, got %d", result.Total)
 	}
@@ -180,7 +180,7 @@ func TestClaimSuccess(t *testing.T) {
 	b := newBeadWithFields("bd-clm00001", "Claimable", model.StatusOpen, model.PriorityMedium, model.TypeTask, "", nil, nil, now)
 	s.Create(b)
 
-	claimed, err := s.Claim("bd-clm00001", "agent-1")
+	claimed, err := s.Claim("bd-clm00001", "agent-1", 0)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -199,8 +199,8 @@ func TestClaimIdempotent(t *testing.T) {
 	b := newBeadWithFields("bd-clm00001", "Claimable", model.StatusOpen, model.PriorityMedium, model.TypeTask, "", nil, nil, now)
 	s.Create(b)
 
-	s.Claim("bd-clm00001", "agent-1")
-	claimed, err := s.Claim("bd-clm00001", "agent-1")
+	s.Claim("bd-clm00001", "agent-1", 0)
+	claimed, err := s.Claim("bd-clm00001", "agent-1", 0)
 	if err != nil {
 		t.Fatalf("expected idempotent claim to succeed, got error: %v", err)
 	}
@@ -219,8 +219,8 @@ func TestClaimConflictDifferentUser(t *testing.T) {
 	b := newBeadWithFields("bd-clm00001", "Claimable", model.StatusOpen, model.PriorityMedium, model.TypeTask, "", nil, nil, now)
 	s.Create(b)
 
-	s.Claim("bd-clm00001", "agent-1")
-	_, err := s.Claim("bd-clm00001", "agent-2")
+	s.Claim("bd-clm00001", "agent-1", 0)
+	_, err := s.Claim("bd-clm00001", "agent-2", 0)
 	if err == nil {
 		t.Fatal("expected conflict error when claiming bead owned by different user")
 	}
@@ -238,7 +238,7 @@ func TestClaimConflictTerminalClosed(t *testing.T) {
 	b := newBeadWithFields("bd-clm00001", "Closed", model.StatusClosed, model.PriorityMedium, model.TypeTask, "", nil, nil, now)
 	s.Create(b)
 
-	_, err := s.Claim("bd-clm00001", "agent-1")
+	_, err := s.Claim("bd-clm00001", "agent-1", 0)
 	if err == nil {
 		t.Fatal("expected conflict error when claiming closed bead")
 	}
@@ -256,7 +256,7 @@ func TestClaimConflictTerminalDeleted(t *testing.T) {
 	b := newBeadWithFields("bd-clm00001", "Deleted", model.StatusDeleted, model.PriorityMedium, model.TypeTask, "", nil, nil, now)
 	s.Create(b)
 
-	_, err := s.Claim("bd-clm00001", "agent-1")
+	_, err := s.Claim("bd-clm00001", "agent-1", 0)
 	if err == nil {
 		t.Fatal("expected conflict error when claiming deleted bead")
 	}
@@ -270,7 +270,7 @@ func TestClaimConflictTerminalDeleted(t *testing.T) {
 func TestClaimNotFound(t *testing.T) {
 	s, _ := Load(tempPath(t))
 
-	_, err := s.Claim("bd-nonexist", "agent-1")
+	_, err := s.Claim("bd-nonexist", "agent-1", 0)
 	if err == nil {
 		t.Fatal("expected error claiming non-existent bead")
 	}
@@ -282,6 +282,90 @@ func TestClaimNotFound(t *testing.T) {
 	}
 }
 
+// --- ClaimNext tests ---
+
+func TestClaimNext_PicksHighestPriorityReady(t *testing.T) {
+	path := tempPath(t)
+	s, _ := Load(path)
+
+	now := time.Now().UTC()
+	s.Create(newBeadWithFields("bd-nxt00001", "Low", model.StatusOpen, model.PriorityLow, model.TypeTask, "", nil, nil, now))
+	s.Create(newBeadWithFields("bd-nxt00002", "Blocker", model.StatusOpen, model.PriorityHigh, model.TypeTask, "", nil, nil, now.Add(-time.Minute)))
+	s.Create(newBeadWithFields("bd-nxt00003", "Blocked", model.StatusOpen, model.PriorityCritical, model.TypeTask, "", nil, []string{"bd-nxt00002"}, now))
+	s.Create(newBeadWithFields("bd-nxt00004", "Newer high", model.StatusOpen, model.PriorityHigh, model.TypeTask, "", nil, nil, now))
+
+	var order []string
+	for range 3 {
+		_, b, err := s.ClaimNext(ListFilters{}, "agent-1", time.Minute)
+		if err != nil {
+			t.Fatalf("ClaimNext: %v", err)
+		}
+		if b.Status != model.StatusInProgress || b.Assignee != "agent-1" || b.LeaseExpiresAt == nil {
+			t.Errorf("expected claimed bead with lease, got %+v", b)
+		}
+		order = append(order, b.ID)
+	}
+	want := []string{"bd-nxt00004", "bd-nxt00002", "bd-nxt00001"}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("claim order = %v, want %v", order, want)
+		}
+	}
+
+	// The blocker is in progress, so nothing else is ready.
+	_, _, err := s.ClaimNext(ListFilters{}, "agent-1", 0)
+	var notFoundErr *NotFoundError
+	if !errors.As(err, &notFoundErr) {
+		t.Errorf("expected NotFoundError when nothing is ready, got %v", err)
+	}
+
+	s2, _ := Load(path)
+	got, _ := s2.Get("bd-nxt00004")
+	if got.Assignee != "agent-1" {
+		t.Errorf("expected claim to persist, got assignee %q", got.Assignee)
+	}
+}
+
+func TestClaimNext_Filters(t *testing.T) {
+	s, _ := Load(tempPath(t))
+
+	now := time.Now().UTC()
+	s.Create(newBeadWithFields("bd-nxt00001", "Critical bug", model.StatusOpen, model.PriorityCritical, model.TypeBug, "", nil, nil, now))
+	s.Create(newBeadWithFields("bd-nxt00002", "Tagged task", model.StatusOpen, model.PriorityLow, model.TypeTask, "", []string{"backend"}, nil, now))
+
+	task := model.TypeTask
+	_, b, err := s.ClaimNext(ListFilters{Type: &task, Tags: []string{"backend"}}, "agent-1", 0)
+	if err != nil {
+		t.Fatalf("ClaimNext: %v", err)
+	}
+	if b.ID != "bd-nxt00002" {
+		t.Errorf("expected filtered bead bd-nxt00002, got %s", b.ID)
+	}
+
+	high := model.PriorityHigh
+	if _, _, err := s.ClaimNext(ListFilters{Priority: &high}, "agent-1", 0); err == nil {
+		t.Error("expected no match for priority high")
+	}
+}
+
+func TestClaimNext_SkipsEpics(t *testing.T) {
+	s, _ := Load(tempPath(t))
+
+	epic, _ := s.Create(model.NewBead("Epic"))
+	child, err := s.CreateWithParent(model.NewBead("Child"), epic.ID)
+	if err != nil {
+		t.Fatalf("CreateWithParent: %v", err)
+	}
+
+	_, b, err := s.ClaimNext(ListFilters{}, "agent-1", 0)
+	if err != nil {
+		t.Fatalf("ClaimNext: %v", err)
+	}
+	if b.ID != child.ID {
+		t.Errorf("expected child %s to be claimed, got %s", child.ID, b.ID)
+	}
+}
+
 // --- Clean tests ---
 
 func TestCleanRemovesOldClosedBeads(t *testing.T) {
@@ -452,7 +536,7 @@ func TestClaim_NotReadyRejected(t *testing.T) {
 	b := newBeadWithFields("bd-clm00001", "Not ready", model.StatusNotReady, model.PriorityMedium, model.TypeTask, "", nil, nil, now)
 	s.Create(b)
 
-	_, err := s.Claim("bd-clm00001", "agent-1")
+	_, err := s.Claim("bd-clm00001", "agent-1", 0)
 	if err == nil {
 		t.Fatal("expected error when claiming not_ready bead")
 	}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// NewID returns a random bead ID for which taken reports false, using the
+// same length escalation as IDs assigned on create.
+func NewID(taken func(id string) bool) string {
+	return generateID(taken)
+}
+
+// Restore writes b exactly as given, keeping its ID, timestamps and
+// revision, and replaces the bead's history with entries (renumbered from
+// 1). Any existing bead with the same ID is overwritten. It is meant for
+// imports: nothing about b is validated beyond its ID, so callers must
+// check references themselves.
+func (s *Store) Restore(b model.Bead, entries []model.HistoryEntry) error {
+	if b.ID == "" {
+		return errors.New("bead ID is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := make([]model.HistoryEntry, len(entries))
+	rec := journalRecord{Ops: []journalOp{
+		{Op: "delete", ID: b.ID},
+		{Op: "put", ID: b.ID, Bead: &b},
+	}}
+	for i, e := range entries {
+		e.Seq = i + 1
+		history[i] = e
+		rec.Ops = append(rec.Ops, journalOp{Op: "history", ID: b.ID, Entry: &history[i]})
+	}
+
+	oldBead, hadBead := s.beads[b.ID]
+	oldHistory, hadHistory := s.history[b.ID]
+	s.put(b)
+	s.dropHistory(b.ID)
+	if len(history) > 0 {
+		s.setHistory(b.ID, history)
+	}
+
+	if err := s.appendRecord(rec); err != nil {
+		s.remove(b.ID)
+		s.dropHistory(b.ID)
+		if hadBead {
+			s.put(oldBead)
+		}
+		if hadHistory {
+			s.setHistory(b.ID, oldHistory)
+		}
+		return err
+	}
+	return nil
+}
+
+// Purge permanently removes a bead and its history, as Clean does, without
+// touching its parent or dependents.
+// Returns NotFoundError if the bead does not exist.
+func (s *Store) Purge(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, ok := s.beads[id]
+	if !ok {
+		return &NotFoundError{Message: fmt.Sprintf("bead %s not found", id)}
+	}
+	history, hadHistory := s.history[id]
+	s.remove(id)
+	s.dropHistory(id)
+
+	if err := s.appendRecord(journalRecord{Ops: []journalOp{{Op: "delete", ID: id}}}); err != nil {
+		s.put(b)
+		if hadHistory {
+			s.setHistory(id, history)
+		}
+		return err
+	}
+	return nil
+}
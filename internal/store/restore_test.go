@@ -0,0 +1,88 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// --- Restore / Purge tests ---
+
+func TestRestore_KeepsBeadVerbatim(t *testing.T) {
+	for _, kind := range []string{BackendJSON, BackendSQLite} {
+		t.Run(kind, func(t *testing.T) {
+			s, reopen := openBackend(t, kind)
+			existing := mustCreate(t, s, model.NewBead("Existing"))
+			s.RecordHistory(existing.ID, model.HistoryEntry{Actor: "a", Action: model.ActionCreated})
+
+			created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+			b := model.NewBead("Imported")
+			b.ID = "bd-imp1"
+			b.ParentID = "bd-gone"
+			b.BlockedBy = []string{existing.ID}
+			b.CreatedAt, b.UpdatedAt = created, created
+			b.Revision = 7
+			entries := []model.HistoryEntry{
+				{Seq: 4, Actor: "x", Action: model.ActionCreated, At: created},
+				{Seq: 9, Actor: "y", Action: model.ActionUpdated, At: created},
+			}
+			if err := s.Restore(b, entries); err != nil {
+				t.Fatalf("Restore: %v", err)
+			}
+
+			// Restoring over an existing bead replaces its history.
+			replaced := existing
+			replaced.Title = "Replaced"
+			if err := s.Restore(replaced, nil); err != nil {
+				t.Fatalf("Restore existing: %v", err)
+			}
+
+			for _, st := range []Backend{s, reopen()} {
+				got, err := st.Get("bd-imp1")
+				if err != nil {
+					t.Fatalf("Get: %v", err)
+				}
+				if got.Revision != 7 || !got.CreatedAt.Equal(created) || got.ParentID != "bd-gone" || len(got.BlockedBy) != 1 {
+					t.Errorf("restored bead = %+v", got)
+				}
+				if h, _ := st.History("bd-imp1"); len(h) != 2 || h[0].Seq != 1 || h[1].Seq != 2 || h[1].Actor != "y" {
+					t.Errorf("restored history = %+v", h)
+				}
+				if got, _ := st.Get(existing.ID); got.Title != "Replaced" {
+					t.Errorf("existing bead = %+v", got)
+				}
+				if h, _ := st.History(existing.ID); len(h) != 0 {
+					t.Errorf("existing history = %+v, want none", h)
+				}
+				if deps, _ := st.Deps(existing.ID); len(deps.Blocks) != 1 {
+					t.Errorf("deps of existing = %+v, want it to block the restored bead", deps)
+				}
+			}
+		})
+	}
+}
+
+func TestPurge(t *testing.T) {
+	for _, kind := range []string{BackendJSON, BackendSQLite} {
+		t.Run(kind, func(t *testing.T) {
+			s, reopen := openBackend(t, kind)
+			b := mustCreate(t, s, model.NewBead("Doomed"))
+			s.RecordHistory(b.ID, model.HistoryEntry{Actor: "a", Action: model.ActionCreated})
+
+			if err := s.Purge(b.ID); err != nil {
+				t.Fatalf("Purge: %v", err)
+			}
+			var nf *NotFoundError
+			if err := s.Purge(b.ID); !errors.As(err, &nf) {
+				t.Errorf("second Purge err = %v, want NotFoundError", err)
+			}
+			for _, st := range []Backend{s, reopen()} {
+				if len(st.All()) != 0 {
+					t.Errorf("store after purge = %+v", st.All())
+				}
+			}
+		})
+	}
+}
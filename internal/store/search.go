@@ -0,0 +1,545 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// SearchField is a part of a bead that search looks in.
+type SearchField string
+
+// Search fields.
+const (
+	SearchTitle       SearchField = "title"
+	SearchDescription SearchField = "description"
+	SearchComments    SearchField = "comments"
+)
+
+// searchFields lists the fields in index order: a field's position is its
+// slot in a fieldCounts.
+var searchFields = [...]SearchField{SearchTitle, SearchDescription, SearchComments}
+
+const numSearchFields = len(searchFields)
+
+// Search orders.
+const (
+	SortPriority  = "priority"  // priority, then newest first, as List
+	SortRelevance = "relevance" // BM25 score, best first
+)
+
+// BM25 parameters, and how much a match in each field counts for.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+var fieldWeights = [numSearchFields]float64{2, 1, 1}
+
+// Snippet sizes, in words, and the most comment matches reported per bead.
+const (
+	snippetWords      = 20
+	snippetLead       = 5
+	maxCommentMatches = 3
+)
+
+// SearchOptions specifies a search. Query is a list of words, all of which
+// must match. A word ending in "*" matches any word it is a prefix of, as
+// does the last word of the query unless it is in a phrase, so that "auth"
+// finds "authentication". Words in double quotes must appear together, in
+// order. A query with no words matches nothing.
+type SearchOptions struct {
+	Query   string
+	In      []SearchField // fields to search; empty = all
+	Sort    string        // SortPriority (default) or SortRelevance
+	Page    int           // 1-indexed page number (default: 1)
+	PerPage int           // Items per page (default: 100)
+}
+
+// SearchMatch is one place a search matched a bead, with a snippet of the
+// text around it in which matching words are marked **like this**.
+type SearchMatch struct {
+	Field   SearchField `json:"field"`
+	Comment *int        `json:"comment,omitempty"` // index into comments, for a comment match
+	Snippet string      `json:"snippet"`
+}
+
+// fieldCounts holds one count per search field.
+type fieldCounts [numSearchFields]int
+
+// fieldSet marks the search fields a search looks in.
+type fieldSet [numSearchFields]bool
+
+// token is one word of a text: lowercased, with its byte offsets.
+type token struct {
+	text       string
+	start, end int
+}
+
+// tokenize splits text into words: runs of letters and digits.
+func tokenize(text string) []token {
+	var toks []token
+	start := -1
+	for i, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			toks = append(toks, token{text: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		toks = append(toks, token{text: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return toks
+}
+
+// fieldTexts returns the text of each search field of b. Each comment is a
+// separate text, so phrases do not run from one comment into the next.
+func fieldTexts(b model.Bead) [numSearchFields][]string {
+	comments := make([]string, len(b.Comments))
+	for i, c := range b.Comments {
+		comments[i] = c.Text
+	}
+	return [numSearchFields][]string{{b.Title}, {b.Description}, comments}
+}
+
+// termCounts returns how often each term appears in each field of b, and
+// the length of each field in terms.
+func termCounts(b model.Bead) (map[string]fieldCounts, fieldCounts) {
+	terms := make(map[string]fieldCounts)
+	var lens fieldCounts
+	for f, texts := range fieldTexts(b) {
+		for _, text := range texts {
+			for _, t := range tokenize(text) {
+				c := terms[t.text]
+				c[f]++
+				terms[t.text] = c
+				lens[f]++
+			}
+		}
+	}
+	return terms, lens
+}
+
+// sameText reports whether a and b are indexed alike, so that changing one
+// into the other leaves the text index as it is.
+func sameText(a, b model.Bead) bool {
+	if a.Title != b.Title || a.Description != b.Description || len(a.Comments) != len(b.Comments) {
+		return false
+	}
+	if (a.Status == model.StatusDeleted) != (b.Status == model.StatusDeleted) {
+		return false
+	}
+	for i := range a.Comments {
+		if a.Comments[i].Text != b.Comments[i].Text {
+			return false
+		}
+	}
+	return true
+}
+
+// queryClause is one part of a search query: a word, or several that must
+// appear together in order, as in a quoted phrase. If prefix is set, the
+// last term matches any word it is a prefix of.
+type queryClause struct {
+	terms  []string
+	prefix bool
+}
+
+// matchAt reports whether c's terms appear in toks starting at toks[i].
+func (c queryClause) matchAt(toks []token, i int) bool {
+	if i+len(c.terms) > len(toks) {
+		return false
+	}
+	for j, term := range c.terms {
+		word := toks[i+j].text
+		if j == len(c.terms)-1 && c.prefix {
+			if !strings.HasPrefix(word, term) {
+				return false
+			}
+		} else if word != term {
+			return false
+		}
+	}
+	return true
+}
+
+// parse checks o and parses its query, filling in defaults.
+func (o *SearchOptions) parse() ([]queryClause, fieldSet, error) {
+	if o.Page < 1 {
+		o.Page = 1
+	}
+	if o.PerPage < 1 {
+		o.PerPage = 100
+	}
+	switch o.Sort {
+	case "":
+		o.Sort = SortPriority
+	case SortPriority, SortRelevance:
+	default:
+		return nil, fieldSet{}, fmt.Errorf("sort must be %q or %q", SortPriority, SortRelevance)
+	}
+
+	var fields fieldSet
+	for _, name := range o.In {
+		found := false
+		for f, field := range searchFields {
+			if name == field {
+				fields[f], found = true, true
+			}
+		}
+		if !found {
+			return nil, fieldSet{}, fmt.Errorf("cannot search in %q: fields are %q, %q and %q", name, SearchTitle, SearchDescription, SearchComments)
+		}
+	}
+	if len(o.In) == 0 {
+		fields = fieldSet{true, true, true}
+	}
+
+	var clauses []queryClause
+	lastIsWord := false // the last clause is a word rather than a phrase
+	add := func(text string, phrase bool) {
+		toks := tokenize(text)
+		if len(toks) == 0 {
+			return
+		}
+		c := queryClause{prefix: strings.HasSuffix(strings.TrimSpace(text), "*")}
+		for _, t := range toks {
+			c.terms = append(c.terms, t.text)
+		}
+		clauses = append(clauses, c)
+		lastIsWord = !phrase
+	}
+	// Odd-numbered parts are inside quotes; an unclosed quote runs to the end.
+	for i, part := range strings.Split(o.Query, `"`) {
+		if i%2 == 1 {
+			add(part, true)
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			add(word, false)
+		}
+	}
+	// The last word may be one still being typed.
+	if lastIsWord {
+		clauses[len(clauses)-1].prefix = true
+	}
+	return clauses, fields, nil
+}
+
+// textPosting is one bead holding a term: the term's count and the bead's
+// length in each field, and the bead's sort keys.
+type textPosting struct {
+	tf, lens fieldCounts
+	rank     int   // priority rank
+	created  int64 // created_at, in Unix nanoseconds
+}
+
+// textCorpus is a backend's text index, as search reads it.
+type textCorpus interface {
+	// textStats returns the number of indexed beads and the total length
+	// of each field across them.
+	textStats() (int, fieldCounts, error)
+	// docFreqs returns how many beads hold term or, if prefix is set, each
+	// indexed term starting with it.
+	docFreqs(term string, prefix bool) (map[string]int, error)
+	// postings calls visit for each bead that holds term, or each term
+	// starting with it. If ids is not nil, only those beads are visited.
+	postings(term string, prefix bool, ids []string, visit func(term, id string, p textPosting)) error
+	// textBead returns an indexed bead, to check phrases against.
+	textBead(id string) (model.Bead, bool)
+}
+
+// maxRestrict is the most candidate beads a postings lookup is narrowed
+// to; past that, reading a term's whole posting list is cheaper.
+const maxRestrict = 500
+
+// searchHit is a bead that matched a search.
+type searchHit struct {
+	id      string
+	score   float64
+	rank    int
+	created int64
+	step    int // the last term lookup that found the bead
+}
+
+// clauseTerm is one term position of a clause, with the document
+// frequency of each indexed term it stands for.
+type clauseTerm struct {
+	term   string
+	prefix bool
+	dfs    map[string]int
+	size   int // beads holding any of the terms, at most
+}
+
+// runSearch returns the beads in c that match every clause within fields,
+// scored with BM25 and sorted by sortBy. Clauses and terms are looked up
+// rarest first, so a common word only needs reading for the beads the rare
+// ones already matched.
+func runSearch(c textCorpus, clauses []queryClause, fields fieldSet, sortBy string) ([]searchHit, error) {
+	if len(clauses) == 0 {
+		return []searchHit{}, nil
+	}
+	n, total, err := c.textStats()
+	if err != nil {
+		return nil, err
+	}
+	var avg [numSearchFields]float64
+	for f := range total {
+		avg[f] = 1
+		if n > 0 && total[f] > 0 {
+			avg[f] = float64(total[f]) / float64(n)
+		}
+	}
+	// score is a term's BM25 score for one bead, summed over fields.
+	score := func(df int, p textPosting) float64 {
+		idf := math.Log(1 + (float64(n)-float64(df)+0.5)/(float64(df)+0.5))
+		s := 0.0
+		for f, tf := range p.tf {
+			if !fields[f] || tf == 0 {
+				continue
+			}
+			norm := bm25K1 * (1 - bm25B + bm25B*float64(p.lens[f])/avg[f])
+			s += fieldWeights[f] * idf * float64(tf) * (bm25K1 + 1) / (float64(tf) + norm)
+		}
+		return s
+	}
+	inFields := func(p textPosting) bool {
+		for f, tf := range p.tf {
+			if fields[f] && tf > 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	plans := make([][]clauseTerm, len(clauses))
+	sizes := make([]int, len(clauses))
+	for i, clause := range clauses {
+		sizes[i] = n
+		for j, term := range clause.terms {
+			ct := clauseTerm{term: term, prefix: clause.prefix && j == len(clause.terms)-1}
+			if ct.dfs, err = c.docFreqs(ct.term, ct.prefix); err != nil {
+				return nil, err
+			}
+			for _, df := range ct.dfs {
+				ct.size += df
+			}
+			plans[i] = append(plans[i], ct)
+			sizes[i] = min(sizes[i], ct.size)
+		}
+		sort.SliceStable(plans[i], func(a, b int) bool { return plans[i][a].size < plans[i][b].size })
+	}
+	order := make([]int, len(clauses))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return sizes[order[a]] < sizes[order[b]] })
+
+	// hits holds the beads holding every term looked up so far; nil before
+	// the first. Each lookup adds to their scores and drops those it did
+	// not find.
+	var hits map[string]*searchHit
+	var phrases []queryClause
+	step := 0
+	for _, i := range order {
+		if len(clauses[i].terms) > 1 {
+			phrases = append(phrases, clauses[i])
+		}
+		for _, ct := range plans[i] {
+			step++
+			first := hits == nil
+			if first {
+				hits = make(map[string]*searchHit, ct.size)
+			}
+			var ids []string
+			if !first && len(hits) <= maxRestrict {
+				ids = make([]string, 0, len(hits))
+				for id := range hits {
+					ids = append(ids, id)
+				}
+			}
+			err := c.postings(ct.term, ct.prefix, ids, func(term, id string, p textPosting) {
+				if !inFields(p) {
+					return
+				}
+				h := hits[id]
+				if h == nil {
+					if !first {
+						return
+					}
+					h = &searchHit{id: id, rank: p.rank, created: p.created}
+					hits[id] = h
+				}
+				h.step = step
+				h.score += score(ct.dfs[term], p)
+			})
+			if err != nil {
+				return nil, err
+			}
+			for id, h := range hits {
+				if h.step != step {
+					delete(hits, id)
+				}
+			}
+			if len(hits) == 0 {
+				return []searchHit{}, nil
+			}
+		}
+	}
+
+	out := make([]searchHit, 0, len(hits))
+	for id, h := range hits {
+		if len(phrases) > 0 {
+			b, ok := c.textBead(id)
+			if !ok || !matchesAll(b, phrases, fields) {
+				continue
+			}
+		}
+		// Rounding makes ties between equally good matches exact, whatever
+		// order their terms were added up in.
+		h.score = math.Round(h.score*1000) / 1000
+		out = append(out, *h)
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if sortBy == SortRelevance && a.score != b.score {
+			return a.score > b.score
+		}
+		if a.rank != b.rank {
+			return a.rank < b.rank
+		}
+		if a.created != b.created {
+			return a.created > b.created
+		}
+		return a.id < b.id
+	})
+	return out, nil
+}
+
+// matchesAll reports whether each clause matches somewhere in fields of b.
+func matchesAll(b model.Bead, clauses []queryClause, fields fieldSet) bool {
+	texts := fieldTexts(b)
+	for _, c := range clauses {
+		found := false
+		for f := range texts {
+			if !fields[f] {
+				continue
+			}
+			for _, text := range texts[f] {
+				toks := tokenize(text)
+				for i := range toks {
+					if c.matchAt(toks, i) {
+						found = true
+						break
+					}
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// searchMatches returns where the clauses match in fields of b, with a
+// snippet of each place.
+func searchMatches(b model.Bead, clauses []queryClause, fields fieldSet) []SearchMatch {
+	var out []SearchMatch
+	for f, texts := range fieldTexts(b) {
+		if !fields[f] {
+			continue
+		}
+		comments := 0
+		for i, text := range texts {
+			snippet, ok := highlight(text, clauses)
+			if !ok {
+				continue
+			}
+			m := SearchMatch{Field: searchFields[f], Snippet: snippet}
+			if searchFields[f] == SearchComments {
+				if comments == maxCommentMatches {
+					break
+				}
+				comments++
+				m.Comment = &i
+			}
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// highlight returns a snippet of text around the first place a clause
+// matches, with every matching word marked, or false if none matches.
+func highlight(text string, clauses []queryClause) (string, bool) {
+	toks := tokenize(text)
+	marked := make([]bool, len(toks))
+	first := -1
+	for i := range toks {
+		for _, c := range clauses {
+			if !c.matchAt(toks, i) {
+				continue
+			}
+			for j := range c.terms {
+				marked[i+j] = true
+			}
+			if first < 0 {
+				first = i
+			}
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+
+	from := max(0, first-snippetLead)
+	to := min(len(toks), from+snippetWords)
+	from = max(0, to-snippetWords)
+	start, end := 0, len(text)
+	if from > 0 {
+		start = toks[from].start
+	}
+	if to < len(toks) {
+		end = toks[to-1].end
+	}
+
+	var sb strings.Builder
+	if from > 0 {
+		sb.WriteString("…")
+	}
+	pos := start
+	for i := from; i < to; i++ {
+		t := toks[i]
+		sb.WriteString(text[pos:t.start])
+		if marked[i] {
+			sb.WriteString("**" + text[t.start:t.end] + "**")
+		} else {
+			sb.WriteString(text[t.start:t.end])
+		}
+		pos = t.end
+	}
+	sb.WriteString(text[pos:end])
+	if to < len(toks) {
+		sb.WriteString("…")
+	}
+	return strings.Join(strings.Fields(sb.String()), " "), true
+}
+
+// searchPage returns the hits on the given page, and the number of pages.
+func searchPage(hits []searchHit, page, perPage int) ([]searchHit, int) {
+	start := min((page-1)*perPage, len(hits))
+	end := min(start+perPage, len(hits))
+	return hits[start:end], totalPages(len(hits), perPage)
+}
@@ -0,0 +1,283 @@
+package store
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// mustSearch runs a search that should succeed.
+func mustSearch(t *testing.T, s Backend, opts SearchOptions) ListResult {
+	t.Helper()
+	result, err := s.Search(opts)
+	if err != nil {
+		t.Fatalf("Search(%+v): %v", opts, err)
+	}
+	return result
+}
+
+// searchIDs returns the IDs a search finds, in order.
+func searchIDs(t *testing.T, s Backend, opts SearchOptions) []string {
+	t.Helper()
+	var ids []string
+	for _, b := range mustSearch(t, s, opts).Beads {
+		ids = append(ids, b.ID)
+	}
+	return ids
+}
+
+// checkText fails the test unless s's text index matches one rebuilt from
+// its beads.
+func checkText(t *testing.T, s *Store) {
+	t.Helper()
+	want := newTextIndex()
+	for _, b := range s.beads {
+		want.add(b)
+	}
+	if !reflect.DeepEqual(s.text, want) {
+		t.Fatalf("text index out of step with beads:\n got %+v\nwant %+v", s.text, want)
+	}
+}
+
+func bothBackends(t *testing.T) map[string]Backend {
+	return map[string]Backend{"json": tempStore(t), "sqlite": tempSQLite(t)}
+}
+
+func TestSearch_CommentsPrefixAndPhrase(t *testing.T) {
+	for name, s := range bothBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			login := mustCreate(t, s, model.NewBead("Fix login redirect"))
+			cache := model.NewBead("Tune the cache")
+			cache.Description = "Logging in is slow because the session cache is cold."
+			cache = mustCreate(t, s, cache)
+			quiet := mustCreate(t, s, model.NewBead("Quiet bead"))
+			s.AddComment(quiet.ID, model.Comment{Author: "alice", Text: "Nothing here."})
+			s.AddComment(quiet.ID, model.Comment{Author: "bob", Text: "The login redirect loops on Safari."})
+
+			if got := searchIDs(t, s, SearchOptions{Query: "safari"}); !reflect.DeepEqual(got, []string{quiet.ID}) {
+				t.Errorf("safari = %v, want the bead with the comment", got)
+			}
+			if got := searchIDs(t, s, SearchOptions{Query: "log*", Sort: SortRelevance}); len(got) != 3 || got[0] != login.ID {
+				t.Errorf("log* = %v, want all three, the title match first", got)
+			}
+			if got := searchIDs(t, s, SearchOptions{Query: `"login redirect"`, In: []SearchField{SearchComments}}); !reflect.DeepEqual(got, []string{quiet.ID}) {
+				t.Errorf("phrase in comments = %v, want only the commented bead", got)
+			}
+			if got := searchIDs(t, s, SearchOptions{Query: `"redirect login"`}); len(got) != 0 {
+				t.Errorf("phrase out of order = %v, want nothing", got)
+			}
+			if got := searchIDs(t, s, SearchOptions{Query: "session slow"}); !reflect.DeepEqual(got, []string{cache.ID}) {
+				t.Errorf("session slow = %v, want the cache bead", got)
+			}
+			if got := searchIDs(t, s, SearchOptions{Query: "session safari"}); len(got) != 0 {
+				t.Errorf("session safari = %v, want nothing: no bead has both", got)
+			}
+
+			res := mustSearch(t, s, SearchOptions{Query: "redirect"})
+			var quietHit BeadSummary
+			for _, b := range res.Beads {
+				if b.ID == quiet.ID {
+					quietHit = b
+				}
+			}
+			if len(quietHit.Matches) != 1 || quietHit.Matches[0].Field != SearchComments || *quietHit.Matches[0].Comment != 1 {
+				t.Fatalf("matches = %+v, want the second comment", quietHit.Matches)
+			}
+			if got, want := quietHit.Matches[0].Snippet, "The login **redirect** loops on Safari."; got != want {
+				t.Errorf("snippet = %q, want %q", got, want)
+			}
+			if quietHit.Score <= 0 {
+				t.Errorf("score = %v, want a positive score", quietHit.Score)
+			}
+		})
+	}
+}
+
+func TestSearch_Sort(t *testing.T) {
+	for name, s := range bothBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			mention := model.NewBead("Release checklist")
+			mention.Priority = model.PriorityCritical
+			mention.Description = "Includes a step for the scheduler among many other steps to follow."
+			mention = mustCreate(t, s, mention)
+			focus := model.NewBead("Scheduler drops jobs")
+			focus.Priority = model.PriorityLow
+			focus = mustCreate(t, s, focus)
+
+			if got := searchIDs(t, s, SearchOptions{Query: "scheduler"}); !reflect.DeepEqual(got, []string{mention.ID, focus.ID}) {
+				t.Errorf("by priority = %v, want the critical bead first", got)
+			}
+			if got := searchIDs(t, s, SearchOptions{Query: "scheduler", Sort: SortRelevance}); !reflect.DeepEqual(got, []string{focus.ID, mention.ID}) {
+				t.Errorf("by relevance = %v, want the title match first", got)
+			}
+		})
+	}
+}
+
+func TestSearch_BadOptions(t *testing.T) {
+	s := tempStore(t)
+	for _, opts := range []SearchOptions{
+		{Query: "x", Sort: "newest"},
+		{Query: "x", In: []SearchField{"tags"}},
+	} {
+		if _, err := s.Search(opts); err == nil {
+			t.Errorf("Search(%+v) succeeded, want an error", opts)
+		}
+	}
+}
+
+func TestSearch_LastWordIsPrefix(t *testing.T) {
+	for name, s := range bothBackends(t) {
+		t.Run(name, func(t *testing.T) {
+			authn := mustCreate(t, s, model.NewBead("Add authentication to the admin API"))
+			fix := mustCreate(t, s, model.NewBead("Fix the auth header"))
+
+			if got := searchIDs(t, s, SearchOptions{Query: "auth", Sort: SortRelevance}); len(got) != 2 || got[0] != fix.ID {
+				t.Errorf("auth = %v, want both, the whole-word match first", got)
+			}
+			if got := searchIDs(t, s, SearchOptions{Query: "admin authent"}); !reflect.DeepEqual(got, []string{authn.ID}) {
+				t.Errorf("admin authent = %v, want the authentication bead", got)
+			}
+			// Only the last word is a prefix, and a phrase never is.
+			if got := searchIDs(t, s, SearchOptions{Query: "auth admin"}); len(got) != 0 {
+				t.Errorf("auth admin = %v, want nothing: auth is not last", got)
+			}
+			if got := searchIDs(t, s, SearchOptions{Query: `"the auth"`}); !reflect.DeepEqual(got, []string{fix.ID}) {
+				t.Errorf("phrase = %v, want only the whole-word match", got)
+			}
+
+			res, err := s.Search(SearchOptions{Query: `  * " - `})
+			if err != nil {
+				t.Fatalf("query with no words: %v", err)
+			}
+			if len(res.Beads) != 0 || res.Total != 0 {
+				t.Errorf("query with no words = %+v, want an empty result", res)
+			}
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	text := "one two three four five six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen seventeen eighteen nineteen twenty twentyone twentytwo match twentyfour twentyfive"
+	got, ok := highlight(text, []queryClause{{terms: []string{"match"}}})
+	// Near the end, the window reaches back to keep snippetWords words.
+	want := "…six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen seventeen eighteen nineteen twenty twentyone twentytwo **match** twentyfour twentyfive"
+	if !ok || got != want {
+		t.Errorf("highlight = %q, want %q", got, want)
+	}
+	got, _ = highlight(text, []queryClause{{terms: []string{"three"}}})
+	if want := "one two **three** four five six seven eight nine ten eleven twelve thirteen fourteen fifteen sixteen seventeen eighteen nineteen twenty…"; got != want {
+		t.Errorf("highlight = %q, want %q", got, want)
+	}
+	if _, ok := highlight(text, []queryClause{{terms: []string{"mat"}}}); ok {
+		t.Error("a whole-word term matched part of a word")
+	}
+	got, _ = highlight("Fix the\nlogin   page", []queryClause{{terms: []string{"log"}, prefix: true}})
+	if got != "Fix the **login** page" {
+		t.Errorf("highlight = %q", got)
+	}
+}
+
+func TestTextIndex_KeptInStep(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "beads.json")
+	s, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	a := createBead(t, s, "Parse the config")
+	b := createBead(t, s, "Write the docs")
+	title := "Parse the config file"
+	s.Update(a.ID, UpdateFields{Title: &title})
+	s.AddComment(b.ID, model.Comment{Author: "alice", Text: "Docs go in docs/"})
+	deleted := model.StatusDeleted
+	s.Update(b.ID, UpdateFields{Status: &deleted})
+	checkText(t, s)
+	if got := searchIDs(t, s, SearchOptions{Query: "docs"}); len(got) != 0 {
+		t.Errorf("deleted bead found: %v", got)
+	}
+
+	err = s.Batch(func(tx Backend) error {
+		desc := "YAML or TOML"
+		if _, err := tx.Update(a.ID, UpdateFields{Description: &desc}); err != nil {
+			return err
+		}
+		if got, _ := tx.Search(SearchOptions{Query: "toml"}); got.Total != 1 {
+			return fmt.Errorf("search inside the batch found %d beads, want 1", got.Total)
+		}
+		_, err := tx.Create(model.NewBead("Ship it"))
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Batch: %v", err)
+	}
+	checkText(t, s)
+
+	if _, err := s.Clean(time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Clean: %v", err)
+	}
+	checkText(t, s)
+
+	reloaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !reflect.DeepEqual(reloaded.text, s.text) {
+		t.Errorf("text index after reload differs from before")
+	}
+}
+
+func TestSQLite_SearchIndexesOldDatabases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "beads.db")
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	b := mustCreate(t, s, model.NewBead("Migrate the search index"))
+	// Drop the index, as in a database from before it existed.
+	if _, err := s.db.Exec(`DELETE FROM bead_text; DELETE FROM bead_terms`); err != nil {
+		t.Fatalf("clearing index: %v", err)
+	}
+	s.Close()
+
+	s, err = OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	defer s.Close()
+	if got := searchIDs(t, s, SearchOptions{Query: "migrate"}); !reflect.DeepEqual(got, []string{b.ID}) {
+		t.Errorf("search after reopening = %v, want the bead", got)
+	}
+}
+
+// TestBackends_SearchParity checks that both backends find and score the
+// same beads.
+func TestBackends_SearchParity(t *testing.T) {
+	results := map[string][]BeadSummary{}
+	for name, s := range bothBackends(t) {
+		base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i, title := range []string{"Retry failed webhooks", "Webhook signing", "Dashboard shows retries", "Unrelated"} {
+			b := model.NewBead(title)
+			b.ID = fmt.Sprintf("bd-par%d", i)
+			b.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+			b.Description = "Webhooks are retried with backoff; see the retry queue."
+			mustCreate(t, s, b)
+		}
+		s.AddComment("bd-par3", model.Comment{Author: "alice", Text: "webhook retry storms"})
+		results[name] = mustSearch(t, s, SearchOptions{Query: "webhook* retr*", Sort: SortRelevance}).Beads
+	}
+	json, sqlite := results["json"], results["sqlite"]
+	if len(json) != 4 || len(json) != len(sqlite) {
+		t.Fatalf("json found %d, sqlite %d; want 4 each", len(json), len(sqlite))
+	}
+	for i := range json {
+		j, q := json[i], sqlite[i]
+		if j.ID != q.ID || j.Score != q.Score || !reflect.DeepEqual(j.Matches, q.Matches) {
+			t.Errorf("result %d: json %+v, sqlite %+v", i, j, q)
+		}
+	}
+}
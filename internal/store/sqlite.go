@@ -0,0 +1,1340 @@
+package store
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vector76/beads_server/model"
+	_ "modernc.org/sqlite" // pure-Go driver, registered as "sqlite"
+)
+
+// SQLiteStore is a Backend that keeps beads in an SQLite database instead of
+// an in-memory map. Each bead is stored as a JSON document alongside indexed
+// columns for the fields that list, ready and dependency queries filter on,
+// so only the rows a request touches are read into memory.
+type SQLiteStore struct {
+	mu sync.Mutex // serializes writers so read-check-write sequences are atomic
+	db *sql.DB
+	q  querier // reads go through q: db, or tx inside a batch
+	tx *sql.Tx // set on the view of the store that Batch passes to its fn
+
+	foreign *foreignLookup // blockers held outside this store; see SetForeign
+}
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS beads (
+	id            TEXT PRIMARY KEY,
+	parent_id     TEXT NOT NULL DEFAULT '',
+	status        TEXT NOT NULL,
+	priority      TEXT NOT NULL,
+	priority_rank INTEGER NOT NULL,
+	type          TEXT NOT NULL,
+	assignee      TEXT NOT NULL DEFAULT '',
+	title         TEXT NOT NULL,
+	description   TEXT NOT NULL,
+	created_at    INTEGER NOT NULL,
+	updated_at    INTEGER NOT NULL,
+	data          TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS beads_parent ON beads(parent_id);
+CREATE INDEX IF NOT EXISTS beads_status ON beads(status);
+CREATE INDEX IF NOT EXISTS beads_order ON beads(priority_rank, created_at);
+CREATE TABLE IF NOT EXISTS bead_deps (
+	bead_id    TEXT NOT NULL,
+	blocker_id TEXT NOT NULL,
+	PRIMARY KEY (bead_id, blocker_id)
+);
+CREATE INDEX IF NOT EXISTS bead_deps_blocker ON bead_deps(blocker_id);
+CREATE TABLE IF NOT EXISTS bead_tags (
+	bead_id TEXT NOT NULL,
+	tag     TEXT NOT NULL,
+	PRIMARY KEY (bead_id, tag)
+);
+CREATE INDEX IF NOT EXISTS bead_tags_tag ON bead_tags(tag);
+CREATE TABLE IF NOT EXISTS bead_history (
+	bead_id TEXT NOT NULL,
+	seq     INTEGER NOT NULL,
+	data    TEXT NOT NULL,
+	PRIMARY KEY (bead_id, seq)
+);
+CREATE TABLE IF NOT EXISTS bead_text (
+	bead_id         TEXT PRIMARY KEY,
+	title_len       INTEGER NOT NULL,
+	description_len INTEGER NOT NULL,
+	comments_len    INTEGER NOT NULL,
+	digest          TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS bead_terms (
+	term           TEXT NOT NULL,
+	bead_id        TEXT NOT NULL,
+	title_tf       INTEGER NOT NULL,
+	description_tf INTEGER NOT NULL,
+	comments_tf    INTEGER NOT NULL,
+	PRIMARY KEY (term, bead_id)
+);
+CREATE INDEX IF NOT EXISTS bead_terms_bead ON bead_terms(bead_id);
+`
+
+// activeStatusSQL lists the statuses that count as active blockers
+// (see isActiveBlocker).
+const activeStatusSQL = `('open','in_progress','not_ready')`
+
+// OpenSQLite opens (creating if needed) an SQLite database at path.
+func OpenSQLite(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite", "file:"+path+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("opening sqlite database: %w", err)
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("initializing sqlite schema: %w", err)
+	}
+	s := &SQLiteStore{db: db, q: db, foreign: newForeignLookup()}
+	if err := s.indexUnindexed(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// indexUnindexed adds to the search index the beads that are missing from
+// it, such as those in a database created before there was one.
+func (s *SQLiteStore) indexUnindexed() error {
+	return s.write(func(tx *sql.Tx) error {
+		missing, err := sqlQueryBeads(tx, `SELECT data FROM beads
+			WHERE status <> 'deleted' AND id NOT IN (SELECT bead_id FROM bead_text)`)
+		if err != nil {
+			return err
+		}
+		for _, b := range missing {
+			if err := sqlIndexText(tx, b); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close closes the underlying database. It is a no-op on a batch's view of
+// the store.
+func (s *SQLiteStore) Close() error {
+	if s.tx != nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx.
+type querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// write runs fn inside a transaction while holding the writer lock. Inside a
+// batch, fn joins the batch's transaction.
+func (s *SQLiteStore) write(fn func(tx *sql.Tx) error) error {
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	return nil
+}
+
+// Batch runs fn in a single transaction. The Backend passed to fn is a view
+// of the store bound to that transaction; if fn returns an error, everything
+// it wrote is rolled back. See Store.Batch.
+func (s *SQLiteStore) Batch(fn func(tx Backend) error) error {
+	if s.tx != nil {
+		return fn(s)
+	}
+	return s.write(func(tx *sql.Tx) error {
+		return fn(&SQLiteStore{db: s.db, q: tx, tx: tx, foreign: s.foreign})
+	})
+}
+
+// Prior returns the bead with the given ID as it was when the batch s
+// belongs to began: it reads through the connection pool, which sees only
+// committed beads. Outside a batch that is the bead as it is now. See
+// Store.Prior.
+func (s *SQLiteStore) Prior(id string) (model.Bead, bool) {
+	b, ok, _ := sqlGet(s.db, id)
+	return b, ok
+}
+
+// sqlGet loads a bead by ID.
+func sqlGet(q querier, id string) (model.Bead, bool, error) {
+	var data string
+	err := q.QueryRow(`SELECT data FROM beads WHERE id = ?`, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return model.Bead{}, false, nil
+	}
+	if err != nil {
+		return model.Bead{}, false, fmt.Errorf("loading bead %s: %w", id, err)
+	}
+	var b model.Bead
+	if err := json.Unmarshal([]byte(data), &b); err != nil {
+		return model.Bead{}, false, fmt.Errorf("decoding bead %s: %w", id, err)
+	}
+	return b, true, nil
+}
+
+// sqlMustGet loads a bead by ID, returning NotFoundError if it is absent.
+func sqlMustGet(q querier, id string) (model.Bead, error) {
+	b, ok, err := sqlGet(q, id)
+	if err != nil {
+		return model.Bead{}, err
+	}
+	if !ok {
+		return model.Bead{}, &NotFoundError{Message: fmt.Sprintf("bead %s not found", id)}
+	}
+	return b, nil
+}
+
+// sqlLookup adapts q to a lookupFunc. Query errors are treated as absence.
+func sqlLookup(q querier) lookupFunc {
+	return func(id string) (model.Bead, bool) {
+		b, ok, _ := sqlGet(q, id)
+		return b, ok
+	}
+}
+
+// sqlQueryBeads runs a query selecting the data column and decodes each row.
+func sqlQueryBeads(q querier, query string, args ...any) ([]model.Bead, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying beads: %w", err)
+	}
+	defer rows.Close()
+
+	var beads []model.Bead
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("scanning bead: %w", err)
+		}
+		var b model.Bead
+		if err := json.Unmarshal([]byte(data), &b); err != nil {
+			return nil, fmt.Errorf("decoding bead: %w", err)
+		}
+		beads = append(beads, b)
+	}
+	return beads, rows.Err()
+}
+
+// sqlChildren returns the children of parentID.
+func sqlChildren(q querier, parentID string) []model.Bead {
+	children, _ := sqlQueryBeads(q, `SELECT data FROM beads WHERE parent_id = ?`, parentID)
+	return children
+}
+
+// sqlHasChildren reports whether any bead has parentID as its parent.
+func sqlHasChildren(q querier, parentID string) bool {
+	var one int
+	err := q.QueryRow(`SELECT 1 FROM beads WHERE parent_id = ? LIMIT 1`, parentID).Scan(&one)
+	return err == nil
+}
+
+// sqlDependents returns the beads whose blocked_by list contains blockerID.
+func sqlDependents(q querier, blockerID string) []model.Bead {
+	deps, _ := sqlQueryBeads(q,
+		`SELECT b.data FROM beads b JOIN bead_deps d ON d.bead_id = b.id WHERE d.blocker_id = ?`, blockerID)
+	return deps
+}
+
+// sqlForeignBlocked returns the IDs of beads with an active blocker that is
+// not in the database but is one of foreign's beads. Only blocked_by entries
+// naming no local bead are looked up.
+func sqlForeignBlocked(q querier, foreign *foreignLookup) []string {
+	rows, err := q.Query(`SELECT d.bead_id, d.blocker_id FROM bead_deps d
+		WHERE NOT EXISTS (SELECT 1 FROM beads x WHERE x.id = d.blocker_id)`)
+	if err != nil {
+		return nil
+	}
+	defer rows.Close()
+
+	var blocked []string
+	for rows.Next() {
+		var beadID, blockerID string
+		if err := rows.Scan(&beadID, &blockerID); err != nil {
+			return blocked
+		}
+		if b, ok := foreign.get(blockerID); ok && isActiveBlocker(b.Status) {
+			blocked = append(blocked, beadID)
+		}
+	}
+	return blocked
+}
+
+// sqlPut inserts or replaces a bead and refreshes its dependency and tag rows.
+func sqlPut(q querier, b model.Bead) error {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return fmt.Errorf("encoding bead %s: %w", b.ID, err)
+	}
+	if _, err := q.Exec(`INSERT OR REPLACE INTO beads
+		(id, parent_id, status, priority, priority_rank, type, assignee, title, description, created_at, updated_at, data)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		b.ID, b.ParentID, string(b.Status), string(b.Priority), b.Priority.Rank(), string(b.Type),
+		b.Assignee, b.Title, b.Description, b.CreatedAt.UnixNano(), b.UpdatedAt.UnixNano(), string(data),
+	); err != nil {
+		return fmt.Errorf("writing bead %s: %w", b.ID, err)
+	}
+
+	if _, err := q.Exec(`DELETE FROM bead_deps WHERE bead_id = ?`, b.ID); err != nil {
+		return fmt.Errorf("writing deps for %s: %w", b.ID, err)
+	}
+	for _, dep := range b.BlockedBy {
+		if _, err := q.Exec(`INSERT OR IGNORE INTO bead_deps (bead_id, blocker_id) VALUES (?, ?)`, b.ID, dep); err != nil {
+			return fmt.Errorf("writing deps for %s: %w", b.ID, err)
+		}
+	}
+
+	if _, err := q.Exec(`DELETE FROM bead_tags WHERE bead_id = ?`, b.ID); err != nil {
+		return fmt.Errorf("writing tags for %s: %w", b.ID, err)
+	}
+	for _, tag := range b.Tags {
+		if _, err := q.Exec(`INSERT OR IGNORE INTO bead_tags (bead_id, tag) VALUES (?, ?)`, b.ID, tag); err != nil {
+			return fmt.Errorf("writing tags for %s: %w", b.ID, err)
+		}
+	}
+	return sqlIndexText(q, b)
+}
+
+// sqlIndexText brings the search index up to date for b: its lengths in
+// bead_text and its terms in bead_terms. A bead whose text is unchanged
+// since it was indexed is left alone, and a deleted bead is unindexed.
+func sqlIndexText(q querier, b model.Bead) error {
+	var digest string
+	if b.Status != model.StatusDeleted {
+		h := sha256.New()
+		for _, texts := range fieldTexts(b) {
+			for _, text := range texts {
+				h.Write([]byte(text))
+				h.Write([]byte{0})
+			}
+			h.Write([]byte{1})
+		}
+		digest = hex.EncodeToString(h.Sum(nil))
+	}
+
+	var indexed string
+	err := q.QueryRow(`SELECT digest FROM bead_text WHERE bead_id = ?`, b.ID).Scan(&indexed)
+	switch {
+	case err == sql.ErrNoRows:
+		if digest == "" {
+			return nil
+		}
+	case err != nil:
+		return fmt.Errorf("reading search index for %s: %w", b.ID, err)
+	case indexed == digest:
+		return nil
+	default:
+		if err := sqlUnindexText(q, b.ID); err != nil {
+			return err
+		}
+		if digest == "" {
+			return nil
+		}
+	}
+
+	terms, lens := termCounts(b)
+	if _, err := q.Exec(`INSERT INTO bead_text (bead_id, title_len, description_len, comments_len, digest) VALUES (?, ?, ?, ?, ?)`,
+		b.ID, lens[0], lens[1], lens[2], digest); err != nil {
+		return fmt.Errorf("indexing %s: %w", b.ID, err)
+	}
+	for term, tf := range terms {
+		if _, err := q.Exec(`INSERT INTO bead_terms (term, bead_id, title_tf, description_tf, comments_tf) VALUES (?, ?, ?, ?, ?)`,
+			term, b.ID, tf[0], tf[1], tf[2]); err != nil {
+			return fmt.Errorf("indexing %s: %w", b.ID, err)
+		}
+	}
+	return nil
+}
+
+// sqlUnindexText removes a bead from the search index.
+func sqlUnindexText(q querier, id string) error {
+	for _, stmt := range []string{
+		`DELETE FROM bead_text WHERE bead_id = ?`,
+		`DELETE FROM bead_terms WHERE bead_id = ?`,
+	} {
+		if _, err := q.Exec(stmt, id); err != nil {
+			return fmt.Errorf("unindexing %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// sqlDelete permanently removes a bead and its dependency, tag, history
+// and search index rows.
+func sqlDelete(q querier, id string) error {
+	for _, stmt := range []string{
+		`DELETE FROM beads WHERE id = ?`,
+		`DELETE FROM bead_deps WHERE bead_id = ?`,
+		`DELETE FROM bead_tags WHERE bead_id = ?`,
+		`DELETE FROM bead_history WHERE bead_id = ?`,
+	} {
+		if _, err := q.Exec(stmt, id); err != nil {
+			return fmt.Errorf("deleting bead %s: %w", id, err)
+		}
+	}
+	return sqlUnindexText(q, id)
+}
+
+// sqlRecomputeEpic recomputes and stores the derived status of epicID.
+// If the epic has no children left, it reverts to a regular bead with status "open".
+func sqlRecomputeEpic(q querier, epicID string) error {
+	epic, ok, err := sqlGet(q, epicID)
+	if err != nil || !ok {
+		return err
+	}
+
+	children := sqlChildren(q, epicID)
+	newStatus := model.StatusOpen
+	if len(children) > 0 {
+		newStatus = deriveStatus(children)
+		if epic.Status == newStatus {
+			return nil
+		}
+	}
+	epic.Status = newStatus
+	touch(&epic, time.Now().UTC())
+	return sqlPut(q, epic)
+}
+
+// sqlRecomputeEpics is sqlRecomputeEpic for each of epicIDs, skipping empty
+// and repeated IDs.
+func sqlRecomputeEpics(q querier, epicIDs ...string) error {
+	done := make(map[string]bool)
+	for _, id := range epicIDs {
+		if id == "" || done[id] {
+			continue
+		}
+		done[id] = true
+		if err := sqlRecomputeEpic(q, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sqlCreate generates an ID if needed, one reserve also accepts if it is
+// not nil, and inserts b.
+func sqlCreate(q querier, b model.Bead, reserve ReserveFunc) (model.Bead, error) {
+	if b.ID == "" {
+		b.ID = generateID(func(id string) bool {
+			if _, exists, _ := sqlGet(q, id); exists {
+				return true
+			}
+			return reserve != nil && !reserve(id)
+		})
+	} else if _, exists, err := sqlGet(q, b.ID); err != nil {
+		return model.Bead{}, err
+	} else if exists {
+		return model.Bead{}, fmt.Errorf("bead %s already exists", b.ID)
+	}
+	b.Revision = 1
+	if err := sqlPut(q, b); err != nil {
+		return model.Bead{}, err
+	}
+	return b, nil
+}
+
+// Create adds a bead to the database.
+func (s *SQLiteStore) Create(b model.Bead) (model.Bead, error) {
+	return s.CreateExcluding(b, nil)
+}
+
+// CreateExcluding adds a bead, generating an ID that reserve also accepts.
+func (s *SQLiteStore) CreateExcluding(b model.Bead, reserve ReserveFunc) (model.Bead, error) {
+	var created model.Bead
+	err := s.write(func(tx *sql.Tx) error {
+		var err error
+		created, err = sqlCreate(tx, b, reserve)
+		return err
+	})
+	return created, err
+}
+
+// CreateWithParent creates a child bead and recomputes the parent's status
+// in the same transaction.
+func (s *SQLiteStore) CreateWithParent(b model.Bead, parentID string) (model.Bead, error) {
+	return s.CreateWithParentExcluding(b, parentID, nil)
+}
+
+// CreateWithParentExcluding is CreateWithParent, generating an ID that
+// reserve also accepts.
+func (s *SQLiteStore) CreateWithParentExcluding(b model.Bead, parentID string, reserve ReserveFunc) (model.Bead, error) {
+	var created model.Bead
+	err := s.write(func(tx *sql.Tx) error {
+		parent, ok, err := sqlGet(tx, parentID)
+		if err != nil {
+			return err
+		}
+		if err := validateParent(parentID, parent, ok); err != nil {
+			return err
+		}
+		b.ParentID = parentID
+		if created, err = sqlCreate(tx, b, reserve); err != nil {
+			return err
+		}
+		return sqlRecomputeEpic(tx, parentID)
+	})
+	return created, err
+}
+
+// Get returns a bead by exact ID.
+func (s *SQLiteStore) Get(id string) (model.Bead, error) {
+	return sqlMustGet(s.q, id)
+}
+
+// Resolve finds a bead by exact ID.
+func (s *SQLiteStore) Resolve(id string) (model.Bead, error) {
+	return sqlMustGet(s.q, id)
+}
+
+// mutate loads a bead, checks it is at revision rev (if set), applies fn and
+// stores the result.
+func (s *SQLiteStore) mutate(id string, rev *int64, fn func(tx *sql.Tx, b *model.Bead) error) (model.Bead, error) {
+	var out model.Bead
+	err := s.write(func(tx *sql.Tx) error {
+		b, err := sqlMustGet(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkRevision(b, rev); err != nil {
+			return err
+		}
+		if err := fn(tx, &b); err != nil {
+			return err
+		}
+		if err := sqlPut(tx, b); err != nil {
+			return err
+		}
+		out = b
+		return nil
+	})
+	return out, err
+}
+
+// Update applies partial updates to a bead and sets updated_at, recomputing
+// its parent epic's status in the same transaction.
+func (s *SQLiteStore) Update(id string, fields UpdateFields) (model.Bead, error) {
+	var out model.Bead
+	err := s.write(func(tx *sql.Tx) error {
+		b, err := sqlMustGet(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := checkRevision(b, fields.IfRevision); err != nil {
+			return err
+		}
+		oldParent := b.ParentID
+		applyUpdate(&b, fields)
+		touch(&b, time.Now().UTC())
+		if err := sqlPut(tx, b); err != nil {
+			return err
+		}
+		out = b
+		return sqlRecomputeEpics(tx, oldParent, b.ParentID)
+	})
+	return out, err
+}
+
+// Delete soft-deletes a bead by setting its status to deleted.
+func (s *SQLiteStore) Delete(id string) (model.Bead, error) {
+	return s.DeleteIfRevision(id, nil)
+}
+
+// DeleteIfRevision is Delete with a revision precondition.
+func (s *SQLiteStore) DeleteIfRevision(id string, rev *int64) (model.Bead, error) {
+	status := model.StatusDeleted
+	return s.Update(id, UpdateFields{Status: &status, IfRevision: rev})
+}
+
+// All returns all beads in the database.
+func (s *SQLiteStore) All() []model.Bead {
+	beads, _ := sqlQueryBeads(s.q, `SELECT data FROM beads`)
+	if beads == nil {
+		return []model.Bead{}
+	}
+	return beads
+}
+
+// AddComment appends a comment to a bead.
+func (s *SQLiteStore) AddComment(beadID string, comment model.Comment) (model.Bead, error) {
+	return s.AddCommentIfRevision(beadID, comment, nil)
+}
+
+// AddCommentIfRevision is AddComment with a revision precondition.
+func (s *SQLiteStore) AddCommentIfRevision(beadID string, comment model.Comment, rev *int64) (model.Bead, error) {
+	return s.mutate(beadID, rev, func(_ *sql.Tx, b *model.Bead) error {
+		comment.CreatedAt = time.Now().UTC()
+		b.Comments = append(b.Comments, comment)
+		touch(b, time.Now().UTC())
+		return nil
+	})
+}
+
+// Claim atomically sets a bead's status to in_progress and assignee to user.
+func (s *SQLiteStore) Claim(beadID, user string, lease time.Duration) (model.Bead, error) {
+	var out model.Bead
+	err := s.write(func(tx *sql.Tx) error {
+		b, err := sqlMustGet(tx, beadID)
+		if err != nil {
+			return err
+		}
+		done, err := checkClaim(b, user)
+		if err != nil {
+			return err
+		}
+		if !done || lease > 0 {
+			now := time.Now().UTC()
+			if !done {
+				b.Status = model.StatusInProgress
+				b.Assignee = user
+				touch(&b, now)
+			}
+			b.LeaseExpiresAt = leaseUntil(now, lease)
+			if err := sqlPut(tx, b); err != nil {
+				return err
+			}
+		}
+		out = b
+		return nil
+	})
+	return out, err
+}
+
+// ClaimNext claims the first ready bead matching filters for user. See
+// Store.ClaimNext.
+func (s *SQLiteStore) ClaimNext(filters ListFilters, user string, lease time.Duration) (before, claimed model.Bead, err error) {
+	filters.Ready = true
+
+	err = s.write(func(tx *sql.Tx) error {
+		whereSQL, args, _ := sqlListWhere(filters, sqlForeignBlocked(tx, s.foreign))
+		next, err := sqlQueryBeads(tx, "SELECT b.data FROM beads b"+whereSQL+
+			" ORDER BY b.priority_rank, b.created_at DESC, b.id LIMIT 1", args...)
+		if err != nil {
+			return err
+		}
+		if len(next) == 0 {
+			return &NotFoundError{Message: "no ready bead matches the filters"}
+		}
+		before = next[0]
+		b := before
+		now := time.Now().UTC()
+		b.Status = model.StatusInProgress
+		b.Assignee = user
+		touch(&b, now)
+		b.LeaseExpiresAt = leaseUntil(now, lease)
+		if err := sqlPut(tx, b); err != nil {
+			return err
+		}
+		claimed = b
+		return nil
+	})
+	if err != nil {
+		return model.Bead{}, model.Bead{}, err
+	}
+	return before, claimed, nil
+}
+
+// Heartbeat renews the lease on a bead claimed by user. See Store.Heartbeat.
+func (s *SQLiteStore) Heartbeat(beadID, user string, lease time.Duration) (model.Bead, error) {
+	return s.mutate(beadID, nil, func(tx *sql.Tx, b *model.Bead) error {
+		if err := checkHeartbeat(*b, user); err != nil {
+			return err
+		}
+		b.LeaseExpiresAt = leaseUntil(time.Now().UTC(), lease)
+		return nil
+	})
+}
+
+// ReleaseExpired returns claims whose lease expired before now to open.
+// See Store.ReleaseExpired.
+func (s *SQLiteStore) ReleaseExpired(now time.Time) ([]Release, error) {
+	var released []Release
+	var parents []string
+	err := s.write(func(tx *sql.Tx) error {
+		claimed, err := sqlQueryBeads(tx, `SELECT data FROM beads WHERE status = 'in_progress'`)
+		if err != nil {
+			return err
+		}
+		for _, b := range claimed {
+			if !leaseExpired(b, now) {
+				continue
+			}
+			rel := Release{Before: b, After: b}
+			releaseClaim(&rel.After, now)
+			if err := sqlPut(tx, rel.After); err != nil {
+				return err
+			}
+			released = append(released, rel)
+			parents = append(parents, b.ParentID)
+		}
+		return sqlRecomputeEpics(tx, parents...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return released, nil
+}
+
+// Clean permanently removes old closed/deleted beads using the same epic-aware
+// rules as Store.Clean.
+func (s *SQLiteStore) Clean(cutoff time.Time) (int, error) {
+	removed := 0
+	err := s.write(func(tx *sql.Tx) error {
+		terminal, err := sqlQueryBeads(tx, `SELECT data FROM beads WHERE status IN ('closed','deleted')`)
+		if err != nil {
+			return err
+		}
+		childrenOf := func(id string) []model.Bead { return sqlChildren(tx, id) }
+		removeSet := selectCleanable(terminal, sqlLookup(tx), childrenOf, cutoff)
+		for id := range removeSet {
+			if err := sqlDelete(tx, id); err != nil {
+				return err
+			}
+		}
+		removed = len(removeSet)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return removed, nil
+}
+
+// Link adds blockedByID to beadID's blocked_by list.
+func (s *SQLiteStore) Link(beadID, blockedByID string) (model.Bead, error) {
+	return s.LinkIfRevision(beadID, blockedByID, nil)
+}
+
+// LinkIfRevision is Link with a revision precondition.
+func (s *SQLiteStore) LinkIfRevision(beadID, blockedByID string, rev *int64) (model.Bead, error) {
+	if beadID == blockedByID {
+		return model.Bead{}, fmt.Errorf("cannot link bead to itself")
+	}
+	return s.mutate(beadID, rev, func(tx *sql.Tx, b *model.Bead) error {
+		target, ok := s.depLookup(tx)(blockedByID)
+		if !ok {
+			return &NotFoundError{Message: fmt.Sprintf("bead %s not found", blockedByID)}
+		}
+		if err := validateLink(*b, target); err != nil {
+			return err
+		}
+		if wouldCreateCycle(beadID, blockedByID, s.depLookup(tx)) {
+			return cycleError(beadID, blockedByID)
+		}
+		b.BlockedBy = withBlocker(b.BlockedBy, blockedByID)
+		b.Revision++
+		return nil
+	})
+}
+
+// Unlink removes blockedByID from beadID's blocked_by list.
+func (s *SQLiteStore) Unlink(beadID, blockedByID string) (model.Bead, error) {
+	return s.UnlinkIfRevision(beadID, blockedByID, nil)
+}
+
+// UnlinkIfRevision is Unlink with a revision precondition.
+func (s *SQLiteStore) UnlinkIfRevision(beadID, blockedByID string, rev *int64) (model.Bead, error) {
+	return s.mutate(beadID, rev, func(_ *sql.Tx, b *model.Bead) error {
+		newBlocked, found := withoutBlocker(b.BlockedBy, blockedByID)
+		if !found {
+			return fmt.Errorf("bead %s is not blocked by %s", beadID, blockedByID)
+		}
+		b.BlockedBy = newBlocked
+		b.Revision++
+		return nil
+	})
+}
+
+// Deps returns the dependency information for a bead.
+func (s *SQLiteStore) Deps(beadID string) (DepsResult, error) {
+	b, err := sqlMustGet(s.q, beadID)
+	if err != nil {
+		return DepsResult{}, err
+	}
+	return buildDeps(b, s.depLookup(s.q), sqlDependents(s.q, beadID)), nil
+}
+
+// GetUnblocked returns beads that are no longer blocked now that beadID is terminal.
+func (s *SQLiteStore) GetUnblocked(beadID string) []model.Bead {
+	return unblockedAmong(sqlDependents(s.q, beadID), s.depLookup(s.q))
+}
+
+// Dependents returns the beads whose blocked_by list contains blockerID.
+func (s *SQLiteStore) Dependents(blockerID string) []model.Bead {
+	return sqlDependents(s.q, blockerID)
+}
+
+// ChildrenOf returns all children of the given bead.
+func (s *SQLiteStore) ChildrenOf(parentID string) []model.Bead {
+	children := sqlChildren(s.q, parentID)
+	if children == nil {
+		return []model.Bead{}
+	}
+	return children
+}
+
+// IsEpic returns true if the bead with the given ID has any children.
+func (s *SQLiteStore) IsEpic(id string) bool {
+	return sqlHasChildren(s.q, id)
+}
+
+// MoveInto moves a bead into an epic and recomputes both parents' statuses.
+func (s *SQLiteStore) MoveInto(beadID, targetID string) (model.Bead, error) {
+	return s.MoveIntoIfRevision(beadID, targetID, nil)
+}
+
+// MoveIntoIfRevision is MoveInto with a revision precondition.
+func (s *SQLiteStore) MoveIntoIfRevision(beadID, targetID string, rev *int64) (model.Bead, error) {
+	var out model.Bead
+	err := s.write(func(tx *sql.Tx) error {
+		b, err := sqlMustGet(tx, beadID)
+		if err != nil {
+			return err
+		}
+		if err := checkRevision(b, rev); err != nil {
+			return err
+		}
+		target, err := sqlMustGet(tx, targetID)
+		if err != nil {
+			return err
+		}
+		if err := validateMoveInto(b, target, sqlHasChildren(tx, beadID)); err != nil {
+			return err
+		}
+
+		oldParent := b.ParentID
+		b.ParentID = targetID
+		touch(&b, time.Now().UTC())
+		if err := sqlPut(tx, b); err != nil {
+			return err
+		}
+		if err := sqlRecomputeEpic(tx, targetID); err != nil {
+			return err
+		}
+		if oldParent != "" && oldParent != targetID {
+			if err := sqlRecomputeEpic(tx, oldParent); err != nil {
+				return err
+			}
+		}
+		out, err = sqlMustGet(tx, beadID)
+		return err
+	})
+	return out, err
+}
+
+// MoveOut detaches a bead from its parent epic.
+func (s *SQLiteStore) MoveOut(beadID string) (model.Bead, error) {
+	return s.MoveOutIfRevision(beadID, nil)
+}
+
+// MoveOutIfRevision is MoveOut with a revision precondition.
+func (s *SQLiteStore) MoveOutIfRevision(beadID string, rev *int64) (model.Bead, error) {
+	var out model.Bead
+	err := s.write(func(tx *sql.Tx) error {
+		b, err := sqlMustGet(tx, beadID)
+		if err != nil {
+			return err
+		}
+		if err := checkRevision(b, rev); err != nil {
+			return err
+		}
+		if b.ParentID == "" {
+			return fmt.Errorf("bead %s has no parent", beadID)
+		}
+		oldParent := b.ParentID
+		b.ParentID = ""
+		touch(&b, time.Now().UTC())
+		if err := sqlPut(tx, b); err != nil {
+			return err
+		}
+		if err := sqlRecomputeEpic(tx, oldParent); err != nil {
+			return err
+		}
+		out = b
+		return nil
+	})
+	return out, err
+}
+
+// RecomputeParentStatus recomputes the parent epic status of a child.
+// See Store.RecomputeParentStatus.
+func (s *SQLiteStore) RecomputeParentStatus(childID string) error {
+	return s.write(func(tx *sql.Tx) error {
+		child, ok, err := sqlGet(tx, childID)
+		if err != nil || !ok || child.ParentID == "" {
+			return err
+		}
+		return sqlRecomputeEpic(tx, child.ParentID)
+	})
+}
+
+// ValidateStatusChangeOnEpic rejects explicit status changes on epics.
+func (s *SQLiteStore) ValidateStatusChangeOnEpic(beadID string) error {
+	if sqlHasChildren(s.q, beadID) {
+		return &ConflictError{Message: "cannot set status on an epic; status is derived from children"}
+	}
+	return nil
+}
+
+// ValidateClaimOnEpic returns an error if the bead is an epic.
+func (s *SQLiteStore) ValidateClaimOnEpic(beadID string) error {
+	if sqlHasChildren(s.q, beadID) {
+		return &ConflictError{Message: "cannot claim an epic; claim individual children"}
+	}
+	return nil
+}
+
+// ValidateDeleteOnEpic returns an error if the bead is an epic with open children.
+func (s *SQLiteStore) ValidateDeleteOnEpic(beadID string) error {
+	return validateDeleteEpic(sqlChildren(s.q, beadID))
+}
+
+// ValidateLinkParentChild returns an error if one bead is the parent of the other.
+func (s *SQLiteStore) ValidateLinkParentChild(beadID, blockedByID string) error {
+	a, ok, _ := sqlGet(s.q, beadID)
+	if !ok {
+		return nil
+	}
+	b, ok, _ := sqlGet(s.q, blockedByID)
+	if !ok {
+		return nil
+	}
+	return validateLinkParentChild(a, b)
+}
+
+// ValidateLinkCycle returns an error if making beadID blocked by blockedByID
+// would create a circular dependency. See Store.ValidateLinkCycle.
+func (s *SQLiteStore) ValidateLinkCycle(beadID, blockedByID string) error {
+	if beadID == blockedByID || wouldCreateCycle(beadID, blockedByID, s.depLookup(s.q)) {
+		return cycleError(beadID, blockedByID)
+	}
+	return nil
+}
+
+// Restore writes b exactly as given and replaces its history. See
+// Store.Restore.
+func (s *SQLiteStore) Restore(b model.Bead, entries []model.HistoryEntry) error {
+	if b.ID == "" {
+		return errors.New("bead ID is required")
+	}
+	return s.write(func(tx *sql.Tx) error {
+		if err := sqlDelete(tx, b.ID); err != nil {
+			return err
+		}
+		if err := sqlPut(tx, b); err != nil {
+			return err
+		}
+		for i, e := range entries {
+			e.Seq = i + 1
+			data, err := json.Marshal(e)
+			if err != nil {
+				return fmt.Errorf("encoding history entry: %w", err)
+			}
+			if _, err := tx.Exec(`INSERT INTO bead_history (bead_id, seq, data) VALUES (?, ?, ?)`, b.ID, e.Seq, string(data)); err != nil {
+				return fmt.Errorf("storing history of %s: %w", b.ID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// Purge permanently removes a bead and its history. See Store.Purge.
+func (s *SQLiteStore) Purge(id string) error {
+	return s.write(func(tx *sql.Tx) error {
+		if _, err := sqlMustGet(tx, id); err != nil {
+			return err
+		}
+		return sqlDelete(tx, id)
+	})
+}
+
+// RecordHistory appends an entry to a bead's history. See Store.RecordHistory.
+func (s *SQLiteStore) RecordHistory(beadID string, e model.HistoryEntry) error {
+	return s.write(func(tx *sql.Tx) error {
+		if _, err := sqlMustGet(tx, beadID); err != nil {
+			return err
+		}
+		var last int
+		if err := tx.QueryRow(`SELECT COALESCE(MAX(seq), 0) FROM bead_history WHERE bead_id = ?`, beadID).Scan(&last); err != nil {
+			return fmt.Errorf("reading history of %s: %w", beadID, err)
+		}
+		e.Seq = last + 1
+		if e.At.IsZero() {
+			e.At = time.Now().UTC()
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("encoding history entry: %w", err)
+		}
+		if _, err := tx.Exec(`INSERT INTO bead_history (bead_id, seq, data) VALUES (?, ?, ?)`, beadID, e.Seq, string(data)); err != nil {
+			return fmt.Errorf("storing history of %s: %w", beadID, err)
+		}
+		return nil
+	})
+}
+
+// History returns a bead's history entries, oldest first.
+func (s *SQLiteStore) History(beadID string) ([]model.HistoryEntry, error) {
+	if _, err := sqlMustGet(s.q, beadID); err != nil {
+		return nil, err
+	}
+	rows, err := s.q.Query(`SELECT data FROM bead_history WHERE bead_id = ? ORDER BY seq`, beadID)
+	if err != nil {
+		return nil, fmt.Errorf("loading history of %s: %w", beadID, err)
+	}
+	defer rows.Close()
+
+	entries := []model.HistoryEntry{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("loading history of %s: %w", beadID, err)
+		}
+		var e model.HistoryEntry
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			return nil, fmt.Errorf("decoding history of %s: %w", beadID, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// StatusMap returns a map of bead ID → status string for the provided IDs.
+func (s *SQLiteStore) StatusMap(ids []string) map[string]string {
+	result := make(map[string]string)
+	if len(ids) == 0 {
+		return result
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := s.q.Query(`SELECT id, status FROM beads WHERE id IN (`+placeholders(len(ids))+`)`, args...)
+	if err != nil {
+		return result
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, status string
+		if rows.Scan(&id, &status) == nil {
+			result[id] = status
+		}
+	}
+	return result
+}
+
+// placeholders returns n comma-separated "?" markers.
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// List returns beads matching the given filters, sorted and paginated, with
+// the same flat and hierarchical modes as Store.List.
+func (s *SQLiteStore) List(filters ListFilters) ListResult {
+	if filters.Page < 1 {
+		filters.Page = 1
+	}
+	if filters.PerPage < 1 {
+		filters.PerPage = 100
+	}
+
+	var foreignBlocked []string
+	if filters.Ready {
+		foreignBlocked = sqlForeignBlocked(s.q, s.foreign)
+	}
+	whereSQL, args, flat := sqlListWhere(filters, foreignBlocked)
+
+	var total int
+	if err := s.q.QueryRow("SELECT COUNT(*) FROM beads b"+whereSQL, args...).Scan(&total); err != nil {
+		return emptyListResult(filters)
+	}
+
+	pageArgs := append(append([]any{}, args...), filters.PerPage, (filters.Page-1)*filters.PerPage)
+	page, err := sqlQueryBeads(s.q, "SELECT b.data FROM beads b"+whereSQL+
+		" ORDER BY b.priority_rank, b.created_at DESC, b.id LIMIT ? OFFSET ?", pageArgs...)
+	if err != nil {
+		return emptyListResult(filters)
+	}
+
+	get := s.depLookup(s.q)
+	memo := make(map[string]int)
+	summaries := make([]BeadSummary, len(page))
+	for i, b := range page {
+		sum := newSummary(b, blockDepth(b, get, memo))
+		if flat {
+			if b.ParentID != "" {
+				sum.ParentID = b.ParentID
+				if parent, ok := get(b.ParentID); ok {
+					sum.ParentTitle = parent.Title
+				}
+			}
+		} else if children := sqlChildren(s.q, b.ID); len(children) > 0 {
+			sum.IsEpic = true
+			sortBeads(children)
+			childSummaries := []BeadSummary{}
+			for _, c := range children {
+				if c.Status == model.StatusDeleted {
+					continue
+				}
+				childSummaries = append(childSummaries, newSummary(c, blockDepth(c, get, memo)))
+			}
+			sum.Children = childSummaries
+		}
+		summaries[i] = sum
+	}
+
+	return ListResult{
+		Beads:      summaries,
+		Page:       filters.Page,
+		PerPage:    filters.PerPage,
+		Total:      total,
+		TotalPages: totalPages(total, filters.PerPage),
+	}
+}
+
+// sqlListWhere builds the WHERE clause and arguments selecting the beads
+// List would return for filters, and reports whether the view is flat.
+// foreignBlocked lists beads held back by blockers outside the database
+// (see sqlForeignBlocked); the ready filter excludes them and their children.
+func sqlListWhere(filters ListFilters, foreignBlocked []string) (string, []any, bool) {
+	statuses := filters.Statuses
+	if filters.Ready {
+		statuses = []model.Status{model.StatusOpen}
+	} else if !filters.All && len(statuses) == 0 {
+		statuses = []model.Status{model.StatusOpen, model.StatusInProgress, model.StatusNotReady}
+	}
+
+	var where []string
+	var args []any
+	if len(statuses) > 0 {
+		where = append(where, "b.status IN ("+placeholders(len(statuses))+")")
+		for _, st := range statuses {
+			args = append(args, string(st))
+		}
+	}
+	if filters.Priority != nil {
+		where = append(where, "b.priority = ?")
+		args = append(args, string(*filters.Priority))
+	}
+	if filters.Type != nil {
+		where = append(where, "b.type = ?")
+		args = append(args, string(*filters.Type))
+	}
+	if filters.Assignee != nil {
+		where = append(where, "b.assignee = ?")
+		args = append(args, *filters.Assignee)
+	}
+	if len(filters.Tags) > 0 {
+		where = append(where, "EXISTS (SELECT 1 FROM bead_tags t WHERE t.bead_id = b.id AND t.tag IN ("+placeholders(len(filters.Tags))+"))")
+		for _, tag := range filters.Tags {
+			args = append(args, tag)
+		}
+	}
+	if filters.Ready {
+		// No active blocker, own or inherited from the parent epic.
+		where = append(where, `NOT EXISTS (SELECT 1 FROM bead_deps d JOIN beads x ON x.id = d.blocker_id
+			WHERE (d.bead_id = b.id OR (b.parent_id <> '' AND d.bead_id = b.parent_id))
+			AND x.status IN `+activeStatusSQL+`)`)
+		if len(foreignBlocked) > 0 {
+			in := placeholders(len(foreignBlocked))
+			where = append(where, "b.id NOT IN ("+in+") AND b.parent_id NOT IN ("+in+")")
+			for range 2 {
+				for _, id := range foreignBlocked {
+					args = append(args, id)
+				}
+			}
+		}
+	}
+
+	flat := filters.Ready || filters.Assignee != nil
+	if flat {
+		// Epics are containers, not claimable.
+		where = append(where, "NOT EXISTS (SELECT 1 FROM beads c WHERE c.parent_id = b.id)")
+	} else {
+		// Children are nested under their parent, not listed at top level.
+		where = append(where, "b.parent_id = ''")
+	}
+
+	whereSQL := ""
+	if len(where) > 0 {
+		whereSQL = " WHERE " + strings.Join(where, " AND ")
+	}
+	return whereSQL, args, flat
+}
+
+// sqlCorpus reads the search index tables for runSearch.
+type sqlCorpus struct {
+	q querier
+}
+
+func (c sqlCorpus) textStats() (int, fieldCounts, error) {
+	var n int
+	var total fieldCounts
+	err := c.q.QueryRow(`SELECT COUNT(*), COALESCE(SUM(title_len), 0), COALESCE(SUM(description_len), 0), COALESCE(SUM(comments_len), 0) FROM bead_text`).
+		Scan(&n, &total[0], &total[1], &total[2])
+	if err != nil {
+		return 0, fieldCounts{}, fmt.Errorf("reading search index: %w", err)
+	}
+	return n, total, nil
+}
+
+// termWhere returns the condition on bead_terms.term matching term or, if
+// prefix is set, every term starting with it.
+func termWhere(term string, prefix bool) (string, []any) {
+	if prefix {
+		// No term holds U+10FFFF, so this bounds the terms starting with term.
+		return `t.term >= ? AND t.term < ?`, []any{term, term + "\U0010FFFF"}
+	}
+	return `t.term = ?`, []any{term}
+}
+
+func (c sqlCorpus) docFreqs(term string, prefix bool) (map[string]int, error) {
+	where, args := termWhere(term, prefix)
+	rows, err := c.q.Query(`SELECT t.term, COUNT(*) FROM bead_terms t WHERE `+where+` GROUP BY t.term`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("reading search index: %w", err)
+	}
+	defer rows.Close()
+	out := make(map[string]int)
+	for rows.Next() {
+		var t string
+		var n int
+		if err := rows.Scan(&t, &n); err != nil {
+			return nil, fmt.Errorf("reading search index: %w", err)
+		}
+		out[t] = n
+	}
+	return out, rows.Err()
+}
+
+func (c sqlCorpus) postings(term string, prefix bool, ids []string, visit func(term, id string, p textPosting)) error {
+	where, args := termWhere(term, prefix)
+	if ids != nil {
+		if len(ids) == 0 {
+			return nil
+		}
+		where += ` AND t.bead_id IN (?` + strings.Repeat(`, ?`, len(ids)-1) + `)`
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+	rows, err := c.q.Query(`SELECT t.term, t.bead_id, t.title_tf, t.description_tf, t.comments_tf,
+		x.title_len, x.description_len, x.comments_len, b.priority_rank, b.created_at
+		FROM bead_terms t
+		JOIN bead_text x ON x.bead_id = t.bead_id
+		JOIN beads b ON b.id = t.bead_id
+		WHERE `+where, args...)
+	if err != nil {
+		return fmt.Errorf("reading search index: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t, id string
+		var p textPosting
+		if err := rows.Scan(&t, &id, &p.tf[0], &p.tf[1], &p.tf[2], &p.lens[0], &p.lens[1], &p.lens[2], &p.rank, &p.created); err != nil {
+			return fmt.Errorf("reading search index: %w", err)
+		}
+		visit(t, id, p)
+	}
+	return rows.Err()
+}
+
+func (c sqlCorpus) textBead(id string) (model.Bead, bool) {
+	b, ok, err := sqlGet(c.q, id)
+	return b, ok && err == nil
+}
+
+// Search finds beads matching opts using the search index tables. See
+// Store.Search.
+func (s *SQLiteStore) Search(opts SearchOptions) (ListResult, error) {
+	clauses, fields, err := opts.parse()
+	if err != nil {
+		return ListResult{}, err
+	}
+	hits, err := runSearch(sqlCorpus{q: s.q}, clauses, fields, opts.Sort)
+	if err != nil {
+		return ListResult{}, err
+	}
+
+	page, pages := searchPage(hits, opts.Page, opts.PerPage)
+	get := s.depLookup(s.q)
+	memo := make(map[string]int)
+	summaries := make([]BeadSummary, 0, len(page))
+	for _, h := range page {
+		b, ok, err := sqlGet(s.q, h.id)
+		if err != nil {
+			return ListResult{}, err
+		}
+		if !ok {
+			continue
+		}
+		sum := newSummary(b, blockDepth(b, get, memo))
+		if b.ParentID != "" {
+			sum.ParentID = b.ParentID
+			if parent, ok := get(b.ParentID); ok {
+				sum.ParentTitle = parent.Title
+			}
+		}
+		if sqlHasChildren(s.q, b.ID) {
+			sum.IsEpic = true
+		}
+		sum.Score = h.score
+		sum.Matches = searchMatches(b, clauses, fields)
+		summaries = append(summaries, sum)
+	}
+
+	return ListResult{
+		Beads:      summaries,
+		Page:       opts.Page,
+		PerPage:    opts.PerPage,
+		Total:      len(hits),
+		TotalPages: pages,
+	}, nil
+}
+
+// totalPages returns the page count for total items, never less than 1.
+func totalPages(total, perPage int) int {
+	n := (total + perPage - 1) / perPage
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// emptyListResult is returned when a list query fails.
+func emptyListResult(filters ListFilters) ListResult {
+	return ListResult{
+		Beads:      []BeadSummary{},
+		Page:       filters.Page,
+		PerPage:    filters.PerPage,
+		TotalPages: 1,
+	}
+}
@@ -0,0 +1,576 @@
+package store
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vector76/beads_server/model"
+)
+
+func tempSQLite(t *testing.T) *SQLiteStore {
+	t.Helper()
+	s, err := OpenSQLite(filepath.Join(t.TempDir(), "beads.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func mustCreate(t *testing.T, s Backend, b model.Bead) model.Bead {
+	t.Helper()
+	created, err := s.Create(b)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	return created
+}
+
+// --- SQLite backend tests ---
+
+func TestSQLite_CreateGetReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "beads.db")
+	s, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("OpenSQLite: %v", err)
+	}
+
+	b := model.NewBead("Persisted")
+	b.Tags = []string{"x"}
+	created := mustCreate(t, s, b)
+	if _, err := s.AddComment(created.ID, model.Comment{Author: "a", Text: "hi"}); err != nil {
+		t.Fatalf("AddComment: %v", err)
+	}
+	s.Close()
+
+	s2, err := OpenSQLite(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s2.Close()
+
+	got, err := s2.Get(created.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if got.Title != "Persisted" || len(got.Comments) != 1 || len(got.Tags) != 1 {
+		t.Errorf("unexpected bead after reopen: %+v", got)
+	}
+}
+
+func TestSQLite_GetNotFound(t *testing.T) {
+	s := tempSQLite(t)
+	_, err := s.Get("bd-none")
+	if _, ok := err.(*NotFoundError); !ok {
+		t.Errorf("expected NotFoundError, got %v", err)
+	}
+}
+
+func TestSQLite_CreateDuplicateID(t *testing.T) {
+	s := tempSQLite(t)
+	b := model.NewBead("A")
+	b.ID = "bd-dup1"
+	mustCreate(t, s, b)
+	if _, err := s.Create(b); err == nil {
+		t.Error("expected duplicate ID error")
+	}
+}
+
+func TestSQLite_UpdateAndDelete(t *testing.T) {
+	s := tempSQLite(t)
+	b := mustCreate(t, s, model.NewBead("Old"))
+
+	title := "New"
+	updated, err := s.Update(b.ID, UpdateFields{Title: &title})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if updated.Title != "New" {
+		t.Errorf("expected title New, got %q", updated.Title)
+	}
+
+	deleted, err := s.Delete(b.ID)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if deleted.Status != model.StatusDeleted {
+		t.Errorf("expected deleted, got %q", deleted.Status)
+	}
+}
+
+func TestSQLite_ClaimConflict(t *testing.T) {
+	s := tempSQLite(t)
+	b := mustCreate(t, s, model.NewBead("Work"))
+
+	if _, err := s.Claim(b.ID, "alice", 0); err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if _, err := s.Claim(b.ID, "alice", 0); err != nil {
+		t.Errorf("idempotent claim failed: %v", err)
+	}
+	_, err := s.Claim(b.ID, "bob", 0)
+	if _, ok := err.(*ConflictError); !ok {
+		t.Errorf("expected ConflictError, got %v", err)
+	}
+}
+
+func TestSQLite_LinkRejectsCycle(t *testing.T) {
+	s := tempSQLite(t)
+	a := mustCreate(t, s, model.NewBead("A"))
+	b := mustCreate(t, s, model.NewBead("B"))
+	c := mustCreate(t, s, model.NewBead("C"))
+
+	if _, err := s.Link(b.ID, a.ID); err != nil {
+		t.Fatalf("Link b<-a: %v", err)
+	}
+	if _, err := s.Link(c.ID, b.ID); err != nil {
+		t.Fatalf("Link c<-b: %v", err)
+	}
+	if _, err := s.Link(a.ID, c.ID); err == nil {
+		t.Error("expected transitive cycle to be rejected")
+	}
+	if _, err := s.Link(b.ID, a.ID); err == nil {
+		t.Error("expected duplicate link to be rejected")
+	}
+}
+
+func TestSQLite_DepsAndUnblocked(t *testing.T) {
+	s := tempSQLite(t)
+	blocker := mustCreate(t, s, model.NewBead("Blocker"))
+	dep := mustCreate(t, s, model.NewBead("Dependent"))
+	s.Link(dep.ID, blocker.ID)
+
+	deps, err := s.Deps(blocker.ID)
+	if err != nil {
+		t.Fatalf("Deps: %v", err)
+	}
+	if len(deps.Blocks) != 1 || deps.Blocks[0].ID != dep.ID {
+		t.Errorf("expected blocker to block %s, got %+v", dep.ID, deps.Blocks)
+	}
+
+	closed := model.StatusClosed
+	s.Update(blocker.ID, UpdateFields{Status: &closed})
+	unblocked := s.GetUnblocked(blocker.ID)
+	if len(unblocked) != 1 || unblocked[0].ID != dep.ID {
+		t.Errorf("expected %s unblocked, got %+v", dep.ID, unblocked)
+	}
+
+	if _, err := s.Unlink(dep.ID, blocker.ID); err != nil {
+		t.Fatalf("Unlink: %v", err)
+	}
+	deps, _ = s.Deps(blocker.ID)
+	if len(deps.Blocks) != 0 {
+		t.Errorf("expected no dependents after unlink, got %d", len(deps.Blocks))
+	}
+}
+
+func TestSQLite_EpicDerivedStatus(t *testing.T) {
+	s := tempSQLite(t)
+	epic := mustCreate(t, s, model.NewBead("Epic"))
+	child, err := s.CreateWithParent(model.NewBead("Child"), epic.ID)
+	if err != nil {
+		t.Fatalf("CreateWithParent: %v", err)
+	}
+	if !s.IsEpic(epic.ID) {
+		t.Fatal("expected epic after adding child")
+	}
+	if err := s.ValidateClaimOnEpic(epic.ID); err == nil {
+		t.Error("expected claim on epic to be rejected")
+	}
+
+	closed := model.StatusClosed
+	s.Update(child.ID, UpdateFields{Status: &closed})
+	if err := s.RecomputeParentStatus(child.ID); err != nil {
+		t.Fatalf("RecomputeParentStatus: %v", err)
+	}
+	got, _ := s.Get(epic.ID)
+	if got.Status != model.StatusClosed {
+		t.Errorf("expected epic closed, got %q", got.Status)
+	}
+
+	if _, err := s.MoveOut(child.ID); err != nil {
+		t.Fatalf("MoveOut: %v", err)
+	}
+	got, _ = s.Get(epic.ID)
+	if got.Status != model.StatusOpen {
+		t.Errorf("expected epic to revert to open, got %q", got.Status)
+	}
+}
+
+func TestSQLite_Clean(t *testing.T) {
+	s := tempSQLite(t)
+	old := model.NewBead("Old")
+	old.Status = model.StatusClosed
+	old.UpdatedAt = time.Now().UTC().Add(-48 * time.Hour)
+	oldBead := mustCreate(t, s, old)
+	recent := mustCreate(t, s, model.NewBead("Recent"))
+
+	n, err := s.Clean(time.Now().UTC().Add(-24 * time.Hour))
+	if err != nil {
+		t.Fatalf("Clean: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 removed, got %d", n)
+	}
+	if _, err := s.Get(oldBead.ID); err == nil {
+		t.Error("expected old bead to be removed")
+	}
+	if _, err := s.Get(recent.ID); err != nil {
+		t.Errorf("expected recent bead to remain: %v", err)
+	}
+}
+
+func TestSQLite_SearchAndStatusMap(t *testing.T) {
+	s := tempSQLite(t)
+	a := mustCreate(t, s, model.NewBead("Fix Login Bug"))
+	mustCreate(t, s, model.NewBead("Unrelated"))
+
+	res := mustSearch(t, s, SearchOptions{Query: "login", Page: 1, PerPage: 10})
+	if res.Total != 1 || res.Beads[0].ID != a.ID {
+		t.Errorf("expected one match for login, got %+v", res)
+	}
+
+	m := s.StatusMap([]string{a.ID, "bd-none"})
+	if m[a.ID] != "open" {
+		t.Errorf("expected open, got %q", m[a.ID])
+	}
+	if _, ok := m["bd-none"]; ok {
+		t.Error("expected unknown ID to be absent")
+	}
+}
+
+// TestBackends_ListParity runs the same scenario against both backends and
+// checks that List produces identical results.
+func TestBackends_ListParity(t *testing.T) {
+	build := func(s Backend) {
+		base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		mk := func(id, title string, p model.Priority, offset int) model.Bead {
+			b := model.NewBead(title)
+			b.ID = id
+			b.Priority = p
+			b.Tags = []string{"t-" + id}
+			b.CreatedAt = base.Add(time.Duration(offset) * time.Minute)
+			b.UpdatedAt = b.CreatedAt
+			return mustCreate(t, s, b)
+		}
+		mk("bd-aaaa", "Blocker", model.PriorityHigh, 1)
+		mk("bd-bbbb", "Blocked", model.PriorityCritical, 2)
+		mk("bd-cccc", "Epic", model.PriorityMedium, 3)
+		mk("bd-dddd", "Loose", model.PriorityLow, 4)
+		child := model.NewBead("Child")
+		child.ID = "bd-eeee"
+		child.CreatedAt = base.Add(5 * time.Minute)
+		if _, err := s.CreateWithParent(child, "bd-cccc"); err != nil {
+			t.Fatalf("CreateWithParent: %v", err)
+		}
+		if _, err := s.Link("bd-bbbb", "bd-aaaa"); err != nil {
+			t.Fatalf("Link: %v", err)
+		}
+		if _, err := s.Link("bd-cccc", "bd-aaaa"); err != nil {
+			t.Fatalf("Link: %v", err)
+		}
+	}
+
+	jsonStore := tempStore(t)
+	sqlStore := tempSQLite(t)
+	build(jsonStore)
+	build(sqlStore)
+
+	for _, f := range []ListFilters{
+		{},
+		{Ready: true},
+		{All: true, PerPage: 2, Page: 2},
+		{Tags: []string{"t-bd-dddd"}},
+	} {
+		want := jsonStore.List(f)
+		got := sqlStore.List(f)
+		if !reflect.DeepEqual(ids(want), ids(got)) || want.Total != got.Total {
+			t.Errorf("filters %+v: json=%v (total %d) sqlite=%v (total %d)", f, ids(want), want.Total, ids(got), got.Total)
+		}
+		for i := range want.Beads {
+			if i < len(got.Beads) && want.Beads[i].BlockDepth != got.Beads[i].BlockDepth {
+				t.Errorf("filters %+v: %s depth json=%d sqlite=%d", f, want.Beads[i].ID, want.Beads[i].BlockDepth, got.Beads[i].BlockDepth)
+			}
+		}
+	}
+}
+
+// ids flattens a ListResult into IDs, including nested children.
+func ids(r ListResult) []string {
+	var out []string
+	for _, b := range r.Beads {
+		out = append(out, b.ID)
+		for _, c := range b.Children {
+			out = append(out, c.ID)
+		}
+	}
+	return out
+}
+
+func TestOpen_SelectsBackend(t *testing.T) {
+	dir := t.TempDir()
+	if b, err := Open("", filepath.Join(dir, "a.json")); err != nil {
+		t.Fatalf("Open json: %v", err)
+	} else if _, ok := b.(*Store); !ok {
+		t.Errorf("expected *Store, got %T", b)
+	}
+	b, err := Open(BackendSQLite, filepath.Join(dir, "b.db"))
+	if err != nil {
+		t.Fatalf("Open sqlite: %v", err)
+	}
+	defer b.Close()
+	if _, ok := b.(*SQLiteStore); !ok {
+		t.Errorf("expected *SQLiteStore, got %T", b)
+	}
+	if _, err := Open("mongo", filepath.Join(dir, "c")); err == nil {
+		t.Error("expected error for unknown backend")
+	}
+}
+
+func TestRemove_DeletesDataFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, backend := range []string{BackendJSON, BackendSQLite} {
+		path := filepath.Join(dir, "data-"+backend)
+		b, err := Open(backend, path)
+		if err != nil {
+			t.Fatalf("Open %s: %v", backend, err)
+		}
+		mustCreate(t, b, model.NewBead("Doomed"))
+		b.Close()
+
+		if err := Remove(backend, path); err != nil {
+			t.Fatalf("Remove %s: %v", backend, err)
+		}
+		if files, _ := filepath.Glob(path + "*"); len(files) != 0 {
+			t.Errorf("%s: files left behind: %v", backend, files)
+		}
+		if err := Remove(backend, path); err != nil {
+			t.Errorf("Remove %s again: %v, want missing files skipped", backend, err)
+		}
+	}
+}
+
+func TestSQLite_History(t *testing.T) {
+	s := tempSQLite(t)
+	b := mustCreate(t, s, model.NewBead("Tracked"))
+
+	if err := s.RecordHistory(b.ID, model.HistoryEntry{Actor: "alice", Action: model.ActionCreated}); err != nil {
+		t.Fatalf("RecordHistory: %v", err)
+	}
+	if err := s.RecordHistory(b.ID, model.HistoryEntry{Actor: "bob", Action: model.ActionClaimed}); err != nil {
+		t.Fatalf("RecordHistory: %v", err)
+	}
+	entries, err := s.History(b.ID)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(entries) != 2 || entries[1].Seq != 2 || entries[1].Actor != "bob" {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+	if err := s.RecordHistory("bd-none", model.HistoryEntry{}); err == nil {
+		t.Error("expected error for unknown bead")
+	}
+}
+
+func TestSQLite_LeaseHeartbeatRelease(t *testing.T) {
+	s := tempSQLite(t)
+	b := mustCreate(t, s, model.NewBead("Leased"))
+
+	if _, err := s.Claim(b.ID, "alice", time.Minute); err != nil {
+		t.Fatalf("Claim: %v", err)
+	}
+	if _, err := s.Heartbeat(b.ID, "bob", time.Minute); err == nil {
+		t.Error("expected heartbeat by another user to fail")
+	}
+	renewed, err := s.Heartbeat(b.ID, "alice", time.Hour)
+	if err != nil {
+		t.Fatalf("Heartbeat: %v", err)
+	}
+
+	released, err := s.ReleaseExpired(renewed.LeaseExpiresAt.Add(time.Second))
+	if err != nil {
+		t.Fatalf("ReleaseExpired: %v", err)
+	}
+	if len(released) != 1 || released[0].After.Status != model.StatusOpen {
+		t.Fatalf("expected one release back to open, got %+v", released)
+	}
+	got, _ := s.Get(b.ID)
+	if got.Assignee != "" || len(got.Comments) != 1 {
+		t.Errorf("expected unassigned bead with system comment, got %+v", got)
+	}
+}
+
+func TestBackends_ClaimNextParity(t *testing.T) {
+	build := func(s Backend) {
+		base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+		for i, p := range []model.Priority{model.PriorityLow, model.PriorityHigh, model.PriorityHigh, model.PriorityCritical} {
+			b := model.NewBead("Bead")
+			b.ID = "bd-000" + string(rune('a'+i))
+			b.Priority = p
+			b.CreatedAt = base.Add(time.Duration(i%2) * time.Minute)
+			mustCreate(t, s, b)
+		}
+		if _, err := s.Link("bd-000d", "bd-000a"); err != nil {
+			t.Fatalf("Link: %v", err)
+		}
+	}
+
+	claimAll := func(s Backend) []string {
+		var out []string
+		for {
+			_, b, err := s.ClaimNext(ListFilters{}, "agent-1", 0)
+			if err != nil {
+				return out
+			}
+			out = append(out, b.ID)
+		}
+	}
+
+	jsonStore := tempStore(t)
+	sqlStore := tempSQLite(t)
+	build(jsonStore)
+	build(sqlStore)
+
+	want := claimAll(jsonStore)
+	got := claimAll(sqlStore)
+	if !reflect.DeepEqual(want, got) || len(want) != 3 {
+		t.Errorf("claim order json=%v sqlite=%v", want, got)
+	}
+}
+
+func TestBackends_Revisions(t *testing.T) {
+	for name, s := range map[string]Backend{"json": tempStore(t), "sqlite": tempSQLite(t)} {
+		t.Run(name, func(t *testing.T) {
+			a := mustCreate(t, s, model.NewBead("A"))
+			blocker := mustCreate(t, s, model.NewBead("Blocker"))
+			if a.Revision != 1 {
+				t.Fatalf("revision after create = %d, want 1", a.Revision)
+			}
+
+			stale := int64(1)
+			title := "A2"
+			b, err := s.Update(a.ID, UpdateFields{Title: &title, IfRevision: &stale})
+			if err != nil || b.Revision != 2 {
+				t.Fatalf("Update = rev %d, %v; want rev 2", b.Revision, err)
+			}
+
+			// Every conditional mutation now fails against revision 1.
+			var pe *PreconditionError
+			comment := model.Comment{Author: "x", Text: "y"}
+			for op, fn := range map[string]func() (model.Bead, error){
+				"update":  func() (model.Bead, error) { return s.Update(a.ID, UpdateFields{Title: &title, IfRevision: &stale}) },
+				"delete":  func() (model.Bead, error) { return s.DeleteIfRevision(a.ID, &stale) },
+				"comment": func() (model.Bead, error) { return s.AddCommentIfRevision(a.ID, comment, &stale) },
+				"link":    func() (model.Bead, error) { return s.LinkIfRevision(a.ID, blocker.ID, &stale) },
+				"unlink":  func() (model.Bead, error) { return s.UnlinkIfRevision(a.ID, blocker.ID, &stale) },
+			} {
+				if _, err := fn(); !errors.As(err, &pe) || pe.Revision != 2 {
+					t.Errorf("%s with stale revision: err = %v, want PreconditionError at 2", op, err)
+				}
+			}
+
+			cur := int64(2)
+			if b, err = s.LinkIfRevision(a.ID, blocker.ID, &cur); err != nil || b.Revision != 3 {
+				t.Fatalf("LinkIfRevision = rev %d, %v; want rev 3", b.Revision, err)
+			}
+			if b, err = s.AddComment(a.ID, comment); err != nil || b.Revision != 4 {
+				t.Fatalf("AddComment = rev %d, %v; want rev 4", b.Revision, err)
+			}
+			if b, err = s.Claim(a.ID, "agent", time.Minute); err != nil || b.Revision != 5 {
+				t.Fatalf("Claim = rev %d, %v; want rev 5", b.Revision, err)
+			}
+			// Lease renewals do not change the revision.
+			if b, err = s.Heartbeat(a.ID, "agent", time.Minute); err != nil || b.Revision != 5 {
+				t.Fatalf("Heartbeat = rev %d, %v; want rev 5", b.Revision, err)
+			}
+			got, _ := s.Get(a.ID)
+			if got.Revision != 5 || got.Title != "A2" || len(got.Comments) != 1 {
+				t.Errorf("stored bead = %+v", got)
+			}
+		})
+	}
+}
+
+func TestBackends_ForeignBlockers(t *testing.T) {
+	for name, s := range map[string]Backend{"json": tempStore(t), "sqlite": tempSQLite(t)} {
+		t.Run(name, func(t *testing.T) {
+			// bd-ext1 lives elsewhere and is itself blocked by bd-ext2.
+			foreign := map[string]model.Bead{
+				"bd-ext1": {ID: "bd-ext1", Status: model.StatusOpen, BlockedBy: []string{"bd-ext2"}},
+				"bd-ext2": {ID: "bd-ext2", Status: model.StatusOpen},
+			}
+			s.SetForeign(func(id string) (model.Bead, bool) {
+				b, ok := foreign[id]
+				return b, ok
+			})
+
+			a := model.NewBead("Waits on another project")
+			a.ID = "bd-aaaa"
+			mustCreate(t, s, a)
+			epic := model.NewBead("Epic")
+			epic.ID = "bd-cccc"
+			mustCreate(t, s, epic)
+			child := model.NewBead("Child")
+			child.ID = "bd-dddd"
+			if _, err := s.CreateWithParent(child, epic.ID); err != nil {
+				t.Fatalf("CreateWithParent: %v", err)
+			}
+
+			if _, err := s.Link(a.ID, "bd-ext1"); err != nil {
+				t.Fatalf("Link to a foreign bead: %v", err)
+			}
+			if _, err := s.Link(epic.ID, "bd-ext2"); err != nil {
+				t.Fatalf("Link epic to a foreign bead: %v", err)
+			}
+			if _, err := s.Link(a.ID, "bd-none"); err == nil {
+				t.Error("Link to an unknown bead should fail")
+			}
+
+			if got := ids(s.List(ListFilters{Ready: true})); len(got) != 0 {
+				t.Errorf("ready = %v, want none while the foreign blockers are open", got)
+			}
+			if _, _, err := s.ClaimNext(ListFilters{}, "agent-1", 0); err == nil {
+				t.Error("ClaimNext should find nothing ready")
+			}
+			for _, sum := range s.List(ListFilters{}).Beads {
+				if sum.ID == a.ID && sum.BlockDepth != 2 {
+					t.Errorf("block depth = %d, want 2 through the foreign chain", sum.BlockDepth)
+				}
+			}
+			deps, err := s.Deps(a.ID)
+			if err != nil || len(deps.ActiveBlockers) != 1 || deps.ActiveBlockers[0].ID != "bd-ext1" {
+				t.Errorf("Deps = %+v, %v; want bd-ext1 active", deps, err)
+			}
+
+			// A cycle through the other project is rejected.
+			foreign["bd-ext2"] = model.Bead{ID: "bd-ext2", Status: model.StatusOpen, BlockedBy: []string{a.ID}}
+			b := model.NewBead("B")
+			b.ID = "bd-bbbb"
+			mustCreate(t, s, b)
+			if _, err := s.Link(b.ID, a.ID); err != nil {
+				t.Fatalf("Link: %v", err)
+			}
+			if _, err := s.Link(epic.ID, b.ID); err != nil {
+				t.Fatalf("Link: %v", err)
+			}
+			foreign["bd-ext2"] = model.Bead{ID: "bd-ext2", Status: model.StatusOpen, BlockedBy: []string{epic.ID}}
+			if _, err := s.Link(a.ID, "bd-ext2"); err == nil {
+				t.Error("Link closing a cycle through foreign beads should fail")
+			}
+
+			foreign["bd-ext1"] = model.Bead{ID: "bd-ext1", Status: model.StatusClosed}
+			foreign["bd-ext2"] = model.Bead{ID: "bd-ext2", Status: model.StatusClosed}
+			if got := s.GetUnblocked("bd-ext1"); len(got) != 1 || got[0].ID != a.ID {
+				t.Errorf("GetUnblocked = %v, want %s", got, a.ID)
+			}
+			// The child still waits on its epic's local blocker.
+			ready := ids(s.List(ListFilters{Ready: true}))
+			if !reflect.DeepEqual(ready, []string{a.ID}) {
+				t.Errorf("ready = %v, want only %s once the foreign blockers close", ready, a.ID)
+			}
+		})
+	}
+}
@@ -3,7 +3,7 @@ package store
 import (
 	"testing"
 
-	"github.com/vector76/beads_server/internal/model"
+	"github.com/vector76/beads_server/model"
 )
 
 func TestStatusMapAllExist(t *testing.T) {
@@ -8,64 +8,119 @@ import (
 	"sync"
 	"time"
 
-	"github.com/vector76/beads_server/internal/model"
+	"github.com/vector76/beads_server/model"
 )
 
-// Store holds beads in memory and persists them to a JSON file.
+// Store holds beads in memory and persists them to a JSON snapshot file plus
+// an append-only journal of mutations (see journal.go).
 type Store struct {
-	mu       sync.RWMutex
-	beads    map[string]model.Bead
-	filePath string
-}
-
-// fileData is the on-disk JSON format.
-type fileData struct {
-	Beads []model.Bead `json:"beads"`
+	mu               sync.RWMutex
+	beads            map[string]model.Bead
+	beadsMu          *sync.RWMutex // guards writes to beads against Peek; see put
+	index            *storeIndex   // kept in step with beads by put and remove
+	text             *textIndex    // search index, kept in step with beads by put and remove
+	history          map[string][]model.HistoryEntry
+	filePath         string
+	journalRecords   int // records appended since the last snapshot
+	compactThreshold int // compact once journalRecords reaches this
+
+	// pending collects the journal ops of a batch's scratch store instead
+	// of writing them (see Batch).
+	pending *journalRecord
+	// undo records what a batch's scratch store changed, so a failed
+	// batch can be rolled back (see Batch).
+	undo *undoLog
+
+	foreign *foreignLookup // blockers held outside this store; see SetForeign
 }
 
 // rawBead mirrors model.Bead but uses a plain string for Status and Type so
 // that legacy values ("resolved", "wontfix", "epic") survive JSON unmarshaling
 // and can be migrated at load time.
 type rawBead struct {
-	ID          string          `json:"id"`
-	Title       string          `json:"title"`
-	Description string          `json:"description"`
-	Status      string          `json:"status"`
-	Priority    model.Priority  `json:"priority"`
-	Type        string          `json:"type"`
-	Tags        []string        `json:"tags"`
-	BlockedBy   []string        `json:"blocked_by"`
-	Assignee    string          `json:"assignee"`
-	ParentID    string          `json:"parent_id"`
-	Comments    []model.Comment `json:"comments"`
-	CreatedAt   time.Time       `json:"created_at"`
-	UpdatedAt   time.Time       `json:"updated_at"`
+	ID             string          `json:"id"`
+	Title          string          `json:"title"`
+	Description    string          `json:"description"`
+	Status         string          `json:"status"`
+	Priority       model.Priority  `json:"priority"`
+	Type           string          `json:"type"`
+	Tags           []string        `json:"tags"`
+	BlockedBy      []string        `json:"blocked_by"`
+	Assignee       string          `json:"assignee"`
+	ParentID       string          `json:"parent_id"`
+	Comments       []model.Comment `json:"comments"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	LeaseExpiresAt *time.Time      `json:"lease_expires_at"`
+	Revision       int64           `json:"revision"`
+	ExternalKey    string          `json:"external_key"`
 }
 
-// Load reads beads from the given file path, or initializes an empty store
-// if the file does not exist. Legacy statuses "resolved" and "wontfix" are
-// silently migrated to "closed" at load time.
+// Load reads beads from the given snapshot file and then replays the
+// journal on top of it. A missing snapshot or journal is treated as empty.
+// Legacy statuses "resolved" and "wontfix" are silently migrated to "closed"
+// at load time.
 func Load(path string) (*Store, error) {
 	s := &Store{
-		beads:    make(map[string]model.Bead),
-		filePath: path,
+		beads:            make(map[string]model.Bead),
+		beadsMu:          new(sync.RWMutex),
+		index:            newStoreIndex(),
+		text:             newTextIndex(),
+		history:          make(map[string][]model.HistoryEntry),
+		filePath:         path,
+		compactThreshold: defaultCompactThreshold,
+		foreign:          newForeignLookup(),
+	}
+
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+
+	n, err := s.replayJournal()
+	if err != nil {
+		return nil, err
 	}
+	s.journalRecords = n
+
+	return s, nil
+}
 
-	data, err := os.ReadFile(path)
+// loadSnapshot reads the snapshot file into s.beads, migrating legacy values.
+func (s *Store) loadSnapshot() error {
+	data, err := os.ReadFile(s.filePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return s, nil
+			return nil
 		}
-		return nil, fmt.Errorf("reading data file: %w", err)
+		return fmt.Errorf("reading data file: %w", err)
+	}
+
+	snap, err := ParseSnapshot(data)
+	if err != nil {
+		return fmt.Errorf("parsing data file: %w", err)
+	}
+	for _, b := range snap.Beads {
+		s.put(b)
+	}
+	for id, entries := range snap.History {
+		s.history[id] = entries
 	}
 
+	return nil
+}
+
+// ParseSnapshot decodes a snapshot, migrating legacy values: statuses
+// "resolved" and "wontfix" become "closed", and type "epic" becomes "task".
+func ParseSnapshot(data []byte) (model.Snapshot, error) {
 	var fd struct {
-		Beads []rawBead `json:"beads"`
+		Beads   []rawBead                       `json:"beads"`
+		History map[string][]model.HistoryEntry `json:"history"`
 	}
 	if err := json.Unmarshal(data, &fd); err != nil {
-		return nil, fmt.Errorf("parsing data file: %w", err)
+		return model.Snapshot{}, err
 	}
 
+	snap := model.Snapshot{Beads: make([]model.Bead, 0, len(fd.Beads)), History: fd.History}
 	for _, rb := range fd.Beads {
 		status := model.Status(rb.Status)
 		// Migrate legacy statuses to closed.
@@ -77,35 +132,38 @@ func Load(path string) (*Store, error) {
 		if beadType == "epic" {
 			beadType = model.TypeTask
 		}
-		s.beads[rb.ID] = model.Bead{
-			ID:          rb.ID,
-			Title:       rb.Title,
-			Description: rb.Description,
-			Status:      status,
-			Priority:    rb.Priority,
-			Type:        beadType,
-			Tags:        rb.Tags,
-			BlockedBy:   rb.BlockedBy,
-			Assignee:    rb.Assignee,
-			ParentID:    rb.ParentID,
-			Comments:    rb.Comments,
-			CreatedAt:   rb.CreatedAt,
-			UpdatedAt:   rb.UpdatedAt,
-		}
+		snap.Beads = append(snap.Beads, model.Bead{
+			ID:             rb.ID,
+			Title:          rb.Title,
+			Description:    rb.Description,
+			Status:         status,
+			Priority:       rb.Priority,
+			Type:           beadType,
+			Tags:           rb.Tags,
+			BlockedBy:      rb.BlockedBy,
+			Assignee:       rb.Assignee,
+			ParentID:       rb.ParentID,
+			Comments:       rb.Comments,
+			CreatedAt:      rb.CreatedAt,
+			UpdatedAt:      rb.UpdatedAt,
+			LeaseExpiresAt: rb.LeaseExpiresAt,
+			Revision:       rb.Revision,
+			ExternalKey:    rb.ExternalKey,
+		})
 	}
-
-	return s, nil
+	return snap, nil
 }
 
-// save writes all beads to disk atomically (temp file + rename).
+// writeSnapshot writes all beads to the snapshot file atomically
+// (temp file + rename).
 // Caller must hold s.mu.
-func (s *Store) save() error {
+func (s *Store) writeSnapshot() error {
 	beads := make([]model.Bead, 0, len(s.beads))
 	for _, b := range s.beads {
 		beads = append(beads, b)
 	}
 
-	fd := fileData{Beads: beads}
+	fd := model.Snapshot{Beads: beads, History: s.history}
 	data, err := json.MarshalIndent(fd, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshaling data: %w", err)
@@ -136,20 +194,31 @@ func (s *Store) save() error {
 	return nil
 }
 
-// generateUniqueID creates a collision-free bead ID.
+// ReserveFunc claims a new bead ID across stores, such as every project on
+// a server. It reports whether id was free, and if so marks it taken, so
+// that two stores creating beads at once cannot both pick it. Stores call
+// it while holding their own lock, so it must not call back into a store.
+type ReserveFunc func(id string) bool
+
+// generateUniqueIDExcluding creates a collision-free bead ID that reserve,
+// if not nil, also accepts (used for cross-project uniqueness). reserve is
+// only asked about IDs the store does not already hold.
 // It starts at IDMinLen (4) random chars and retries up to 3 times per length.
 // On exhaustion, it increases the length by 1 (up to IDMaxLen).
 // At IDMaxLen, it retries indefinitely.
 // Caller must hold s.mu.
-func (s *Store) generateUniqueID() string {
-	return s.generateUniqueIDExcluding(nil)
+func (s *Store) generateUniqueIDExcluding(reserve ReserveFunc) string {
+	return generateID(func(id string) bool {
+		if _, exists := s.beads[id]; exists {
+			return true
+		}
+		return reserve != nil && !reserve(id)
+	})
 }
 
-// generateUniqueIDExcluding creates a collision-free bead ID, also excluding
-// any IDs in the provided set (used for cross-project uniqueness).
-// Same retry/length-escalation logic as generateUniqueID.
-// Caller must hold s.mu.
-func (s *Store) generateUniqueIDExcluding(excluded map[string]struct{}) string {
+// generateID returns a random bead ID for which taken reports false,
+// escalating the random-part length as collisions accumulate.
+func generateID(taken func(id string) bool) string {
 	for n := model.IDMinLen; n <= model.IDMaxLen; n++ {
 		retries := 3
 		if n == model.IDMaxLen {
@@ -157,13 +226,9 @@ func (s *Store) generateUniqueIDExcluding(excluded map[string]struct{}) string {
 		}
 		for i := 0; i < retries; i++ {
 			id := model.GenerateIDN(n)
-			if _, exists := s.beads[id]; exists {
-				continue
+			if !taken(id) {
+				return id
 			}
-			if _, inExcluded := excluded[id]; inExcluded {
-				continue
-			}
-			return id
 		}
 	}
 	// Should never reach here given 36^8 possible IDs.
@@ -177,21 +242,22 @@ func (s *Store) Create(b model.Bead) (model.Bead, error) {
 }
 
 // CreateExcluding adds a bead to the store and persists to disk.
-// If b.ID is empty, a collision-free ID is generated that is also absent from
-// the excluded set, enabling cross-project uniqueness.
-func (s *Store) CreateExcluding(b model.Bead, excluded map[string]struct{}) (model.Bead, error) {
+// If b.ID is empty, a collision-free ID is generated that reserve also
+// accepts, enabling cross-project uniqueness.
+func (s *Store) CreateExcluding(b model.Bead, reserve ReserveFunc) (model.Bead, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if b.ID == "" {
-		b.ID = s.generateUniqueIDExcluding(excluded)
+		b.ID = s.generateUniqueIDExcluding(reserve)
 	} else if _, exists := s.beads[b.ID]; exists {
 		return model.Bead{}, fmt.Errorf("bead %s already exists", b.ID)
 	}
+	b.Revision = 1
 
-	s.beads[b.ID] = b
-	if err := s.save(); err != nil {
-		delete(s.beads, b.ID)
+	s.put(b)
+	if err := s.persist(b.ID); err != nil {
+		s.remove(b.ID)
 		return model.Bead{}, err
 	}
 
@@ -235,9 +301,14 @@ type UpdateFields struct {
 	BlockedBy   *[]string
 	Assignee    *string
 	ParentID    *string
+
+	// IfRevision, when set, makes the update fail with a PreconditionError
+	// unless the bead is at this revision.
+	IfRevision *int64
 }
 
-// Update applies partial updates to a bead, sets updated_at, and persists.
+// Update applies partial updates to a bead, sets updated_at, and persists
+// it together with any change to its parent epic's derived status.
 func (s *Store) Update(id string, fields UpdateFields) (model.Bead, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -246,7 +317,28 @@ func (s *Store) Update(id string, fields UpdateFields) (model.Bead, error) {
 	if !ok {
 		return model.Bead{}, &NotFoundError{Message: fmt.Sprintf("bead %s not found", id)}
 	}
+	if err := checkRevision(b, fields.IfRevision); err != nil {
+		return model.Bead{}, err
+	}
+
+	applyUpdate(&b, fields)
+
+	touch(&b, time.Now().UTC())
+	old := s.beads[id]
+	s.put(b)
+
+	// A child's change can change its epic's status, or two epics' if it
+	// moved.
+	if err := s.persistWithEpics([]string{id}, old.ParentID, b.ParentID); err != nil {
+		s.put(old)
+		return model.Bead{}, err
+	}
+
+	return b, nil
+}
 
+// applyUpdate copies the non-nil fields onto b.
+func applyUpdate(b *model.Bead, fields UpdateFields) {
 	if fields.Title != nil {
 		b.Title = *fields.Title
 	}
@@ -274,23 +366,28 @@ func (s *Store) Update(id string, fields UpdateFields) (model.Bead, error) {
 	if fields.ParentID != nil {
 		b.ParentID = *fields.ParentID
 	}
-
-	b.UpdatedAt = time.Now().UTC()
-	old := s.beads[id]
-	s.beads[id] = b
-
-	if err := s.save(); err != nil {
-		s.beads[id] = old
-		return model.Bead{}, err
+	// A lease only applies while the bead is claimed.
+	if b.Status != model.StatusInProgress {
+		b.LeaseExpiresAt = nil
 	}
-
-	return b, nil
 }
 
 // Delete soft-deletes a bead by setting its status to deleted.
 func (s *Store) Delete(id string) (model.Bead, error) {
+	return s.DeleteIfRevision(id, nil)
+}
+
+// DeleteIfRevision is Delete, failing with a PreconditionError unless rev is
+// nil or the bead is at revision *rev.
+func (s *Store) DeleteIfRevision(id string, rev *int64) (model.Bead, error) {
 	status := model.StatusDeleted
-	return s.Update(id, UpdateFields{Status: &status})
+	return s.Update(id, UpdateFields{Status: &status, IfRevision: rev})
+}
+
+// Close releases resources held by the store. The JSON store keeps no open
+// handles between writes, so this is a no-op.
+func (s *Store) Close() error {
+	return nil
 }
 
 // All returns all beads in the store.
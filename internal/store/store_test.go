@@ -8,7 +8,7 @@ import (
 	"strings"
 	"testing"
 
-	"github.com/vector76/beads_server/internal/model"
+	"github.com/vector76/beads_server/model"
 )
 
 func tempPath(t *testing.T) string {
@@ -32,7 +32,7 @@ func TestLoadExistingFile(t *testing.T) {
 	// Write a valid data file with an explicit ID
 	b := model.NewBead("Existing bead")
 	b.ID = "bd-exist01"
-	fd := fileData{Beads: []model.Bead{b}}
+	fd := model.Snapshot{Beads: []model.Bead{b}}
 	data, _ := json.MarshalIndent(fd, "", "  ")
 	if err := os.WriteFile(path, data, 0644); err != nil {
 		t.Fatalf("failed to write test file: %v", err)
@@ -491,7 +491,10 @@ func TestCreateExcludingNeverReturnsExcludedID(t *testing.T) {
 
 	for i := 0; i < 50; i++ {
 		b := model.NewBead("Excluded test")
-		created, err := s.CreateExcluding(b, excluded)
+		created, err := s.CreateExcluding(b, func(id string) bool {
+			_, found := excluded[id]
+			return !found
+		})
 		if err != nil {
 			t.Fatalf("iteration %d: unexpected error: %v", i, err)
 		}
@@ -523,11 +526,11 @@ func TestCreateExcludingWithNilExclusionSetBehavesLikeCreate(t *testing.T) {
 	}
 }
 
-func TestCreateExcludingWithEmptyExclusionSetBehavesLikeCreate(t *testing.T) {
+func TestCreateExcludingWithPermissiveReserveBehavesLikeCreate(t *testing.T) {
 	s, _ := Load(tempPath(t))
 
 	b := model.NewBead("Empty exclusion")
-	created, err := s.CreateExcluding(b, map[string]struct{}{})
+	created, err := s.CreateExcluding(b, func(string) bool { return true })
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -568,9 +571,17 @@ func TestAtomicWriteFileExists(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
+	// Mutations are journaled; the snapshot is written on compaction.
+	if _, err := os.Stat(journalPath(path)); os.IsNotExist(err) {
+		t.Error("expected journal file to exist after create")
+	}
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+
 	// Verify file exists on disk
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		t.Error("expected data file to exist after create")
+		t.Error("expected data file to exist after compact")
 	}
 
 	// Verify file contains valid JSON
@@ -578,7 +589,7 @@ func TestAtomicWriteFileExists(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to read file: %v", err)
 	}
-	var fd fileData
+	var fd model.Snapshot
 	if err := json.Unmarshal(data, &fd); err != nil {
 		t.Fatalf("file contains invalid JSON: %v", err)
 	}
@@ -0,0 +1,183 @@
+package store
+
+import (
+	"strings"
+
+	"github.com/vector76/beads_server/model"
+)
+
+// textIndex is the JSON store's inverted index for search: for each term,
+// the beads whose title, description or comments contain it and how often.
+// Deleted beads are not indexed. put and remove keep it in step with
+// Store.beads.
+type textIndex struct {
+	terms map[string]map[string]fieldCounts // term -> bead ID -> count per field
+	lens  map[string]fieldCounts            // bead ID -> field lengths
+	total fieldCounts                       // sum of lens
+}
+
+func newTextIndex() *textIndex {
+	return &textIndex{
+		terms: make(map[string]map[string]fieldCounts),
+		lens:  make(map[string]fieldCounts),
+	}
+}
+
+// add indexes b.
+func (x *textIndex) add(b model.Bead) {
+	if b.Status == model.StatusDeleted {
+		return
+	}
+	terms, lens := termCounts(b)
+	for term, c := range terms {
+		if x.terms[term] == nil {
+			x.terms[term] = make(map[string]fieldCounts)
+		}
+		x.terms[term][b.ID] = c
+	}
+	x.lens[b.ID] = lens
+	for f, n := range lens {
+		x.total[f] += n
+	}
+}
+
+// remove undoes add for b.
+func (x *textIndex) remove(b model.Bead) {
+	lens, ok := x.lens[b.ID]
+	if !ok {
+		return
+	}
+	terms, _ := termCounts(b)
+	for term := range terms {
+		delete(x.terms[term], b.ID)
+		if len(x.terms[term]) == 0 {
+			delete(x.terms, term)
+		}
+	}
+	delete(x.lens, b.ID)
+	for f, n := range lens {
+		x.total[f] -= n
+	}
+}
+
+// replace reindexes a bead that changed from old (if had) to b.
+func (x *textIndex) replace(old model.Bead, had bool, b model.Bead) {
+	if had && sameText(old, b) {
+		return
+	}
+	if had {
+		x.remove(old)
+	}
+	x.add(b)
+}
+
+// storeCorpus reads a Store's text index for runSearch.
+// Caller must hold s.mu (at least RLock).
+type storeCorpus struct {
+	s    *Store
+	text *textIndex
+}
+
+func (c storeCorpus) textStats() (int, fieldCounts, error) {
+	return len(c.text.lens), c.text.total, nil
+}
+
+// matching returns the indexed terms term stands for: itself or, if prefix
+// is set, every term starting with it.
+func (x *textIndex) matching(term string, prefix bool) []string {
+	if !prefix {
+		if _, ok := x.terms[term]; ok {
+			return []string{term}
+		}
+		return nil
+	}
+	var out []string
+	for t := range x.terms {
+		if strings.HasPrefix(t, term) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func (c storeCorpus) docFreqs(term string, prefix bool) (map[string]int, error) {
+	out := make(map[string]int)
+	for _, t := range c.text.matching(term, prefix) {
+		out[t] = len(c.text.terms[t])
+	}
+	return out, nil
+}
+
+func (c storeCorpus) postings(term string, prefix bool, ids []string, visit func(term, id string, p textPosting)) error {
+	posting := func(id string, tf fieldCounts) textPosting {
+		b := c.s.beads[id]
+		return textPosting{tf: tf, lens: c.text.lens[id], rank: b.Priority.Rank(), created: b.CreatedAt.UnixNano()}
+	}
+	for _, t := range c.text.matching(term, prefix) {
+		beads := c.text.terms[t]
+		if ids == nil {
+			for id, tf := range beads {
+				visit(t, id, posting(id, tf))
+			}
+			continue
+		}
+		for _, id := range ids {
+			if tf, ok := beads[id]; ok {
+				visit(t, id, posting(id, tf))
+			}
+		}
+	}
+	return nil
+}
+
+func (c storeCorpus) textBead(id string) (model.Bead, bool) {
+	b, ok := c.s.beads[id]
+	return b, ok
+}
+
+// Search finds the non-deleted beads whose title, description or comments
+// match opts.Query (see SearchOptions), using the text index. Results use
+// the same pagination and summary fields as List, plus each bead's score
+// and where it matched.
+func (s *Store) Search(opts SearchOptions) (ListResult, error) {
+	clauses, fields, err := opts.parse()
+	if err != nil {
+		return ListResult{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	hits, err := runSearch(storeCorpus{s: s, text: s.text}, clauses, fields, opts.Sort)
+	if err != nil {
+		return ListResult{}, err
+	}
+
+	page, pages := searchPage(hits, opts.Page, opts.PerPage)
+	memo := make(map[string]int)
+	summaries := make([]BeadSummary, len(page))
+	for i, h := range page {
+		b := s.beads[h.id]
+		sum := s.summaryFromBead(b, memo)
+		if b.ParentID != "" {
+			sum.ParentID = b.ParentID
+			if parent, ok := s.beads[b.ParentID]; ok {
+				sum.ParentTitle = parent.Title
+			}
+		}
+		if s.hasChildren(b.ID) {
+			sum.IsEpic = true
+		}
+		sum.Score = h.score
+		sum.Matches = searchMatches(b, clauses, fields)
+		summaries[i] = sum
+	}
+
+	return ListResult{
+		Beads:      summaries,
+		Page:       opts.Page,
+		PerPage:    opts.PerPage,
+		Total:      len(hits),
+		TotalPages: pages,
+	}, nil
+}
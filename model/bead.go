@@ -21,6 +21,18 @@ type Bead struct {
 	Comments    []Comment `json:"comments"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// LeaseExpiresAt is when an in_progress claim lapses unless renewed by a
+	// heartbeat. Nil when the bead is not claimed or claims do not expire.
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty"`
+
+	// Revision counts the changes made to the bead, starting at 1 when it is
+	// created. Lease renewals do not count. It is the bead's ETag.
+	Revision int64 `json:"revision"`
+
+	// ExternalKey identifies the bead to an outside source, such as the plan
+	// it was imported from, so that re-importing finds it again.
+	ExternalKey string `json:"external_key,omitempty"`
 }
 
 const IDPrefix = "bd-"
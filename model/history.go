@@ -0,0 +1,76 @@
+package model
+
+import (
+	"slices"
+	"time"
+)
+
+// History actions recorded for bead mutations.
+const (
+	ActionCreated     = "created"
+	ActionUpdated     = "updated"
+	ActionDeleted     = "deleted"
+	ActionClaimed     = "claimed"
+	ActionCommented   = "commented"
+	ActionLinked      = "linked"
+	ActionUnlinked    = "unlinked"
+	ActionMoved       = "moved"
+	ActionReleased    = "released"
+	ActionTransferred = "transferred"
+)
+
+// HistoryEntry records one mutation of a bead: who made it, when, and which
+// fields changed. Seq numbers a bead's entries from 1 in the order they were
+// recorded.
+type HistoryEntry struct {
+	Seq     int           `json:"seq"`
+	At      time.Time     `json:"at"`
+	Actor   string        `json:"actor"`
+	Action  string        `json:"action"`
+	Changes []FieldChange `json:"changes,omitempty"`
+}
+
+// FieldChange holds the old and new value of a single bead field.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old"`
+	New   any    `json:"new"`
+}
+
+// Diff returns the user-visible fields that differ between before and after,
+// in a fixed order. Comments and timestamps are not compared.
+func Diff(before, after Bead) []FieldChange {
+	var changes []FieldChange
+	add := func(field string, old, new any) {
+		changes = append(changes, FieldChange{Field: field, Old: old, New: new})
+	}
+
+	if before.Title != after.Title {
+		add("title", before.Title, after.Title)
+	}
+	if before.Description != after.Description {
+		add("description", before.Description, after.Description)
+	}
+	if before.Status != after.Status {
+		add("status", before.Status, after.Status)
+	}
+	if before.Priority != after.Priority {
+		add("priority", before.Priority, after.Priority)
+	}
+	if before.Type != after.Type {
+		add("type", before.Type, after.Type)
+	}
+	if !slices.Equal(before.Tags, after.Tags) {
+		add("tags", before.Tags, after.Tags)
+	}
+	if !slices.Equal(before.BlockedBy, after.BlockedBy) {
+		add("blocked_by", before.BlockedBy, after.BlockedBy)
+	}
+	if before.Assignee != after.Assignee {
+		add("assignee", before.Assignee, after.Assignee)
+	}
+	if before.ParentID != after.ParentID {
+		add("parent_id", before.ParentID, after.ParentID)
+	}
+	return changes
+}
@@ -324,3 +324,32 @@ func TestBeadUnmarshalInvalidType(t *testing.T) {
 		t.Error("expected error unmarshaling bead with invalid type")
 	}
 }
+
+func TestDiff(t *testing.T) {
+	before := NewBead("Old title")
+	after := before
+	after.Title = "New title"
+	after.Status = StatusInProgress
+	after.Tags = []string{"x"}
+	after.Comments = []Comment{{Author: "a", Text: "ignored"}}
+	after.UpdatedAt = before.UpdatedAt.Add(time.Minute)
+
+	changes := Diff(before, after)
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 changes, got %d: %+v", len(changes), changes)
+	}
+	if changes[0].Field != "title" || changes[0].Old != "Old title" || changes[0].New != "New title" {
+		t.Errorf("unexpected title change: %+v", changes[0])
+	}
+	if changes[1].Field != "status" || changes[2].Field != "tags" {
+		t.Errorf("unexpected field order: %+v", changes)
+	}
+}
+
+func TestDiff_NilAndEmptySlicesEqual(t *testing.T) {
+	a := Bead{Title: "t", Tags: nil}
+	b := Bead{Title: "t", Tags: []string{}}
+	if changes := Diff(a, b); len(changes) != 0 {
+		t.Errorf("expected no changes, got %+v", changes)
+	}
+}
@@ -0,0 +1,9 @@
+package model
+
+// Snapshot is a complete copy of a project's beads and their history. It is
+// the on-disk format of the JSON backend's snapshot file, and the body of
+// project exports.
+type Snapshot struct {
+	Beads   []Bead                    `json:"beads"`
+	History map[string][]HistoryEntry `json:"history,omitempty"`
+}